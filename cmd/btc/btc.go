@@ -20,6 +20,18 @@ func init() {
 
 func main() {
 	flag.Parse()
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "script":
+			err := runScript(flag.Args()[1:])
+			if err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("unknown command %q", flag.Arg(0))
+		}
+		return
+	}
 	client := NewClient(port)
 	if connect != "" {
 		log.Printf("attempting to connect to peer at %v", connect)
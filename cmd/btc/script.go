@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+	"github.com/jacobkaufmann/gocoin/pkg/script"
+)
+
+// scriptFlagSets maps the names accepted by the -flags option of script
+// debug to their verification flags.
+var scriptFlagSets = map[string]script.ScriptFlags{
+	"none":      script.ScriptVerifyNone,
+	"consensus": script.ConsensusVerifyFlags,
+	"standard":  script.StandardVerifyFlags,
+}
+
+// runScript runs the script subcommand named by args[0].
+func runScript(args []string) error {
+	if len(args) == 0 || args[0] != "debug" {
+		return errors.New("usage: btc script debug [flags]")
+	}
+	return runScriptDebug(args[1:])
+}
+
+// runScriptDebug executes a spend one instruction at a time, either
+// interactively or printing the full trace.
+func runScriptDebug(args []string) error {
+	fs := flag.NewFlagSet("script debug", flag.ExitOnError)
+	unlockAsm := fs.String("unlock", "", "unlocking script in ASM (default: from -tx)")
+	lockAsm := fs.String("lock", "", "locking script in ASM")
+	witnessHex := fs.String("witness", "", "comma-separated hex witness items (default: from -tx)")
+	txHex := fs.String("tx", "", "hex serialized spending transaction")
+	txIdx := fs.Int("input", 0, "index of the input being spent")
+	amount := fs.Int64("amount", 0, "value in satoshis of the output being spent")
+	flagsName := fs.String("flags", "standard", "verification flags: none, consensus or standard")
+	trace := fs.Bool("trace", false, "print the full trace instead of stepping interactively")
+	fs.Parse(args)
+
+	flags, ok := scriptFlagSets[*flagsName]
+	if !ok {
+		return fmt.Errorf("unknown flags %q", *flagsName)
+	}
+	lock, err := script.Assemble(*lockAsm)
+	if err != nil {
+		return fmt.Errorf("locking script: %v", err)
+	}
+	tx, err := debugTx(*txHex)
+	if err != nil {
+		return err
+	}
+	if *txIdx < 0 || *txIdx >= len(tx.Inputs) {
+		return fmt.Errorf("input %d out of range", *txIdx)
+	}
+
+	in := tx.Inputs[*txIdx]
+	unlock, witness := in.ScriptUnlock, in.Witness
+	if *unlockAsm != "" {
+		unlock, err = script.Assemble(*unlockAsm)
+		if err != nil {
+			return fmt.Errorf("unlocking script: %v", err)
+		}
+	}
+	if *witnessHex != "" {
+		witness = nil
+		for _, item := range strings.Split(*witnessHex, ",") {
+			b, err := hex.DecodeString(item)
+			if err != nil {
+				return fmt.Errorf("witness: %v", err)
+			}
+			witness = append(witness, b)
+		}
+	}
+
+	vm, err := script.NewEngine(unlock, lock, witness, tx, *txIdx, *amount, flags, nil)
+	if err != nil {
+		return err
+	}
+
+	tracer := &debugTracer{w: os.Stdout, in: bufio.NewReader(os.Stdin), step: !*trace}
+	vm.SetTracer(tracer)
+	for !tracer.quit {
+		done, err := vm.Step()
+		if err == script.ErrScriptDone || done && err == nil {
+			break
+		}
+		if err != nil {
+			fmt.Printf("script failed: %v\n", err)
+			return nil
+		}
+	}
+	if tracer.quit {
+		return nil
+	}
+
+	// Execute applies the final stack checks once every script has run.
+	if err := vm.Execute(); err != nil {
+		fmt.Printf("script failed: %v\n", err)
+		return nil
+	}
+	fmt.Println("script succeeded")
+	return nil
+}
+
+// debugTx returns the transaction serialized by txHex, or a transaction with
+// a single empty input and output if txHex is empty.
+func debugTx(txHex string) (*protocol.MsgTx, error) {
+	if txHex == "" {
+		in := &protocol.TxIn{
+			PrevOutput: protocol.TxOutPoint{Hash: new([protocol.HashSize]byte)},
+			Sequence:   0xffffffff,
+		}
+		return protocol.NewMsgTx(1, []*protocol.TxIn{in},
+			[]*protocol.TxOut{{}}, 0), nil
+	}
+
+	b, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, fmt.Errorf("transaction: %v", err)
+	}
+	tx := &protocol.MsgTx{}
+	err = tx.Deserialize(bytes.NewReader(b), 0)
+	if err != nil {
+		return nil, fmt.Errorf("transaction: %v", err)
+	}
+	return tx, nil
+}
+
+// scriptNames names the scripts of a spend by their index in the engine.
+var scriptNames = []string{"unlocking", "locking"}
+
+// A debugTracer prints each instruction executed by an engine along with the
+// resulting stacks. When step is set, it waits for a command after each
+// instruction.
+type debugTracer struct {
+	w    io.Writer
+	in   *bufio.Reader
+	step bool
+	quit bool
+}
+
+// BeforeStep prints the instruction about to execute.
+func (t *debugTracer) BeforeStep(state *script.StepState) {
+	name := fmt.Sprintf("script %d", state.ScriptIdx)
+	if state.ScriptIdx < len(scriptNames) {
+		name = scriptNames[state.ScriptIdx]
+	}
+	skip := ""
+	if !state.Executing {
+		skip = " (not executing)"
+	}
+	fmt.Fprintf(t.w, "%s:%d %s%s\n", name, state.OpIdx, state.Instruction(), skip)
+}
+
+// AfterStep prints the stacks left by the instruction and, when stepping,
+// reads the next command: enter to step, c to continue to the end or q to
+// quit.
+func (t *debugTracer) AfterStep(state *script.StepState, err error) {
+	fmt.Fprintf(t.w, "  stack:    %s\n", formatStack(state.Stack))
+	if len(state.AltStack) != 0 {
+		fmt.Fprintf(t.w, "  altstack: %s\n", formatStack(state.AltStack))
+	}
+	if state.CondDepth != 0 {
+		fmt.Fprintf(t.w, "  conditionals: %d\n", state.CondDepth)
+	}
+	if err != nil {
+		fmt.Fprintf(t.w, "  error: %v\n", err)
+	}
+	if !t.step || err != nil {
+		return
+	}
+
+	fmt.Fprint(t.w, "(debug) ")
+	line, readErr := t.in.ReadString('\n')
+	switch strings.TrimSpace(line) {
+	case "c":
+		t.step = false
+	case "q":
+		t.quit = true
+	}
+	if readErr != nil {
+		t.step = false
+	}
+}
+
+// formatStack returns the items of stack, from bottom to top, in hex.
+func formatStack(stack [][]byte) string {
+	items := make([]string, len(stack))
+	for i, item := range stack {
+		items[i] = hex.EncodeToString(item)
+		if len(item) == 0 {
+			items[i] = "<>"
+		}
+	}
+	return "[" + strings.Join(items, " ") + "]"
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/script"
+)
+
+func TestDebugTracer(t *testing.T) {
+	unlock, err := script.Assemble("0 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := script.Assemble("OP_IF 2 OP_ENDIF OP_VERIFY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := debugTx("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := script.NewEngine(unlock, lock, nil, tx, 0, 0,
+		script.ScriptVerifyNone, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	vm.SetTracer(&debugTracer{w: &buf})
+	if err := vm.Execute(); err != script.ErrVerify {
+		t.Fatalf("got %v, want %v", err, script.ErrVerify)
+	}
+	want := `unlocking:0 0
+  stack:    [<>]
+unlocking:1 0
+  stack:    [<> <>]
+locking:0 OP_IF
+  stack:    [<>]
+  conditionals: 1
+locking:1 2 (not executing)
+  stack:    [<>]
+  conditionals: 1
+locking:2 OP_ENDIF (not executing)
+  stack:    [<>]
+locking:3 OP_VERIFY
+  stack:    []
+  error: verify failed
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got trace\n%s\nwant\n%s", got, want)
+	}
+}
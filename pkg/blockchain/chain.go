@@ -0,0 +1,245 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/util"
+)
+
+var (
+	// ErrEmptyChain is returned when an operation requires at least one
+	// block in the chain.
+	ErrEmptyChain = errors.New("chain has no blocks")
+
+	// ErrPrevBlockMismatch is returned when a block being connected does not
+	// reference the current tip of the chain.
+	ErrPrevBlockMismatch = errors.New("block does not extend the chain tip")
+)
+
+// BlockStore provides access to the blocks of the main chain by height.
+type BlockStore interface {
+	// BlockCount returns the number of blocks in the store.
+	BlockCount() uint64
+
+	// BlockByHeight returns the block at height.
+	BlockByHeight(height uint64) (*util.Block, error)
+}
+
+// BlockChain represents the main chain of blocks. Connecting and
+// disconnecting blocks keeps all registered indexers in sync with the tip
+// and notifies subscribers. BlockChain implements the BlockStore interface.
+type BlockChain struct {
+	blocks   []*util.Block
+	hashes   []*hashing.Hash
+	indexers []Indexer
+	notifier *Notifier
+	pver     uint32
+	mux      sync.RWMutex
+
+	// procMux serializes block processing so that notifications are sent
+	// in order without holding mux, which lets subscribers query the chain
+	// while the chain waits on them.
+	procMux sync.Mutex
+}
+
+// New returns an empty chain which serializes blocks with protocol version
+// pver and keeps indexers up to date as blocks are connected and
+// disconnected.
+func New(pver uint32, indexers ...Indexer) *BlockChain {
+	return &BlockChain{
+		indexers: indexers,
+		notifier: NewNotifier(),
+		pver:     pver,
+	}
+}
+
+// BlockCount returns the number of blocks in the chain.
+func (bc *BlockChain) BlockCount() uint64 {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return uint64(len(bc.blocks))
+}
+
+// BlockByHeight returns the block at height if it exists in the chain.
+func (bc *BlockChain) BlockByHeight(height uint64) (*util.Block, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	if height >= uint64(len(bc.blocks)) {
+		return nil, fmt.Errorf("no block at height %v", height)
+	}
+	return bc.blocks[height], nil
+}
+
+// Tip returns the block at the tip of the chain along with its hash and
+// height. An error is returned if the chain is empty.
+func (bc *BlockChain) Tip() (*util.Block, *hashing.Hash, uint64, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	n := len(bc.blocks)
+	if n == 0 {
+		return nil, nil, 0, ErrEmptyChain
+	}
+	return bc.blocks[n-1], bc.hashes[n-1], uint64(n - 1), nil
+}
+
+// Subscribe registers a subscriber for chain notifications whose channel
+// holds up to bufSize undelivered notifications. If types are given, only
+// notifications of those types are delivered.
+func (bc *BlockChain) Subscribe(bufSize int, types ...NotificationType) *Subscription {
+	return bc.notifier.Subscribe(bufSize, types...)
+}
+
+// ConnectBlock adds blk to the tip of the chain, connects it to every
+// indexer, and notifies subscribers. If an indexer fails to connect blk, the
+// chain is left unchanged: the indexers which connected it disconnect it
+// again, and any indexer left in an unknown state is rebuilt.
+func (bc *BlockChain) ConnectBlock(blk *util.Block) error {
+	bc.procMux.Lock()
+	defer bc.procMux.Unlock()
+
+	blkHash, height, err := bc.connectBlock(blk)
+	if err != nil {
+		return err
+	}
+
+	bc.notifier.Notify(&Notification{
+		Type: NTBlockConnected,
+		Data: &BlockNotification{Block: blk, Hash: *blkHash, Height: height},
+	})
+	bc.notifier.Notify(&Notification{
+		Type: NTChainTipChanged,
+		Data: &TipNotification{Hash: blkHash, Height: height},
+	})
+	return nil
+}
+
+// connectBlock adds blk to the tip of the chain and connects it to every
+// indexer. It returns the hash and height of blk.
+func (bc *BlockChain) connectBlock(blk *util.Block) (*hashing.Hash, uint64,
+	error) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	blkHash, err := blk.BlockHash(bc.pver)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Every block after the genesis block must build on the current tip.
+	n := len(bc.blocks)
+	if n > 0 {
+		if blk.PrevBlockHash == nil || *blk.PrevBlockHash != *bc.hashes[n-1] {
+			return nil, 0, ErrPrevBlockMismatch
+		}
+	}
+
+	height := uint64(n)
+	for i, idx := range bc.indexers {
+		err = idx.ConnectBlock(blk, blkHash, height)
+		if err != nil {
+			err = fmt.Errorf("%v: %v", idx.Name(), err)
+
+			// The indexers connected before this one disconnect blk
+			// again, so that all of them match the chain.
+			for j := i - 1; j >= 0; j-- {
+				prev := bc.indexers[j]
+				if prev.DisconnectBlock(blk, blkHash, height) != nil {
+					err = bc.rebuildIndexer(prev, err)
+				}
+			}
+			return nil, 0, bc.rebuildIndexer(idx, err)
+		}
+	}
+
+	bc.blocks = append(bc.blocks, blk)
+	bc.hashes = append(bc.hashes, blkHash)
+	return blkHash, height, nil
+}
+
+// DisconnectBlock removes the block at the tip of the chain, disconnects it
+// from every indexer, notifies subscribers, and returns the block. As with
+// ConnectBlock, a failing indexer leaves the chain and indexers unchanged.
+func (bc *BlockChain) DisconnectBlock() (*util.Block, error) {
+	bc.procMux.Lock()
+	defer bc.procMux.Unlock()
+
+	blk, blkHash, height, err := bc.disconnectBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	bc.notifier.Notify(&Notification{
+		Type: NTBlockDisconnected,
+		Data: &BlockNotification{Block: blk, Hash: *blkHash, Height: height},
+	})
+
+	// The new tip is the parent of the disconnected block, if any.
+	tip := &TipNotification{}
+	if height > 0 {
+		tip.Hash = &hashing.Hash{}
+		copy(tip.Hash[:], blk.PrevBlockHash[:])
+		tip.Height = height - 1
+	}
+	bc.notifier.Notify(&Notification{Type: NTChainTipChanged, Data: tip})
+	return blk, nil
+}
+
+// disconnectBlock removes the block at the tip of the chain and disconnects
+// it from every indexer. It returns the block along with its hash and
+// height.
+func (bc *BlockChain) disconnectBlock() (*util.Block, *hashing.Hash, uint64,
+	error) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	n := len(bc.blocks)
+	if n == 0 {
+		return nil, nil, 0, ErrEmptyChain
+	}
+	blk, blkHash := bc.blocks[n-1], bc.hashes[n-1]
+
+	// Indexers are disconnected in the reverse order in which they were
+	// connected.
+	height := uint64(n - 1)
+	for i := len(bc.indexers) - 1; i >= 0; i-- {
+		idx := bc.indexers[i]
+		err := idx.DisconnectBlock(blk, blkHash, height)
+		if err != nil {
+			err = fmt.Errorf("%v: %v", idx.Name(), err)
+
+			// The indexers disconnected before this one connect blk
+			// again, so that all of them match the chain.
+			for _, next := range bc.indexers[i+1:] {
+				if next.ConnectBlock(blk, blkHash, height) != nil {
+					err = bc.rebuildIndexer(next, err)
+				}
+			}
+			return nil, nil, 0, bc.rebuildIndexer(idx, err)
+		}
+	}
+
+	bc.blocks = bc.blocks[:n-1]
+	bc.hashes = bc.hashes[:n-1]
+	return blk, blkHash, height, nil
+}
+
+// rebuildIndexer resets idx and connects every block of the chain to it. It
+// is used when a failed indexer update leaves idx in an unknown state, and
+// returns err, the error which caused the update to fail, along with any
+// error rebuilding idx. The caller must hold mux.
+func (bc *BlockChain) rebuildIndexer(idx Indexer, err error) error {
+	idx.Reset()
+	for height, blk := range bc.blocks {
+		rerr := idx.ConnectBlock(blk, bc.hashes[height], uint64(height))
+		if rerr != nil {
+			return fmt.Errorf("%v (rebuilding %v: %v)", err, idx.Name(),
+				rerr)
+		}
+	}
+	return err
+}
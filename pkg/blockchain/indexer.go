@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"math"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/util"
+)
+
+// An Indexer maintains an optional index over the main chain. Indexers are
+// updated as blocks are connected to and disconnected from the tip.
+type Indexer interface {
+	// Name returns a human-readable name for the index.
+	Name() string
+
+	// ConnectBlock adds the data of blk, which has hash blkHash and sits at
+	// height, to the index.
+	ConnectBlock(blk *util.Block, blkHash *hashing.Hash, height uint64) error
+
+	// DisconnectBlock removes the data of blk, which has hash blkHash and sits
+	// at height, from the index.
+	DisconnectBlock(blk *util.Block, blkHash *hashing.Hash, height uint64) error
+
+	// Reset removes all data from the index.
+	Reset()
+}
+
+// RebuildIndex resets idx and rebuilds it by connecting every block in store
+// in order of height. Blocks are hashed with protocol version pver.
+func RebuildIndex(idx Indexer, store BlockStore, pver uint32) error {
+	idx.Reset()
+
+	count := store.BlockCount()
+	for height := uint64(0); height < count; height++ {
+		blk, err := store.BlockByHeight(height)
+		if err != nil {
+			return err
+		}
+		blkHash, err := blk.BlockHash(pver)
+		if err != nil {
+			return err
+		}
+		err = idx.ConnectBlock(blk, blkHash, height)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// coinbaseOutputIndex is the previous output index referenced by the single
+// input of a coinbase transaction.
+const coinbaseOutputIndex = math.MaxUint32
+
+// isCoinbase returns whether tx is a coinbase transaction. A coinbase
+// transaction has a single input which references a null previous output.
+func isCoinbase(tx *util.Tx) bool {
+	if len(tx.Inputs) != 1 {
+		return false
+	}
+
+	prevOut := tx.Inputs[0].PrevOutput
+	if prevOut.Index != coinbaseOutputIndex {
+		return false
+	}
+	return prevOut.Hash == nil || *prevOut.Hash == [hashing.HashSize]byte{}
+}
@@ -0,0 +1,352 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+	"github.com/jacobkaufmann/gocoin/pkg/util"
+)
+
+// testPver is the protocol version with which test blocks are serialized.
+const testPver = 70015
+
+// coinbaseTx returns a coinbase transaction paying value to lock. The tag
+// is committed to by the unlocking script so that coinbases differ.
+func coinbaseTx(tag byte, value int64, lock []byte) *util.Tx {
+	in := &protocol.TxIn{
+		PrevOutput: protocol.TxOutPoint{
+			Hash:  &[protocol.HashSize]byte{},
+			Index: coinbaseOutputIndex,
+		},
+		ScriptUnlock: []byte{0x01, tag},
+		Sequence:     0xffffffff,
+	}
+	out := &protocol.TxOut{Value: value, ScriptLock: lock}
+	return util.NewTx(1, []*protocol.TxIn{in}, []*protocol.TxOut{out}, 0)
+}
+
+// spendTx returns a transaction spending output index of prev to lock.
+func spendTx(t *testing.T, prev *util.Tx, index uint32, value int64,
+	lock []byte) *util.Tx {
+	txID, err := prev.TxID(testPver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := [protocol.HashSize]byte(*txID)
+	in := &protocol.TxIn{
+		PrevOutput: protocol.TxOutPoint{Hash: &hash, Index: index},
+		Sequence:   0xffffffff,
+	}
+	out := &protocol.TxOut{Value: value, ScriptLock: lock}
+	return util.NewTx(1, []*protocol.TxIn{in}, []*protocol.TxOut{out}, 0)
+}
+
+// newTestBlock returns a block with txns extending the tip of bc.
+func newTestBlock(t *testing.T, bc *BlockChain, txns ...*util.Tx) *util.Block {
+	prev := &[protocol.HashSize]byte{}
+	if _, hash, _, err := bc.Tip(); err == nil {
+		*prev = *hash
+	}
+	merkleRoot := &[protocol.HashSize]byte{byte(bc.BlockCount())}
+	timestamp := time.Unix(1231006505+int64(bc.BlockCount()), 0)
+	hdr := protocol.NewBlockHeader(1, prev, merkleRoot, timestamp, 0x1d00ffff,
+		0, uint64(len(txns)))
+	return &util.Block{BlockHeader: hdr, Txns: txns}
+}
+
+// txID returns the transaction id of tx.
+func txID(t *testing.T, tx *util.Tx) *hashing.Hash {
+	id, err := tx.TxID(testPver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// errIndexer is the error of a failingIndexer.
+var errIndexer = errors.New("indexer failed")
+
+// A failingIndexer fails to connect or disconnect blocks on demand, and
+// counts the blocks connected to it.
+type failingIndexer struct {
+	failConnect    bool
+	failDisconnect bool
+	blocks         int
+}
+
+func (idx *failingIndexer) Name() string { return "failing index" }
+
+func (idx *failingIndexer) ConnectBlock(blk *util.Block,
+	blkHash *hashing.Hash, height uint64) error {
+	if idx.failConnect {
+		return errIndexer
+	}
+	idx.blocks++
+	return nil
+}
+
+func (idx *failingIndexer) DisconnectBlock(blk *util.Block,
+	blkHash *hashing.Hash, height uint64) error {
+	if idx.failDisconnect {
+		return errIndexer
+	}
+	idx.blocks--
+	return nil
+}
+
+func (idx *failingIndexer) Reset() { idx.blocks = 0 }
+
+// TestIndexerRollback checks that a failing indexer leaves the chain and the
+// other indexers as they were.
+func TestIndexerRollback(t *testing.T) {
+	txIdx := NewTxIndex(testPver)
+	failing := &failingIndexer{}
+	shIdx := NewScriptHashIndex(testPver)
+	bc := New(testPver, txIdx, failing, shIdx)
+
+	cb0 := coinbaseTx(0, 50, []byte{0x51})
+	if err := bc.ConnectBlock(newTestBlock(t, bc, cb0)); err != nil {
+		t.Fatal(err)
+	}
+
+	failing.failConnect = true
+	cb1 := coinbaseTx(1, 50, []byte{0x52})
+	if err := bc.ConnectBlock(newTestBlock(t, bc, cb1)); err == nil {
+		t.Fatal("connected a block which an indexer failed to connect")
+	}
+	failing.failConnect = false
+	if bc.BlockCount() != 1 {
+		t.Fatalf("chain has %d blocks, want 1", bc.BlockCount())
+	}
+	if idx := txIdx.Get(txID(t, cb1)); idx != nil {
+		t.Fatalf("failed block still in the transaction index: %+v", idx)
+	}
+
+	failing.failDisconnect = true
+	if _, err := bc.DisconnectBlock(); err == nil {
+		t.Fatal("disconnected a block which an indexer failed to disconnect")
+	}
+	if bc.BlockCount() != 1 {
+		t.Fatalf("chain has %d blocks, want 1", bc.BlockCount())
+	}
+	if txIdx.Get(txID(t, cb0)) == nil {
+		t.Fatal("block removed from the transaction index")
+	}
+	hash := ScriptHash(cb0.Outputs[0].ScriptLock)
+	if history := shIdx.History(&hash); len(history) != 1 {
+		t.Fatalf("script hash history = %+v, want one entry", history)
+	}
+
+	// The failing indexer could not be rebuilt when it failed to connect
+	// the block; it is rebuilt after failing to disconnect it.
+	if failing.blocks != 1 {
+		t.Fatalf("failing index has %d blocks, want 1", failing.blocks)
+	}
+}
+
+func TestTxIndexConnectDisconnect(t *testing.T) {
+	idx := NewTxIndex(testPver)
+	bc := New(testPver, idx)
+
+	cb0 := coinbaseTx(0, 50, []byte{0x51})
+	if err := bc.ConnectBlock(newTestBlock(t, bc, cb0)); err != nil {
+		t.Fatal(err)
+	}
+	cb1 := coinbaseTx(1, 50, []byte{0x52})
+	spend := spendTx(t, cb0, 0, 40, []byte{0x53})
+	if err := bc.ConnectBlock(newTestBlock(t, bc, cb1, spend)); err != nil {
+		t.Fatal(err)
+	}
+
+	loc := idx.Get(txID(t, spend))
+	if loc == nil || loc.Height != 1 || loc.TxIndex != 1 {
+		t.Fatalf("spend location = %+v", loc)
+	}
+	tx, loc, err := idx.FetchTx(txID(t, cb0), bc)
+	if err != nil || loc.Height != 0 || txID(t, tx) == nil {
+		t.Fatalf("FetchTx: %v %+v", err, loc)
+	}
+
+	if _, err := bc.DisconnectBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Get(txID(t, spend)) != nil || idx.Get(txID(t, cb1)) != nil {
+		t.Fatal("disconnected transactions still indexed")
+	}
+	if idx.Get(txID(t, cb0)) == nil {
+		t.Fatal("transaction of remaining block removed")
+	}
+	if _, _, err := idx.FetchTx(txID(t, spend), bc); err != ErrTxNotIndexed {
+		t.Fatalf("FetchTx error = %v, want %v", err, ErrTxNotIndexed)
+	}
+}
+
+func TestTxIndexDuplicateTxID(t *testing.T) {
+	idx := NewTxIndex(testPver)
+	bc := New(testPver, idx)
+
+	// Identical coinbases, like those at heights 91812 and 91842, have the
+	// same transaction id.
+	dup := coinbaseTx(7, 50, []byte{0x51})
+	for i := 0; i < 2; i++ {
+		if err := bc.ConnectBlock(newTestBlock(t, bc, dup)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loc := idx.Get(txID(t, dup)); loc == nil || loc.Height != 1 {
+		t.Fatalf("location = %+v, want height 1", loc)
+	}
+
+	if _, err := bc.DisconnectBlock(); err != nil {
+		t.Fatal(err)
+	}
+	loc := idx.Get(txID(t, dup))
+	if loc == nil || loc.Height != 0 {
+		t.Fatalf("location after disconnect = %+v, want height 0", loc)
+	}
+
+	if _, err := bc.DisconnectBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Get(txID(t, dup)) != nil {
+		t.Fatal("transaction still indexed after both blocks disconnected")
+	}
+}
+
+func TestTxIndexRebuild(t *testing.T) {
+	bc := New(testPver)
+	var txns []*util.Tx
+	for i := 0; i < 3; i++ {
+		cb := coinbaseTx(byte(i), 50, []byte{0x51})
+		txns = append(txns, cb)
+		if err := bc.ConnectBlock(newTestBlock(t, bc, cb)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx := NewTxIndex(testPver)
+	stale := coinbaseTx(99, 1, nil)
+	idx.ConnectBlock(newTestBlock(t, bc, stale), &hashing.Hash{}, 9)
+	if err := RebuildIndex(idx, bc, testPver); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Get(txID(t, stale)) != nil {
+		t.Fatal("rebuild kept a stale entry")
+	}
+	for i, tx := range txns {
+		if loc := idx.Get(txID(t, tx)); loc == nil || loc.Height != uint64(i) {
+			t.Fatalf("tx %d location = %+v", i, loc)
+		}
+	}
+}
+
+func TestScriptHashIndexConnectDisconnect(t *testing.T) {
+	idx := NewScriptHashIndex(testPver)
+	bc := New(testPver, idx)
+	lockA, lockB := []byte{0x51}, []byte{0x52}
+	hashA, hashB := ScriptHash(lockA), ScriptHash(lockB)
+
+	cb0 := coinbaseTx(0, 50, lockA)
+	if err := bc.ConnectBlock(newTestBlock(t, bc, cb0)); err != nil {
+		t.Fatal(err)
+	}
+	cb1 := coinbaseTx(1, 50, lockA)
+	spend := spendTx(t, cb0, 0, 40, lockB)
+	if err := bc.ConnectBlock(newTestBlock(t, bc, cb1, spend)); err != nil {
+		t.Fatal(err)
+	}
+
+	history := idx.History(&hashA)
+	if len(history) != 2 {
+		t.Fatalf("history of A has %d entries, want 2", len(history))
+	}
+	if !history[0].Spent || history[0].SpendTxID != *txID(t, spend) ||
+		history[0].SpendHeight != 1 {
+		t.Fatalf("spent entry = %+v", history[0])
+	}
+	if unspent := idx.Unspent(&hashA); len(unspent) != 1 ||
+		unspent[0].TxID != *txID(t, cb1) {
+		t.Fatalf("unspent of A = %+v", unspent)
+	}
+	if unspent := idx.Unspent(&hashB); len(unspent) != 1 ||
+		unspent[0].Value != 40 {
+		t.Fatalf("unspent of B = %+v", unspent)
+	}
+
+	if _, err := bc.DisconnectBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.History(&hashB)) != 0 {
+		t.Fatal("outputs of disconnected block still indexed")
+	}
+	history = idx.History(&hashA)
+	if len(history) != 1 || history[0].Spent {
+		t.Fatalf("history of A after disconnect = %+v", history)
+	}
+}
+
+func TestScriptHashIndexDuplicateTxID(t *testing.T) {
+	idx := NewScriptHashIndex(testPver)
+	bc := New(testPver, idx)
+	lock := []byte{0x51}
+	hash := ScriptHash(lock)
+
+	dup := coinbaseTx(7, 50, lock)
+	for i := 0; i < 2; i++ {
+		if err := bc.ConnectBlock(newTestBlock(t, bc, dup)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if history := idx.History(&hash); len(history) != 2 {
+		t.Fatalf("history has %d entries, want 2", len(history))
+	}
+
+	// Disconnecting the later block restores the earlier output, which
+	// a spend then marks as spent.
+	if _, err := bc.DisconnectBlock(); err != nil {
+		t.Fatal(err)
+	}
+	spend := spendTx(t, dup, 0, 40, []byte{0x52})
+	if err := bc.ConnectBlock(newTestBlock(t, bc, coinbaseTx(1, 50, nil), spend)); err != nil {
+		t.Fatal(err)
+	}
+	history := idx.History(&hash)
+	if len(history) != 1 || history[0].Height != 0 || !history[0].Spent {
+		t.Fatalf("history after spend = %+v", history)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := bc.DisconnectBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if history := idx.History(&hash); len(history) != 0 {
+		t.Fatalf("history after both blocks disconnected = %+v", history)
+	}
+}
+
+func TestScriptHashIndexRebuild(t *testing.T) {
+	bc := New(testPver)
+	lock := []byte{0x51}
+	cb0 := coinbaseTx(0, 50, lock)
+	if err := bc.ConnectBlock(newTestBlock(t, bc, cb0)); err != nil {
+		t.Fatal(err)
+	}
+	spend := spendTx(t, cb0, 0, 40, lock)
+	if err := bc.ConnectBlock(newTestBlock(t, bc, coinbaseTx(1, 50, nil), spend)); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewScriptHashIndex(testPver)
+	if err := RebuildIndex(idx, bc, testPver); err != nil {
+		t.Fatal(err)
+	}
+	hash := ScriptHash(lock)
+	history := idx.History(&hash)
+	if len(history) != 2 || !history[0].Spent || history[1].Spent {
+		t.Fatalf("history after rebuild = %+v", history)
+	}
+}
@@ -0,0 +1,213 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/util"
+)
+
+// NotificationType identifies the kind of event carried by a notification.
+type NotificationType int
+
+// Chain and mempool notification types.
+const (
+	// NTBlockConnected indicates that a block was connected to the tip of the
+	// chain. The notification data is a *BlockNotification.
+	NTBlockConnected NotificationType = iota
+
+	// NTBlockDisconnected indicates that the block at the tip of the chain was
+	// disconnected. The notification data is a *BlockNotification.
+	NTBlockDisconnected
+
+	// NTChainTipChanged indicates that the tip of the chain changed. The
+	// notification data is a *TipNotification.
+	NTChainTipChanged
+
+	// NTTxAcceptedToMempool indicates that a transaction was accepted to the
+	// mempool. The notification data is a *TxNotification.
+	NTTxAcceptedToMempool
+
+	// NTTxRemovedFromMempool indicates that a transaction was removed from
+	// the mempool. The notification data is a *TxNotification.
+	NTTxRemovedFromMempool
+)
+
+// notificationTypeStrings maps notification types to human-readable names.
+var notificationTypeStrings = map[NotificationType]string{
+	NTBlockConnected:       "BlockConnected",
+	NTBlockDisconnected:    "BlockDisconnected",
+	NTChainTipChanged:      "ChainTipChanged",
+	NTTxAcceptedToMempool:  "TxAcceptedToMempool",
+	NTTxRemovedFromMempool: "TxRemovedFromMempool",
+}
+
+// String returns the notification type in human-readable form.
+func (t NotificationType) String() string {
+	if s, ok := notificationTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown NotificationType (%d)", int(t))
+}
+
+// A Notification is delivered to subscribers when an event occurs on the
+// chain or in the mempool. The concrete type of Data depends on Type.
+type Notification struct {
+	Type NotificationType
+	Data interface{}
+}
+
+// BlockNotification is the data of a block connected or block disconnected
+// notification.
+type BlockNotification struct {
+	Block  *util.Block
+	Hash   hashing.Hash
+	Height uint64
+}
+
+// TipNotification is the data of a chain tip changed notification. Hash is
+// nil when the chain no longer has any blocks.
+type TipNotification struct {
+	Hash   *hashing.Hash
+	Height uint64
+}
+
+// RemovalReason describes why a transaction was removed from the mempool.
+type RemovalReason int
+
+// Reasons for removing a transaction from the mempool.
+const (
+	// RemovalReasonBlock indicates that the transaction was included in a
+	// connected block.
+	RemovalReasonBlock RemovalReason = iota
+
+	// RemovalReasonConflict indicates that the transaction conflicted with a
+	// transaction in a connected block.
+	RemovalReasonConflict
+
+	// RemovalReasonManual indicates that the transaction was removed by
+	// request, including when the mempool is cleared.
+	RemovalReasonManual
+)
+
+// removalReasonStrings maps removal reasons to human-readable names.
+var removalReasonStrings = map[RemovalReason]string{
+	RemovalReasonBlock:    "block",
+	RemovalReasonConflict: "conflict",
+	RemovalReasonManual:   "manual",
+}
+
+// String returns the removal reason in human-readable form.
+func (r RemovalReason) String() string {
+	if s, ok := removalReasonStrings[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown RemovalReason (%d)", int(r))
+}
+
+// TxNotification is the data of a mempool notification. Reason is only
+// meaningful for transactions removed from the mempool.
+type TxNotification struct {
+	Tx     *util.Tx
+	TxID   hashing.Hash
+	Reason RemovalReason
+}
+
+// A Subscription receives the notifications of a Notifier through a buffered
+// channel. Once the buffer is full, the notifier blocks until the subscriber
+// receives a notification or unsubscribes, so slow subscribers apply
+// backpressure to the chain or mempool instead of missing events.
+type Subscription struct {
+	ch       chan *Notification
+	quit     chan struct{}
+	types    map[NotificationType]bool
+	notifier *Notifier
+	once     sync.Once
+}
+
+// Notifications returns the channel on which notifications are delivered.
+// The channel is closed after the subscription is cancelled.
+func (s *Subscription) Notifications() <-chan *Notification {
+	return s.ch
+}
+
+// Unsubscribe cancels the subscription. It is safe to call Unsubscribe more
+// than once.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		// Closing quit first releases a notifier blocked on a full
+		// buffer, which in turn releases the notifier's lock.
+		close(s.quit)
+
+		s.notifier.mux.Lock()
+		delete(s.notifier.subs, s)
+		s.notifier.mux.Unlock()
+
+		close(s.ch)
+	})
+}
+
+// wants returns whether the subscriber asked for notifications of type t.
+func (s *Subscription) wants(t NotificationType) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+// A Notifier delivers notifications to its subscribers. Notifications are
+// delivered to each subscriber in the order in which they are sent.
+type Notifier struct {
+	subs map[*Subscription]struct{}
+	mux  sync.RWMutex
+}
+
+// NewNotifier returns a notifier without any subscribers.
+func NewNotifier() *Notifier {
+	return &Notifier{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber whose channel holds up to bufSize
+// undelivered notifications. If types are given, only notifications of
+// those types are delivered.
+func (n *Notifier) Subscribe(bufSize int, types ...NotificationType) *Subscription {
+	sub := &Subscription{
+		ch:       make(chan *Notification, bufSize),
+		quit:     make(chan struct{}),
+		types:    make(map[NotificationType]bool),
+		notifier: n,
+	}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.subs[sub] = struct{}{}
+	return sub
+}
+
+// Notify delivers ntfn to every interested subscriber. Notify blocks while a
+// subscriber's buffer is full until the subscriber receives a notification
+// or unsubscribes.
+func (n *Notifier) Notify(ntfn *Notification) {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+
+	for sub := range n.subs {
+		if !sub.wants(ntfn.Type) {
+			continue
+		}
+		select {
+		case sub.ch <- ntfn:
+		case <-sub.quit:
+		}
+	}
+}
+
+// NumSubscribers returns the number of active subscriptions.
+func (n *Notifier) NumSubscribers() int {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return len(n.subs)
+}
@@ -0,0 +1,163 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// nextNotification returns the next notification of sub, failing the test
+// if none arrives.
+func nextNotification(t *testing.T, sub *Subscription) *Notification {
+	t.Helper()
+	select {
+	case ntfn := <-sub.Notifications():
+		return ntfn
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification delivered")
+		return nil
+	}
+}
+
+func TestChainNotifications(t *testing.T) {
+	bc := New(testPver)
+	sub := bc.Subscribe(8)
+	defer sub.Unsubscribe()
+
+	blocks := make([]*BlockNotification, 2)
+	for i := range blocks {
+		blk := newTestBlock(t, bc, coinbaseTx(byte(i), 50, nil))
+		if err := bc.ConnectBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+
+		ntfn := nextNotification(t, sub)
+		data, ok := ntfn.Data.(*BlockNotification)
+		if ntfn.Type != NTBlockConnected || !ok {
+			t.Fatalf("got %v notification, want %v", ntfn.Type,
+				NTBlockConnected)
+		}
+		if data.Block != blk || data.Height != uint64(i) {
+			t.Fatalf("connected block at height %d, want %d", data.Height, i)
+		}
+		blocks[i] = data
+
+		ntfn = nextNotification(t, sub)
+		tip, ok := ntfn.Data.(*TipNotification)
+		if ntfn.Type != NTChainTipChanged || !ok {
+			t.Fatalf("got %v notification, want %v", ntfn.Type,
+				NTChainTipChanged)
+		}
+		if *tip.Hash != data.Hash || tip.Height != uint64(i) {
+			t.Fatalf("tip %v at height %d, want %v at %d", tip.Hash,
+				tip.Height, data.Hash, i)
+		}
+	}
+
+	// Disconnecting a block makes its parent the tip, and disconnecting
+	// the last block leaves no tip.
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if _, err := bc.DisconnectBlock(); err != nil {
+			t.Fatal(err)
+		}
+
+		ntfn := nextNotification(t, sub)
+		data, ok := ntfn.Data.(*BlockNotification)
+		if ntfn.Type != NTBlockDisconnected || !ok {
+			t.Fatalf("got %v notification, want %v", ntfn.Type,
+				NTBlockDisconnected)
+		}
+		if data.Hash != blocks[i].Hash || data.Height != uint64(i) {
+			t.Fatalf("disconnected block at height %d, want %d",
+				data.Height, i)
+		}
+
+		ntfn = nextNotification(t, sub)
+		tip, ok := ntfn.Data.(*TipNotification)
+		if ntfn.Type != NTChainTipChanged || !ok {
+			t.Fatalf("got %v notification, want %v", ntfn.Type,
+				NTChainTipChanged)
+		}
+		if i == 0 {
+			if tip.Hash != nil {
+				t.Fatalf("tip %v of empty chain, want nil", tip.Hash)
+			}
+			continue
+		}
+		if *tip.Hash != blocks[i-1].Hash || tip.Height != uint64(i-1) {
+			t.Fatalf("tip %v at height %d, want %v at %d", tip.Hash,
+				tip.Height, blocks[i-1].Hash, i-1)
+		}
+	}
+}
+
+func TestChainNotificationTypes(t *testing.T) {
+	bc := New(testPver)
+	sub := bc.Subscribe(8, NTChainTipChanged)
+	defer sub.Unsubscribe()
+
+	if err := bc.ConnectBlock(newTestBlock(t, bc, coinbaseTx(0, 50, nil))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.DisconnectBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(sub.Notifications()); n != 2 {
+		t.Fatalf("got %d notifications, want 2", n)
+	}
+	for i := 0; i < 2; i++ {
+		if ntfn := <-sub.Notifications(); ntfn.Type != NTChainTipChanged {
+			t.Fatalf("got %v notification", ntfn.Type)
+		}
+	}
+}
+
+// TestChainBackpressure checks that a subscriber with a full buffer holds up
+// block processing without blocking queries of the chain, and that
+// unsubscribing releases it.
+func TestChainBackpressure(t *testing.T) {
+	bc := New(testPver)
+	sub := bc.Subscribe(1, NTBlockConnected)
+
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 3; i++ {
+			blk := newTestBlock(t, bc, coinbaseTx(byte(i), 50, nil))
+			if err := bc.ConnectBlock(blk); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	// The first notification fills the buffer, so the second block is
+	// connected but its notification waits for the subscriber.
+	deadline := time.Now().Add(5 * time.Second)
+	for bc.BlockCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("second block not connected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("block processing finished early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if bc.BlockCount() != 2 {
+		t.Fatalf("chain has %d blocks while held up, want 2", bc.BlockCount())
+	}
+
+	sub.Unsubscribe()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("block processing still held up after unsubscribing")
+	}
+	if bc.BlockCount() != 3 {
+		t.Fatalf("chain has %d blocks, want 3", bc.BlockCount())
+	}
+}
@@ -0,0 +1,224 @@
+package blockchain
+
+import (
+	"sync"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/util"
+)
+
+// A ScriptHashEntry records a transaction output paying to a script along
+// with whether, and by which transaction, the output has been spent.
+type ScriptHashEntry struct {
+	TxID   hashing.Hash
+	Index  uint32
+	Height uint64
+	Value  int64
+
+	Spent       bool
+	SpendTxID   hashing.Hash
+	SpendHeight uint64
+}
+
+// outPoint is a comparable reference to a transaction output.
+type outPoint struct {
+	hash  hashing.Hash
+	index uint32
+}
+
+// ScriptHashIndex maps the SHA-256 hash of each locking script in the main
+// chain to the history of outputs paying to it. Indexing by script hash
+// rather than by address covers every kind of output, including those
+// without an address encoding. As in TxIndex, an output of a duplicated
+// transaction id (see BIP30) refers to the most recent transaction, and the
+// earlier output is restored when the later block is disconnected.
+// ScriptHashIndex implements the Indexer interface.
+type ScriptHashIndex struct {
+	entries map[hashing.Hash][]*ScriptHashEntry
+
+	// outputs holds the entries of each outpoint in the order the blocks
+	// containing them were connected.
+	outputs map[outPoint][]*ScriptHashEntry
+	pver    uint32
+	mux     sync.RWMutex
+}
+
+// NewScriptHashIndex returns an empty script hash index which computes
+// transaction ids with protocol version pver.
+func NewScriptHashIndex(pver uint32) *ScriptHashIndex {
+	return &ScriptHashIndex{
+		entries: make(map[hashing.Hash][]*ScriptHashEntry),
+		outputs: make(map[outPoint][]*ScriptHashEntry),
+		pver:    pver,
+	}
+}
+
+// ScriptHash returns the hash under which outputs paying to script are
+// indexed.
+func ScriptHash(script []byte) hashing.Hash {
+	return hashing.SHA256H(script)
+}
+
+// Name returns the name of the script hash index.
+func (idx *ScriptHashIndex) Name() string {
+	return "script hash index"
+}
+
+// ConnectBlock adds each output in blk to the history of its script and marks
+// each output spent by blk as spent.
+func (idx *ScriptHashIndex) ConnectBlock(blk *util.Block,
+	blkHash *hashing.Hash, height uint64) error {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	for _, tx := range blk.Txns {
+		txID, err := tx.TxID(idx.pver)
+		if err != nil {
+			return err
+		}
+
+		if !isCoinbase(tx) {
+			for _, in := range tx.Inputs {
+				entry := idx.prevOutputEntry(in.PrevOutput.Hash,
+					in.PrevOutput.Index)
+				if entry == nil {
+					continue
+				}
+				entry.Spent = true
+				entry.SpendTxID = *txID
+				entry.SpendHeight = height
+			}
+		}
+
+		for i, out := range tx.Outputs {
+			entry := &ScriptHashEntry{
+				TxID:   *txID,
+				Index:  uint32(i),
+				Height: height,
+				Value:  out.Value,
+			}
+			scriptHash := ScriptHash(out.ScriptLock)
+			idx.entries[scriptHash] = append(idx.entries[scriptHash], entry)
+			op := outPoint{*txID, uint32(i)}
+			idx.outputs[op] = append(idx.outputs[op], entry)
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock removes each output in blk from the history of its script
+// and marks each output spent by blk as unspent.
+func (idx *ScriptHashIndex) DisconnectBlock(blk *util.Block,
+	blkHash *hashing.Hash, height uint64) error {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	// Transactions are undone in reverse order so that outputs spent within
+	// the block are restored before they are removed.
+	for i := len(blk.Txns) - 1; i >= 0; i-- {
+		tx := blk.Txns[i]
+		txID, err := tx.TxID(idx.pver)
+		if err != nil {
+			return err
+		}
+
+		for j, out := range tx.Outputs {
+			// Only remove the entry if it belongs to this block,
+			// since duplicate transaction ids exist in the chain.
+			op := outPoint{*txID, uint32(j)}
+			outputs := idx.outputs[op]
+			n := len(outputs)
+			if n == 0 || outputs[n-1].Height != height {
+				continue
+			}
+			if n == 1 {
+				delete(idx.outputs, op)
+			} else {
+				idx.outputs[op] = outputs[:n-1]
+			}
+			idx.removeEntry(ScriptHash(out.ScriptLock), outputs[n-1])
+		}
+
+		if isCoinbase(tx) {
+			continue
+		}
+		for _, in := range tx.Inputs {
+			entry := idx.prevOutputEntry(in.PrevOutput.Hash,
+				in.PrevOutput.Index)
+			if entry == nil || entry.SpendTxID != *txID ||
+				entry.SpendHeight != height {
+				continue
+			}
+			entry.Spent = false
+			entry.SpendTxID = hashing.Hash{}
+			entry.SpendHeight = 0
+		}
+	}
+	return nil
+}
+
+// prevOutputEntry returns the most recent entry for the output referenced by
+// hash and index if it exists in the index.
+func (idx *ScriptHashIndex) prevOutputEntry(hash *[hashing.HashSize]byte,
+	index uint32) *ScriptHashEntry {
+	if hash == nil {
+		return nil
+	}
+	outputs := idx.outputs[outPoint{hashing.Hash(*hash), index}]
+	if len(outputs) == 0 {
+		return nil
+	}
+	return outputs[len(outputs)-1]
+}
+
+// removeEntry removes entry from the history of scriptHash.
+func (idx *ScriptHashIndex) removeEntry(scriptHash hashing.Hash,
+	entry *ScriptHashEntry) {
+	history := idx.entries[scriptHash]
+	for i, e := range history {
+		if e == entry {
+			history = append(history[:i], history[i+1:]...)
+			break
+		}
+	}
+
+	if len(history) == 0 {
+		delete(idx.entries, scriptHash)
+		return
+	}
+	idx.entries[scriptHash] = history
+}
+
+// Reset removes all entries from the index.
+func (idx *ScriptHashIndex) Reset() {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	idx.entries = make(map[hashing.Hash][]*ScriptHashEntry)
+	idx.outputs = make(map[outPoint][]*ScriptHashEntry)
+}
+
+// History returns the outputs paying to the script specified by scriptHash in
+// the order in which they were added to the chain.
+func (idx *ScriptHashIndex) History(scriptHash *hashing.Hash) []ScriptHashEntry {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	history := idx.entries[*scriptHash]
+	entries := make([]ScriptHashEntry, 0, len(history))
+	for _, e := range history {
+		entries = append(entries, *e)
+	}
+	return entries
+}
+
+// Unspent returns the unspent outputs paying to the script specified by
+// scriptHash.
+func (idx *ScriptHashIndex) Unspent(scriptHash *hashing.Hash) []ScriptHashEntry {
+	var unspent []ScriptHashEntry
+	for _, e := range idx.History(scriptHash) {
+		if !e.Spent {
+			unspent = append(unspent, e)
+		}
+	}
+	return unspent
+}
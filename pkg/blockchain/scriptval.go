@@ -0,0 +1,180 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+	"github.com/jacobkaufmann/gocoin/pkg/script"
+	"github.com/jacobkaufmann/gocoin/pkg/util"
+)
+
+var (
+	// ErrMissingPrevOutput is returned when an output spent by a block is
+	// unknown.
+	ErrMissingPrevOutput = errors.New("spent output is unknown")
+
+	// ErrBatchVerify is returned when a batch of Schnorr signatures fails
+	// to verify but each of its inputs validates on its own, which can only
+	// result from a fault.
+	ErrBatchVerify = errors.New("schnorr signature batch failed to verify")
+)
+
+// A ScriptError reports an input of a block whose scripts are invalid.
+type ScriptError struct {
+	// TxIndex is the index of the transaction in the block and InputIndex
+	// the index of the input in the transaction.
+	TxIndex    int
+	InputIndex int
+
+	// Err is the reason the input is invalid.
+	Err error
+}
+
+// Error returns a description of the invalid input.
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("input %d of transaction %d: %v", e.InputIndex,
+		e.TxIndex, e.Err)
+}
+
+// Unwrap returns the reason the input is invalid.
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// A scriptInput is an input of a block to validate together with the output
+// it spends.
+type scriptInput struct {
+	txIdx     int
+	inIdx     int
+	tx        *protocol.MsgTx
+	prevOut   *protocol.TxOut
+	sigHashes *script.TxSigHashes
+}
+
+// validate executes the scripts of the input with flags. If bv is not nil,
+// the Schnorr signatures of a taproot spend are added to it rather than
+// verified.
+func (in *scriptInput) validate(flags script.ScriptFlags,
+	bv *schnorr.BatchVerifier) error {
+	txIn := in.tx.Inputs[in.inIdx]
+	vm, err := script.NewEngine(txIn.ScriptUnlock, in.prevOut.ScriptLock,
+		txIn.Witness, in.tx, in.inIdx, in.prevOut.Value, flags, in.sigHashes)
+	if err != nil {
+		return err
+	}
+	vm.SetBatchVerifier(bv)
+	return vm.Execute()
+}
+
+// ValidateBlockScripts validates the scripts of every input of blk but the
+// coinbase against the outputs they spend, which prevOuts supplies, with
+// flags. The inputs are validated in parallel, and the Schnorr signatures of
+// taproot spends are collected and verified in a single batch. If the batch
+// fails, the taproot inputs are validated again one at a time to find those
+// with invalid signatures. The error for the first invalid input in block
+// order is returned as a *ScriptError.
+func ValidateBlockScripts(blk *util.Block, prevOuts script.PrevOutputFetcher,
+	flags script.ScriptFlags) error {
+	var inputs []*scriptInput
+	for i, tx := range blk.Txns {
+		if isCoinbase(tx) {
+			continue
+		}
+		msg := tx.Message()
+		for j, in := range msg.Inputs {
+			prevOut := prevOuts.FetchPrevOutput(in.PrevOutput)
+			if prevOut == nil {
+				return &ScriptError{i, j, ErrMissingPrevOutput}
+			}
+			inputs = append(inputs, &scriptInput{
+				txIdx:   i,
+				inIdx:   j,
+				tx:      msg,
+				prevOut: prevOut,
+			})
+		}
+		if len(msg.Inputs) == 0 {
+			continue
+		}
+
+		// The inputs of a transaction share its signature hashes.
+		sigHashes, err := script.NewTxSigHashes(msg, prevOuts)
+		if err != nil {
+			return &ScriptError{i, 0, err}
+		}
+		for _, in := range inputs[len(inputs)-len(msg.Inputs):] {
+			in.sigHashes = sigHashes
+		}
+	}
+
+	bv := schnorr.NewBatchVerifier()
+	errs := validateInputs(inputs, flags, bv)
+	failed := false
+	for _, err := range errs {
+		if err != nil {
+			failed = true
+			break
+		}
+	}
+
+	if bv.Len() > 0 && bv.Verify() != nil {
+		// Only taproot spends add signatures to the batch, so either
+		// one of those which succeeded has an invalid signature or the
+		// invalid signature belongs to an input which failed anyway.
+		var retry []*scriptInput
+		var retryIdx []int
+		for i, in := range inputs {
+			class := script.GetScriptClass(in.prevOut.ScriptLock)
+			if errs[i] == nil && class == script.WitnessV1TaprootTy {
+				retry = append(retry, in)
+				retryIdx = append(retryIdx, i)
+			}
+		}
+		found := false
+		for i, err := range validateInputs(retry, flags, nil) {
+			if err != nil {
+				errs[retryIdx[i]] = err
+				found = true
+			}
+		}
+		if !found && !failed {
+			return ErrBatchVerify
+		}
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			return &ScriptError{inputs[i].txIdx, inputs[i].inIdx, err}
+		}
+	}
+	return nil
+}
+
+// validateInputs validates inputs in parallel with flags, adding the Schnorr
+// signatures of taproot spends to bv if it is not nil. It returns the result
+// of each input.
+func validateInputs(inputs []*scriptInput, flags script.ScriptFlags,
+	bv *schnorr.BatchVerifier) []error {
+	errs := make([]error, len(inputs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = inputs[i].validate(flags, bv)
+			}
+		}()
+	}
+	for i := range inputs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return errs
+}
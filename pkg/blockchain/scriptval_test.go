@@ -0,0 +1,208 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+	"github.com/jacobkaufmann/gocoin/pkg/script"
+	"github.com/jacobkaufmann/gocoin/pkg/util"
+)
+
+// testKey returns the private key with the number k.
+func testKey(k byte) *btcec.PrivateKey {
+	priv, _ := btcec.PrivKeyFromBytes([]byte{k})
+	return priv
+}
+
+// taprootLock returns the locking script of a taproot output without scripts
+// whose internal key is the public key of priv.
+func taprootLock(t *testing.T, priv *btcec.PrivateKey) []byte {
+	outputKey, err := script.ComputeTaprootOutputKey(priv.PubKey(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := script.PayToTaprootScript(schnorr.SerializePubKey(outputKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lock
+}
+
+// scriptTestBlock returns a block of a coinbase followed by n transactions,
+// together with the outputs they spend. Transaction i spends a taproot key
+// path output of key i+1 and is signed by signers[i] if it is set, which
+// makes its signature invalid unless it is the same key. The outputs listed
+// in falseLocks are locked by OP_FALSE instead, so their spends fail.
+func scriptTestBlock(t *testing.T, n int, signers map[int]byte,
+	falseLocks ...int) (*util.Block, *script.MultiPrevOutFetcher) {
+	prevOuts := script.NewMultiPrevOutFetcher()
+	txns := []*util.Tx{coinbaseTx(0, 50, []byte{byte(script.OpTrue)})}
+	for i := 0; i < n; i++ {
+		key := testKey(byte(i + 1))
+		prevOut := &protocol.TxOut{Value: int64(1000 + i),
+			ScriptLock: taprootLock(t, key)}
+		falseLock := false
+		for _, j := range falseLocks {
+			if i == j {
+				prevOut.ScriptLock = []byte{byte(script.OpFalse)}
+				falseLock = true
+			}
+		}
+		outPoint := protocol.TxOutPoint{
+			Hash:  &[protocol.HashSize]byte{byte(i + 1)},
+			Index: uint32(i),
+		}
+		prevOuts.AddPrevOut(outPoint, prevOut)
+
+		in := &protocol.TxIn{PrevOutput: outPoint, Sequence: 0xffffffff}
+		out := &protocol.TxOut{Value: prevOut.Value - 100,
+			ScriptLock: []byte{byte(script.OpTrue)}}
+		tx := util.NewTx(2, []*protocol.TxIn{in}, []*protocol.TxOut{out}, 0)
+		txns = append(txns, tx)
+		if falseLock {
+			continue
+		}
+
+		if signer, ok := signers[i]; ok {
+			key = testKey(signer)
+		}
+		msg := tx.Message()
+		sigHashes, err := script.NewTxSigHashes(msg, prevOuts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := script.RawTxInTaprootSignature(msg, sigHashes, 0, prevOut,
+			nil, script.SigHashDefault, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in.Witness = protocol.TxWitness{sig}
+	}
+	return &util.Block{Txns: txns}, prevOuts
+}
+
+// tapscriptTx returns a transaction spending an output whose script tree
+// holds only leaf through that leaf, with the signature by signer as the
+// only other witness item, and adds the output to prevOuts.
+func tapscriptTx(t *testing.T, prevOuts *script.MultiPrevOutFetcher,
+	leaf []byte, signer *btcec.PrivateKey) *util.Tx {
+	internalKey := testKey(1).PubKey()
+	leafHash := script.TapLeafHash(script.BaseLeafVersion, leaf)
+	outputKey, err := script.ComputeTaprootOutputKey(internalKey, leafHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := script.PayToTaprootScript(schnorr.SerializePubKey(outputKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevOut := &protocol.TxOut{Value: 1000, ScriptLock: lock}
+	outPoint := protocol.TxOutPoint{Hash: &[protocol.HashSize]byte{0xff}}
+	prevOuts.AddPrevOut(outPoint, prevOut)
+
+	in := &protocol.TxIn{PrevOutput: outPoint, Sequence: 0xffffffff}
+	out := &protocol.TxOut{Value: prevOut.Value - 100,
+		ScriptLock: []byte{byte(script.OpTrue)}}
+	tx := util.NewTx(2, []*protocol.TxIn{in}, []*protocol.TxOut{out}, 0)
+	msg := tx.Message()
+	sigHashes, err := script.NewTxSigHashes(msg, prevOuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := script.RawTxInTapscriptSignature(msg, sigHashes, 0, prevOut,
+		leafHash, script.SigHashDefault, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controlBlock := append([]byte{script.BaseLeafVersion | byte(outputKey.Y.Bit(0))},
+		schnorr.SerializePubKey(internalKey)...)
+	in.Witness = protocol.TxWitness{sig, leaf, controlBlock}
+	return tx
+}
+
+func TestValidateBlockScripts(t *testing.T) {
+	blk, prevOuts := scriptTestBlock(t, 8, nil)
+	err := ValidateBlockScripts(blk, prevOuts, script.ConsensusVerifyFlags)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestValidateBlockScriptsInvalid checks that the inputs with invalid
+// signatures, which only the fallback from the failed batch finds, are
+// reported in block order along with inputs which fail outright.
+func TestValidateBlockScriptsInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		signers    map[int]byte
+		falseLocks []int
+		txIdx      int
+		code       script.ErrorCode
+	}{
+		{"one bad signature", map[int]byte{4: 99}, nil, 5,
+			script.ErrCodeTaprootSigInvalid},
+		{"first bad signature", map[int]byte{6: 99, 1: 99}, nil, 2,
+			script.ErrCodeTaprootSigInvalid},
+		{"first bad input", map[int]byte{5: 99}, []int{2}, 3,
+			script.ErrCodeEvalFalse},
+		{"bad signature before bad input", map[int]byte{1: 99}, []int{6}, 2,
+			script.ErrCodeTaprootSigInvalid},
+	}
+	for _, test := range tests {
+		blk, prevOuts := scriptTestBlock(t, 8, test.signers, test.falseLocks...)
+		err := ValidateBlockScripts(blk, prevOuts, script.ConsensusVerifyFlags)
+		var serr *ScriptError
+		if !errors.As(err, &serr) {
+			t.Errorf("%s: got %v, want a ScriptError", test.name, err)
+			continue
+		}
+		if serr.TxIndex != test.txIdx || serr.InputIndex != 0 {
+			t.Errorf("%s: got input %d of transaction %d, want input 0 "+
+				"of transaction %d", test.name, serr.InputIndex,
+				serr.TxIndex, test.txIdx)
+		}
+		if !script.IsErrorCode(err, test.code) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.code)
+		}
+	}
+}
+
+// TestValidateBlockScriptsFailedInputSignature checks that an input which
+// adds an invalid signature to the batch and then fails is reported, rather
+// than the failed batch, which no input that succeeded accounts for.
+func TestValidateBlockScriptsFailedInputSignature(t *testing.T) {
+	blk, prevOuts := scriptTestBlock(t, 4, nil)
+	leaf, err := script.NewScriptBuilder().
+		AddData(schnorr.SerializePubKey(testKey(3).PubKey())).
+		AddOp(byte(script.OpCheckSigVerify)).AddOp(byte(script.OpFalse)).
+		Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk.Txns = append(blk.Txns, tapscriptTx(t, prevOuts, leaf, testKey(2)))
+
+	err = ValidateBlockScripts(blk, prevOuts, script.ConsensusVerifyFlags)
+	var serr *ScriptError
+	if !errors.As(err, &serr) || serr.TxIndex != 5 {
+		t.Fatalf("got %v, want the input of transaction 5", err)
+	}
+	if !script.IsErrorCode(err, script.ErrCodeEvalFalse) {
+		t.Fatalf("got %v, want %v", err, script.ErrCodeEvalFalse)
+	}
+}
+
+func TestValidateBlockScriptsMissingPrevOutput(t *testing.T) {
+	blk, _ := scriptTestBlock(t, 2, nil)
+	err := ValidateBlockScripts(blk, script.NewMultiPrevOutFetcher(),
+		script.ConsensusVerifyFlags)
+	if !errors.Is(err, ErrMissingPrevOutput) {
+		t.Fatalf("got %v, want %v", err, ErrMissingPrevOutput)
+	}
+	var serr *ScriptError
+	if !errors.As(err, &serr) || serr.TxIndex != 1 {
+		t.Fatalf("got %v, want the input of transaction 1", err)
+	}
+}
@@ -0,0 +1,136 @@
+package blockchain
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/util"
+)
+
+// ErrTxNotIndexed is returned when a transaction is not found in the
+// transaction index.
+var ErrTxNotIndexed = errors.New("transaction not found in index")
+
+// A TxLocation identifies the position of a transaction in the main chain.
+type TxLocation struct {
+	BlockHash hashing.Hash
+	Height    uint64
+	TxIndex   uint32
+}
+
+// TxIndex maps the transaction id of every transaction in the main chain to
+// its location. Two pairs of historical coinbase transactions share a
+// transaction id (see BIP30); the index returns the most recent location of
+// such a transaction and restores the earlier one when the later block is
+// disconnected. TxIndex implements the Indexer interface.
+type TxIndex struct {
+	// txns holds the locations of each transaction id in the order the
+	// blocks containing them were connected.
+	txns map[hashing.Hash][]*TxLocation
+	pver uint32
+	mux  sync.RWMutex
+}
+
+// NewTxIndex returns an empty transaction index which computes transaction
+// ids with protocol version pver.
+func NewTxIndex(pver uint32) *TxIndex {
+	return &TxIndex{
+		txns: make(map[hashing.Hash][]*TxLocation),
+		pver: pver,
+	}
+}
+
+// Name returns the name of the transaction index.
+func (idx *TxIndex) Name() string {
+	return "transaction index"
+}
+
+// ConnectBlock adds the location of each transaction in blk to the index.
+func (idx *TxIndex) ConnectBlock(blk *util.Block, blkHash *hashing.Hash,
+	height uint64) error {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	for i, tx := range blk.Txns {
+		txID, err := tx.TxID(idx.pver)
+		if err != nil {
+			return err
+		}
+		idx.txns[*txID] = append(idx.txns[*txID], &TxLocation{
+			BlockHash: *blkHash,
+			Height:    height,
+			TxIndex:   uint32(i),
+		})
+	}
+	return nil
+}
+
+// DisconnectBlock removes each transaction in blk from the index, restoring
+// the previous location of a duplicated transaction id.
+func (idx *TxIndex) DisconnectBlock(blk *util.Block, blkHash *hashing.Hash,
+	height uint64) error {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	for _, tx := range blk.Txns {
+		txID, err := tx.TxID(idx.pver)
+		if err != nil {
+			return err
+		}
+
+		// Only remove the location if it belongs to this block, since
+		// duplicate transaction ids exist in the chain.
+		locs := idx.txns[*txID]
+		n := len(locs)
+		if n == 0 || locs[n-1].BlockHash != *blkHash {
+			continue
+		}
+		if n == 1 {
+			delete(idx.txns, *txID)
+		} else {
+			idx.txns[*txID] = locs[:n-1]
+		}
+	}
+	return nil
+}
+
+// Reset removes all transactions from the index.
+func (idx *TxIndex) Reset() {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	idx.txns = make(map[hashing.Hash][]*TxLocation)
+}
+
+// Get returns the location of the transaction specified by id if it exists
+// in the index.
+func (idx *TxIndex) Get(id *hashing.Hash) *TxLocation {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	locs := idx.txns[*id]
+	if len(locs) == 0 {
+		return nil
+	}
+	locCopy := *locs[len(locs)-1]
+	return &locCopy
+}
+
+// FetchTx looks up the transaction specified by id in the index and loads it
+// from store.
+func (idx *TxIndex) FetchTx(id *hashing.Hash, store BlockStore) (*util.Tx,
+	*TxLocation, error) {
+	loc := idx.Get(id)
+	if loc == nil {
+		return nil, nil, ErrTxNotIndexed
+	}
+
+	blk, err := store.BlockByHeight(loc.Height)
+	if err != nil {
+		return nil, nil, err
+	}
+	if int(loc.TxIndex) >= len(blk.Txns) {
+		return nil, nil, ErrTxNotIndexed
+	}
+	return blk.Txns[loc.TxIndex], loc, nil
+}
@@ -0,0 +1,120 @@
+package btcec
+
+import (
+	"errors"
+	"math/big"
+)
+
+// CompactSigLen is the length of a compact signature: a header byte followed
+// by R and S as 32-byte big-endian numbers.
+const CompactSigLen = 65
+
+// The header byte of a compact signature is compactSigMagicOffset plus the
+// recovery ID, plus compactSigCompPubKey if the signing key is serialized
+// compressed.
+const (
+	compactSigMagicOffset = 27
+	compactSigCompPubKey  = 4
+)
+
+var (
+	// errInvalidCompactSignature indicates a compact signature with the
+	// wrong length or an unknown header byte.
+	errInvalidCompactSignature = errors.New("invalid compact signature")
+
+	// errNoRecovery indicates a signature and recovery ID from which no
+	// public key can be recovered.
+	errNoRecovery = errors.New("no public key recoverable from signature")
+)
+
+// SignCompact returns the compact signature of hash by key, from which the
+// public key can be recovered with RecoverCompact. isCompressedKey records
+// whether the key is serialized compressed, which determines the address
+// the signature is checked against.
+func SignCompact(key *PrivateKey, hash []byte, isCompressedKey bool) ([]byte,
+	error) {
+	sig, recID, err := key.sign(hash, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	header := compactSigMagicOffset + recID
+	if isCompressedKey {
+		header += compactSigCompPubKey
+	}
+	b := make([]byte, 0, CompactSigLen)
+	b = append(b, header)
+	b = paddedAppend(32, b, sig.R.Bytes())
+	return paddedAppend(32, b, sig.S.Bytes()), nil
+}
+
+// RecoverCompact returns the public key which produced the compact signature
+// of hash and whether the key is serialized compressed.
+func RecoverCompact(signature, hash []byte) (*PublicKey, bool, error) {
+	if len(signature) != CompactSigLen {
+		return nil, false, errInvalidCompactSignature
+	}
+	header := signature[0]
+	if header < compactSigMagicOffset ||
+		header >= compactSigMagicOffset+2*compactSigCompPubKey {
+		return nil, false, errInvalidCompactSignature
+	}
+	recID := (header - compactSigMagicOffset) % compactSigCompPubKey
+	compressed := header-compactSigMagicOffset >= compactSigCompPubKey
+
+	sig := &Signature{
+		R: new(big.Int).SetBytes(signature[1:33]),
+		S: new(big.Int).SetBytes(signature[33:]),
+	}
+	pubKey, err := RecoverPubKey(sig, hash, recID)
+	if err != nil {
+		return nil, false, err
+	}
+	return pubKey, compressed, nil
+}
+
+// RecoverPubKey returns the public key for which sig is a valid signature of
+// hash, given the recovery ID produced when signing: bit 0 is the parity of
+// the y coordinate of the nonce point R and bit 1 is set if its x coordinate
+// is r plus the curve order.
+func RecoverPubKey(sig *Signature, hash []byte, recID byte) (*PublicKey,
+	error) {
+	curve := S256()
+	if recID > 3 || sig.R.Sign() <= 0 || sig.R.Cmp(curve.N) >= 0 ||
+		sig.S.Sign() <= 0 || sig.S.Cmp(curve.N) >= 0 {
+		return nil, errNoRecovery
+	}
+
+	// Rebuild R from its x coordinate and parity.
+	rx := new(big.Int).Set(sig.R)
+	if recID&2 != 0 {
+		rx.Add(rx, curve.N)
+		if rx.Cmp(curve.P) >= 0 {
+			return nil, errNoRecovery
+		}
+	}
+	ry, err := curve.decompressY(rx, recID&1 == 1)
+	if err != nil {
+		return nil, errNoRecovery
+	}
+	point := fromAffine(fieldFromBig(rx), fieldFromBig(ry))
+
+	// Q = (s*R - z*G) / r.
+	var rb, sb [32]byte
+	sig.R.FillBytes(rb[:])
+	sig.S.FillBytes(sb[:])
+	r, _ := scalarFromBytes(&rb)
+	s, _ := scalarFromBytes(&sb)
+	rInv := r.inverse()
+	u1 := hashToScalar(hash).mul(rInv).neg()
+	u2 := s.mul(rInv)
+
+	a := scalarBaseMult(u1)
+	b := scalarMult(u2, &point)
+	q := a.add(&b)
+	if q.isInfinity() == 1 {
+		return nil, errNoRecovery
+	}
+	x, y := fromJacobian(&q)
+	return &PublicKey{Curve: curve, X: x, Y: y}, nil
+}
@@ -0,0 +1,143 @@
+package btcec
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+// TestSignCompact checks that the key and its serialization are recovered
+// from compact signatures made with either parity of the nonce point.
+func TestSignCompact(t *testing.T) {
+	priv, pub := PrivKeyFromBytes([]byte{0x01})
+	for _, compressed := range []bool{true, false} {
+		var seen [2]bool
+		for i := 0; i < 64 && !(seen[0] && seen[1]); i++ {
+			hash := sha256.Sum256([]byte{byte(i)})
+			sig, err := SignCompact(priv, hash[:], compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sig) != CompactSigLen {
+				t.Fatalf("signature of %d bytes, want %d", len(sig), CompactSigLen)
+			}
+
+			recID := sig[0] - compactSigMagicOffset
+			if compressed {
+				recID -= compactSigCompPubKey
+			}
+			if recID > 1 {
+				t.Fatalf("header %d, compressed %v", sig[0], compressed)
+			}
+			seen[recID] = true
+
+			pubKey, wasCompressed, err := RecoverCompact(sig, hash[:])
+			if err != nil {
+				t.Fatalf("recovery ID %d: %v", recID, err)
+			}
+			if !pubKey.IsEqual(pub) || wasCompressed != compressed {
+				t.Errorf("recovery ID %d: got %x compressed %v", recID,
+					pubKey.SerializeCompressed(), wasCompressed)
+			}
+		}
+		if !seen[0] || !seen[1] {
+			t.Errorf("compressed %v: recovery IDs seen %v", compressed, seen)
+		}
+	}
+}
+
+// TestRecoverPubKey checks recovery with each recovery ID. Since both 2 and
+// the curve order plus 2 are x coordinates of points, r = 2 recovers a
+// different key for every recovery ID, and the signature is valid for each.
+// Recovery IDs 2 and 3 cannot practically come from signing, as the x
+// coordinate of the nonce point exceeds the curve order with negligible
+// probability.
+func TestRecoverPubKey(t *testing.T) {
+	hash := sha256.Sum256([]byte("recovery"))
+	sig := &Signature{R: big.NewInt(2), S: big.NewInt(3)}
+
+	var keys []*PublicKey
+	for recID := byte(0); recID < 4; recID++ {
+		pubKey, err := RecoverPubKey(sig, hash[:], recID)
+		if err != nil {
+			t.Fatalf("recovery ID %d: %v", recID, err)
+		}
+		if !sig.Verify(hash[:], pubKey) {
+			t.Errorf("recovery ID %d: signature does not verify", recID)
+		}
+		for i, key := range keys {
+			if key.IsEqual(pubKey) {
+				t.Errorf("recovery IDs %d and %d recover the same key", i, recID)
+			}
+		}
+		keys = append(keys, pubKey)
+
+		// The compact form gives the same key with either serialization.
+		for _, compressed := range []bool{true, false} {
+			compact := make([]byte, CompactSigLen)
+			compact[0] = compactSigMagicOffset + recID
+			if compressed {
+				compact[0] += compactSigCompPubKey
+			}
+			sig.R.FillBytes(compact[1:33])
+			sig.S.FillBytes(compact[33:])
+			got, wasCompressed, err := RecoverCompact(compact, hash[:])
+			if err != nil {
+				t.Fatalf("header %d: %v", compact[0], err)
+			}
+			if !got.IsEqual(pubKey) || wasCompressed != compressed {
+				t.Errorf("header %d: got %x compressed %v", compact[0],
+					got.SerializeCompressed(), wasCompressed)
+			}
+		}
+	}
+}
+
+func TestRecoverPubKeyInvalid(t *testing.T) {
+	hash := sha256.Sum256([]byte("recovery"))
+	n := secp256k1.N
+	tests := []struct {
+		name  string
+		r, s  *big.Int
+		recID byte
+	}{
+		{"zero r", big.NewInt(0), big.NewInt(1), 0},
+		{"zero s", big.NewInt(2), big.NewInt(0), 0},
+		{"r of the curve order", new(big.Int).Set(n), big.NewInt(1), 0},
+		{"s of the curve order", big.NewInt(2), new(big.Int).Set(n), 0},
+		{"recovery ID 4", big.NewInt(2), big.NewInt(1), 4},
+		{"x without a point", big.NewInt(5), big.NewInt(1), 0},
+		{"x beyond p", new(big.Int).Sub(secp256k1.P, n), big.NewInt(1), 2},
+	}
+	for _, test := range tests {
+		sig := &Signature{R: test.r, S: test.s}
+		if _, err := RecoverPubKey(sig, hash[:], test.recID); err != errNoRecovery {
+			t.Errorf("%s: got %v, want %v", test.name, err, errNoRecovery)
+		}
+	}
+}
+
+func TestRecoverCompactInvalid(t *testing.T) {
+	hash := sha256.Sum256([]byte("recovery"))
+	priv, _ := PrivKeyFromBytes([]byte{0x01})
+	sig, err := SignCompact(priv, hash[:], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		sig  []byte
+	}{
+		{"short", sig[:CompactSigLen-1]},
+		{"long", append(append([]byte(nil), sig...), 0)},
+		{"header below range", append([]byte{compactSigMagicOffset - 1}, sig[1:]...)},
+		{"header above range", append([]byte{compactSigMagicOffset + 8}, sig[1:]...)},
+	}
+	for _, test := range tests {
+		if _, _, err := RecoverCompact(test.sig, hash[:]); err != errInvalidCompactSignature {
+			t.Errorf("%s: got %v, want %v", test.name, err,
+				errInvalidCompactSignature)
+		}
+	}
+}
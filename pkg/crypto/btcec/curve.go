@@ -0,0 +1,145 @@
+package btcec
+
+import (
+	"crypto/elliptic"
+	"math/big"
+)
+
+// A KoblitzCurve is an elliptic curve of the form y² = x³ + b over a prime
+// field. The curve operations of elliptic.CurveParams assume a = -3 and give
+// wrong results for such curves, so KoblitzCurve implements its own on top of
+// native constant-time secp256k1 arithmetic. KoblitzCurve implements the
+// elliptic.Curve interface.
+type KoblitzCurve struct {
+	*elliptic.CurveParams
+}
+
+// secp256k1 holds the parameters of the secp256k1 curve used by Bitcoin.
+var secp256k1 = &KoblitzCurve{
+	CurveParams: &elliptic.CurveParams{
+		P:       fromHex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F"),
+		N:       fromHex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141"),
+		B:       fromHex("0000000000000000000000000000000000000000000000000000000000000007"),
+		Gx:      fromHex("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798"),
+		Gy:      fromHex("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8"),
+		BitSize: 256,
+		Name:    "secp256k1",
+	},
+}
+
+// S256 returns the secp256k1 curve, the default curve for keys and
+// signatures.
+func S256() *KoblitzCurve {
+	return secp256k1
+}
+
+// fromHex converts a hexadecimal string into a big.Int. It panics on invalid
+// input and is only used for curve constants.
+func fromHex(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid hex in source file: " + s)
+	}
+	return n
+}
+
+// Params returns the parameters of the curve.
+func (c *KoblitzCurve) Params() *elliptic.CurveParams {
+	return c.CurveParams
+}
+
+// IsOnCurve returns whether the point (x, y) lies on the curve.
+func (c *KoblitzCurve) IsOnCurve(x, y *big.Int) bool {
+	if x.Sign() < 0 || x.Cmp(c.P) >= 0 || y.Sign() < 0 || y.Cmp(c.P) >= 0 {
+		return false
+	}
+
+	// y² = x³ + b
+	fy := fieldFromBig(y)
+	return fy.square().equal(polynomial(fieldFromBig(x))) == 1
+}
+
+// polynomial returns x³ + b.
+func polynomial(x fieldVal) fieldVal {
+	return x.square().mul(x).add(fieldVal{7})
+}
+
+// isInfinity returns whether (x, y) is the point at infinity, which is
+// represented as (0, 0).
+func isInfinity(x, y *big.Int) bool {
+	return x.Sign() == 0 && y.Sign() == 0
+}
+
+// toJacobian returns the affine point (x, y) in Jacobian coordinates.
+func toJacobian(x, y *big.Int) jacobianPoint {
+	if isInfinity(x, y) {
+		return infinity
+	}
+	return fromAffine(fieldFromBig(x), fieldFromBig(y))
+}
+
+// fromJacobian returns the affine coordinates of p.
+func fromJacobian(p *jacobianPoint) (*big.Int, *big.Int) {
+	x, y := p.toAffine()
+	return x.big(), y.big()
+}
+
+// Add returns the sum of (x1, y1) and (x2, y2).
+func (c *KoblitzCurve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	p, q := toJacobian(x1, y1), toJacobian(x2, y2)
+	r := p.add(&q)
+	return fromJacobian(&r)
+}
+
+// Double returns 2 * (x, y).
+func (c *KoblitzCurve) Double(x, y *big.Int) (*big.Int, *big.Int) {
+	p := toJacobian(x, y)
+	r := p.double()
+	return fromJacobian(&r)
+}
+
+// ScalarMult returns k * (x, y), where k is a big-endian integer.
+func (c *KoblitzCurve) ScalarMult(x, y *big.Int, k []byte) (*big.Int,
+	*big.Int) {
+	p := toJacobian(x, y)
+	r := scalarMult(scalarFromSlice(k), &p)
+	return fromJacobian(&r)
+}
+
+// ScalarBaseMult returns k * G, where G is the base point of the curve and k
+// is a big-endian integer.
+func (c *KoblitzCurve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
+	r := scalarBaseMult(scalarFromSlice(k))
+	return fromJacobian(&r)
+}
+
+// MultiScalarMult returns k_1⋅(x_1, y_1) + ... + k_n⋅(x_n, y_n), where the
+// points and scalars are given by xs, ys and ks, which have the same length,
+// and the k_i are big-endian integers. It costs a fraction of computing each
+// product separately, but unlike ScalarMult it is variable time, so it must
+// only be used with public scalars, as when verifying signatures.
+func (c *KoblitzCurve) MultiScalarMult(xs, ys []*big.Int, ks [][]byte) (*big.Int,
+	*big.Int) {
+	scalars := make([]scalar, len(ks))
+	points := make([]jacobianPoint, len(ks))
+	for i := range ks {
+		scalars[i] = scalarFromSlice(ks[i])
+		points[i] = toJacobian(xs[i], ys[i])
+	}
+	r := multiScalarMult(scalars, points)
+	return fromJacobian(&r)
+}
+
+// decompressY returns the y coordinate with the given parity of the point on
+// the curve with x coordinate x. An error is returned if no such point
+// exists.
+func (c *KoblitzCurve) decompressY(x *big.Int, odd bool) (*big.Int, error) {
+	y, ok := polynomial(fieldFromBig(x)).sqrt()
+	if !ok {
+		return nil, errPubKeyNotOnCurve
+	}
+	if y.isOdd() != odd {
+		y = y.neg()
+	}
+	return y.big(), nil
+}
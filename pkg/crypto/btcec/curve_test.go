@@ -0,0 +1,246 @@
+package btcec
+
+import (
+	"math/big"
+	"testing"
+)
+
+// baseMultTests are multiples k * G of the generator.
+var baseMultTests = []struct {
+	k, x, y string
+}{
+	{"1",
+		"79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		"483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"},
+	{"2",
+		"c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5",
+		"1ae168fea63dc339a3c58419466ceaeef7f632653266d0e1236431a950cfe52a"},
+	{"3",
+		"f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+		"388f7b0f632de8140fe337e62a37f3566500a99934c2231b6cb9fd7584b8e672"},
+	{"4",
+		"e493dbf1c10d80f3581e4904930b1404cc6c13900ee0758474fa94abe8c4cd13",
+		"51ed993ea0d455b75642e2098ea51448d967ae33bfbdfe40cfe97bdc47739922"},
+	{"5",
+		"2f8bde4d1a07209355b4a7250a5c5128e88b84bddc619ab7cba8d569b240efe4",
+		"d8ac222636e5e3d6d4dba9dda6c9c426f788271bab0d6840dca87d3aa6ac62d6"},
+	{"7",
+		"5cbdf0646e5db4eaa398f365f2ea7a0e3d419b7e0330e39ce92bddedcac4f9bc",
+		"6aebca40ba255960a3178d6d861a54dba813d0b813fde7b5a5082628087264da"},
+	{"14",
+		"4ce119c96e2fa357200b559b2f7dd5a5f02d5290aff74b03f3e471b273211c97",
+		"12ba26dcb10ec1625da61fa10a844c676162948271d96967450288ee9233dc3a"},
+	{"18ebbb95eed0e13",
+		"a90cc3d3f3e146daadfc74ca1372207cb4b725ae708cef713a98edd73d99ef29",
+		"5a79d6b289610c68bc3b47f3d72f9788a26a06868b4d8e433e1e2ad76fb7dc76"},
+	{"100000000000000000000000000000000",
+		"8f68b9d2f63b5f339239c1ad981f162ee88c5678723ea3351b7b444c9ec4c0da",
+		"662a9f2dba063986de1d90c2b6be215dbbea2cfe95510bfdf23cbf79501fff82"},
+	{"aa5e28d6a97a2479a65527f7290311a3624d4cc0fa1578598ee3c2613bf99522",
+		"34f9460f0e4f08393d192b3c5133a6ba099aa0ad9fd54ebccfacdfa239ff49c6",
+		"0b71ea9bd730fd8923f6d25a7a91e7dd7728a960686cb5a901bb419e0f2ca232"},
+	{"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd036413f",
+		"c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5",
+		"e51e970159c23cc65c3a7be6b99315110809cd9acd992f1edc9bce55af301705"},
+	{"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140",
+		"79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		"b7c52588d95c3b9aa25b0403f1eef75702e84bb7597aabe663b82f6f04ef2777"},
+
+	// Multiples of the order are the point at infinity.
+	{"0", "0", "0"},
+	{"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141",
+		"0", "0"},
+}
+
+func TestScalarBaseMult(t *testing.T) {
+	curve := S256()
+	for _, test := range baseMultTests {
+		k, x, y := fromHex(test.k), fromHex(test.x), fromHex(test.y)
+		gotX, gotY := curve.ScalarBaseMult(k.Bytes())
+		if gotX.Cmp(x) != 0 || gotY.Cmp(y) != 0 {
+			t.Errorf("%s * G: got (%x, %x), want (%x, %x)", test.k, gotX,
+				gotY, x, y)
+		}
+		if !isInfinity(x, y) && !curve.IsOnCurve(gotX, gotY) {
+			t.Errorf("%s * G is not on the curve", test.k)
+		}
+	}
+}
+
+// TestScalarMultAgreement checks that the precomputed table of the
+// generator, the generic fixed-window multiplication and the variable-time
+// multi-scalar multiplication all give the same multiples of the generator
+// and of another point.
+func TestScalarMultAgreement(t *testing.T) {
+	curve := S256()
+	px, py := curve.ScalarBaseMult([]byte{7})
+
+	var scalars [][]byte
+	for _, test := range baseMultTests {
+		scalars = append(scalars, fromHex(test.k).Bytes())
+	}
+	for _, k := range testValues(curve.N) {
+		scalars = append(scalars, k.Bytes())
+	}
+
+	for _, k := range scalars {
+		baseX, baseY := curve.ScalarBaseMult(k)
+		genX, genY := curve.ScalarMult(curve.Gx, curve.Gy, k)
+		multiX, multiY := curve.MultiScalarMult([]*big.Int{curve.Gx},
+			[]*big.Int{curve.Gy}, [][]byte{k})
+		if genX.Cmp(baseX) != 0 || genY.Cmp(baseY) != 0 {
+			t.Errorf("%x * G: generic (%x, %x), table (%x, %x)", k, genX,
+				genY, baseX, baseY)
+		}
+		if multiX.Cmp(baseX) != 0 || multiY.Cmp(baseY) != 0 {
+			t.Errorf("%x * G: multi (%x, %x), table (%x, %x)", k, multiX,
+				multiY, baseX, baseY)
+		}
+
+		// k * (7 * G) is 7k * G.
+		k7 := new(big.Int).Mul(new(big.Int).SetBytes(k), big.NewInt(7))
+		wantX, wantY := curve.ScalarBaseMult(k7.Mod(k7, curve.N).Bytes())
+		gotX, gotY := curve.ScalarMult(px, py, k)
+		if gotX.Cmp(wantX) != 0 || gotY.Cmp(wantY) != 0 {
+			t.Errorf("%x * 7G: got (%x, %x), want (%x, %x)", k, gotX, gotY,
+				wantX, wantY)
+		}
+		multiX, multiY = curve.MultiScalarMult([]*big.Int{px}, []*big.Int{py},
+			[][]byte{k})
+		if multiX.Cmp(wantX) != 0 || multiY.Cmp(wantY) != 0 {
+			t.Errorf("%x * 7G: multi (%x, %x), want (%x, %x)", k, multiX,
+				multiY, wantX, wantY)
+		}
+	}
+
+	// A sum of products, with scalars which cancel out.
+	n1 := new(big.Int).Sub(curve.N, big.NewInt(1)).Bytes()
+	x, y := curve.MultiScalarMult([]*big.Int{curve.Gx, curve.Gx},
+		[]*big.Int{curve.Gy, curve.Gy}, [][]byte{{1}, n1})
+	if !isInfinity(x, y) {
+		t.Errorf("G + (n-1) * G: got (%x, %x), want infinity", x, y)
+	}
+	x, y = curve.MultiScalarMult([]*big.Int{curve.Gx, px},
+		[]*big.Int{curve.Gy, py}, [][]byte{{3}, {2}})
+	wantX, wantY := curve.ScalarBaseMult([]byte{17})
+	if x.Cmp(wantX) != 0 || y.Cmp(wantY) != 0 {
+		t.Errorf("3G + 2 * 7G: got (%x, %x), want (%x, %x)", x, y, wantX, wantY)
+	}
+}
+
+func TestAddDouble(t *testing.T) {
+	curve := S256()
+	point := func(k int64) (*big.Int, *big.Int) {
+		return curve.ScalarBaseMult(big.NewInt(k).Bytes())
+	}
+	gx, gy := point(1)
+	g2x, g2y := point(2)
+	g3x, g3y := point(3)
+	negGx, negGy := gx, new(big.Int).Sub(curve.P, gy)
+	zero := new(big.Int)
+
+	tests := []struct {
+		name         string
+		x1, y1       *big.Int
+		x2, y2       *big.Int
+		wantX, wantY *big.Int
+	}{
+		{"G + G", gx, gy, gx, gy, g2x, g2y},
+		{"G + 2G", gx, gy, g2x, g2y, g3x, g3y},
+		{"2G + G", g2x, g2y, gx, gy, g3x, g3y},
+		{"G + -G", gx, gy, negGx, negGy, zero, zero},
+		{"G + infinity", gx, gy, zero, zero, gx, gy},
+		{"infinity + G", zero, zero, gx, gy, gx, gy},
+		{"infinity + infinity", zero, zero, zero, zero, zero, zero},
+	}
+	for _, test := range tests {
+		x, y := curve.Add(test.x1, test.y1, test.x2, test.y2)
+		if x.Cmp(test.wantX) != 0 || y.Cmp(test.wantY) != 0 {
+			t.Errorf("%s: got (%x, %x), want (%x, %x)", test.name, x, y,
+				test.wantX, test.wantY)
+		}
+	}
+
+	if x, y := curve.Double(gx, gy); x.Cmp(g2x) != 0 || y.Cmp(g2y) != 0 {
+		t.Errorf("2 * G: got (%x, %x), want (%x, %x)", x, y, g2x, g2y)
+	}
+	g4x, g4y := point(4)
+	if x, y := curve.Double(g2x, g2y); x.Cmp(g4x) != 0 || y.Cmp(g4y) != 0 {
+		t.Errorf("2 * 2G: got (%x, %x), want (%x, %x)", x, y, g4x, g4y)
+	}
+	if x, y := curve.Double(zero, zero); !isInfinity(x, y) {
+		t.Errorf("2 * infinity: got (%x, %x)", x, y)
+	}
+
+	// Adding the same point in different Jacobian representations takes
+	// the doubling case of the addition.
+	p := fromAffine(fieldFromBig(gx), fieldFromBig(gy))
+	q := p.double()
+	q = q.add(&p)
+	r := p.add(&p)
+	r = r.add(&p)
+	if x, y := fromJacobian(&q); x.Cmp(g3x) != 0 || y.Cmp(g3y) != 0 {
+		t.Errorf("2G + G: got (%x, %x), want (%x, %x)", x, y, g3x, g3y)
+	}
+	if x, y := fromJacobian(&r); x.Cmp(g3x) != 0 || y.Cmp(g3y) != 0 {
+		t.Errorf("(G + G) + G: got (%x, %x), want (%x, %x)", x, y, g3x, g3y)
+	}
+	g6x, g6y := point(6)
+	for name, sum := range map[string]jacobianPoint{
+		"add":    q.add(&r),
+		"addVar": q.addVar(&r),
+	} {
+		if x, y := fromJacobian(&sum); x.Cmp(g6x) != 0 || y.Cmp(g6y) != 0 {
+			t.Errorf("3G + 3G with %s: got (%x, %x), want (%x, %x)", name, x,
+				y, g6x, g6y)
+		}
+	}
+}
+
+func TestIsOnCurve(t *testing.T) {
+	curve := S256()
+	if !curve.IsOnCurve(curve.Gx, curve.Gy) {
+		t.Error("G is not on the curve")
+	}
+	if curve.IsOnCurve(curve.Gx, new(big.Int).Add(curve.Gy, big.NewInt(1))) {
+		t.Error("(Gx, Gy + 1) is on the curve")
+	}
+	// Coordinates must be reduced.
+	if curve.IsOnCurve(curve.Gx, new(big.Int).Add(curve.Gy, curve.P)) {
+		t.Error("(Gx, Gy + p) is on the curve")
+	}
+}
+
+// TestUnreducedCoordinates checks that the curve operations reduce
+// coordinates outside [0, p) rather than overflowing or miscomputing.
+func TestUnreducedCoordinates(t *testing.T) {
+	curve := S256()
+	gx, gy := curve.Gx, curve.Gy
+	g2x, g2y := curve.Double(gx, gy)
+	g3x, g3y := curve.ScalarBaseMult([]byte{3})
+	two256 := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	coords := []struct {
+		name string
+		x, y *big.Int
+	}{
+		{"x + p", new(big.Int).Add(gx, curve.P), gy},
+		{"y + p", gx, new(big.Int).Add(gy, curve.P)},
+		{"x + 2^256 p", new(big.Int).Add(gx, new(big.Int).Mul(two256, curve.P)), gy},
+		{"y - p", gx, new(big.Int).Sub(gy, curve.P)},
+	}
+	for _, c := range coords {
+		if x, y := curve.Double(c.x, c.y); x.Cmp(g2x) != 0 || y.Cmp(g2y) != 0 {
+			t.Errorf("2 * G with %s: got (%x, %x), want (%x, %x)", c.name, x,
+				y, g2x, g2y)
+		}
+		if x, y := curve.Add(c.x, c.y, g2x, g2y); x.Cmp(g3x) != 0 || y.Cmp(g3y) != 0 {
+			t.Errorf("G + 2G with %s: got (%x, %x), want (%x, %x)", c.name, x,
+				y, g3x, g3y)
+		}
+		if x, y := curve.ScalarMult(c.x, c.y, []byte{3}); x.Cmp(g3x) != 0 || y.Cmp(g3y) != 0 {
+			t.Errorf("3 * G with %s: got (%x, %x), want (%x, %x)", c.name, x,
+				y, g3x, g3y)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package btcec
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+)
+
+// The arithmetic in this file and scalar.go works on 256-bit numbers held as
+// four 64-bit limbs, least significant first. Both secp256k1 moduli are of
+// the form 2^256 - c for a small c, which allows fast reduction, and every
+// operation runs in time independent of the values involved: there are no
+// branches or table lookups on secret data.
+
+// mulAddTo adds the product a * b to r. r must be long enough to hold the
+// result.
+func mulAddTo(r, a, b []uint64) {
+	for i := range a {
+		var carry uint64
+		for j := range b {
+			hi, lo := bits.Mul64(a[i], b[j])
+			var c uint64
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			r[i+j], c = bits.Add64(r[i+j], lo, 0)
+			carry = hi + c
+		}
+		for k := i + len(b); k < len(r); k++ {
+			r[k], carry = bits.Add64(r[k], carry, 0)
+		}
+	}
+}
+
+// reduceWide returns the 512-bit number t reduced modulo 2^256 - c, where c
+// has at most three limbs.
+func reduceWide(t *[8]uint64, c []uint64) [4]uint64 {
+	// Since 2^256 = c modulo 2^256 - c, the high half of t may be
+	// multiplied by c and added to the low half. Three rounds leave at
+	// most one bit above 256 bits.
+	r := *t
+	for round := 0; round < 3; round++ {
+		next := [8]uint64{r[0], r[1], r[2], r[3]}
+		mulAddTo(next[:], r[4:], c)
+		r = next
+	}
+	return condSubtract([4]uint64{r[0], r[1], r[2], r[3]}, r[4], c)
+}
+
+// condSubtract returns the number a + carry * 2^256, which must be less than
+// twice the modulus 2^256 - c, reduced modulo 2^256 - c.
+func condSubtract(a [4]uint64, carry uint64, c []uint64) [4]uint64 {
+	// a - (2^256 - c) is a + c with the 2^256 dropped, and it is the
+	// result exactly when the addition overflows 256 bits.
+	var t [4]uint64
+	var k uint64
+	for i := range t {
+		var ci uint64
+		if i < len(c) {
+			ci = c[i]
+		}
+		t[i], k = bits.Add64(a[i], ci, k)
+	}
+	return selectLimbs(t, a, carry|k)
+}
+
+// addMod returns a + b modulo 2^256 - c, where a and b are reduced.
+func addMod(a, b [4]uint64, c []uint64) [4]uint64 {
+	var r [4]uint64
+	var carry uint64
+	for i := range r {
+		r[i], carry = bits.Add64(a[i], b[i], carry)
+	}
+	return condSubtract(r, carry, c)
+}
+
+// subMod returns a - b modulo 2^256 - c, where a and b are reduced.
+func subMod(a, b [4]uint64, c []uint64) [4]uint64 {
+	// On a borrow the result is off by 2^256 and adding the modulus
+	// means subtracting c.
+	var r [4]uint64
+	var borrow uint64
+	for i := range r {
+		r[i], borrow = bits.Sub64(a[i], b[i], borrow)
+	}
+	mask := -borrow
+	var k uint64
+	for i := range r {
+		var ci uint64
+		if i < len(c) {
+			ci = c[i]
+		}
+		r[i], k = bits.Sub64(r[i], ci&mask, k)
+	}
+	return r
+}
+
+// mulMod returns a * b modulo 2^256 - c, where a and b are reduced.
+func mulMod(a, b [4]uint64, c []uint64) [4]uint64 {
+	var t [8]uint64
+	mulAddTo(t[:], a[:], b[:])
+	return reduceWide(&t, c)
+}
+
+// selectLimbs returns a if cond is 1 and b if it is 0.
+func selectLimbs(a, b [4]uint64, cond uint64) [4]uint64 {
+	mask := -cond
+	var r [4]uint64
+	for i := range r {
+		r[i] = a[i]&mask | b[i]&^mask
+	}
+	return r
+}
+
+// isZeroLimbs returns 1 if a is zero and 0 otherwise.
+func isZeroLimbs(a [4]uint64) uint64 {
+	v := a[0] | a[1] | a[2] | a[3]
+	return 1 ^ (v|-v)>>63
+}
+
+// limbsFromBytes returns the 256-bit big-endian number b as limbs.
+func limbsFromBytes(b *[32]byte) [4]uint64 {
+	return [4]uint64{
+		binary.BigEndian.Uint64(b[24:]),
+		binary.BigEndian.Uint64(b[16:]),
+		binary.BigEndian.Uint64(b[8:]),
+		binary.BigEndian.Uint64(b[:]),
+	}
+}
+
+// limbsBytes returns the limbs a as a 256-bit big-endian number.
+func limbsBytes(a [4]uint64) [32]byte {
+	var b [32]byte
+	binary.BigEndian.PutUint64(b[24:], a[0])
+	binary.BigEndian.PutUint64(b[16:], a[1])
+	binary.BigEndian.PutUint64(b[8:], a[2])
+	binary.BigEndian.PutUint64(b[:], a[3])
+	return b
+}
+
+// fieldC is 2^256 - p, where p is the prime of the secp256k1 field.
+var fieldC = []uint64{0x1000003d1}
+
+// A fieldVal is an element of the secp256k1 field, always fully reduced.
+type fieldVal [4]uint64
+
+// fieldFromBig returns the field element of x reduced modulo p. Coordinates
+// reach the curve operations as arbitrary big.Ints, which would otherwise
+// overflow 32 bytes or break the invariant that field elements are fully
+// reduced.
+func fieldFromBig(x *big.Int) fieldVal {
+	if x.Sign() < 0 || x.Cmp(secp256k1.P) >= 0 {
+		x = new(big.Int).Mod(x, secp256k1.P)
+	}
+	var b [32]byte
+	x.FillBytes(b[:])
+	return fieldVal(limbsFromBytes(&b))
+}
+
+// fieldFromBytes returns the field element of the big-endian number b and
+// whether b was less than p.
+func fieldFromBytes(b *[32]byte) (fieldVal, bool) {
+	a := limbsFromBytes(b)
+	r := condSubtract(a, 0, fieldC)
+	return fieldVal(r), r == a
+}
+
+// big returns f as a big.Int.
+func (f fieldVal) big() *big.Int {
+	b := f.bytes()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// bytes returns f as a 32-byte big-endian number.
+func (f fieldVal) bytes() [32]byte {
+	return limbsBytes(f)
+}
+
+// add returns f + g.
+func (f fieldVal) add(g fieldVal) fieldVal {
+	return addMod(f, g, fieldC)
+}
+
+// sub returns f - g.
+func (f fieldVal) sub(g fieldVal) fieldVal {
+	return subMod(f, g, fieldC)
+}
+
+// neg returns -f.
+func (f fieldVal) neg() fieldVal {
+	return fieldVal{}.sub(f)
+}
+
+// mul returns f * g.
+func (f fieldVal) mul(g fieldVal) fieldVal {
+	return mulMod(f, g, fieldC)
+}
+
+// square returns f².
+func (f fieldVal) square() fieldVal {
+	return mulMod(f, f, fieldC)
+}
+
+// mulInt returns f * n for a small n.
+func (f fieldVal) mulInt(n uint64) fieldVal {
+	return f.mul(fieldVal{n})
+}
+
+// pow returns f raised to the power e, a public exponent.
+func (f fieldVal) pow(e [4]uint64) fieldVal {
+	r := fieldVal{1}
+	for i := 255; i >= 0; i-- {
+		r = r.square()
+		if e[i/64]>>uint(i%64)&1 == 1 {
+			r = r.mul(f)
+		}
+	}
+	return r
+}
+
+// inverse returns 1/f, computed as f^(p-2). The inverse of zero is zero.
+func (f fieldVal) inverse() fieldVal {
+	return f.pow([4]uint64{0xfffffffefffffc2d, 0xffffffffffffffff,
+		0xffffffffffffffff, 0xffffffffffffffff})
+}
+
+// sqrt returns a square root of f and whether f is a square. Since
+// p = 3 mod 4, a root of a square f is f^((p+1)/4).
+func (f fieldVal) sqrt() (fieldVal, bool) {
+	r := f.pow([4]uint64{0xffffffffbfffff0c, 0xffffffffffffffff,
+		0xffffffffffffffff, 0x3fffffffffffffff})
+	return r, r.square().equal(f) == 1
+}
+
+// isZero returns 1 if f is zero and 0 otherwise.
+func (f fieldVal) isZero() uint64 {
+	return isZeroLimbs(f)
+}
+
+// equal returns 1 if f equals g and 0 otherwise.
+func (f fieldVal) equal(g fieldVal) uint64 {
+	return isZeroLimbs([4]uint64{f[0] ^ g[0], f[1] ^ g[1], f[2] ^ g[2], f[3] ^ g[3]})
+}
+
+// isOdd returns whether f is odd.
+func (f fieldVal) isOdd() bool {
+	return f[0]&1 == 1
+}
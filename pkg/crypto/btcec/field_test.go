@@ -0,0 +1,196 @@
+package btcec
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// testValues returns numbers modulo m which exercise the carries and
+// reductions of the limb arithmetic: the smallest and largest values, values
+// with single limbs set or clear, and random values.
+func testValues(m *big.Int) []*big.Int {
+	one := big.NewInt(1)
+	vals := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(2),
+		new(big.Int).Sub(m, one),
+		new(big.Int).Sub(m, big.NewInt(2)),
+		new(big.Int).Rsh(m, 1),
+		new(big.Int).Add(new(big.Int).Rsh(m, 1), one),
+		new(big.Int).Lsh(one, 64),
+		new(big.Int).Sub(new(big.Int).Lsh(one, 64), one),
+		new(big.Int).Lsh(one, 128),
+		new(big.Int).Lsh(one, 255),
+		new(big.Int).Sub(new(big.Int).Lsh(one, 192), one),
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		vals = append(vals, new(big.Int).Rand(rng, m))
+	}
+	return vals
+}
+
+// bytes32 returns x as a 32-byte big-endian number.
+func bytes32(x *big.Int) *[32]byte {
+	var b [32]byte
+	x.FillBytes(b[:])
+	return &b
+}
+
+func TestFieldFromBytes(t *testing.T) {
+	p := secp256k1.P
+	one := big.NewInt(1)
+	max := new(big.Int).Sub(new(big.Int).Lsh(one, 256), one)
+	tests := []struct {
+		in      *big.Int
+		want    *big.Int
+		reduced bool
+	}{
+		{big.NewInt(0), big.NewInt(0), true},
+		{new(big.Int).Sub(p, one), new(big.Int).Sub(p, one), true},
+		{p, big.NewInt(0), false},
+		{new(big.Int).Add(p, one), one, false},
+		{max, new(big.Int).Sub(max, p), false},
+	}
+	for _, test := range tests {
+		f, ok := fieldFromBytes(bytes32(test.in))
+		if f.big().Cmp(test.want) != 0 || ok != test.reduced {
+			t.Errorf("%x: got %x %v, want %x %v", test.in, f.big(), ok,
+				test.want, test.reduced)
+		}
+	}
+}
+
+func TestFieldArithmetic(t *testing.T) {
+	p := secp256k1.P
+	vals := testValues(p)
+	for _, a := range vals {
+		fa := fieldFromBig(a)
+		if got, want := fa.neg().big(), new(big.Int).Mod(new(big.Int).Neg(a), p); got.Cmp(want) != 0 {
+			t.Errorf("-%x: got %x, want %x", a, got, want)
+		}
+		if got, want := fa.square().big(), new(big.Int).Mod(new(big.Int).Mul(a, a), p); got.Cmp(want) != 0 {
+			t.Errorf("%x²: got %x, want %x", a, got, want)
+		}
+		if got, want := fa.mulInt(8).big(), new(big.Int).Mod(new(big.Int).Lsh(a, 3), p); got.Cmp(want) != 0 {
+			t.Errorf("8 * %x: got %x, want %x", a, got, want)
+		}
+
+		for _, b := range vals {
+			fb := fieldFromBig(b)
+			sum := new(big.Int).Mod(new(big.Int).Add(a, b), p)
+			if got := fa.add(fb).big(); got.Cmp(sum) != 0 {
+				t.Errorf("%x + %x: got %x, want %x", a, b, got, sum)
+			}
+			diff := new(big.Int).Mod(new(big.Int).Sub(a, b), p)
+			if got := fa.sub(fb).big(); got.Cmp(diff) != 0 {
+				t.Errorf("%x - %x: got %x, want %x", a, b, got, diff)
+			}
+			prod := new(big.Int).Mod(new(big.Int).Mul(a, b), p)
+			if got := fa.mul(fb).big(); got.Cmp(prod) != 0 {
+				t.Errorf("%x * %x: got %x, want %x", a, b, got, prod)
+			}
+			if eq := fa.equal(fb) == 1; eq != (a.Cmp(b) == 0) {
+				t.Errorf("%x == %x: got %v", a, b, eq)
+			}
+		}
+	}
+}
+
+func TestFieldInverse(t *testing.T) {
+	p := secp256k1.P
+	for _, a := range testValues(p) {
+		got := fieldFromBig(a).inverse().big()
+		want := new(big.Int).ModInverse(a, p)
+		if want == nil {
+			want = big.NewInt(0)
+		}
+		if got.Cmp(want) != 0 {
+			t.Errorf("1/%x: got %x, want %x", a, got, want)
+		}
+	}
+}
+
+func TestFieldSqrt(t *testing.T) {
+	p := secp256k1.P
+	for _, a := range testValues(p) {
+		root, ok := fieldFromBig(a).sqrt()
+		want := new(big.Int).ModSqrt(a, p) != nil
+		if ok != want {
+			t.Errorf("sqrt(%x): square is %v, want %v", a, ok, want)
+			continue
+		}
+		if ok && root.square().big().Cmp(a) != 0 {
+			t.Errorf("sqrt(%x): %x is not a root", a, root.big())
+		}
+	}
+}
+
+func TestScalarFromBytes(t *testing.T) {
+	n := secp256k1.N
+	one := big.NewInt(1)
+	max := new(big.Int).Sub(new(big.Int).Lsh(one, 256), one)
+	tests := []struct {
+		in      *big.Int
+		want    *big.Int
+		reduced bool
+	}{
+		{big.NewInt(0), big.NewInt(0), true},
+		{new(big.Int).Sub(n, one), new(big.Int).Sub(n, one), true},
+		{n, big.NewInt(0), false},
+		{new(big.Int).Add(n, one), one, false},
+		{max, new(big.Int).Sub(max, n), false},
+	}
+	for _, test := range tests {
+		s, ok := scalarFromBytes(bytes32(test.in))
+		b := s.bytes()
+		if got := new(big.Int).SetBytes(b[:]); got.Cmp(test.want) != 0 || ok != test.reduced {
+			t.Errorf("%x: got %x %v, want %x %v", test.in, got, ok,
+				test.want, test.reduced)
+		}
+	}
+
+	// Longer numbers are reduced too.
+	long := append([]byte{1}, make([]byte, 32)...)
+	b := scalarFromSlice(long).bytes()
+	want := new(big.Int).Mod(new(big.Int).SetBytes(long), n)
+	if got := new(big.Int).SetBytes(b[:]); got.Cmp(want) != 0 {
+		t.Errorf("2^256: got %x, want %x", got, want)
+	}
+}
+
+func TestScalarArithmetic(t *testing.T) {
+	n := secp256k1.N
+	toBig := func(s scalar) *big.Int {
+		b := s.bytes()
+		return new(big.Int).SetBytes(b[:])
+	}
+	vals := testValues(n)
+	for _, a := range vals {
+		sa, _ := scalarFromBytes(bytes32(a))
+		if got, want := toBig(sa.neg()), new(big.Int).Mod(new(big.Int).Neg(a), n); got.Cmp(want) != 0 {
+			t.Errorf("-%x: got %x, want %x", a, got, want)
+		}
+		want := new(big.Int).ModInverse(a, n)
+		if want == nil {
+			want = big.NewInt(0)
+		}
+		if got := toBig(sa.inverse()); got.Cmp(want) != 0 {
+			t.Errorf("1/%x: got %x, want %x", a, got, want)
+		}
+
+		for _, b := range vals {
+			sb, _ := scalarFromBytes(bytes32(b))
+			sum := new(big.Int).Mod(new(big.Int).Add(a, b), n)
+			if got := toBig(sa.add(sb)); got.Cmp(sum) != 0 {
+				t.Errorf("%x + %x: got %x, want %x", a, b, got, sum)
+			}
+			prod := new(big.Int).Mod(new(big.Int).Mul(a, b), n)
+			if got := toBig(sa.mul(sb)); got.Cmp(prod) != 0 {
+				t.Errorf("%x * %x: got %x, want %x", a, b, got, prod)
+			}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package btcec
+
+import "sync"
+
+// A jacobianPoint is a point on secp256k1 in Jacobian coordinates: the
+// affine point (x/z², y/z³), or the point at infinity if z is zero. Keeping
+// the denominator separate avoids a field inversion in every operation.
+type jacobianPoint struct {
+	x, y, z fieldVal
+}
+
+// infinity is the point at infinity.
+var infinity = jacobianPoint{x: fieldVal{1}, y: fieldVal{1}}
+
+// fromAffine returns the affine point (x, y) in Jacobian coordinates.
+func fromAffine(x, y fieldVal) jacobianPoint {
+	return jacobianPoint{x: x, y: y, z: fieldVal{1}}
+}
+
+// toAffine returns the affine coordinates of p. The point at infinity is
+// returned as (0, 0).
+func (p *jacobianPoint) toAffine() (fieldVal, fieldVal) {
+	zInv := p.z.inverse()
+	zInv2 := zInv.square()
+	return p.x.mul(zInv2), p.y.mul(zInv2.mul(zInv))
+}
+
+// isInfinity returns 1 if p is the point at infinity and 0 otherwise.
+func (p *jacobianPoint) isInfinity() uint64 {
+	return p.z.isZero()
+}
+
+// double returns 2p. Since secp256k1 has no point of order two, the formula
+// has no exceptional cases: doubling infinity gives z = 0 again.
+func (p *jacobianPoint) double() jacobianPoint {
+	// dbl-2009-l from the Explicit-Formulas Database, for a = 0.
+	a := p.x.square()
+	b := p.y.square()
+	c := b.square()
+	d := p.x.add(b).square().sub(a).sub(c)
+	d = d.add(d)
+	e := a.mulInt(3)
+	f := e.square()
+
+	var r jacobianPoint
+	r.x = f.sub(d.add(d))
+	r.y = e.mul(d.sub(r.x)).sub(c.mulInt(8))
+	r.z = p.y.mul(p.z)
+	r.z = r.z.add(r.z)
+	return r
+}
+
+// add returns p + q. The exceptional cases of the addition formula are
+// handled by computing their results unconditionally and selecting between
+// them, so the time taken does not reveal whether they occurred.
+func (p *jacobianPoint) add(q *jacobianPoint) jacobianPoint {
+	// add-2007-bl from the Explicit-Formulas Database. When p = -q, h is
+	// zero and so is the resulting z: the sum is infinity as required.
+	z1z1 := p.z.square()
+	z2z2 := q.z.square()
+	u1 := p.x.mul(z2z2)
+	u2 := q.x.mul(z1z1)
+	s1 := p.y.mul(q.z).mul(z2z2)
+	s2 := q.y.mul(p.z).mul(z1z1)
+	h := u2.sub(u1)
+	i := h.add(h).square()
+	j := h.mul(i)
+	r := s2.sub(s1)
+	r = r.add(r)
+	v := u1.mul(i)
+
+	var sum jacobianPoint
+	sum.x = r.square().sub(j).sub(v.add(v))
+	s1j := s1.mul(j)
+	sum.y = r.mul(v.sub(sum.x)).sub(s1j.add(s1j))
+	sum.z = p.z.add(q.z).square().sub(z1z1).sub(z2z2).mul(h)
+
+	// When p = q the formula degenerates and the sum is a doubling.
+	dbl := p.double()
+	sum = selectPoint(&dbl, &sum, h.isZero()&r.isZero())
+	sum = selectPoint(q, &sum, p.isInfinity())
+	return selectPoint(p, &sum, q.isInfinity())
+}
+
+// addVar returns p + q like add, but branches on the exceptional cases
+// instead of computing every result, which makes it faster and variable
+// time. It must only be used with public points.
+func (p *jacobianPoint) addVar(q *jacobianPoint) jacobianPoint {
+	if p.isInfinity() == 1 {
+		return *q
+	}
+	if q.isInfinity() == 1 {
+		return *p
+	}
+
+	z1z1 := p.z.square()
+	z2z2 := q.z.square()
+	u1 := p.x.mul(z2z2)
+	u2 := q.x.mul(z1z1)
+	s1 := p.y.mul(q.z).mul(z2z2)
+	s2 := q.y.mul(p.z).mul(z1z1)
+	h := u2.sub(u1)
+	r := s2.sub(s1)
+	if h.isZero() == 1 {
+		if r.isZero() == 1 {
+			return p.double()
+		}
+		return infinity
+	}
+	i := h.add(h).square()
+	j := h.mul(i)
+	r = r.add(r)
+	v := u1.mul(i)
+
+	var sum jacobianPoint
+	sum.x = r.square().sub(j).sub(v.add(v))
+	s1j := s1.mul(j)
+	sum.y = r.mul(v.sub(sum.x)).sub(s1j.add(s1j))
+	sum.z = p.z.add(q.z).square().sub(z1z1).sub(z2z2).mul(h)
+	return sum
+}
+
+// selectPoint returns a if cond is 1 and b if it is 0.
+func selectPoint(a, b *jacobianPoint, cond uint64) jacobianPoint {
+	return jacobianPoint{
+		x: fieldVal(selectLimbs(a.x, b.x, cond)),
+		y: fieldVal(selectLimbs(a.y, b.y, cond)),
+		z: fieldVal(selectLimbs(a.z, b.z, cond)),
+	}
+}
+
+// lookup returns table[i] by scanning the whole table, so that the memory
+// accessed does not depend on i.
+func lookup(table *[16]jacobianPoint, i uint64) jacobianPoint {
+	r := infinity
+	for j := range table {
+		d := uint64(j) ^ i
+		r = selectPoint(&table[j], &r, 1^(d|-d)>>63)
+	}
+	return r
+}
+
+// scalarMult returns k * p using a fixed window of four bits.
+func scalarMult(k scalar, p *jacobianPoint) jacobianPoint {
+	var table [16]jacobianPoint
+	table[0] = infinity
+	table[1] = *p
+	for i := 2; i < len(table); i++ {
+		table[i] = table[i-1].add(p)
+	}
+
+	r := infinity
+	for i := 63; i >= 0; i-- {
+		for j := 0; j < 4; j++ {
+			r = r.double()
+		}
+		t := lookup(&table, k.window(i))
+		r = r.add(&t)
+	}
+	return r
+}
+
+// baseTable holds the multiples of the generator used by scalarBaseMult:
+// baseTable[i][j] is j * 16^i * G.
+var (
+	baseTable     *[64][16]jacobianPoint
+	baseTableOnce sync.Once
+)
+
+// computeBaseTable fills baseTable.
+func computeBaseTable() {
+	baseTable = new([64][16]jacobianPoint)
+	g := fromAffine(fieldFromBig(secp256k1.Gx), fieldFromBig(secp256k1.Gy))
+	for i := range baseTable {
+		baseTable[i][0] = infinity
+		baseTable[i][1] = g
+		for j := 2; j < 16; j++ {
+			baseTable[i][j] = baseTable[i][j-1].add(&g)
+		}
+		g = baseTable[i][15].add(&g)
+	}
+}
+
+// scalarBaseMult returns k * G. With the precomputed table it needs one
+// addition per window of k and no doublings.
+func scalarBaseMult(k scalar) jacobianPoint {
+	baseTableOnce.Do(computeBaseTable)
+	r := infinity
+	for i := range baseTable {
+		t := lookup(&baseTable[i], k.window(i))
+		r = r.add(&t)
+	}
+	return r
+}
+
+// multiScalarMult returns k_1⋅p_1 + ... + k_n⋅p_n by Straus' method: the
+// points share a single chain of doublings, with each 4-bit window of each
+// scalar adding a precomputed multiple of its point. It is variable time
+// and must only be used with public scalars.
+func multiScalarMult(ks []scalar, ps []jacobianPoint) jacobianPoint {
+	tables := make([][16]jacobianPoint, len(ps))
+	for i := range ps {
+		tables[i][0] = infinity
+		tables[i][1] = ps[i]
+		for j := 2; j < 16; j++ {
+			tables[i][j] = tables[i][j-1].addVar(&ps[i])
+		}
+	}
+
+	r := infinity
+	for w := 63; w >= 0; w-- {
+		if r.isInfinity() == 0 {
+			for j := 0; j < 4; j++ {
+				r = r.double()
+			}
+		}
+		for i := range ks {
+			if d := ks[i].window(w); d != 0 {
+				r = r.addVar(&tables[i][d])
+			}
+		}
+	}
+	return r
+}
@@ -2,8 +2,8 @@ package btcec
 
 import (
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
+	"errors"
 	"math/big"
 
 	"github.com/jacobkaufmann/gocoin/pkg/util/encoding/base58"
@@ -12,9 +12,9 @@ import (
 // A PrivateKey wraps an ecdsa.PrivateKey and represents a Bitcoin private key.
 type PrivateKey ecdsa.PrivateKey
 
-// NewPrivateKey generates a new private key for the elliptic curve.
-func NewPrivateKey(curve elliptic.Curve) (*PrivateKey, error) {
-	k, err := ecdsa.GenerateKey(curve, rand.Reader)
+// NewPrivateKey generates a new private key on the secp256k1 curve.
+func NewPrivateKey() (*PrivateKey, error) {
+	k, err := ecdsa.GenerateKey(S256(), rand.Reader)
 	if err != nil {
 		return nil, err
 	}
@@ -22,10 +22,14 @@ func NewPrivateKey(curve elliptic.Curve) (*PrivateKey, error) {
 	return (*PrivateKey)(k), nil
 }
 
-// PrivKeyFromBytes returns a private and public key for `curve' based on the
-// private key passed as an argument as a byte slice.
-func PrivKeyFromBytes(curve elliptic.Curve, pk []byte) (*PrivateKey,
-	*PublicKey) {
+// errInvalidPrivateKey indicates a private key which is zero or not less
+// than the curve order.
+var errInvalidPrivateKey = errors.New("invalid private key")
+
+// PrivKeyFromBytes returns the secp256k1 private key with the big-endian
+// number pk, and its public key.
+func PrivKeyFromBytes(pk []byte) (*PrivateKey, *PublicKey) {
+	curve := S256()
 	x, y := curve.ScalarBaseMult(pk)
 
 	priv := &ecdsa.PrivateKey{
@@ -45,13 +49,82 @@ func (p *PrivateKey) PubKey() *PublicKey {
 	return (*PublicKey)(&p.PublicKey)
 }
 
-// Sign generates a signature for the provided hash using the private key.
+// Sign returns the signature of hash by the private key. The nonce is
+// derived deterministically from the key and hash as specified by RFC6979,
+// so signing the same hash again gives the same signature, and the
+// signature is normalized to a low S value.
 func (p *PrivateKey) Sign(hash []byte) (*Signature, error) {
-	r, s, err := ecdsa.Sign(rand.Reader, p.ToECDSA(), hash)
+	return p.SignWithEntropy(hash, nil)
+}
+
+// SignWithEntropy is like Sign but mixes extra, typically 32 bytes, into the
+// RFC6979 nonce derivation. Different extra data gives different, still
+// reproducible signatures.
+func (p *PrivateKey) SignWithEntropy(hash, extra []byte) (*Signature, error) {
+	sig, _, err := p.sign(hash, extra)
+	return sig, err
+}
+
+// sign returns the low-S signature of hash using RFC6979 nonces with extra
+// data, together with its recovery ID: bit 0 is the parity of the y
+// coordinate of the nonce point R and bit 1 is set if its x coordinate
+// overflowed the curve order when reduced to r.
+func (p *PrivateKey) sign(hash, extra []byte) (*Signature, byte, error) {
+	d, err := p.scalar()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	z := hashToScalar(hash)
+
+	nonces := newNonceGenerator(d, hash, extra)
+	for {
+		k := nonces.next()
+
+		// r is the x coordinate of kG reduced modulo n, and
+		// s = (z + r*d) / k.
+		point := scalarBaseMult(k)
+		x, y := point.toAffine()
+		xb := x.bytes()
+		r, ok := scalarFromBytes(&xb)
+		s := k.inverse().mul(z.add(r.mul(d)))
+		if r.isZero() == 1 || s.isZero() == 1 {
+			continue
+		}
+
+		var recID byte
+		if y.isOdd() {
+			recID |= 1
+		}
+		if !ok {
+			recID |= 2
+		}
+
+		rb, sb := r.bytes(), s.bytes()
+		sig := &Signature{
+			R: new(big.Int).SetBytes(rb[:]),
+			S: new(big.Int).SetBytes(sb[:]),
+		}
+
+		// Negating S corresponds to negating the nonce, which flips
+		// the parity of R.
+		if !sig.IsLowS() {
+			sig.NormalizeS()
+			recID ^= 1
+		}
+		return sig, recID, nil
+	}
+}
+
+// scalar returns the private key number d as a scalar. An error is returned
+// unless d is in [1, n-1].
+func (p *PrivateKey) scalar() (scalar, error) {
+	if p.D.Sign() <= 0 || p.D.Cmp(secp256k1.N) >= 0 {
+		return scalar{}, errInvalidPrivateKey
 	}
-	return &Signature{r, s}, nil
+	var b [32]byte
+	p.D.FillBytes(b[:])
+	d, _ := scalarFromBytes(&b)
+	return d, nil
 }
 
 // ToECDSA returns the private key as a *ecdsa.PrivateKey.
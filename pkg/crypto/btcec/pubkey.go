@@ -2,6 +2,7 @@ package btcec
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"math/big"
 )
 
@@ -9,13 +10,17 @@ import (
 const (
 	PubKeyBytesLenCompressed   = 33
 	PubKeyBytesLenUncompressed = 65
+	PubKeyBytesLenHybrid       = 65
 )
 
-// These constants define the prefixes for serialized public keys.
+// These constants define the prefixes for serialized public keys. A hybrid
+// key is an uncompressed key whose prefix also gives the parity of Y.
 const (
 	pubkeyCompressedEvenY byte = 0x2
 	pubKeyCompressedOddY  byte = 0x3
 	pubkeyUncompressed    byte = 0x4
+	pubkeyHybridEvenY     byte = 0x6
+	pubkeyHybridOddY      byte = 0x7
 )
 
 // isOdd returns whether a big.Int is odd.  It is a helper for determining
@@ -34,6 +39,82 @@ func IsCompressedPubKey(pubKey []byte) bool {
 			pubKey[0]&^byte(0x1) == pubKeyCompressedOddY)
 }
 
+var (
+	// errPubKeyNotOnCurve indicates that a serialized public key does not
+	// describe a point on the curve.
+	errPubKeyNotOnCurve = errors.New("public key is not on the curve")
+
+	// errInvalidPubKeyFormat indicates that a serialized public key has an
+	// unrecognized prefix or length.
+	errInvalidPubKeyFormat = errors.New("invalid public key format")
+)
+
+// ParsePubKey parses a public key for the secp256k1 curve from its
+// compressed, uncompressed or hybrid serialization. Hybrid keys are accepted
+// because consensus allows them in scripts which predate strict encoding;
+// use ParsePubKeyStrict where they are not acceptable.
+func ParsePubKey(pubKeyStr []byte) (*PublicKey, error) {
+	return parsePubKey(pubKeyStr, false)
+}
+
+// ParsePubKeyStrict parses a public key like ParsePubKey but rejects hybrid
+// keys, so that only compressed and uncompressed keys are accepted.
+func ParsePubKeyStrict(pubKeyStr []byte) (*PublicKey, error) {
+	return parsePubKey(pubKeyStr, true)
+}
+
+// parsePubKey parses a serialized public key, rejecting hybrid keys if
+// strict is set. The coordinates must be less than p and the point must lie
+// on the curve.
+func parsePubKey(pubKeyStr []byte, strict bool) (*PublicKey, error) {
+	if len(pubKeyStr) == 0 {
+		return nil, errInvalidPubKeyFormat
+	}
+
+	curve := S256()
+	pubKey := &PublicKey{Curve: curve}
+	format := pubKeyStr[0]
+
+	switch {
+	case len(pubKeyStr) == PubKeyBytesLenUncompressed &&
+		format == pubkeyUncompressed:
+		pubKey.X = new(big.Int).SetBytes(pubKeyStr[1:33])
+		pubKey.Y = new(big.Int).SetBytes(pubKeyStr[33:])
+
+	case len(pubKeyStr) == PubKeyBytesLenHybrid && !strict &&
+		(format == pubkeyHybridEvenY || format == pubkeyHybridOddY):
+		pubKey.X = new(big.Int).SetBytes(pubKeyStr[1:33])
+		pubKey.Y = new(big.Int).SetBytes(pubKeyStr[33:])
+		if isOdd(pubKey.Y) != (format == pubkeyHybridOddY) {
+			return nil, errInvalidPubKeyFormat
+		}
+
+	case len(pubKeyStr) == PubKeyBytesLenCompressed &&
+		(format == pubkeyCompressedEvenY || format == pubKeyCompressedOddY):
+		pubKey.X = new(big.Int).SetBytes(pubKeyStr[1:])
+		if pubKey.X.Cmp(curve.P) >= 0 {
+			return nil, errPubKeyNotOnCurve
+		}
+		y, err := curve.decompressY(pubKey.X, format == pubKeyCompressedOddY)
+		if err != nil {
+			return nil, err
+		}
+		pubKey.Y = y
+
+	default:
+		return nil, errInvalidPubKeyFormat
+	}
+
+	// The curve operations reduce coordinates modulo p, so coordinates of
+	// at least p must be rejected here to keep a point from having more
+	// than one serialization.
+	if pubKey.X.Cmp(curve.P) >= 0 || pubKey.Y.Cmp(curve.P) >= 0 ||
+		!curve.IsOnCurve(pubKey.X, pubKey.Y) {
+		return nil, errPubKeyNotOnCurve
+	}
+	return pubKey, nil
+}
+
 // A PublicKey wraps an ecdsa.PublicKey represents a Bitcoin public key.
 type PublicKey ecdsa.PublicKey
 
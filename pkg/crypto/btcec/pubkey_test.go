@@ -0,0 +1,120 @@
+package btcec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// The coordinates of the generator G, whose y is even, the y coordinate of
+// -G, which is odd, and the even y coordinate of the point with x = 1.
+const (
+	pubKeyGX    = "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	pubKeyGY    = "483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+	pubKeyNegGY = "b7c52588d95c3b9aa25b0403f1eef75702e84bb7597aabe663b82f6f04ef2777"
+	pubKeyOneY  = "4218f20ae6c646b363db68605822fb14264ca8d2587fdd6fbc750d587e76a7ee"
+
+	// pubKeyP is the field prime p, and pubKeyPPlusOne is p+1, which
+	// reduces to 1.
+	pubKeyP        = "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f"
+	pubKeyPPlusOne = "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc30"
+	pubKeyOne      = "0000000000000000000000000000000000000000000000000000000000000001"
+)
+
+func TestParsePubKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		err       error
+		strictErr error
+		x, y      string
+	}{
+		{"compressed even", "02" + pubKeyGX, nil, nil, pubKeyGX, pubKeyGY},
+		{"compressed odd", "03" + pubKeyGX, nil, nil, pubKeyGX, pubKeyNegGY},
+		{"uncompressed", "04" + pubKeyGX + pubKeyGY, nil, nil, pubKeyGX, pubKeyGY},
+		{"uncompressed odd", "04" + pubKeyGX + pubKeyNegGY, nil, nil,
+			pubKeyGX, pubKeyNegGY},
+		{"uncompressed small x", "04" + pubKeyOne + pubKeyOneY, nil, nil,
+			pubKeyOne, pubKeyOneY},
+
+		// Hybrid keys are only accepted with the parity of y.
+		{"hybrid even", "06" + pubKeyGX + pubKeyGY, nil,
+			errInvalidPubKeyFormat, pubKeyGX, pubKeyGY},
+		{"hybrid odd", "07" + pubKeyGX + pubKeyNegGY, nil,
+			errInvalidPubKeyFormat, pubKeyGX, pubKeyNegGY},
+		{"hybrid even with odd y", "06" + pubKeyGX + pubKeyNegGY,
+			errInvalidPubKeyFormat, errInvalidPubKeyFormat, "", ""},
+		{"hybrid odd with even y", "07" + pubKeyGX + pubKeyGY,
+			errInvalidPubKeyFormat, errInvalidPubKeyFormat, "", ""},
+
+		// Points off the curve.
+		{"uncompressed off the curve", "04" + pubKeyGX + pubKeyOneY,
+			errPubKeyNotOnCurve, errPubKeyNotOnCurve, "", ""},
+		{"hybrid off the curve", "06" + pubKeyGX + pubKeyOneY,
+			errPubKeyNotOnCurve, errInvalidPubKeyFormat, "", ""},
+		{"compressed x without a point",
+			"02" + "0000000000000000000000000000000000000000000000000000000000000005",
+			errPubKeyNotOnCurve, errPubKeyNotOnCurve, "", ""},
+
+		// Coordinates of at least p, including ones which reduce to a
+		// point on the curve.
+		{"compressed x of p", "02" + pubKeyP, errPubKeyNotOnCurve,
+			errPubKeyNotOnCurve, "", ""},
+		{"compressed x of p+1", "02" + pubKeyPPlusOne, errPubKeyNotOnCurve,
+			errPubKeyNotOnCurve, "", ""},
+		{"uncompressed x of p+1", "04" + pubKeyPPlusOne + pubKeyOneY,
+			errPubKeyNotOnCurve, errPubKeyNotOnCurve, "", ""},
+		{"hybrid x of p+1", "06" + pubKeyPPlusOne + pubKeyOneY,
+			errPubKeyNotOnCurve, errInvalidPubKeyFormat, "", ""},
+
+		// Unknown prefixes and lengths.
+		{"empty", "", errInvalidPubKeyFormat, errInvalidPubKeyFormat, "", ""},
+		{"x only", pubKeyGX, errInvalidPubKeyFormat, errInvalidPubKeyFormat,
+			"", ""},
+		{"prefix 05", "05" + pubKeyGX + pubKeyGY, errInvalidPubKeyFormat,
+			errInvalidPubKeyFormat, "", ""},
+		{"compressed prefix with 65 bytes", "02" + pubKeyGX + pubKeyGY,
+			errInvalidPubKeyFormat, errInvalidPubKeyFormat, "", ""},
+		{"uncompressed prefix with 33 bytes", "04" + pubKeyGX,
+			errInvalidPubKeyFormat, errInvalidPubKeyFormat, "", ""},
+		{"compressed truncated", "02" + pubKeyGX[:62], errInvalidPubKeyFormat,
+			errInvalidPubKeyFormat, "", ""},
+	}
+	for _, test := range tests {
+		b, _ := hex.DecodeString(test.key)
+		pubKey, err := ParsePubKey(b)
+		if err != test.err {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if _, err := ParsePubKeyStrict(b); err != test.strictErr {
+			t.Errorf("%s: strict: got %v, want %v", test.name, err,
+				test.strictErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		x, _ := new(big.Int).SetString(test.x, 16)
+		y, _ := new(big.Int).SetString(test.y, 16)
+		if pubKey.X.Cmp(x) != 0 || pubKey.Y.Cmp(y) != 0 {
+			t.Errorf("%s: got (%x, %x), want (%s, %s)", test.name, pubKey.X,
+				pubKey.Y, test.x, test.y)
+		}
+
+		// Compressed and uncompressed keys serialize back to themselves.
+		var ser []byte
+		switch b[0] {
+		case pubkeyCompressedEvenY, pubKeyCompressedOddY:
+			ser = pubKey.SerializeCompressed()
+		case pubkeyUncompressed:
+			ser = pubKey.SerializeUncompressed()
+		default:
+			continue
+		}
+		if !bytes.Equal(ser, b) {
+			t.Errorf("%s: serialized as %x", test.name, ser)
+		}
+	}
+}
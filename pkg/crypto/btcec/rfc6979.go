@@ -0,0 +1,74 @@
+package btcec
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// A nonceGenerator produces the deterministic signature nonces of RFC6979
+// section 3.2 using HMAC-SHA256 as the DRBG. Successive calls to next return
+// further candidates, used if a nonce yields an invalid signature.
+type nonceGenerator struct {
+	k, v []byte
+}
+
+// newNonceGenerator returns the nonce generator for signing hash with the
+// private key d. extra is additional data mixed into the seed as described
+// in RFC6979 section 3.6; signatures with different extra data use
+// unrelated nonces.
+func newNonceGenerator(d scalar, hash, extra []byte) *nonceGenerator {
+	key := d.bytes()
+	h := hashToScalar(hash).bytes()
+
+	g := &nonceGenerator{
+		k: make([]byte, sha256.Size),
+		v: make([]byte, sha256.Size),
+	}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	for _, sep := range []byte{0x00, 0x01} {
+		g.k = g.mac(g.v, []byte{sep}, key[:], h[:], extra)
+		g.v = g.mac(g.v)
+	}
+	return g
+}
+
+// mac returns the HMAC-SHA256 of the concatenation of data keyed by g.k.
+func (g *nonceGenerator) mac(data ...[]byte) []byte {
+	m := hmac.New(sha256.New, g.k)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+// next returns the next candidate nonce, a scalar in [1, n-1].
+func (g *nonceGenerator) next() scalar {
+	for {
+		g.v = g.mac(g.v)
+		var b [32]byte
+		copy(b[:], g.v)
+		k, ok := scalarFromBytes(&b)
+
+		// Reseed for the following candidate, or for a retry when this
+		// one is out of range.
+		g.k = g.mac(g.v, []byte{0x00})
+		g.v = g.mac(g.v)
+		if ok && k.isZero() == 0 {
+			return k
+		}
+	}
+}
+
+// hashToScalar converts a message hash to a scalar as ECDSA specifies: the
+// leftmost 256 bits are taken as a big-endian number and reduced modulo n.
+func hashToScalar(hash []byte) scalar {
+	var b [32]byte
+	if len(hash) > len(b) {
+		hash = hash[:len(b)]
+	}
+	copy(b[len(b)-len(hash):], hash)
+	s, _ := scalarFromBytes(&b)
+	return s
+}
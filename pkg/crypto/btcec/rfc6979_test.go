@@ -0,0 +1,164 @@
+package btcec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// TestNonceGenerator checks the RFC6979 nonces against the vectors of other
+// implementations. Extra data of 48 bytes is how those implementations mix
+// in 32 bytes of extra data followed by a 16-byte version.
+func TestNonceGenerator(t *testing.T) {
+	const key1 = "0011111111111111111111111111111111111111111111111111111111111111"
+	const hash1 = "0000000000000000000000000000000000000000000000000000000000000001"
+	tests := []struct {
+		name       string
+		key        string
+		hash       string
+		extra      string
+		iterations int
+		nonce      string
+	}{
+		{"no extra data", key1, hash1, "", 0,
+			"154e92760f77ad9af6b547edd6f14ad0fae023eb2221bc8be2911675d8a686a3"},
+		{"extra data", key1, hash1,
+			"0000000000000000000000000000000000000000000000000000000000000002", 0,
+			"67893461ade51cde61824b20bc293b585d058e6b9f40fb68453d5143f15116ae"},
+		{"extra data and version", key1, hash1,
+			"0000000000000000000000000000000000000000000000000000000000000002" +
+				"00000000000000000000000000000003", 0,
+			"9b5657643dfd4b77d99dfa505ed8a17e1b9616354fc890669b4aabece2170686"},
+		{"zero extra data and version", key1, hash1,
+			"0000000000000000000000000000000000000000000000000000000000000000" +
+				"00000000000000000000000000000003", 0,
+			"7b27d6ceff87e1ded1860ca4e271a530e48514b9d3996db0af2bb8bda189007d"},
+		{"second candidate", key1, hash1, "", 1,
+			"66fca3fe494a6216e4a3f15cfbc1d969c60d9cdefda1a1c193edabd34aa8cd5e"},
+		{"third candidate", key1, hash1, "", 2,
+			"70da248c92b5d28a52eafca1848b1a37d4cb36526c02553c9c48bb0b895fc77d"},
+	}
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		hash, _ := hex.DecodeString(test.hash)
+		extra, _ := hex.DecodeString(test.extra)
+		g := newNonceGenerator(scalarFromSlice(key), hash, extra)
+		for i := 0; i < test.iterations; i++ {
+			g.next()
+		}
+		k := g.next().bytes()
+		if got := hex.EncodeToString(k[:]); got != test.nonce {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.nonce)
+		}
+	}
+}
+
+// rfc6979Tests are signatures of the SHA256 hash of msg. The nonces of the
+// first three are those of the Trezor and CoreBitcoin test vectors; the
+// signature of "Satoshi Nakamoto" by key 1 has a high S before
+// normalization.
+var rfc6979Tests = []struct {
+	key   string
+	msg   string
+	extra string
+	nonce string
+	der   string
+}{
+	{
+		"01", "Satoshi Nakamoto", "",
+		"8f8a276c19f4149656b280621e358cce24f5f52542772691ee69063b74f15d15",
+		"3045022100934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8" +
+			"02202442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5",
+	},
+	{
+		"01", "All those moments will be lost in time, like tears in rain. Time to die...", "",
+		"38aa22d72376b4dbc472e06c3ba403ee0a394da63fc58d88686c611aba98d6b3",
+		"30450221008600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b" +
+			"0220547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21",
+	},
+	{
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140", "Satoshi Nakamoto", "",
+		"33a19b60e25fb6f4435af53a3d42d493644827367e6453928554f43e49aa6f90",
+		"3045022100fd567d121db66e382991534ada77a6bd3106f0a1098c231e47993447cd6af2d0" +
+			"02206b39cd0eb1bc8603e159ef5c20a5c8ad685a45b06ce9bebed3f153d10d93bed5",
+	},
+
+	// Extra entropy. An S of 31 bytes is encoded without padding.
+	{
+		"01", "Satoshi Nakamoto",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"c2d46cf83bd97a7f7f56ee7cb455ee32144bbe55ccd6a396841cf8fad25c4edf",
+		"3044022100e9b9772c96fa631dd74baa0da145c46012112b1dbd5d81600fc9f9905b946cbb" +
+			"021f0d08f7541213737694648cf81d77373a9e55632d3f6eb1826194adcb596707",
+	},
+	{
+		"01", "Satoshi Nakamoto",
+		"0101010101010101010101010101010101010101010101010101010101010101",
+		"ff49282725ee554d481ee92230ebf201d5137cdc427fcda67210387e20a1b90b",
+		"3045022100bb6cf569458d507451271380d2863dad30355387836d5c3287a4efbd5ed1ad8e" +
+			"02204bb4b7899e803f760fe89027e55f5d93768983d6e28af4b5722f6226b345380e",
+	},
+	{
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140", "Satoshi Nakamoto",
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"fc98e2b35bcd0755958b621230636e60000e7fe961645a18243c84f31fcdaaca",
+		"304402203c1a1cebb621880b837b5c73ef01573ca8ad2fe6667fcf2898d3e6bdadb4b710" +
+			"0220230960042774e077f8c90f9b6e40dabce792c8153b0e5d5a09a795fc600b56ad",
+	},
+}
+
+func TestSignRFC6979(t *testing.T) {
+	for _, test := range rfc6979Tests {
+		name := test.msg
+		if test.extra != "" {
+			name += " with entropy " + test.extra[:8]
+		}
+		keyBytes, _ := hex.DecodeString(test.key)
+		extra, _ := hex.DecodeString(test.extra)
+		hash := sha256.Sum256([]byte(test.msg))
+		priv, pub := PrivKeyFromBytes(keyBytes)
+
+		d, err := priv.scalar()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		k := newNonceGenerator(d, hash[:], extra).next().bytes()
+		if got := hex.EncodeToString(k[:]); got != test.nonce {
+			t.Errorf("%s: nonce %s, want %s", name, got, test.nonce)
+		}
+
+		sig, err := priv.SignWithEntropy(hash[:], extra)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := hex.EncodeToString(sig.Serialize()); got != test.der {
+			t.Errorf("%s: signature %s, want %s", name, got, test.der)
+		}
+		if !sig.IsLowS() {
+			t.Errorf("%s: signature has a high S", name)
+		}
+		if !sig.Verify(hash[:], pub) {
+			t.Errorf("%s: signature does not verify", name)
+		}
+
+		// Signing is deterministic.
+		again, _ := priv.SignWithEntropy(hash[:], extra)
+		if !again.IsEqual(sig) {
+			t.Errorf("%s: signing again gave %x", name, again.Serialize())
+		}
+	}
+}
+
+func TestSignInvalidKey(t *testing.T) {
+	hash := sha256.Sum256([]byte("Satoshi Nakamoto"))
+	for _, key := range []string{
+		"00",
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141",
+	} {
+		b, _ := hex.DecodeString(key)
+		priv, _ := PrivKeyFromBytes(b)
+		if _, err := priv.Sign(hash[:]); err != errInvalidPrivateKey {
+			t.Errorf("key %s: got %v, want %v", key, err, errInvalidPrivateKey)
+		}
+	}
+}
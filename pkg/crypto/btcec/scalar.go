@@ -0,0 +1,78 @@
+package btcec
+
+import "math/big"
+
+// scalarC is 2^256 - n, where n is the order of the secp256k1 group.
+var scalarC = []uint64{0x402da1732fc9bebf, 0x4551231950b75fc4, 0x1}
+
+// A scalar is an integer modulo the group order n, always fully reduced.
+type scalar [4]uint64
+
+// scalarFromBytes returns the big-endian number b reduced modulo n and
+// whether b was already less than n.
+func scalarFromBytes(b *[32]byte) (scalar, bool) {
+	a := limbsFromBytes(b)
+	r := condSubtract(a, 0, scalarC)
+	return scalar(r), r == a
+}
+
+// scalarFromSlice returns the big-endian number b of any length reduced
+// modulo n. Numbers longer than 32 bytes are reduced with big.Int and so not
+// in constant time; they do not occur as secret scalars.
+func scalarFromSlice(b []byte) scalar {
+	var buf [32]byte
+	if len(b) > len(buf) {
+		k := new(big.Int).SetBytes(b)
+		k.Mod(k, secp256k1.N)
+		k.FillBytes(buf[:])
+	} else {
+		copy(buf[32-len(b):], b)
+	}
+	s, _ := scalarFromBytes(&buf)
+	return s
+}
+
+// bytes returns s as a 32-byte big-endian number.
+func (s scalar) bytes() [32]byte {
+	return limbsBytes(s)
+}
+
+// add returns s + t.
+func (s scalar) add(t scalar) scalar {
+	return addMod(s, t, scalarC)
+}
+
+// neg returns -s.
+func (s scalar) neg() scalar {
+	return subMod([4]uint64{}, s, scalarC)
+}
+
+// mul returns s * t.
+func (s scalar) mul(t scalar) scalar {
+	return mulMod(s, t, scalarC)
+}
+
+// inverse returns 1/s, computed as s^(n-2). The inverse of zero is zero.
+func (s scalar) inverse() scalar {
+	e := [4]uint64{0xbfd25e8cd036413f, 0xbaaedce6af48a03b,
+		0xfffffffffffffffe, 0xffffffffffffffff}
+	r := scalar{1}
+	for i := 255; i >= 0; i-- {
+		r = r.mul(r)
+		if e[i/64]>>uint(i%64)&1 == 1 {
+			r = r.mul(s)
+		}
+	}
+	return r
+}
+
+// isZero returns 1 if s is zero and 0 otherwise.
+func (s scalar) isZero() uint64 {
+	return isZeroLimbs(s)
+}
+
+// window returns the 4-bit window i of s, counting from the least
+// significant.
+func (s scalar) window(i int) uint64 {
+	return s[i/16] >> uint(4*(i%16)) & 0xf
+}
@@ -0,0 +1,152 @@
+package schnorr
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+)
+
+// A BatchVerifier verifies many signatures together, which is considerably
+// faster than verifying each in turn. It is safe for concurrent use, so the
+// workers validating the inputs of a block can share one.
+type BatchVerifier struct {
+	mu      sync.Mutex
+	entries []batchEntry
+}
+
+// A batchEntry is a signature queued for verification.
+type batchEntry struct {
+	pubKey *btcec.PublicKey
+	hash   []byte
+	sig    *Signature
+}
+
+// NewBatchVerifier returns an empty batch verifier.
+func NewBatchVerifier() *BatchVerifier {
+	return &BatchVerifier{}
+}
+
+// Add queues sig, a signature of the message hash by pubKey, for verification.
+func (b *BatchVerifier) Add(pubKey *btcec.PublicKey, hash []byte,
+	sig *Signature) {
+	b.mu.Lock()
+	b.entries = append(b.entries, batchEntry{pubKey, hash, sig})
+	b.mu.Unlock()
+}
+
+// Len returns the number of signatures queued.
+func (b *BatchVerifier) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// Verify verifies the queued signatures and returns the indices, in the
+// order they were added, of those which are invalid, or nil if all are
+// valid. The signatures are checked in a single batch; if it fails, its
+// halves are checked in turn to narrow down the invalid signatures, so a
+// batch with few of them costs little more than one without.
+func (b *BatchVerifier) Verify() []int {
+	b.mu.Lock()
+	entries := b.entries
+	b.mu.Unlock()
+
+	var invalid []int
+	findInvalid(entries, 0, &invalid)
+	return invalid
+}
+
+// findInvalid appends to invalid the indices of the invalid signatures of
+// entries, the first of which has index offset.
+func findInvalid(entries []batchEntry, offset int, invalid *[]int) {
+	if batchVerify(entries) {
+		return
+	}
+	if len(entries) == 1 {
+		*invalid = append(*invalid, offset)
+		return
+	}
+	mid := len(entries) / 2
+	findInvalid(entries[:mid], offset, invalid)
+	findInvalid(entries[mid:], offset+mid, invalid)
+}
+
+// batchVerify returns whether all signatures of entries are valid, as
+// specified by BIP340. Rather than checking s_i⋅G = R_i + e_i⋅P_i for each,
+// it checks a random linear combination of the equations,
+// (a_1⋅s_1 + ... + a_u⋅s_u)⋅G = a_1⋅R_1 + a_1⋅e_1⋅P_1 + ... + a_u⋅e_u⋅P_u,
+// with a multi-scalar multiplication. An invalid signature only passes if
+// the a_i happen to cancel it out, which they do with negligible
+// probability since the signer cannot predict them.
+func batchVerify(entries []batchEntry) bool {
+	switch len(entries) {
+	case 0:
+		return true
+	case 1:
+		e := entries[0]
+		return e.sig.Verify(e.hash, e.pubKey)
+	}
+
+	// The coefficients are derived from a random seed, with a_1 = 1.
+	var seed [32]byte
+	if _, err := io.ReadFull(rand.Reader, seed[:]); err != nil {
+		return false
+	}
+
+	curve := btcec.S256()
+	xs := make([]*big.Int, 0, 2*len(entries))
+	ys := make([]*big.Int, 0, 2*len(entries))
+	ks := make([][]byte, 0, 2*len(entries))
+	sum := new(big.Int)
+	for i, entry := range entries {
+		if entry.sig.R.Cmp(curve.P) >= 0 || entry.sig.S.Cmp(curve.N) >= 0 {
+			return false
+		}
+
+		// R is the point with x coordinate r and an even y coordinate.
+		var rBytes, pBytes [32]byte
+		entry.sig.R.FillBytes(rBytes[:])
+		r, err := ParsePubKey(rBytes[:])
+		if err != nil {
+			return false
+		}
+
+		// e = int(hash_challenge(r || P.x || m)) mod n
+		entry.pubKey.X.FillBytes(pBytes[:])
+		challenge := hashing.TaggedHash(challengeTag, rBytes[:], pBytes[:],
+			entry.hash)
+		e := new(big.Int).SetBytes(challenge[:])
+		e.Mod(e, curve.N)
+
+		a := big.NewInt(1)
+		if i > 0 {
+			var buf [36]byte
+			copy(buf[:], seed[:])
+			binary.BigEndian.PutUint32(buf[32:], uint32(i))
+			a.SetBytes(hashing.SHA256B(buf[:]))
+			a.Mod(a, curve.N)
+		}
+
+		py := entry.pubKey.Y
+		if py.Bit(0) == 1 {
+			py = new(big.Int).Sub(curve.P, py)
+		}
+		e.Mul(e, a)
+		e.Mod(e, curve.N)
+		xs = append(xs, r.X, entry.pubKey.X)
+		ys = append(ys, r.Y, py)
+		ks = append(ks, a.Bytes(), e.Bytes())
+
+		sum.Add(sum, new(big.Int).Mul(a, entry.sig.S))
+	}
+	sum.Mod(sum, curve.N)
+
+	x, y := curve.MultiScalarMult(xs, ys, ks)
+	gx, gy := curve.ScalarBaseMult(sum.Bytes())
+	return x.Cmp(gx) == 0 && y.Cmp(gy) == 0
+}
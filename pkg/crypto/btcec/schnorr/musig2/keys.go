@@ -0,0 +1,222 @@
+// Package musig2 implements the MuSig2 multi-signature scheme for BIP340
+// Schnorr signatures specified by BIP327. A group of signers aggregates its
+// public keys into a single key and jointly produces a signature which
+// verifies against that key like any other Schnorr signature, so an n-of-n
+// taproot key path spend looks the same on chain as a single-signer one.
+//
+// Signing takes two rounds. Each signer generates a nonce with NonceGen and
+// sends the public half to the others, and the public nonces are combined
+// with NonceAgg. Each signer then produces a partial signature with Sign,
+// which the others can check with PartialSigVerify, and the partial
+// signatures are combined into the final signature with PartialSigAgg.
+package musig2
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+)
+
+// Tags of the hashes used to aggregate and tweak public keys.
+const (
+	keyAggListTag  = "KeyAgg list"
+	keyAggCoeffTag = "KeyAgg coefficient"
+	tapTweakTag    = "TapTweak"
+)
+
+var (
+	// ErrNoPubKeys indicates an attempt to aggregate an empty list of
+	// public keys.
+	ErrNoPubKeys = errors.New("no public keys to aggregate")
+
+	// ErrInvalidKeyAgg indicates public keys whose aggregate is the point
+	// at infinity, which only happens if they were chosen adversarially.
+	ErrInvalidKeyAgg = errors.New("aggregate public key is the point at infinity")
+
+	// ErrInvalidTweak indicates a tweak which is not less than the curve
+	// order or which takes the aggregate key to the point at infinity.
+	ErrInvalidTweak = errors.New("invalid tweak")
+)
+
+// A Tweak is added to an aggregate public key. A plain tweak t takes the key
+// Q to Q + t⋅G, as in BIP32 derivation. An x-only tweak first negates Q if its
+// y coordinate is odd, as in BIP341 taproot tweaking.
+type Tweak struct {
+	Tweak [32]byte
+	XOnly bool
+}
+
+// A KeyAggContext is the aggregate of the public keys of a group of signers
+// with any tweaks applied to it. It is immutable: applying a tweak returns a
+// new context.
+type KeyAggContext struct {
+	pubKeys    []*btcec.PublicKey
+	keys       [][]byte // compressed pubKeys
+	listHash   hashing.Hash
+	secondKey  []byte
+	tweaks     []Tweak
+	q          *btcec.PublicKey
+	gacc, tacc *big.Int
+}
+
+// KeySort returns pubKeys sorted by their compressed serializations. Signers
+// who sort their keys before aggregating them get the same aggregate key
+// whatever order the keys were exchanged in.
+func KeySort(pubKeys []*btcec.PublicKey) []*btcec.PublicKey {
+	sorted := make([]*btcec.PublicKey, len(pubKeys))
+	copy(sorted, pubKeys)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].SerializeCompressed(),
+			sorted[j].SerializeCompressed()) < 0
+	})
+	return sorted
+}
+
+// KeyAgg aggregates the public keys of a group of signers, in the order
+// given, into a single public key: Q = a_1⋅P_1 + ... + a_u⋅P_u, where each
+// coefficient a_i commits to the whole list of keys so that no signer can
+// choose a key which cancels out the others.
+func KeyAgg(pubKeys []*btcec.PublicKey) (*KeyAggContext, error) {
+	if len(pubKeys) == 0 {
+		return nil, ErrNoPubKeys
+	}
+
+	c := &KeyAggContext{
+		pubKeys: append([]*btcec.PublicKey(nil), pubKeys...),
+		keys:    make([][]byte, len(pubKeys)),
+		gacc:    big.NewInt(1),
+		tacc:    new(big.Int),
+	}
+	for i, pubKey := range pubKeys {
+		c.keys[i] = pubKey.SerializeCompressed()
+	}
+	c.listHash = hashing.TaggedHash(keyAggListTag, c.keys...)
+
+	// The coefficient of the first key differing from the first in the
+	// list is one, which saves a scalar multiplication when verifying.
+	for _, key := range c.keys[1:] {
+		if !bytes.Equal(key, c.keys[0]) {
+			c.secondKey = key
+			break
+		}
+	}
+
+	curve := btcec.S256()
+	qx, qy := new(big.Int), new(big.Int)
+	for i, pubKey := range pubKeys {
+		a := c.coefficient(c.keys[i])
+		x, y := curve.ScalarMult(pubKey.X, pubKey.Y, a.Bytes())
+		qx, qy = curve.Add(qx, qy, x, y)
+	}
+	if isInfinity(qx, qy) {
+		return nil, ErrInvalidKeyAgg
+	}
+	c.q = &btcec.PublicKey{Curve: curve, X: qx, Y: qy}
+	return c, nil
+}
+
+// coefficient returns the aggregation coefficient of key, the compressed
+// serialization of one of the public keys of c.
+func (c *KeyAggContext) coefficient(key []byte) *big.Int {
+	if bytes.Equal(key, c.secondKey) {
+		return big.NewInt(1)
+	}
+	hash := hashing.TaggedHash(keyAggCoeffTag, c.listHash[:], key)
+	a := new(big.Int).SetBytes(hash[:])
+	return a.Mod(a, btcec.S256().N)
+}
+
+// hasKey returns whether key is the compressed serialization of one of the
+// public keys of c.
+func (c *KeyAggContext) hasKey(key []byte) bool {
+	for _, k := range c.keys {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyTweak returns the context of the aggregate key of c with tweak
+// applied to it.
+func (c *KeyAggContext) ApplyTweak(tweak Tweak) (*KeyAggContext, error) {
+	curve := btcec.S256()
+	t := new(big.Int).SetBytes(tweak.Tweak[:])
+	if t.Cmp(curve.N) >= 0 {
+		return nil, ErrInvalidTweak
+	}
+
+	// An x-only tweak applies to the key with an even y coordinate, so
+	// an odd key is negated first: Q' = g⋅Q + t⋅G with g = -1.
+	g := big.NewInt(1)
+	qx, qy := c.q.X, c.q.Y
+	if tweak.XOnly && qy.Bit(0) == 1 {
+		g.Sub(curve.N, g)
+		qy = new(big.Int).Sub(curve.P, qy)
+	}
+	tx, ty := curve.ScalarBaseMult(tweak.Tweak[:])
+	qx, qy = curve.Add(qx, qy, tx, ty)
+	if isInfinity(qx, qy) {
+		return nil, ErrInvalidTweak
+	}
+
+	gacc := new(big.Int).Mul(g, c.gacc)
+	gacc.Mod(gacc, curve.N)
+	tacc := new(big.Int).Mul(g, c.tacc)
+	tacc.Add(tacc, t)
+	tacc.Mod(tacc, curve.N)
+
+	tweaked := *c
+	tweaked.tweaks = append(c.Tweaks(), tweak)
+	tweaked.q = &btcec.PublicKey{Curve: curve, X: qx, Y: qy}
+	tweaked.gacc, tweaked.tacc = gacc, tacc
+	return &tweaked, nil
+}
+
+// ApplyTaprootTweak returns the context of the taproot output key whose
+// internal key is the aggregate key of c and whose script tree has the given
+// merkle root, or nil if it has none. The signers of a session with the
+// resulting context produce a key path spend of the output.
+func (c *KeyAggContext) ApplyTaprootTweak(merkleRoot []byte) (*KeyAggContext,
+	error) {
+	hash := hashing.TaggedHash(tapTweakTag, schnorr.SerializePubKey(c.q),
+		merkleRoot)
+	return c.ApplyTweak(Tweak{Tweak: hash, XOnly: true})
+}
+
+// PubKey returns the aggregate public key, with any tweaks applied. Its
+// x-only serialization is the key which the final signature verifies
+// against.
+func (c *KeyAggContext) PubKey() *btcec.PublicKey {
+	return c.q
+}
+
+// PubKeys returns the public keys which were aggregated, in order.
+func (c *KeyAggContext) PubKeys() []*btcec.PublicKey {
+	return append([]*btcec.PublicKey(nil), c.pubKeys...)
+}
+
+// Tweaks returns the tweaks applied to the aggregate key, in order.
+func (c *KeyAggContext) Tweaks() []Tweak {
+	return append([]Tweak(nil), c.tweaks...)
+}
+
+// parity returns 1 if the aggregate key has an even y coordinate and -1
+// modulo the curve order otherwise.
+func (c *KeyAggContext) parity() *big.Int {
+	if c.q.Y.Bit(0) == 1 {
+		return new(big.Int).Sub(btcec.S256().N, big.NewInt(1))
+	}
+	return big.NewInt(1)
+}
+
+// isInfinity returns whether (x, y) is the point at infinity, which the
+// curve operations represent as (0, 0).
+func isInfinity(x, y *big.Int) bool {
+	return x.Sign() == 0 && y.Sign() == 0
+}
@@ -0,0 +1,672 @@
+package musig2
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+)
+
+// readVectors decodes the BIP327 vector file name in testdata into v.
+func readVectors(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// decodeHex decodes the hex string s, failing the test if it is invalid.
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// vectorError is the error expected by a vector: either an invalid
+// contribution of a signer, or an invalid value.
+type vectorError struct {
+	Type    string `json:"type"`
+	Signer  *int   `json:"signer"`
+	Contrib string `json:"contrib"`
+}
+
+// parseKeys parses the compressed public keys of keys with the given
+// indices. If one does not parse, its position among the indices is
+// returned with it.
+func parseKeys(t *testing.T, keys []string, indices []int) ([]*btcec.PublicKey,
+	int, error) {
+	t.Helper()
+	pubKeys := make([]*btcec.PublicKey, len(indices))
+	for i, idx := range indices {
+		pubKey, err := parsePoint(decodeHex(t, keys[idx]))
+		if err != nil {
+			return nil, i, err
+		}
+		pubKeys[i] = pubKey
+	}
+	return pubKeys, 0, nil
+}
+
+// tweakKeyAgg applies the tweaks with the given indices to keyAgg.
+func tweakKeyAgg(t *testing.T, keyAgg *KeyAggContext, tweaks []string,
+	indices []int, xOnly []bool) (*KeyAggContext, error) {
+	t.Helper()
+	for i, idx := range indices {
+		var tweak Tweak
+		copy(tweak.Tweak[:], decodeHex(t, tweaks[idx]))
+		tweak.XOnly = xOnly[i]
+		var err error
+		keyAgg, err = keyAgg.ApplyTweak(tweak)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keyAgg, nil
+}
+
+// pubNonces returns the public nonces of nonces with the given indices.
+func pubNonces(t *testing.T, nonces []string, indices []int) []PubNonce {
+	t.Helper()
+	pubNonces := make([]PubNonce, len(indices))
+	for i, idx := range indices {
+		copy(pubNonces[i][:], decodeHex(t, nonces[idx]))
+	}
+	return pubNonces
+}
+
+func TestKeyAggVectors(t *testing.T) {
+	var vectors struct {
+		PubKeys []string `json:"pubkeys"`
+		Tweaks  []string `json:"tweaks"`
+		Valid   []struct {
+			KeyIndices []int  `json:"key_indices"`
+			Expected   string `json:"expected"`
+		} `json:"valid_test_cases"`
+		Errors []struct {
+			KeyIndices   []int       `json:"key_indices"`
+			TweakIndices []int       `json:"tweak_indices"`
+			IsXOnly      []bool      `json:"is_xonly"`
+			Error        vectorError `json:"error"`
+			Comment      string      `json:"comment"`
+		} `json:"error_test_cases"`
+	}
+	readVectors(t, "key_agg_vectors.json", &vectors)
+
+	for i, test := range vectors.Valid {
+		pubKeys, _, err := parseKeys(t, vectors.PubKeys, test.KeyIndices)
+		if err != nil {
+			t.Fatalf("valid %d: %v", i, err)
+		}
+		keyAgg, err := KeyAgg(pubKeys)
+		if err != nil {
+			t.Errorf("valid %d: %v", i, err)
+			continue
+		}
+		got := schnorr.SerializePubKey(keyAgg.PubKey())
+		if want := decodeHex(t, test.Expected); !bytes.Equal(got, want) {
+			t.Errorf("valid %d: got %X, want %X", i, got, want)
+		}
+	}
+
+	for _, test := range vectors.Errors {
+		pubKeys, signer, err := parseKeys(t, vectors.PubKeys, test.KeyIndices)
+		if test.Error.Type == "invalid_contribution" {
+			if err == nil || signer != *test.Error.Signer {
+				t.Errorf("%s: got signer %d error %v, want signer %d",
+					test.Comment, signer, err, *test.Error.Signer)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+		keyAgg, err := KeyAgg(pubKeys)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+		_, err = tweakKeyAgg(t, keyAgg, vectors.Tweaks, test.TweakIndices,
+			test.IsXOnly)
+		if err != ErrInvalidTweak {
+			t.Errorf("%s: got %v, want %v", test.Comment, err, ErrInvalidTweak)
+		}
+	}
+}
+
+func TestKeySort(t *testing.T) {
+	var vectors struct {
+		PubKeys []string `json:"pubkeys"`
+	}
+	readVectors(t, "key_agg_vectors.json", &vectors)
+	pubKeys, _, err := parseKeys(t, vectors.PubKeys, []int{1, 0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := pubKeys[0]
+	sorted := KeySort(pubKeys)
+	for i := 1; i < len(sorted); i++ {
+		if bytes.Compare(sorted[i-1].SerializeCompressed(),
+			sorted[i].SerializeCompressed()) > 0 {
+			t.Fatalf("keys %d and %d out of order", i-1, i)
+		}
+	}
+	if pubKeys[0] != first {
+		t.Error("sorting changed the order of its argument")
+	}
+}
+
+func TestNonceGenVectors(t *testing.T) {
+	var vectors struct {
+		TestCases []struct {
+			Rand             string  `json:"rand_"`
+			SecKey           *string `json:"sk"`
+			PubKey           string  `json:"pk"`
+			AggPubKey        *string `json:"aggpk"`
+			Msg              *string `json:"msg"`
+			ExtraIn          *string `json:"extra_in"`
+			ExpectedSecNonce string  `json:"expected_secnonce"`
+			ExpectedPubNonce string  `json:"expected_pubnonce"`
+		} `json:"test_cases"`
+	}
+	readVectors(t, "nonce_gen_vectors.json", &vectors)
+
+	for i, test := range vectors.TestCases {
+		pubKey, err := btcec.ParsePubKey(decodeHex(t, test.PubKey))
+		if err != nil {
+			t.Fatalf("vector %d: %v", i, err)
+		}
+		var secKey *btcec.PrivateKey
+		if test.SecKey != nil {
+			secKey, _ = btcec.PrivKeyFromBytes(decodeHex(t, *test.SecKey))
+		}
+		var aggPubKey *btcec.PublicKey
+		if test.AggPubKey != nil {
+			aggPubKey, err = schnorr.ParsePubKey(decodeHex(t, *test.AggPubKey))
+			if err != nil {
+				t.Fatalf("vector %d: %v", i, err)
+			}
+		}
+
+		// A missing message is distinct from an empty one.
+		var msg, extraIn []byte
+		if test.Msg != nil {
+			msg = decodeHex(t, *test.Msg)
+		}
+		if test.ExtraIn != nil {
+			extraIn = decodeHex(t, *test.ExtraIn)
+		}
+
+		secNonce, pubNonce, err := nonceGen(decodeHex(t, test.Rand), pubKey,
+			secKey, aggPubKey, msg, extraIn)
+		if err != nil {
+			t.Errorf("vector %d: %v", i, err)
+			continue
+		}
+		if want := decodeHex(t, test.ExpectedSecNonce); !bytes.Equal(secNonce[:], want) {
+			t.Errorf("vector %d: secret nonce %X, want %X", i, secNonce[:], want)
+		}
+		if want := decodeHex(t, test.ExpectedPubNonce); !bytes.Equal(pubNonce[:], want) {
+			t.Errorf("vector %d: public nonce %X, want %X", i, pubNonce[:], want)
+		}
+	}
+}
+
+func TestNonceAggVectors(t *testing.T) {
+	var vectors struct {
+		PubNonces []string `json:"pnonces"`
+		Valid     []struct {
+			NonceIndices []int  `json:"pnonce_indices"`
+			Expected     string `json:"expected"`
+		} `json:"valid_test_cases"`
+		Errors []struct {
+			NonceIndices []int       `json:"pnonce_indices"`
+			Error        vectorError `json:"error"`
+			Comment      string      `json:"comment"`
+		} `json:"error_test_cases"`
+	}
+	readVectors(t, "nonce_agg_vectors.json", &vectors)
+
+	for i, test := range vectors.Valid {
+		aggNonce, err := NonceAgg(pubNonces(t, vectors.PubNonces, test.NonceIndices))
+		if err != nil {
+			t.Errorf("valid %d: %v", i, err)
+			continue
+		}
+		if want := decodeHex(t, test.Expected); !bytes.Equal(aggNonce[:], want) {
+			t.Errorf("valid %d: got %X, want %X", i, aggNonce[:], want)
+		}
+	}
+
+	for _, test := range vectors.Errors {
+		_, err := NonceAgg(pubNonces(t, vectors.PubNonces, test.NonceIndices))
+		want := fmt.Sprintf("%v: signer %d", ErrInvalidPubNonce, *test.Error.Signer)
+		if !errors.Is(err, ErrInvalidPubNonce) || err.Error() != want {
+			t.Errorf("%s: got %v, want %s", test.Comment, err, want)
+		}
+	}
+}
+
+func TestSignVerifyVectors(t *testing.T) {
+	var vectors struct {
+		SecKey    string   `json:"sk"`
+		PubKeys   []string `json:"pubkeys"`
+		SecNonces []string `json:"secnonces"`
+		PubNonces []string `json:"pnonces"`
+		AggNonces []string `json:"aggnonces"`
+		Msgs      []string `json:"msgs"`
+		Valid     []struct {
+			KeyIndices    []int  `json:"key_indices"`
+			NonceIndices  []int  `json:"nonce_indices"`
+			AggNonceIndex int    `json:"aggnonce_index"`
+			MsgIndex      int    `json:"msg_index"`
+			SignerIndex   int    `json:"signer_index"`
+			Expected      string `json:"expected"`
+			Comment       string `json:"comment"`
+		} `json:"valid_test_cases"`
+		SignErrors []struct {
+			KeyIndices    []int       `json:"key_indices"`
+			AggNonceIndex int         `json:"aggnonce_index"`
+			MsgIndex      int         `json:"msg_index"`
+			SecNonceIndex int         `json:"secnonce_index"`
+			Error         vectorError `json:"error"`
+			Comment       string      `json:"comment"`
+		} `json:"sign_error_test_cases"`
+		VerifyFails []struct {
+			Sig          string      `json:"sig"`
+			KeyIndices   []int       `json:"key_indices"`
+			NonceIndices []int       `json:"nonce_indices"`
+			MsgIndex     int         `json:"msg_index"`
+			SignerIndex  int         `json:"signer_index"`
+			Error        vectorError `json:"error"`
+			Comment      string      `json:"comment"`
+		} `json:"verify_fail_test_cases"`
+		VerifyErrors []struct {
+			Sig          string      `json:"sig"`
+			KeyIndices   []int       `json:"key_indices"`
+			NonceIndices []int       `json:"nonce_indices"`
+			MsgIndex     int         `json:"msg_index"`
+			SignerIndex  int         `json:"signer_index"`
+			Error        vectorError `json:"error"`
+			Comment      string      `json:"comment"`
+		} `json:"verify_error_test_cases"`
+	}
+	readVectors(t, "sign_verify_vectors.json", &vectors)
+	secKey, _ := btcec.PrivKeyFromBytes(decodeHex(t, vectors.SecKey))
+
+	// session returns the session context of a vector.
+	session := func(pubKeys []*btcec.PublicKey, aggNonceIndex,
+		msgIndex int) *SessionContext {
+		s := &SessionContext{
+			PubKeys: pubKeys,
+			Msg:     decodeHex(t, vectors.Msgs[msgIndex]),
+		}
+		copy(s.AggNonce[:], decodeHex(t, vectors.AggNonces[aggNonceIndex]))
+		return s
+	}
+	secNonce := func(i int) *SecNonce {
+		var secNonce SecNonce
+		copy(secNonce[:], decodeHex(t, vectors.SecNonces[i]))
+		return &secNonce
+	}
+
+	for _, test := range vectors.Valid {
+		name := test.Comment
+		if name == "" {
+			name = fmt.Sprintf("signer %d", test.SignerIndex)
+		}
+		pubKeys, _, err := parseKeys(t, vectors.PubKeys, test.KeyIndices)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		nonces := pubNonces(t, vectors.PubNonces, test.NonceIndices)
+		aggNonce, err := NonceAgg(nonces)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		s := session(pubKeys, test.AggNonceIndex, test.MsgIndex)
+		if aggNonce != s.AggNonce {
+			t.Errorf("%s: aggregate nonce %X, want %X", name, aggNonce[:],
+				s.AggNonce[:])
+		}
+
+		psig, err := Sign(secNonce(0), secKey, s)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if want := decodeHex(t, test.Expected); !bytes.Equal(psig[:], want) {
+			t.Errorf("%s: got %X, want %X", name, psig[:], want)
+		}
+		if !PartialSigVerify(psig, &nonces[test.SignerIndex],
+			pubKeys[test.SignerIndex], s) {
+			t.Errorf("%s: partial signature does not verify", name)
+		}
+	}
+
+	for _, test := range vectors.SignErrors {
+		pubKeys, signer, err := parseKeys(t, vectors.PubKeys, test.KeyIndices)
+		if test.Error.Contrib == "pubkey" {
+			if err == nil || signer != *test.Error.Signer {
+				t.Errorf("%s: got signer %d error %v, want signer %d",
+					test.Comment, signer, err, *test.Error.Signer)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+
+		var want error
+		switch {
+		case test.Error.Contrib == "aggnonce":
+			want = ErrInvalidAggNonce
+		case test.SecNonceIndex != 0:
+			want = ErrInvalidSecNonce
+		default:
+			want = ErrKeyNotInSession
+		}
+		s := session(pubKeys, test.AggNonceIndex, test.MsgIndex)
+		if _, err := Sign(secNonce(test.SecNonceIndex), secKey, s); err != want {
+			t.Errorf("%s: got %v, want %v", test.Comment, err, want)
+		}
+	}
+
+	for _, test := range vectors.VerifyFails {
+		pubKeys, _, err := parseKeys(t, vectors.PubKeys, test.KeyIndices)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+		nonces := pubNonces(t, vectors.PubNonces, test.NonceIndices)
+		aggNonce, err := NonceAgg(nonces)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+		s := &SessionContext{
+			AggNonce: aggNonce,
+			PubKeys:  pubKeys,
+			Msg:      decodeHex(t, vectors.Msgs[test.MsgIndex]),
+		}
+		var psig PartialSig
+		copy(psig[:], decodeHex(t, test.Sig))
+		if PartialSigVerify(psig, &nonces[test.SignerIndex],
+			pubKeys[test.SignerIndex], s) {
+			t.Errorf("%s: partial signature verifies", test.Comment)
+		}
+	}
+
+	for _, test := range vectors.VerifyErrors {
+		pubKeys, signer, err := parseKeys(t, vectors.PubKeys, test.KeyIndices)
+		if test.Error.Contrib == "pubkey" {
+			if err == nil || signer != *test.Error.Signer {
+				t.Errorf("%s: got signer %d error %v, want signer %d",
+					test.Comment, signer, err, *test.Error.Signer)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+
+		// The invalid public nonce cannot be aggregated, and the
+		// partial signature does not verify with it.
+		nonces := pubNonces(t, vectors.PubNonces, test.NonceIndices)
+		if _, err := NonceAgg(nonces); !errors.Is(err, ErrInvalidPubNonce) {
+			t.Errorf("%s: got %v, want %v", test.Comment, err,
+				ErrInvalidPubNonce)
+		}
+		s := session(pubKeys, 0, test.MsgIndex)
+		var psig PartialSig
+		copy(psig[:], decodeHex(t, test.Sig))
+		if PartialSigVerify(psig, &nonces[test.SignerIndex],
+			pubKeys[test.SignerIndex], s) {
+			t.Errorf("%s: partial signature verifies", test.Comment)
+		}
+	}
+}
+
+func TestTweakVectors(t *testing.T) {
+	var vectors struct {
+		SecKey    string   `json:"sk"`
+		PubKeys   []string `json:"pubkeys"`
+		SecNonce  string   `json:"secnonce"`
+		PubNonces []string `json:"pnonces"`
+		AggNonce  string   `json:"aggnonce"`
+		Tweaks    []string `json:"tweaks"`
+		Msg       string   `json:"msg"`
+		Valid     []struct {
+			KeyIndices   []int  `json:"key_indices"`
+			NonceIndices []int  `json:"nonce_indices"`
+			TweakIndices []int  `json:"tweak_indices"`
+			IsXOnly      []bool `json:"is_xonly"`
+			SignerIndex  int    `json:"signer_index"`
+			Expected     string `json:"expected"`
+			Comment      string `json:"comment"`
+		} `json:"valid_test_cases"`
+		Errors []struct {
+			KeyIndices   []int  `json:"key_indices"`
+			TweakIndices []int  `json:"tweak_indices"`
+			IsXOnly      []bool `json:"is_xonly"`
+			Comment      string `json:"comment"`
+		} `json:"error_test_cases"`
+	}
+	readVectors(t, "tweak_vectors.json", &vectors)
+	secKey, _ := btcec.PrivKeyFromBytes(decodeHex(t, vectors.SecKey))
+	var aggNonce AggNonce
+	copy(aggNonce[:], decodeHex(t, vectors.AggNonce))
+
+	for _, test := range vectors.Valid {
+		pubKeys, _, err := parseKeys(t, vectors.PubKeys, test.KeyIndices)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+		keyAgg, err := KeyAgg(pubKeys)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+		keyAgg, err = tweakKeyAgg(t, keyAgg, vectors.Tweaks, test.TweakIndices,
+			test.IsXOnly)
+		if err != nil {
+			t.Errorf("%s: %v", test.Comment, err)
+			continue
+		}
+		nonces := pubNonces(t, vectors.PubNonces, test.NonceIndices)
+		if got, err := NonceAgg(nonces); err != nil || got != aggNonce {
+			t.Fatalf("%s: aggregate nonce %X, %v", test.Comment, got[:], err)
+		}
+
+		s := NewSessionContext(keyAgg, aggNonce, decodeHex(t, vectors.Msg))
+		var secNonce SecNonce
+		copy(secNonce[:], decodeHex(t, vectors.SecNonce))
+		psig, err := Sign(&secNonce, secKey, s)
+		if err != nil {
+			t.Errorf("%s: %v", test.Comment, err)
+			continue
+		}
+		if want := decodeHex(t, test.Expected); !bytes.Equal(psig[:], want) {
+			t.Errorf("%s: got %X, want %X", test.Comment, psig[:], want)
+		}
+		if !PartialSigVerify(psig, &nonces[test.SignerIndex],
+			pubKeys[test.SignerIndex], s) {
+			t.Errorf("%s: partial signature does not verify", test.Comment)
+		}
+	}
+
+	for _, test := range vectors.Errors {
+		pubKeys, _, err := parseKeys(t, vectors.PubKeys, test.KeyIndices)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+		keyAgg, err := KeyAgg(pubKeys)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Comment, err)
+		}
+		_, err = tweakKeyAgg(t, keyAgg, vectors.Tweaks, test.TweakIndices,
+			test.IsXOnly)
+		if err != ErrInvalidTweak {
+			t.Errorf("%s: got %v, want %v", test.Comment, err, ErrInvalidTweak)
+		}
+	}
+}
+
+func TestSigAggVectors(t *testing.T) {
+	var vectors struct {
+		PubKeys   []string `json:"pubkeys"`
+		PubNonces []string `json:"pnonces"`
+		Tweaks    []string `json:"tweaks"`
+		PartSigs  []string `json:"psigs"`
+		Msg       string   `json:"msg"`
+		Valid     []struct {
+			AggNonce     string `json:"aggnonce"`
+			NonceIndices []int  `json:"nonce_indices"`
+			KeyIndices   []int  `json:"key_indices"`
+			TweakIndices []int  `json:"tweak_indices"`
+			IsXOnly      []bool `json:"is_xonly"`
+			PSigIndices  []int  `json:"psig_indices"`
+			Expected     string `json:"expected"`
+		} `json:"valid_test_cases"`
+		Errors []struct {
+			AggNonce     string      `json:"aggnonce"`
+			KeyIndices   []int       `json:"key_indices"`
+			TweakIndices []int       `json:"tweak_indices"`
+			IsXOnly      []bool      `json:"is_xonly"`
+			PSigIndices  []int       `json:"psig_indices"`
+			Error        vectorError `json:"error"`
+			Comment      string      `json:"comment"`
+		} `json:"error_test_cases"`
+	}
+	readVectors(t, "sig_agg_vectors.json", &vectors)
+	msg := decodeHex(t, vectors.Msg)
+
+	// session returns the session context of a vector.
+	session := func(keyIndices, tweakIndices []int, xOnly []bool,
+		aggNonceHex string) (*KeyAggContext, *SessionContext) {
+		pubKeys, _, err := parseKeys(t, vectors.PubKeys, keyIndices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyAgg, err := KeyAgg(pubKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyAgg, err = tweakKeyAgg(t, keyAgg, vectors.Tweaks, tweakIndices, xOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var aggNonce AggNonce
+		copy(aggNonce[:], decodeHex(t, aggNonceHex))
+		return keyAgg, NewSessionContext(keyAgg, aggNonce, msg)
+	}
+	psigs := func(indices []int) []PartialSig {
+		psigs := make([]PartialSig, len(indices))
+		for i, idx := range indices {
+			copy(psigs[i][:], decodeHex(t, vectors.PartSigs[idx]))
+		}
+		return psigs
+	}
+
+	for i, test := range vectors.Valid {
+		keyAgg, s := session(test.KeyIndices, test.TweakIndices, test.IsXOnly,
+			test.AggNonce)
+		aggNonce, err := NonceAgg(pubNonces(t, vectors.PubNonces, test.NonceIndices))
+		if err != nil || aggNonce != s.AggNonce {
+			t.Fatalf("valid %d: aggregate nonce %X, %v", i, aggNonce[:], err)
+		}
+
+		sig, err := PartialSigAgg(psigs(test.PSigIndices), s)
+		if err != nil {
+			t.Errorf("valid %d: %v", i, err)
+			continue
+		}
+		if want := decodeHex(t, test.Expected); !bytes.Equal(sig.Serialize(), want) {
+			t.Errorf("valid %d: got %X, want %X", i, sig.Serialize(), want)
+		}
+		if !sig.Verify(msg, keyAgg.PubKey()) {
+			t.Errorf("valid %d: signature does not verify", i)
+		}
+	}
+
+	for _, test := range vectors.Errors {
+		_, s := session(test.KeyIndices, test.TweakIndices, test.IsXOnly,
+			test.AggNonce)
+		_, err := PartialSigAgg(psigs(test.PSigIndices), s)
+		want := fmt.Sprintf("%v: signer %d", ErrInvalidPartialSig,
+			*test.Error.Signer)
+		if !errors.Is(err, ErrInvalidPartialSig) || err.Error() != want {
+			t.Errorf("%s: got %v, want %s", test.Comment, err, want)
+		}
+	}
+}
+
+func TestSessionContextSerialize(t *testing.T) {
+	var vectors struct {
+		PubKeys  []string `json:"pubkeys"`
+		AggNonce string   `json:"aggnonce"`
+		Tweaks   []string `json:"tweaks"`
+		Msg      string   `json:"msg"`
+	}
+	readVectors(t, "tweak_vectors.json", &vectors)
+	pubKeys, _, err := parseKeys(t, vectors.PubKeys, []int{1, 2, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyAgg, err := KeyAgg(pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyAgg, err = tweakKeyAgg(t, keyAgg, vectors.Tweaks, []int{0, 1},
+		[]bool{false, true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var aggNonce AggNonce
+	copy(aggNonce[:], decodeHex(t, vectors.AggNonce))
+	s := NewSessionContext(keyAgg, aggNonce, decodeHex(t, vectors.Msg))
+
+	b := s.Serialize()
+	parsed, err := ParseSessionContext(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsed.Serialize(), b) {
+		t.Fatalf("parsed as %X, want %X", parsed.Serialize(), b)
+	}
+	parsedKeyAgg, err := parsed.KeyAgg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsedKeyAgg.PubKey().IsEqual(keyAgg.PubKey()) {
+		t.Error("parsed session has a different aggregate key")
+	}
+
+	// Every truncation, trailing data and an unknown tweak flag are
+	// rejected.
+	for n := 0; n < len(b); n++ {
+		if _, err := ParseSessionContext(b[:n]); err != ErrInvalidSessionContext {
+			t.Errorf("truncated to %d bytes: got %v, want %v", n, err,
+				ErrInvalidSessionContext)
+		}
+	}
+	if _, err := ParseSessionContext(append(b[:len(b):len(b)], 0)); err != ErrInvalidSessionContext {
+		t.Errorf("trailing data: got %v, want %v", err, ErrInvalidSessionContext)
+	}
+	flag := AggNonceSize + 4 + len(pubKeys)*btcec.PubKeyBytesLenCompressed + 4 + 32
+	bad := append([]byte(nil), b...)
+	bad[flag] = 2
+	if _, err := ParseSessionContext(bad); err != ErrInvalidSessionContext {
+		t.Errorf("tweak flag 2: got %v, want %v", err, ErrInvalidSessionContext)
+	}
+}
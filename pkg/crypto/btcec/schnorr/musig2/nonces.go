@@ -0,0 +1,191 @@
+package musig2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+)
+
+// These constants define the sizes of serialized nonces.
+const (
+	SecNonceSize = 97
+	PubNonceSize = 66
+	AggNonceSize = 66
+)
+
+// Tags of the hashes used to generate nonces.
+const (
+	auxTag   = "MuSig/aux"
+	nonceTag = "MuSig/nonce"
+)
+
+var (
+	// ErrInvalidPubNonce indicates a public nonce which is not two
+	// compressed points.
+	ErrInvalidPubNonce = errors.New("invalid public nonce")
+
+	// ErrInvalidAggNonce indicates an aggregate nonce which is not two
+	// compressed points or points at infinity.
+	ErrInvalidAggNonce = errors.New("invalid aggregate nonce")
+
+	// ErrInvalidSecNonce indicates a secret nonce which is malformed,
+	// belongs to another key or has already been used to sign.
+	ErrInvalidSecNonce = errors.New("invalid or reused secret nonce")
+
+	// ErrNonceGen indicates that nonce generation produced a zero nonce,
+	// which happens with negligible probability.
+	ErrNonceGen = errors.New("nonce generation failed")
+)
+
+// A SecNonce is the secret half of a signer's nonce: the two nonce scalars
+// followed by the signer's compressed public key. It must never be used to
+// sign more than once, so it must not be copied or persisted; Sign zeroes it.
+type SecNonce [SecNonceSize]byte
+
+// A PubNonce is the public half of a signer's nonce, the two compressed
+// nonce points, which is sent to the other signers.
+type PubNonce [PubNonceSize]byte
+
+// An AggNonce is the sum of the public nonces of all signers of a session.
+// Either point may be at infinity, which is serialized as 33 zero bytes.
+type AggNonce [AggNonceSize]byte
+
+// NonceGen generates a fresh nonce for a signing session of the signer with
+// public key pubKey. secKey, aggPubKey, msg and extraIn are optional and
+// may be nil: whatever is known of the signer's private key, the aggregate
+// public key, the message and any other session data is mixed into the
+// nonce, which keeps it unpredictable should the random number generator
+// fail. A nil msg means the message is not known, which is distinct from an
+// empty message.
+func NonceGen(pubKey *btcec.PublicKey, secKey *btcec.PrivateKey,
+	aggPubKey *btcec.PublicKey, msg, extraIn []byte) (*SecNonce, *PubNonce,
+	error) {
+	var randBytes [32]byte
+	if _, err := io.ReadFull(rand.Reader, randBytes[:]); err != nil {
+		return nil, nil, err
+	}
+	return nonceGen(randBytes[:], pubKey, secKey, aggPubKey, msg, extraIn)
+}
+
+// nonceGen derives a nonce from the 32 random bytes randBytes and the
+// optional arguments of NonceGen.
+func nonceGen(randBytes []byte, pubKey *btcec.PublicKey,
+	secKey *btcec.PrivateKey, aggPubKey *btcec.PublicKey, msg,
+	extraIn []byte) (*SecNonce, *PubNonce, error) {
+	if secKey != nil {
+		var sk [32]byte
+		secKey.D.FillBytes(sk[:])
+		aux := hashing.TaggedHash(auxTag, randBytes)
+		masked := make([]byte, len(sk))
+		for i := range masked {
+			masked[i] = sk[i] ^ aux[i]
+		}
+		randBytes = masked
+	}
+
+	pk := pubKey.SerializeCompressed()
+	var aggPk []byte
+	if aggPubKey != nil {
+		aggPk = schnorr.SerializePubKey(aggPubKey)
+	}
+
+	// rand || len(pk) || pk || len(aggpk) || aggpk || m_prefixed ||
+	// len(extra_in) || extra_in, with m_prefixed either 0 for an unknown
+	// message or 1 || len(m) || m.
+	var buf bytes.Buffer
+	buf.Write(randBytes)
+	buf.WriteByte(byte(len(pk)))
+	buf.Write(pk)
+	buf.WriteByte(byte(len(aggPk)))
+	buf.Write(aggPk)
+	if msg == nil {
+		buf.WriteByte(0)
+	} else {
+		buf.WriteByte(1)
+		binary.Write(&buf, binary.BigEndian, uint64(len(msg)))
+		buf.Write(msg)
+	}
+	binary.Write(&buf, binary.BigEndian, uint32(len(extraIn)))
+	buf.Write(extraIn)
+
+	curve := btcec.S256()
+	var secNonce SecNonce
+	var pubNonce PubNonce
+	for i := 0; i < 2; i++ {
+		hash := hashing.TaggedHash(nonceTag, buf.Bytes(), []byte{byte(i)})
+		k := new(big.Int).SetBytes(hash[:])
+		k.Mod(k, curve.N)
+		if k.Sign() == 0 {
+			return nil, nil, ErrNonceGen
+		}
+		k.FillBytes(secNonce[32*i : 32*(i+1)])
+		x, y := curve.ScalarBaseMult(k.Bytes())
+		copy(pubNonce[33*i:], serializePoint(x, y))
+	}
+	copy(secNonce[64:], pk)
+	return &secNonce, &pubNonce, nil
+}
+
+// NonceAgg returns the aggregate of the public nonces of all signers of a
+// session. An invalid nonce is reported with the index of its signer.
+func NonceAgg(pubNonces []PubNonce) (AggNonce, error) {
+	curve := btcec.S256()
+	var aggNonce AggNonce
+	for j := 0; j < 2; j++ {
+		rx, ry := new(big.Int), new(big.Int)
+		for i := range pubNonces {
+			r, err := parsePoint(pubNonces[i][33*j : 33*(j+1)])
+			if err != nil {
+				return AggNonce{}, fmt.Errorf("%w: signer %d",
+					ErrInvalidPubNonce, i)
+			}
+			rx, ry = curve.Add(rx, ry, r.X, r.Y)
+		}
+		copy(aggNonce[33*j:], serializePointExt(rx, ry))
+	}
+	return aggNonce, nil
+}
+
+// parsePoint parses a point from its compressed serialization.
+func parsePoint(b []byte) (*btcec.PublicKey, error) {
+	if len(b) != btcec.PubKeyBytesLenCompressed {
+		return nil, ErrInvalidPubNonce
+	}
+	return btcec.ParsePubKeyStrict(b)
+}
+
+// parsePointExt parses a point like parsePoint, but also accepts 33 zero
+// bytes as the point at infinity.
+func parsePointExt(b []byte) (*big.Int, *big.Int, error) {
+	if bytes.Equal(b, make([]byte, btcec.PubKeyBytesLenCompressed)) {
+		return new(big.Int), new(big.Int), nil
+	}
+	p, err := parsePoint(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.X, p.Y, nil
+}
+
+// serializePoint returns the compressed serialization of (x, y).
+func serializePoint(x, y *big.Int) []byte {
+	p := btcec.PublicKey{Curve: btcec.S256(), X: x, Y: y}
+	return p.SerializeCompressed()
+}
+
+// serializePointExt returns the compressed serialization of (x, y), or 33
+// zero bytes for the point at infinity.
+func serializePointExt(x, y *big.Int) []byte {
+	if isInfinity(x, y) {
+		return make([]byte, btcec.PubKeyBytesLenCompressed)
+	}
+	return serializePoint(x, y)
+}
@@ -0,0 +1,197 @@
+package musig2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+)
+
+// Tags of the hashes which bind a session's nonce to its key and message.
+const (
+	nonceCoeffTag = "MuSig/noncecoef"
+	challengeTag  = "BIP0340/challenge"
+)
+
+// ErrInvalidSessionContext indicates a serialized session context which
+// could not be parsed.
+var ErrInvalidSessionContext = errors.New("malformed session context")
+
+// A SessionContext is the state of a signing session which all of its
+// signers agree on: the aggregate of their nonces, their public keys in the
+// order they were aggregated, the tweaks applied to the aggregate key and
+// the message to sign.
+type SessionContext struct {
+	AggNonce AggNonce
+	PubKeys  []*btcec.PublicKey
+	Tweaks   []Tweak
+	Msg      []byte
+}
+
+// NewSessionContext returns the context of a session signing msg with the
+// aggregate key of keyAgg and the aggregate nonce aggNonce.
+func NewSessionContext(keyAgg *KeyAggContext, aggNonce AggNonce,
+	msg []byte) *SessionContext {
+	return &SessionContext{
+		AggNonce: aggNonce,
+		PubKeys:  keyAgg.PubKeys(),
+		Tweaks:   keyAgg.Tweaks(),
+		Msg:      msg,
+	}
+}
+
+// KeyAgg returns the context of the session's aggregate key.
+func (s *SessionContext) KeyAgg() (*KeyAggContext, error) {
+	keyAgg, err := KeyAgg(s.PubKeys)
+	if err != nil {
+		return nil, err
+	}
+	for _, tweak := range s.Tweaks {
+		keyAgg, err = keyAgg.ApplyTweak(tweak)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keyAgg, nil
+}
+
+// sessionValues are the values derived from a session context which the
+// signing algorithms share.
+type sessionValues struct {
+	keyAgg *KeyAggContext
+	b      *big.Int // nonce coefficient
+	rx, ry *big.Int // final nonce
+	e      *big.Int // challenge
+}
+
+// values derives the session values of s.
+func (s *SessionContext) values() (*sessionValues, error) {
+	keyAgg, err := s.KeyAgg()
+	if err != nil {
+		return nil, err
+	}
+	curve := btcec.S256()
+	qx := schnorr.SerializePubKey(keyAgg.q)
+
+	// b = int(hash_noncecoef(aggnonce || Q.x || m)) mod n
+	hash := hashing.TaggedHash(nonceCoeffTag, s.AggNonce[:], qx, s.Msg)
+	b := new(big.Int).SetBytes(hash[:])
+	b.Mod(b, curve.N)
+
+	// R = R_1 + b⋅R_2, replaced by G in the unlikely case that it is
+	// infinity so that the signature is still well defined.
+	r1x, r1y, err := parsePointExt(s.AggNonce[:33])
+	if err != nil {
+		return nil, ErrInvalidAggNonce
+	}
+	r2x, r2y, err := parsePointExt(s.AggNonce[33:])
+	if err != nil {
+		return nil, ErrInvalidAggNonce
+	}
+	bx, by := curve.ScalarMult(r2x, r2y, b.Bytes())
+	rx, ry := curve.Add(r1x, r1y, bx, by)
+	if isInfinity(rx, ry) {
+		rx = new(big.Int).Set(curve.Gx)
+		ry = new(big.Int).Set(curve.Gy)
+	}
+
+	// e = int(hash_challenge(R.x || Q.x || m)) mod n
+	var rBytes [32]byte
+	rx.FillBytes(rBytes[:])
+	hash = hashing.TaggedHash(challengeTag, rBytes[:], qx, s.Msg)
+	e := new(big.Int).SetBytes(hash[:])
+	e.Mod(e, curve.N)
+
+	return &sessionValues{keyAgg: keyAgg, b: b, rx: rx, ry: ry, e: e}, nil
+}
+
+// Serialize returns the serialization of s: the aggregate nonce, then the
+// public keys, the tweaks and the message, each preceded by a 4-byte
+// big-endian count. Public keys are compressed and each tweak is followed
+// by a byte which is 1 for an x-only tweak and 0 otherwise.
+func (s *SessionContext) Serialize() []byte {
+	var buf bytes.Buffer
+	buf.Write(s.AggNonce[:])
+	binary.Write(&buf, binary.BigEndian, uint32(len(s.PubKeys)))
+	for _, pubKey := range s.PubKeys {
+		buf.Write(pubKey.SerializeCompressed())
+	}
+	binary.Write(&buf, binary.BigEndian, uint32(len(s.Tweaks)))
+	for _, tweak := range s.Tweaks {
+		buf.Write(tweak.Tweak[:])
+		if tweak.XOnly {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	}
+	binary.Write(&buf, binary.BigEndian, uint32(len(s.Msg)))
+	buf.Write(s.Msg)
+	return buf.Bytes()
+}
+
+// ParseSessionContext parses a session context from the serialization
+// produced by Serialize.
+func ParseSessionContext(b []byte) (*SessionContext, error) {
+	r := bytes.NewReader(b)
+	var s SessionContext
+	if _, err := io.ReadFull(r, s.AggNonce[:]); err != nil {
+		return nil, ErrInvalidSessionContext
+	}
+
+	// Counts are checked against the bytes left before allocating.
+	readCount := func(size int) (int, error) {
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		if err != nil || uint64(n)*uint64(size) > uint64(r.Len()) {
+			return 0, ErrInvalidSessionContext
+		}
+		return int(n), nil
+	}
+
+	n, err := readCount(btcec.PubKeyBytesLenCompressed)
+	if err != nil {
+		return nil, err
+	}
+	s.PubKeys = make([]*btcec.PublicKey, n)
+	for i := range s.PubKeys {
+		key := make([]byte, btcec.PubKeyBytesLenCompressed)
+		r.Read(key)
+		s.PubKeys[i], err = parsePoint(key)
+		if err != nil {
+			return nil, ErrInvalidSessionContext
+		}
+	}
+
+	n, err = readCount(33)
+	if err != nil {
+		return nil, err
+	}
+	s.Tweaks = make([]Tweak, n)
+	for i := range s.Tweaks {
+		r.Read(s.Tweaks[i].Tweak[:])
+		switch flag, _ := r.ReadByte(); flag {
+		case 0:
+		case 1:
+			s.Tweaks[i].XOnly = true
+		default:
+			return nil, ErrInvalidSessionContext
+		}
+	}
+
+	n, err = readCount(1)
+	if err != nil {
+		return nil, err
+	}
+	s.Msg = make([]byte, n)
+	r.Read(s.Msg)
+	if r.Len() != 0 {
+		return nil, ErrInvalidSessionContext
+	}
+	return &s, nil
+}
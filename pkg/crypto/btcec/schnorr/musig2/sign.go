@@ -0,0 +1,185 @@
+package musig2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+)
+
+// PartialSigSize is the size of a serialized partial signature.
+const PartialSigSize = 32
+
+var (
+	// ErrInvalidPrivateKey indicates a private key which is zero or not
+	// less than the curve order.
+	ErrInvalidPrivateKey = errors.New("invalid private key")
+
+	// ErrKeyNotInSession indicates a public key which is not one of the
+	// keys aggregated for a session.
+	ErrKeyNotInSession = errors.New("public key is not a signer of the session")
+
+	// ErrInvalidPartialSig indicates a partial signature which is not
+	// less than the curve order.
+	ErrInvalidPartialSig = errors.New("invalid partial signature")
+
+	// ErrSigningFailed indicates a partial signature which did not
+	// verify, which can only result from a fault during signing.
+	ErrSigningFailed = errors.New("partial signature failed to verify")
+)
+
+// A PartialSig is a signer's share of a session's signature, a scalar
+// serialized as a 32-byte big-endian number.
+type PartialSig [PartialSigSize]byte
+
+// Sign returns the partial signature by secKey for a session, using the
+// secret nonce generated for it. The nonce is zeroed first, whatever the
+// outcome: signing two sessions with the same nonce reveals the private key.
+// The partial signature is verified before it is returned.
+func Sign(secNonce *SecNonce, secKey *btcec.PrivateKey,
+	session *SessionContext) (PartialSig, error) {
+	curve := btcec.S256()
+	k1 := new(big.Int).SetBytes(secNonce[:32])
+	k2 := new(big.Int).SetBytes(secNonce[32:64])
+	nonceKey := make([]byte, btcec.PubKeyBytesLenCompressed)
+	copy(nonceKey, secNonce[64:])
+	*secNonce = SecNonce{}
+
+	for _, k := range []*big.Int{k1, k2} {
+		if k.Sign() == 0 || k.Cmp(curve.N) >= 0 {
+			return PartialSig{}, ErrInvalidSecNonce
+		}
+	}
+	if secKey.D.Sign() <= 0 || secKey.D.Cmp(curve.N) >= 0 {
+		return PartialSig{}, ErrInvalidPrivateKey
+	}
+	pubKey := secKey.PubKey()
+	key := pubKey.SerializeCompressed()
+	if !bytes.Equal(key, nonceKey) {
+		return PartialSig{}, ErrInvalidSecNonce
+	}
+
+	v, err := session.values()
+	if err != nil {
+		return PartialSig{}, err
+	}
+	if !v.keyAgg.hasKey(key) {
+		return PartialSig{}, ErrKeyNotInSession
+	}
+
+	// Keep the public nonce for the final check before the nonces are
+	// negated to match the final nonce, whose y coordinate must be even.
+	var pubNonce PubNonce
+	for i, k := range []*big.Int{k1, k2} {
+		x, y := curve.ScalarBaseMult(k.Bytes())
+		copy(pubNonce[33*i:], serializePoint(x, y))
+	}
+	if v.ry.Bit(0) == 1 {
+		k1.Sub(curve.N, k1)
+		k2.Sub(curve.N, k2)
+	}
+
+	// d = g⋅gacc⋅d', so that the tweaked key with an even y coordinate
+	// is the sum of the signers' keys d⋅G and the accumulated tweak.
+	d := new(big.Int).Mul(v.keyAgg.parity(), v.keyAgg.gacc)
+	d.Mul(d, secKey.D)
+	d.Mod(d, curve.N)
+
+	// s = k1 + b⋅k2 + e⋅a⋅d mod n
+	s := new(big.Int).Mul(v.e, v.keyAgg.coefficient(key))
+	s.Mul(s, d)
+	s.Add(s, k1)
+	s.Add(s, k2.Mul(k2, v.b))
+	s.Mod(s, curve.N)
+
+	var psig PartialSig
+	s.FillBytes(psig[:])
+	if !partialSigVerify(psig, &pubNonce, pubKey, v) {
+		return PartialSig{}, ErrSigningFailed
+	}
+	return psig, nil
+}
+
+// PartialSigVerify returns whether psig is a valid partial signature for a
+// session by the signer with public key pubKey and public nonce pubNonce.
+// Checking each partial signature identifies a signer who disrupts the
+// session, whose share would otherwise only make the final signature
+// invalid.
+func PartialSigVerify(psig PartialSig, pubNonce *PubNonce,
+	pubKey *btcec.PublicKey, session *SessionContext) bool {
+	v, err := session.values()
+	if err != nil {
+		return false
+	}
+	return partialSigVerify(psig, pubNonce, pubKey, v)
+}
+
+// partialSigVerify returns whether psig is a valid partial signature by the
+// signer with public key pubKey and public nonce pubNonce for the session
+// with values v.
+func partialSigVerify(psig PartialSig, pubNonce *PubNonce,
+	pubKey *btcec.PublicKey, v *sessionValues) bool {
+	curve := btcec.S256()
+	s := new(big.Int).SetBytes(psig[:])
+	if s.Cmp(curve.N) >= 0 {
+		return false
+	}
+	key := pubKey.SerializeCompressed()
+	if !v.keyAgg.hasKey(key) {
+		return false
+	}
+
+	// The signer's nonce R_1 + b⋅R_2, negated if the final nonce was.
+	r1, err := parsePoint(pubNonce[:33])
+	if err != nil {
+		return false
+	}
+	r2, err := parsePoint(pubNonce[33:])
+	if err != nil {
+		return false
+	}
+	bx, by := curve.ScalarMult(r2.X, r2.Y, v.b.Bytes())
+	rx, ry := curve.Add(r1.X, r1.Y, bx, by)
+	if v.ry.Bit(0) == 1 && !isInfinity(rx, ry) {
+		ry.Sub(curve.P, ry)
+	}
+
+	// s⋅G = R + e⋅a⋅g⋅gacc⋅P
+	t := new(big.Int).Mul(v.e, v.keyAgg.coefficient(key))
+	t.Mul(t, v.keyAgg.parity())
+	t.Mul(t, v.keyAgg.gacc)
+	t.Mod(t, curve.N)
+	px, py := curve.ScalarMult(pubKey.X, pubKey.Y, t.Bytes())
+	ex, ey := curve.Add(rx, ry, px, py)
+	sx, sy := curve.ScalarBaseMult(s.Bytes())
+	return sx.Cmp(ex) == 0 && sy.Cmp(ey) == 0
+}
+
+// PartialSigAgg combines the partial signatures of all signers of a session
+// into its signature, which verifies against the x-only serialization of
+// the session's aggregate key. An invalid partial signature is reported
+// with the index of its signer.
+func PartialSigAgg(psigs []PartialSig,
+	session *SessionContext) (*schnorr.Signature, error) {
+	v, err := session.values()
+	if err != nil {
+		return nil, err
+	}
+
+	// s = s_1 + ... + s_u + e⋅g⋅tacc mod n
+	curve := btcec.S256()
+	s := new(big.Int).Mul(v.e, v.keyAgg.parity())
+	s.Mul(s, v.keyAgg.tacc)
+	for i := range psigs {
+		si := new(big.Int).SetBytes(psigs[i][:])
+		if si.Cmp(curve.N) >= 0 {
+			return nil, fmt.Errorf("%w: signer %d", ErrInvalidPartialSig, i)
+		}
+		s.Add(s, si)
+	}
+	s.Mod(s, curve.N)
+	return &schnorr.Signature{R: v.rx, S: s}, nil
+}
@@ -0,0 +1,58 @@
+package schnorr
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+)
+
+// PubKeyBytesLen is the length of a serialized x-only public key.
+const PubKeyBytesLen = 32
+
+// errInvalidPubKeyLen indicates that a serialized x-only public key is not
+// PubKeyBytesLen bytes long.
+var errInvalidPubKeyLen = errors.New("x-only public key must be 32 bytes")
+
+// ParsePubKey parses an x-only public key as specified by BIP340. The key is
+// the point with the given x coordinate and an even y coordinate.
+func ParsePubKey(pubKeyStr []byte) (*btcec.PublicKey, error) {
+	if len(pubKeyStr) != PubKeyBytesLen {
+		return nil, errInvalidPubKeyLen
+	}
+
+	// An x-only key is the compressed key with an even y coordinate.
+	compressed := make([]byte, 0, btcec.PubKeyBytesLenCompressed)
+	compressed = append(compressed, 0x02)
+	compressed = append(compressed, pubKeyStr...)
+	return btcec.ParsePubKey(compressed)
+}
+
+// SerializePubKey serializes pubKey as an x-only public key, which is its
+// x coordinate alone.
+func SerializePubKey(pubKey *btcec.PublicKey) []byte {
+	return pubKey.SerializeCompressed()[1:]
+}
+
+// An XOnlyPubKey is a serialized BIP340 public key: the x coordinate of a
+// point whose y coordinate is taken to be even.
+type XOnlyPubKey [PubKeyBytesLen]byte
+
+// NewXOnlyPubKey returns the x-only form of pubKey, dropping the parity of
+// its y coordinate.
+func NewXOnlyPubKey(pubKey *btcec.PublicKey) XOnlyPubKey {
+	var k XOnlyPubKey
+	pubKey.X.FillBytes(k[:])
+	return k
+}
+
+// PubKey returns the point of k, the one with an even y coordinate. An error
+// is returned if k is not the x coordinate of a point on the curve.
+func (k XOnlyPubKey) PubKey() (*btcec.PublicKey, error) {
+	return ParsePubKey(k[:])
+}
+
+// String returns k in hex.
+func (k XOnlyPubKey) String() string {
+	return hex.EncodeToString(k[:])
+}
@@ -0,0 +1,98 @@
+package schnorr
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+)
+
+// Tags of the hashes used when signing.
+const (
+	auxTag   = "BIP0340/aux"
+	nonceTag = "BIP0340/nonce"
+)
+
+var (
+	// errInvalidPrivateKey indicates a private key which is zero or not
+	// less than the curve order.
+	errInvalidPrivateKey = errors.New("invalid private key")
+
+	// errInvalidAuxRandLen indicates auxiliary randomness which is not
+	// 32 bytes.
+	errInvalidAuxRandLen = errors.New("auxiliary randomness must be 32 bytes")
+
+	// errSigningFailed indicates a signature which did not verify, which
+	// can only result from a fault during signing.
+	errSigningFailed = errors.New("schnorr signature failed to verify")
+)
+
+// Sign returns the BIP340 signature of the message hash by priv. Taproot
+// signs 32-byte hashes, but BIP340 allows messages of any length.
+// auxRand is 32 bytes of fresh randomness mixed into the nonce to protect
+// against side channels; nil signs deterministically, as if it were all
+// zeros. The signature is verified before it is returned.
+func Sign(priv *btcec.PrivateKey, hash, auxRand []byte) (*Signature, error) {
+	if auxRand == nil {
+		auxRand = make([]byte, 32)
+	}
+	if len(auxRand) != 32 {
+		return nil, errInvalidAuxRandLen
+	}
+
+	curve := btcec.S256()
+	if priv.D.Sign() <= 0 || priv.D.Cmp(curve.N) >= 0 {
+		return nil, errInvalidPrivateKey
+	}
+
+	// The key is used negated if its point has an odd y coordinate, so
+	// that the public key is the even point with the same x coordinate.
+	pubKey := priv.PubKey()
+	d := evenKey(priv.D, pubKey.Y)
+	var dBytes, pBytes [32]byte
+	d.FillBytes(dBytes[:])
+	pubKey.X.FillBytes(pBytes[:])
+
+	// t = bytes(d) xor hash_aux(a)
+	// k' = int(hash_nonce(t || bytes(P) || m)) mod n
+	aux := hashing.TaggedHash(auxTag, auxRand)
+	t := make([]byte, 32)
+	for i := range t {
+		t[i] = dBytes[i] ^ aux[i]
+	}
+	nonceHash := hashing.TaggedHash(nonceTag, t, pBytes[:], hash)
+	k := new(big.Int).SetBytes(nonceHash[:])
+	k.Mod(k, curve.N)
+	if k.Sign() == 0 {
+		return nil, errSigningFailed
+	}
+
+	rx, ry := curve.ScalarBaseMult(k.Bytes())
+	k = evenKey(k, ry)
+
+	// s = k + e*d mod n, with e = int(hash_challenge(R.x || P.x || m)).
+	var rBytes [32]byte
+	rx.FillBytes(rBytes[:])
+	challenge := hashing.TaggedHash(challengeTag, rBytes[:], pBytes[:], hash)
+	e := new(big.Int).SetBytes(challenge[:])
+	e.Mod(e, curve.N)
+	s := e.Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, curve.N)
+
+	sig := &Signature{R: rx, S: s}
+	if !sig.Verify(hash, pubKey) {
+		return nil, errSigningFailed
+	}
+	return sig, nil
+}
+
+// evenKey returns the scalar k, or its negation if the point k⋅G has an odd
+// y coordinate y.
+func evenKey(k, y *big.Int) *big.Int {
+	if y.Bit(0) == 1 {
+		return new(big.Int).Sub(btcec.S256().N, k)
+	}
+	return k
+}
@@ -0,0 +1,83 @@
+// Package schnorr implements the Schnorr signature scheme for secp256k1
+// specified by BIP340, which is used by taproot.
+package schnorr
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+)
+
+// SignatureSize is the size of a serialized Schnorr signature.
+const SignatureSize = 64
+
+// challengeTag is the tag of the hash which binds a signature to its nonce,
+// public key and message.
+const challengeTag = "BIP0340/challenge"
+
+// A Signature is a BIP340 Schnorr signature. R is the x coordinate of the
+// nonce point, whose y coordinate is even, and S is the scalar.
+type Signature struct {
+	R *big.Int
+	S *big.Int
+}
+
+// errInvalidSignature indicates that a serialized signature could not be
+// parsed.
+var errInvalidSignature = errors.New("malformed schnorr signature")
+
+// ParseSignature parses a Schnorr signature from its 64-byte serialization.
+// An error is returned if R is not a field element or S is not less than the
+// curve order.
+func ParseSignature(sigStr []byte) (*Signature, error) {
+	if len(sigStr) != SignatureSize {
+		return nil, errInvalidSignature
+	}
+
+	curve := btcec.S256()
+	r := new(big.Int).SetBytes(sigStr[:32])
+	s := new(big.Int).SetBytes(sigStr[32:])
+	if r.Cmp(curve.P) >= 0 || s.Cmp(curve.N) >= 0 {
+		return nil, errInvalidSignature
+	}
+	return &Signature{R: r, S: s}, nil
+}
+
+// Serialize returns the 64-byte serialization of sig.
+func (sig *Signature) Serialize() []byte {
+	b := make([]byte, SignatureSize)
+	sig.R.FillBytes(b[:32])
+	sig.S.FillBytes(b[32:])
+	return b
+}
+
+// Verify returns whether sig is a valid signature of the message hash by
+// pubKey. Only the x coordinate of pubKey is used, as specified by BIP340.
+func (sig *Signature) Verify(hash []byte, pubKey *btcec.PublicKey) bool {
+	curve := btcec.S256()
+
+	// e = int(hash_challenge(r || P.x || m)) mod n
+	var rBytes, pBytes [32]byte
+	sig.R.FillBytes(rBytes[:])
+	pubKey.X.FillBytes(pBytes[:])
+	challenge := hashing.TaggedHash(challengeTag, rBytes[:], pBytes[:], hash)
+	e := new(big.Int).SetBytes(challenge[:])
+	e.Mod(e, curve.N)
+
+	// R = s⋅G - e⋅P, where P has an even y coordinate.
+	py := pubKey.Y
+	if py.Bit(0) == 1 {
+		py = new(big.Int).Sub(curve.P, py)
+	}
+	sx, sy := curve.ScalarBaseMult(sig.S.Bytes())
+	e.Sub(curve.N, e)
+	ex, ey := curve.ScalarMult(pubKey.X, py, e.Bytes())
+	rx, ry := curve.Add(sx, sy, ex, ey)
+
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+	return ry.Bit(0) == 0 && rx.Cmp(sig.R) == 0
+}
@@ -0,0 +1,79 @@
+package schnorr
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+)
+
+// tapTweakTag is the tag of the hash which commits a taproot output key to
+// its internal key and script tree.
+const tapTweakTag = "TapTweak"
+
+// errInvalidTweak indicates a tweak which is not less than the curve order
+// or which produces the point at infinity or a zero private key.
+var errInvalidTweak = errors.New("invalid taproot tweak")
+
+// tapTweak returns the BIP341 tweak of the x-only internal key committing to
+// the script tree with the given merkle root, which is nil for an output
+// without scripts.
+func tapTweak(internalKey *btcec.PublicKey, merkleRoot []byte) (*big.Int,
+	error) {
+	hash := hashing.TaggedHash(tapTweakTag, SerializePubKey(internalKey),
+		merkleRoot)
+	t := new(big.Int).SetBytes(hash[:])
+	if t.Cmp(btcec.S256().N) >= 0 {
+		return nil, errInvalidTweak
+	}
+	return t, nil
+}
+
+// TweakPubKey returns the taproot output key committing to internalKey and
+// the script tree with the given merkle root, as specified by BIP341:
+// Q = P + int(hash_TapTweak(P.x || root))⋅G, where P is the internal key with
+// an even y coordinate.
+func TweakPubKey(internalKey *btcec.PublicKey,
+	merkleRoot []byte) (*btcec.PublicKey, error) {
+	t, err := tapTweak(internalKey, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	curve := btcec.S256()
+	px, py := internalKey.X, internalKey.Y
+	if py.Bit(0) == 1 {
+		py = new(big.Int).Sub(curve.P, py)
+	}
+	tx, ty := curve.ScalarBaseMult(t.Bytes())
+	qx, qy := curve.Add(px, py, tx, ty)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return nil, errInvalidTweak
+	}
+	return &btcec.PublicKey{Curve: curve, X: qx, Y: qy}, nil
+}
+
+// TweakPrivKey returns the private key of the taproot output key which
+// TweakPubKey computes from the public key of priv, for signing key path
+// spends.
+func TweakPrivKey(priv *btcec.PrivateKey,
+	merkleRoot []byte) (*btcec.PrivateKey, error) {
+	curve := btcec.S256()
+	if priv.D.Sign() <= 0 || priv.D.Cmp(curve.N) >= 0 {
+		return nil, errInvalidPrivateKey
+	}
+	pubKey := priv.PubKey()
+	t, err := tapTweak(pubKey, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	d := new(big.Int).Add(evenKey(priv.D, pubKey.Y), t)
+	d.Mod(d, curve.N)
+	if d.Sign() == 0 {
+		return nil, errInvalidTweak
+	}
+	tweaked, _ := btcec.PrivKeyFromBytes(d.Bytes())
+	return tweaked, nil
+}
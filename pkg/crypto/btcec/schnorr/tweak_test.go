@@ -0,0 +1,72 @@
+package schnorr
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+)
+
+// TestTweak checks output keys against the scriptPubKey and key path
+// spending vectors of BIP341.
+func TestTweak(t *testing.T) {
+	tests := []struct {
+		internalKey string
+		merkleRoot  string
+		outputKey   string
+	}{
+		{"d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d", "",
+			"53a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343"},
+		{"187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27",
+			"5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21",
+			"147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3"},
+	}
+	for _, test := range tests {
+		internal, err := ParsePubKey(hexBytes(test.internalKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var root []byte
+		if test.merkleRoot != "" {
+			root = hexBytes(test.merkleRoot)
+		}
+		out, err := TweakPubKey(internal, root)
+		if err != nil {
+			t.Errorf("%s: %v", test.internalKey, err)
+			continue
+		}
+		if got := NewXOnlyPubKey(out).String(); got != test.outputKey {
+			t.Errorf("%s: got %s, want %s", test.internalKey, got,
+				test.outputKey)
+		}
+	}
+
+	// The internal private key of the first key path spend, whose public
+	// key is the first internal key above.
+	priv, _ := btcec.PrivKeyFromBytes(hexBytes("6b973d88838f27366ed61c9ad6367663045cb456e28335c109e30717ae0c6baa"))
+	tweaked, err := TweakPrivKey(priv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2405b971772ad26915c8dcdf10f238753a9b837e5f8e6a86fd7c0cce5b7296d9"
+	var d [32]byte
+	tweaked.D.FillBytes(d[:])
+	if got := hex.EncodeToString(d[:]); got != want {
+		t.Errorf("tweaked private key: got %s, want %s", got, want)
+	}
+	out, _ := TweakPubKey(priv.PubKey(), nil)
+	if got := NewXOnlyPubKey(out).String(); got != tests[0].outputKey {
+		t.Errorf("tweaked public key: got %s, want %s", got, tests[0].outputKey)
+	}
+
+	// A key path signature by the tweaked key verifies against the output
+	// key.
+	hash := hexBytes(bip340Tests[1].message)
+	sig, err := Sign(tweaked, hash, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.Verify(hash, out) {
+		t.Error("key path signature does not verify")
+	}
+}
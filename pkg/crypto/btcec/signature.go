@@ -1,7 +1,9 @@
 package btcec
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"errors"
 	"math/big"
 )
 
@@ -11,6 +13,183 @@ type Signature struct {
 	S *big.Int
 }
 
+var (
+	// errInvalidSignature indicates that a serialized signature could not
+	// be parsed.
+	errInvalidSignature = errors.New("malformed signature")
+
+	// errNonCanonicalSignature indicates a signature which is not a
+	// strict DER encoding.
+	errNonCanonicalSignature = errors.New("signature is not strict DER")
+)
+
+// MaxDERSignatureLen is the length of the longest strict DER signature, in
+// which R and S are 33 bytes each.
+const MaxDERSignatureLen = 72
+
+// halfOrder is half the order of the secp256k1 curve.
+var halfOrder = new(big.Int).Rsh(secp256k1.N, 1)
+
+// ParseSignature parses an ECDSA signature from its DER serialization. The
+// parser is lax in the same way as the original Bitcoin software, which
+// relied on OpenSSL: the sequence length is ignored, lengths may take the
+// long form with leading zero bytes, integers may be padded and data may
+// follow the signature, so that historical signatures still parse. Use
+// ParseDERSignature for signatures which must be strict DER.
+func ParseSignature(sigStr []byte) (*Signature, error) {
+	// SEQUENCE tag, length, INTEGER tag, length, R, INTEGER tag, length, S.
+	if len(sigStr) < 2 || sigStr[0] != 0x30 {
+		return nil, errInvalidSignature
+	}
+	rest := sigStr[2:]
+
+	// The bytes of a long form sequence length are skipped unread.
+	if n := int(sigStr[1]); n&0x80 != 0 {
+		n &^= 0x80
+		if n > len(rest) {
+			return nil, errInvalidSignature
+		}
+		rest = rest[n:]
+	}
+
+	r, rest, err := parseLaxInteger(rest)
+	if err != nil {
+		return nil, err
+	}
+	s, _, err := parseLaxInteger(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Sign() <= 0 || s.Sign() <= 0 {
+		return nil, errInvalidSignature
+	}
+	return &Signature{R: r, S: s}, nil
+}
+
+// parseLaxInteger parses a DER INTEGER from the start of b and returns it
+// along with the bytes which follow it. A long form length may have any
+// number of leading zero bytes but at most 7 significant ones.
+func parseLaxInteger(b []byte) (*big.Int, []byte, error) {
+	if len(b) < 2 || b[0] != 0x02 {
+		return nil, nil, errInvalidSignature
+	}
+	n, b := uint64(b[1]), b[2:]
+	if n&0x80 != 0 {
+		lenBytes := int(n &^ 0x80)
+		if lenBytes > len(b) {
+			return nil, nil, errInvalidSignature
+		}
+		length := bytes.TrimLeft(b[:lenBytes], "\x00")
+		b = b[lenBytes:]
+		if len(length) >= 8 {
+			return nil, nil, errInvalidSignature
+		}
+		n = 0
+		for _, c := range length {
+			n = n<<8 | uint64(c)
+		}
+	}
+	if n > uint64(len(b)) {
+		return nil, nil, errInvalidSignature
+	}
+	return new(big.Int).SetBytes(b[:n]), b[n:], nil
+}
+
+// ParseDERSignature parses an ECDSA signature which must be a strict DER
+// encoding as required by BIP66:
+//
+//	0x30 <total length> 0x02 <R length> <R> 0x02 <S length> <S>
+//
+// where R and S are minimally encoded positive integers. Like BIP66, which
+// only constrains the encoding, R and S are not checked against the curve
+// order; a signature with either out of range parses but does not verify.
+func ParseDERSignature(sigStr []byte) (*Signature, error) {
+	if len(sigStr) < 8 || len(sigStr) > MaxDERSignatureLen {
+		return nil, errNonCanonicalSignature
+	}
+	if sigStr[0] != 0x30 || int(sigStr[1]) != len(sigStr)-2 {
+		return nil, errNonCanonicalSignature
+	}
+
+	r, rest, err := parseDERInteger(sigStr[2:])
+	if err != nil {
+		return nil, err
+	}
+	s, rest, err := parseDERInteger(rest)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errNonCanonicalSignature
+	}
+	return &Signature{R: r, S: s}, nil
+}
+
+// parseDERInteger parses a minimally encoded, positive DER INTEGER from the
+// start of b and returns it along with the bytes which follow it.
+func parseDERInteger(b []byte) (*big.Int, []byte, error) {
+	if len(b) < 2 || b[0] != 0x02 {
+		return nil, nil, errNonCanonicalSignature
+	}
+	n := int(b[1])
+	if n == 0 || len(b) < 2+n {
+		return nil, nil, errNonCanonicalSignature
+	}
+	v := b[2 : 2+n]
+
+	// The integer must not be negative, and a leading zero byte is only
+	// allowed when it keeps the next byte from reading as negative.
+	if v[0]&0x80 != 0 || (n > 1 && v[0] == 0x00 && v[1]&0x80 == 0) {
+		return nil, nil, errNonCanonicalSignature
+	}
+	return new(big.Int).SetBytes(v), b[2+n:], nil
+}
+
+// Serialize returns the strict DER encoding of the signature.
+func (sig *Signature) Serialize() []byte {
+	r := derInteger(sig.R)
+	s := derInteger(sig.S)
+	b := make([]byte, 0, 6+len(r)+len(s))
+	b = append(b, 0x30, byte(4+len(r)+len(s)))
+	b = append(b, 0x02, byte(len(r)))
+	b = append(b, r...)
+	b = append(b, 0x02, byte(len(s)))
+	return append(b, s...)
+}
+
+// SerializeWithHashType returns the strict DER encoding of the signature
+// followed by hashType, the form in which signatures appear in scripts and
+// witnesses.
+func (sig *Signature) SerializeWithHashType(hashType byte) []byte {
+	return append(sig.Serialize(), hashType)
+}
+
+// derInteger returns the minimal big-endian encoding of the positive
+// integer v as a DER INTEGER value, with a zero byte prepended when the top
+// bit is set.
+func derInteger(v *big.Int) []byte {
+	b := v.Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0x00}, b...)
+	}
+	return b
+}
+
+// IsLowS returns whether S is at most half the curve order. Since (R, S)
+// and (R, N-S) are both valid, Bitcoin's policy requires the low value so
+// that third parties cannot malleate signatures.
+func (sig *Signature) IsLowS() bool {
+	return sig.S.Cmp(halfOrder) <= 0
+}
+
+// NormalizeS replaces S with N-S if it is above half the curve order.
+func (sig *Signature) NormalizeS() {
+	if !sig.IsLowS() {
+		sig.S = new(big.Int).Sub(secp256k1.N, sig.S)
+	}
+}
+
 // Verify calls ecdsa.Verify to verify the signature of hash using the public
 // key.
 func (sig *Signature) Verify(hash []byte, pubKey *PublicKey) bool {
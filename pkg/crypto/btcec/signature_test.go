@@ -0,0 +1,216 @@
+package btcec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// TestParseDERSignature checks the encoding rules of BIP66. R and S are not
+// checked against the curve order, so signatures with a zero or overflowing
+// value parse.
+func TestParseDERSignature(t *testing.T) {
+	valid := []struct {
+		name string
+		sig  string
+		r, s string
+	}{
+		{"minimal", "3006020101020101", "1", "1"},
+		{"padded high bit", "300802020080020200ff", "80", "ff"},
+		{"zero R", "3006020100020101", "0", "1"},
+		{"S of the curve order", "3026020101022100" +
+			"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141",
+			"1", "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141"},
+		{"longest", "3046022100" + repeatHex("ff", 32) + "022100" + repeatHex("ff", 32),
+			repeatHex("ff", 32), repeatHex("ff", 32)},
+	}
+	for _, test := range valid {
+		b, _ := hex.DecodeString(test.sig)
+		sig, err := ParseDERSignature(b)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		r, _ := new(big.Int).SetString(test.r, 16)
+		s, _ := new(big.Int).SetString(test.s, 16)
+		if sig.R.Cmp(r) != 0 || sig.S.Cmp(s) != 0 {
+			t.Errorf("%s: got (%x, %x), want (%s, %s)", test.name, sig.R,
+				sig.S, test.r, test.s)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		sig  string
+	}{
+		{"empty", ""},
+		{"too short", "30050201010201"},
+		{"too long", "3047022200" + repeatHex("ff", 33) + "022100" + repeatHex("ff", 32)},
+		{"not a sequence", "3106020101020101"},
+		{"sequence length too long", "3007020101020101"},
+		{"sequence length too short", "3005020101020101"},
+		{"long form sequence length", "308106020101020101"},
+		{"R not an integer", "3006030101020101"},
+		{"R length zero", "3006020002020101"},
+		{"R length overruns", "3006020501020101"},
+		{"R negative", "3006020180020101"},
+		{"R padded", "300702020001020101"},
+		{"S not an integer", "3006020101030101"},
+		{"S length zero", "3006020101020001"},
+		{"S length overruns", "3006020101020201"},
+		{"S negative", "3006020101020180"},
+		{"S padded", "300702010102020001"},
+		{"trailing data", "300702010102010100"},
+	}
+	for _, test := range invalid {
+		b, _ := hex.DecodeString(test.sig)
+		if _, err := ParseDERSignature(b); err != errNonCanonicalSignature {
+			t.Errorf("%s: got %v, want %v", test.name, err,
+				errNonCanonicalSignature)
+		}
+	}
+}
+
+// TestParseSignature checks that the lax parser accepts the encodings which
+// the original Bitcoin software accepted through OpenSSL.
+func TestParseSignature(t *testing.T) {
+	valid := []struct {
+		name string
+		sig  string
+	}{
+		{"strict", "3006020101020102"},
+		{"padded", "3009020200010203000002"},
+		{"wrong sequence length", "3000020101020102"},
+		{"long form sequence length", "30820006020101020102"},
+		{"long form lengths", "3008028101010282000102"},
+		{"zero padded long form length", "3006028900000000000000000101020102"},
+		{"trailing data", "3006020101020102ffff"},
+	}
+	for _, test := range valid {
+		b, _ := hex.DecodeString(test.sig)
+		sig, err := ParseSignature(b)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if sig.R.Cmp(big.NewInt(1)) != 0 || sig.S.Cmp(big.NewInt(2)) != 0 {
+			t.Errorf("%s: got (%x, %x), want (1, 2)", test.name, sig.R, sig.S)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		sig  string
+	}{
+		{"empty", ""},
+		{"not a sequence", "3106020101020102"},
+		{"sequence length bytes overrun", "3087020101020102"},
+		{"R not an integer", "3006030101020102"},
+		{"R length overruns", "3006020901020102"},
+		{"R length of 8 bytes", "3006028801000000000000000101020102"},
+		{"long form length bytes overrun", "300602850101"},
+		{"S missing", "3003020101"},
+		{"zero R", "3006020100020102"},
+		{"zero S", "3006020101020100"},
+	}
+	for _, test := range invalid {
+		b, _ := hex.DecodeString(test.sig)
+		if _, err := ParseSignature(b); err != errInvalidSignature {
+			t.Errorf("%s: got %v, want %v", test.name, err, errInvalidSignature)
+		}
+	}
+}
+
+// TestSignatureSerialize checks that signatures serialize to strict DER and
+// parse back to themselves.
+func TestSignatureSerialize(t *testing.T) {
+	priv, pub := PrivKeyFromBytes([]byte{0x2a})
+	for i := 0; i < 64; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+		sig, err := priv.Sign(hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSignatureSerialize(t, sig)
+		if !sig.Verify(hash[:], pub) {
+			t.Errorf("signature %x does not verify", sig.Serialize())
+		}
+	}
+
+	// Values with the high bit set are padded, and short values are not.
+	for _, v := range []string{"1", "7f", "80", "ff", "100", repeatHex("ff", 32)} {
+		n, _ := new(big.Int).SetString(v, 16)
+		checkSignatureSerialize(t, &Signature{R: n, S: n})
+	}
+
+	sig := &Signature{R: big.NewInt(1), S: big.NewInt(0x80)}
+	want := []byte{0x30, 0x07, 0x02, 0x01, 0x01, 0x02, 0x02, 0x00, 0x80, 0x01}
+	if got := sig.SerializeWithHashType(0x01); !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
+
+// checkSignatureSerialize checks that sig parses back from its strict and
+// lax encodings.
+func checkSignatureSerialize(t *testing.T, sig *Signature) {
+	t.Helper()
+	b := sig.Serialize()
+	if len(b) > MaxDERSignatureLen {
+		t.Errorf("signature %x longer than %d bytes", b, MaxDERSignatureLen)
+	}
+	parsed, err := ParseDERSignature(b)
+	if err != nil {
+		t.Errorf("signature %x: %v", b, err)
+		return
+	}
+	if !parsed.IsEqual(sig) {
+		t.Errorf("signature %x parsed as (%x, %x)", b, parsed.R, parsed.S)
+	}
+	if lax, err := ParseSignature(b); err != nil || !lax.IsEqual(sig) {
+		t.Errorf("signature %x: lax parse differs: %v", b, err)
+	}
+}
+
+// TestSignLowS checks that signatures are normalized to a low S, and that
+// the high S counterpart of each is also valid but is not low.
+func TestSignLowS(t *testing.T) {
+	priv, pub := PrivKeyFromBytes([]byte{0x01})
+	for i := 0; i < 64; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+		sig, err := priv.Sign(hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sig.IsLowS() {
+			t.Errorf("hash %x: signature has a high S", hash)
+		}
+
+		high := &Signature{R: sig.R, S: new(big.Int).Sub(secp256k1.N, sig.S)}
+		if high.IsLowS() {
+			t.Errorf("hash %x: negated S is low", hash)
+		}
+		if !high.Verify(hash[:], pub) {
+			t.Errorf("hash %x: negated S does not verify", hash)
+		}
+		high.NormalizeS()
+		if !high.IsEqual(sig) {
+			t.Errorf("hash %x: normalized to (%x, %x)", hash, high.R, high.S)
+		}
+	}
+
+	// Half the order is the highest low S.
+	above := new(big.Int).Add(halfOrder, big.NewInt(1))
+	if sig := (&Signature{R: big.NewInt(1), S: halfOrder}); !sig.IsLowS() {
+		t.Error("half the order is high")
+	}
+	if sig := (&Signature{R: big.NewInt(1), S: above}); sig.IsLowS() {
+		t.Error("half the order plus one is low")
+	}
+}
+
+// repeatHex returns n copies of the hex byte b.
+func repeatHex(b string, n int) string {
+	return string(bytes.Repeat([]byte(b), n))
+}
@@ -49,3 +49,23 @@ func NewHash(newHash []byte) (*Hash, error) {
 	}
 	return &hash, err
 }
+
+// NewHashFromStr returns the Hash whose String is s: the hexadecimal string
+// of the byte-reversed hash, as block and transaction hashes are displayed.
+// An error is returned if s is not 2*HashSize hexadecimal characters.
+func NewHashFromStr(s string) (*Hash, error) {
+	if len(s) != 2*HashSize {
+		return nil, fmt.Errorf("invalid hash string length of %v, want %v",
+			len(s), 2*HashSize)
+	}
+
+	var hash Hash
+	_, err := hex.Decode(hash[:], []byte(s))
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < HashSize/2; i++ {
+		hash[i], hash[HashSize-1-i] = hash[HashSize-1-i], hash[i]
+	}
+	return &hash, nil
+}
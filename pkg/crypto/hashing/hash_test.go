@@ -0,0 +1,68 @@
+package hashing
+
+import (
+	"bytes"
+	"testing"
+)
+
+// genesisHash is the hash of the mainnet genesis block as it is displayed.
+const genesisHash = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+
+func TestNewHashFromStr(t *testing.T) {
+	hash, err := NewHashFromStr(genesisHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The string is of the byte-reversed hash.
+	if hash[0] != 0x6f || hash[HashSize-1] != 0x00 {
+		t.Errorf("got %x, want the bytes of %s reversed", hash[:], genesisHash)
+	}
+	if got := hash.String(); got != genesisHash {
+		t.Errorf("got %s, want %s", got, genesisHash)
+	}
+
+	// String does not change the hash it is called on.
+	b := append([]byte(nil), hash[:]...)
+	_ = hash.String()
+	if !bytes.Equal(hash[:], b) {
+		t.Errorf("String changed the hash to %x", hash[:])
+	}
+
+	upper, err := NewHashFromStr("000000000019D6689C085AE165831E934FF763AE46A2A6C172B3F1B60A8CE26F")
+	if err != nil || *upper != *hash {
+		t.Errorf("upper case: got %v, %v, want %s", upper, err, genesisHash)
+	}
+}
+
+func TestNewHashFromStrInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"empty", ""},
+		{"short", genesisHash[2:]},
+		{"long", genesisHash + "00"},
+		{"odd length", genesisHash[1:]},
+		{"not hex", "zz" + genesisHash[2:]},
+		{"prefixed", "0x" + genesisHash[2:]},
+	}
+	for _, test := range tests {
+		if hash, err := NewHashFromStr(test.s); err == nil {
+			t.Errorf("%s: got %v, want an error", test.name, hash)
+		}
+	}
+}
+
+func TestNewHash(t *testing.T) {
+	b := bytes.Repeat([]byte{0xab}, HashSize)
+	hash, err := NewHash(b)
+	if err != nil || !bytes.Equal(hash.Bytes(), b) {
+		t.Fatalf("got %v, %v, want %x", hash, err, b)
+	}
+	for _, n := range []int{0, HashSize - 1, HashSize + 1} {
+		if _, err := NewHash(make([]byte, n)); err == nil {
+			t.Errorf("%d bytes: got no error", n)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package hashing
 
 import (
 	"crypto/sha256"
+	"hash"
 
 	"golang.org/x/crypto/ripemd160"
 )
@@ -32,10 +33,65 @@ func DoubleSHA256H(b []byte) Hash {
 	return SHA256H(SHA256B(b))
 }
 
-// Hash160 performs the Hash160 hashing algorithm and returns the corresonding
-// bytes.
+// Hash160 performs the Hash160 hashing algorithm, RIPEMD-160 of SHA-256, and
+// returns the corresponding bytes.
 func Hash160(b []byte) []byte {
-	hash := SHA256B(b)
-	hash = ripemd160.New().Sum(hash)
+	h := ripemd160.New()
+	h.Write(SHA256B(b))
+	return h.Sum(nil)
+}
+
+// A DoubleSHA256Hasher computes the double SHA-256 hash of the data written
+// to it, so that a message can be hashed as it is serialized instead of
+// being serialized into a buffer first. Writes never fail.
+type DoubleSHA256Hasher struct {
+	h hash.Hash
+}
+
+// NewDoubleSHA256Hasher returns a hasher with no data written to it.
+func NewDoubleSHA256Hasher() *DoubleSHA256Hasher {
+	return &DoubleSHA256Hasher{h: sha256.New()}
+}
+
+// Write adds p to the data being hashed.
+func (d *DoubleSHA256Hasher) Write(p []byte) (int, error) {
+	return d.h.Write(p)
+}
+
+// Sum returns the double SHA-256 hash of the data written so far. It does
+// not change the state of the hasher.
+func (d *DoubleSHA256Hasher) Sum() Hash {
+	return SHA256H(d.h.Sum(nil))
+}
+
+// Reset discards the data written so far.
+func (d *DoubleSHA256Hasher) Reset() {
+	d.h.Reset()
+}
+
+// TaggedHash performs the BIP340 tagged hash of msgs with tag, which is the
+// SHA-256 hash of SHA256(tag) || SHA256(tag) || msgs. Tagging hashes by their
+// purpose keeps hashes computed in one context from being valid in another.
+func TaggedHash(tag string, msgs ...[]byte) Hash {
+	h := NewTaggedHasher(tag)
+	for _, msg := range msgs {
+		h.Write(msg)
+	}
+
+	var hash Hash
+	copy(hash[:], h.Sum(nil))
 	return hash
 }
+
+// NewTaggedHasher returns a SHA-256 hasher which has been written the prefix
+// of the BIP340 tagged hash with tag, so that the sum of the data written to
+// it is that data's tagged hash. It suits data which is serialized
+// piecewise, such as a taproot signature message.
+func NewTaggedHasher(tag string) hash.Hash {
+	tagHash := sha256.Sum256([]byte(tag))
+
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	return h
+}
@@ -0,0 +1,90 @@
+package hashing
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHashFuncs(t *testing.T) {
+	pubKeyG, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	tests := []struct {
+		name string
+		hash []byte
+		want string
+	}{
+		{"SHA256B empty", SHA256B(nil),
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"SHA256H abc", func() []byte { h := SHA256H([]byte("abc")); return h[:] }(),
+			"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"DoubleSHA256B hello", DoubleSHA256B([]byte("hello")),
+			"9595c9df90075148eb06860365df33584b75bff782a510c6cd4883a419833d50"},
+		{"DoubleSHA256H hello", func() []byte { h := DoubleSHA256H([]byte("hello")); return h[:] }(),
+			"9595c9df90075148eb06860365df33584b75bff782a510c6cd4883a419833d50"},
+		// The hash of the compressed public key of the generator, as in
+		// the address 1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH.
+		{"Hash160 of G", Hash160(pubKeyG),
+			"751e76e8199196d454941c45d1b3a323f1433bd6"},
+	}
+	for _, test := range tests {
+		if got := hex.EncodeToString(test.hash); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDoubleSHA256Hasher(t *testing.T) {
+	data := []byte("The quick brown fox jumps over the lazy dog")
+	h := NewDoubleSHA256Hasher()
+	if got, want := h.Sum(), DoubleSHA256H(nil); got != want {
+		t.Errorf("empty: got %v, want %v", got, want)
+	}
+
+	// Writing in pieces gives the hash of the whole, and Sum leaves the
+	// data written so far in place.
+	for i := 0; i < len(data); i += 10 {
+		end := i + 10
+		if end > len(data) {
+			end = len(data)
+		}
+		h.Write(data[i:end])
+		sum := h.Sum()
+		if want := DoubleSHA256B(data[:end]); !bytes.Equal(sum[:], want) {
+			t.Fatalf("after %d bytes: got %x, want %x", end, sum[:], want)
+		}
+	}
+
+	h.Reset()
+	h.Write([]byte("abc"))
+	if got, want := h.Sum(), DoubleSHA256H([]byte("abc")); got != want {
+		t.Errorf("after reset: got %v, want %v", got, want)
+	}
+}
+
+func TestTaggedHash(t *testing.T) {
+	const tag = "BIP0340/challenge"
+	msgs := [][]byte{[]byte("first"), nil, []byte("second")}
+
+	// SHA256(SHA256(tag) || SHA256(tag) || msgs)
+	tagHash := sha256.Sum256([]byte(tag))
+	want := sha256.Sum256(bytes.Join([][]byte{tagHash[:], tagHash[:],
+		[]byte("firstsecond")}, nil))
+
+	if got := TaggedHash(tag, msgs...); got != Hash(want) {
+		t.Errorf("TaggedHash: got %x, want %x", got[:], want[:])
+	}
+
+	h := NewTaggedHasher(tag)
+	for _, msg := range msgs {
+		h.Write(msg)
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+		t.Errorf("NewTaggedHasher: got %x, want %x", got, want[:])
+	}
+
+	// Tags separate otherwise equal messages.
+	if TaggedHash("TapLeaf", msgs...) == TaggedHash("TapBranch", msgs...) {
+		t.Error("different tags give the same hash")
+	}
+}
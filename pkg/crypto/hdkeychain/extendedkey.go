@@ -0,0 +1,289 @@
+package hdkeychain
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/util/encoding/base58"
+)
+
+// HardenedKeyStart is the index of the first hardened child key. Hardened
+// keys can only be derived from a private extended key.
+const HardenedKeyStart = 0x80000000
+
+// serializedKeyLen is the length of a serialized extended key: version,
+// depth, parent fingerprint, child number, chain code and key data.
+const serializedKeyLen = 4 + 1 + 4 + 4 + 32 + 33
+
+// Version prefixes of serialized extended keys.
+var (
+	// MainNetPrivate and MainNetPublic are the versions of xprv and xpub
+	// keys.
+	MainNetPrivate = [4]byte{0x04, 0x88, 0xad, 0xe4}
+	MainNetPublic  = [4]byte{0x04, 0x88, 0xb2, 0x1e}
+
+	// TestNetPrivate and TestNetPublic are the versions of tprv and tpub
+	// keys.
+	TestNetPrivate = [4]byte{0x04, 0x35, 0x83, 0x94}
+	TestNetPublic  = [4]byte{0x04, 0x35, 0x87, 0xcf}
+)
+
+var (
+	// ErrInvalidKeyLen indicates that a serialized extended key does not
+	// have the expected length.
+	ErrInvalidKeyLen = errors.New("serialized extended key has wrong length")
+
+	// ErrUnknownVersion indicates that a serialized extended key has a
+	// version prefix of no known network.
+	ErrUnknownVersion = errors.New("unknown extended key version")
+
+	// ErrInvalidKeyData indicates that the key data of a serialized
+	// extended key is not a valid private or public key.
+	ErrInvalidKeyData = errors.New("invalid extended key data")
+
+	// ErrDeriveHardFromPublic indicates an attempt to derive a hardened
+	// child from a public extended key.
+	ErrDeriveHardFromPublic = errors.New("cannot derive a hardened key from a public key")
+
+	// ErrInvalidChild indicates that the derivation of a child produced an
+	// invalid key, in which case the next index should be used instead.
+	ErrInvalidChild = errors.New("derived child key is invalid")
+
+	// ErrDepthExceeded indicates a derivation beyond the maximum depth of
+	// 255.
+	ErrDepthExceeded = errors.New("maximum derivation depth exceeded")
+)
+
+// An ExtendedKey is a BIP32 hierarchical deterministic key: a private or
+// public key together with the chain code from which its children derive.
+type ExtendedKey struct {
+	version   [4]byte
+	depth     uint8
+	parentFP  [4]byte
+	childNum  uint32
+	chainCode []byte
+
+	// key is the 32-byte private key of a private extended key or the
+	// 33-byte compressed public key of a public one.
+	key       []byte
+	isPrivate bool
+}
+
+// NewKeyFromString parses a serialized extended key such as an xpub or xprv.
+func NewKeyFromString(s string) (*ExtendedKey, error) {
+	payload, version, err := base58.DecodeCheck(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// The base58 version byte is the first byte of the four-byte extended
+	// key version.
+	b := append([]byte{version}, payload...)
+	if len(b) != serializedKeyLen {
+		return nil, ErrInvalidKeyLen
+	}
+
+	k := &ExtendedKey{
+		depth:     b[4],
+		childNum:  binary.BigEndian.Uint32(b[9:13]),
+		chainCode: append([]byte(nil), b[13:45]...),
+	}
+	copy(k.version[:], b[:4])
+	copy(k.parentFP[:], b[5:9])
+
+	switch k.version {
+	case MainNetPrivate, TestNetPrivate:
+		if b[45] != 0x00 {
+			return nil, ErrInvalidKeyData
+		}
+		k.key = append([]byte(nil), b[46:]...)
+		k.isPrivate = true
+		d := new(big.Int).SetBytes(k.key)
+		if d.Sign() == 0 || d.Cmp(btcec.S256().N) >= 0 {
+			return nil, ErrInvalidKeyData
+		}
+
+	case MainNetPublic, TestNetPublic:
+		k.key = append([]byte(nil), b[45:]...)
+		if _, err := btcec.ParsePubKeyStrict(k.key); err != nil {
+			return nil, ErrInvalidKeyData
+		}
+
+	default:
+		return nil, ErrUnknownVersion
+	}
+	return k, nil
+}
+
+// String returns the base58 serialization of k.
+func (k *ExtendedKey) String() string {
+	b := make([]byte, 0, serializedKeyLen)
+	b = append(b, k.version[:]...)
+	b = append(b, k.depth)
+	b = append(b, k.parentFP[:]...)
+	b = binary.BigEndian.AppendUint32(b, k.childNum)
+	b = append(b, k.chainCode...)
+	if k.isPrivate {
+		b = append(b, 0x00)
+	}
+	b = append(b, k.key...)
+	return base58.EncodeCheck(b[1:], b[0])
+}
+
+// IsPrivate returns whether k is a private extended key.
+func (k *ExtendedKey) IsPrivate() bool {
+	return k.isPrivate
+}
+
+// Depth returns the number of derivations from the master key to k.
+func (k *ExtendedKey) Depth() uint8 {
+	return k.depth
+}
+
+// ChildIndex returns the index at which k was derived from its parent.
+func (k *ExtendedKey) ChildIndex() uint32 {
+	return k.childNum
+}
+
+// ParentFingerprint returns the fingerprint of the parent of k.
+func (k *ExtendedKey) ParentFingerprint() [4]byte {
+	return k.parentFP
+}
+
+// Fingerprint returns the fingerprint of k: the first four bytes of the
+// Hash160 of its public key.
+func (k *ExtendedKey) Fingerprint() [4]byte {
+	var fp [4]byte
+	copy(fp[:], hashing.Hash160(k.pubKeyBytes()))
+	return fp
+}
+
+// ECPubKey returns the public key of k.
+func (k *ExtendedKey) ECPubKey() (*btcec.PublicKey, error) {
+	return btcec.ParsePubKey(k.pubKeyBytes())
+}
+
+// ECPrivKey returns the private key of k. An error is returned if k is a
+// public extended key.
+func (k *ExtendedKey) ECPrivKey() (*btcec.PrivateKey, error) {
+	if !k.isPrivate {
+		return nil, ErrInvalidKeyData
+	}
+	priv, _ := btcec.PrivKeyFromBytes(k.key)
+	return priv, nil
+}
+
+// Neuter returns the public extended key of k, which is k itself if it is
+// already public.
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	if !k.isPrivate {
+		return k
+	}
+
+	version := MainNetPublic
+	if k.version == TestNetPrivate {
+		version = TestNetPublic
+	}
+	return &ExtendedKey{
+		version:   version,
+		depth:     k.depth,
+		parentFP:  k.parentFP,
+		childNum:  k.childNum,
+		chainCode: k.chainCode,
+		key:       k.pubKeyBytes(),
+	}
+}
+
+// Derive returns the child of k at index i, which is hardened if i is at
+// least HardenedKeyStart. A private extended key derives private children
+// and a public one public children. ErrInvalidChild is returned in the
+// unlikely case that index i does not yield a valid key.
+func (k *ExtendedKey) Derive(i uint32) (*ExtendedKey, error) {
+	if k.depth == 255 {
+		return nil, ErrDepthExceeded
+	}
+	hardened := i >= HardenedKeyStart
+	if hardened && !k.isPrivate {
+		return nil, ErrDeriveHardFromPublic
+	}
+
+	// Hardened children commit to the private key and others to the
+	// public key.
+	var data []byte
+	if hardened {
+		data = append([]byte{0x00}, k.key...)
+	} else {
+		data = k.pubKeyBytes()
+	}
+	data = binary.BigEndian.AppendUint32(data, i)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	ilr := mac.Sum(nil)
+	il, chainCode := ilr[:32], ilr[32:]
+
+	curve := btcec.S256()
+	tweak := new(big.Int).SetBytes(il)
+	if tweak.Cmp(curve.N) >= 0 {
+		return nil, ErrInvalidChild
+	}
+
+	var childKey []byte
+	if k.isPrivate {
+		d := new(big.Int).SetBytes(k.key)
+		d.Add(d, tweak)
+		d.Mod(d, curve.N)
+		if d.Sign() == 0 {
+			return nil, ErrInvalidChild
+		}
+		childKey = make([]byte, 32)
+		d.FillBytes(childKey)
+	} else {
+		pubKey, err := btcec.ParsePubKey(k.key)
+		if err != nil {
+			return nil, err
+		}
+		tx, ty := curve.ScalarBaseMult(il)
+		x, y := curve.Add(pubKey.X, pubKey.Y, tx, ty)
+		if x.Sign() == 0 && y.Sign() == 0 {
+			return nil, ErrInvalidChild
+		}
+		childKey = (&btcec.PublicKey{Curve: curve, X: x, Y: y}).SerializeCompressed()
+	}
+
+	return &ExtendedKey{
+		version:   k.version,
+		depth:     k.depth + 1,
+		parentFP:  k.Fingerprint(),
+		childNum:  i,
+		chainCode: chainCode,
+		key:       childKey,
+		isPrivate: k.isPrivate,
+	}, nil
+}
+
+// DerivePath derives the descendant of k along path, one index at a time.
+func (k *ExtendedKey) DerivePath(path []uint32) (*ExtendedKey, error) {
+	var err error
+	for _, i := range path {
+		k, err = k.Derive(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// pubKeyBytes returns the compressed public key of k.
+func (k *ExtendedKey) pubKeyBytes() []byte {
+	if !k.isPrivate {
+		return k.key
+	}
+	_, pubKey := btcec.PrivKeyFromBytes(k.key)
+	return pubKey.SerializeCompressed()
+}
@@ -0,0 +1,105 @@
+package descriptor
+
+import (
+	"errors"
+	"strings"
+)
+
+// checksumLen is the number of characters of a descriptor checksum.
+const checksumLen = 8
+
+// inputCharset is the set of characters allowed in a descriptor, ordered so
+// that the characters most common in descriptors differ only in their low
+// five bits.
+const inputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+	"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+	"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+// checksumCharset is the alphabet of descriptor checksums.
+const checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var (
+	// ErrInvalidCharacter indicates a descriptor with a character outside
+	// of the descriptor character set.
+	ErrInvalidCharacter = errors.New("invalid descriptor character")
+
+	// ErrInvalidChecksum indicates a descriptor whose checksum does not
+	// match its contents.
+	ErrInvalidChecksum = errors.New("invalid descriptor checksum")
+)
+
+// descPolymod computes the descriptor checksum polynomial of symbols, a
+// BCH code over GF(32) with a 40-bit state.
+func descPolymod(symbols []uint64) uint64 {
+	gen := [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+	chk := uint64(1)
+	for _, v := range symbols {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+// Checksum returns the checksum of the descriptor desc, which must not
+// already carry one.
+func Checksum(desc string) (string, error) {
+	// Each character contributes its position within its group of 32 as
+	// one symbol, and every three characters contribute their groups as
+	// a further symbol.
+	symbols := make([]uint64, 0, len(desc)+len(desc)/3+checksumLen+1)
+	var groups []uint64
+	for i := 0; i < len(desc); i++ {
+		v := strings.IndexByte(inputCharset, desc[i])
+		if v < 0 {
+			return "", ErrInvalidCharacter
+		}
+		symbols = append(symbols, uint64(v&31))
+		groups = append(groups, uint64(v>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = groups[:0]
+		}
+	}
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+
+	symbols = append(symbols, make([]uint64, checksumLen)...)
+	chk := descPolymod(symbols) ^ 1
+
+	b := make([]byte, checksumLen)
+	for i := range b {
+		b[i] = checksumCharset[(chk>>uint(5*(checksumLen-1-i)))&31]
+	}
+	return string(b), nil
+}
+
+// splitChecksum splits desc into its body and checksum, verifying the
+// checksum if there is one.
+func splitChecksum(desc string) (string, error) {
+	i := strings.IndexByte(desc, '#')
+	if i < 0 {
+		return desc, nil
+	}
+
+	body, sum := desc[:i], desc[i+1:]
+	if len(sum) != checksumLen {
+		return "", ErrInvalidChecksum
+	}
+	want, err := Checksum(body)
+	if err != nil {
+		return "", err
+	}
+	if sum != want {
+		return "", ErrInvalidChecksum
+	}
+	return body, nil
+}
@@ -0,0 +1,509 @@
+// Package descriptor implements output script descriptors as specified by
+// BIP380 through BIP386: a language describing the output scripts of a
+// wallet, including ranges of keys derived from extended keys. Within wsh()
+// and tr(), scripts may also be written as miniscript expressions.
+package descriptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/miniscript"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+	"github.com/jacobkaufmann/gocoin/pkg/script"
+	"github.com/jacobkaufmann/gocoin/pkg/util/encoding/base58"
+	"github.com/jacobkaufmann/gocoin/pkg/util/encoding/bech32"
+)
+
+var (
+	// ErrInvalidExpression indicates a malformed script expression.
+	ErrInvalidExpression = errors.New("invalid script expression")
+
+	// ErrInvalidContext indicates a script expression where it is not
+	// allowed, such as sh() nested within wsh().
+	ErrInvalidContext = errors.New("script expression not allowed here")
+
+	// ErrInvalidAddress indicates an addr() expression whose address is
+	// not a valid address of a supported network.
+	ErrInvalidAddress = errors.New("invalid address")
+
+	// ErrInvalidIndex indicates an expansion index beyond the unhardened
+	// derivation range.
+	ErrInvalidIndex = errors.New("derivation index out of range")
+
+	// ErrNoAddress indicates a descriptor whose output script has no
+	// address form, such as raw() or bare multi().
+	ErrNoAddress = errors.New("descriptor has no address")
+)
+
+// context identifies where a script expression appears, which determines
+// the expressions and keys allowed there.
+type context int
+
+const (
+	ctxTop context = iota
+	ctxP2SH
+	ctxP2WSH
+	ctxTapscript
+)
+
+// A node is a parsed script expression.
+type node struct {
+	name string
+
+	// keys are the key arguments of pk(), pkh(), wpkh(), multi(),
+	// sortedmulti() and the internal key of tr(), and threshold is the
+	// number of signatures required by multi() and sortedmulti().
+	keys      []*keyExpr
+	threshold int
+
+	// sub is the script expression wrapped by sh() and wsh(), and tree is
+	// the script tree of tr(), if any.
+	sub  *node
+	tree *tapTree
+
+	// script is the fixed output script of addr() and raw().
+	script []byte
+
+	// ms is the miniscript of an expression within wsh() or tr() which
+	// is not a descriptor function. Its keys are parsed into keys in the
+	// order of ms.Keys.
+	ms *miniscript.Node
+}
+
+// A tapTree is a node of a tr() script tree: either a leaf script or a branch
+// with two children.
+type tapTree struct {
+	leaf        *node
+	left, right *tapTree
+}
+
+// A Descriptor is a parsed output script descriptor.
+type Descriptor struct {
+	body string
+	root *node
+}
+
+// Parse parses the descriptor desc, verifying its checksum if it has one.
+func Parse(desc string) (*Descriptor, error) {
+	body, err := splitChecksum(desc)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Checksum(body); err != nil {
+		return nil, err
+	}
+
+	root, err := parseExpr(body, ctxTop)
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{body: body, root: root}, nil
+}
+
+// String returns the descriptor followed by its checksum.
+func (d *Descriptor) String() string {
+	sum, _ := Checksum(d.body)
+	return d.body + "#" + sum
+}
+
+// IsRange returns whether the descriptor contains a ranged key and so
+// describes a different output script at each index.
+func (d *Descriptor) IsRange() bool {
+	return d.root.isRange()
+}
+
+// Script returns the output script of the descriptor at index, which is
+// ignored unless the descriptor is ranged.
+func (d *Descriptor) Script(index uint32) ([]byte, error) {
+	if index > maxPathIndex {
+		return nil, ErrInvalidIndex
+	}
+	return d.root.expand(index)
+}
+
+// Address returns the address of the output script of the descriptor at
+// index on network net. ErrNoAddress is returned for descriptors without an
+// address form.
+func (d *Descriptor) Address(index uint32, net protocol.BitcoinNet) (string, error) {
+	pkScript, err := d.Script(index)
+	if err != nil {
+		return "", err
+	}
+	class, addrs, _, err := script.ExtractScriptAddrs(pkScript, net)
+	if err != nil {
+		return "", err
+	}
+	switch class {
+	case script.NonStandardTy, script.PubKeyTy, script.MultiSigTy,
+		script.NullDataTy:
+		return "", ErrNoAddress
+	}
+	return addrs[0], nil
+}
+
+// parseExpr parses the script expression s appearing in context ctx.
+func parseExpr(s string, ctx context) (*node, error) {
+	open := strings.IndexByte(s, '(')
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidExpression, s)
+	}
+	n := &node{name: s[:open]}
+	args, err := splitArgs(s[open+1 : len(s)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	// compressedOnly applies to keys within segwit scripts.
+	compressedOnly := ctx == ctxP2WSH || ctx == ctxTapscript
+	switch n.name {
+	case "pk", "pkh":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: %s() takes one key", ErrInvalidExpression, n.name)
+		}
+		key, err := parseKey(args[0], ctx == ctxTapscript, compressedOnly)
+		if err != nil {
+			return nil, err
+		}
+		n.keys = []*keyExpr{key}
+
+	case "wpkh":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: wpkh() takes one key", ErrInvalidExpression)
+		}
+		if ctx != ctxTop && ctx != ctxP2SH {
+			return nil, ErrInvalidContext
+		}
+		key, err := parseKey(args[0], false, true)
+		if err != nil {
+			return nil, err
+		}
+		n.keys = []*keyExpr{key}
+
+	case "sh", "wsh":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: %s() takes one script", ErrInvalidExpression, n.name)
+		}
+		subCtx := ctxP2SH
+		if n.name == "wsh" {
+			subCtx = ctxP2WSH
+		}
+		if (n.name == "sh" && ctx != ctxTop) ||
+			(n.name == "wsh" && ctx != ctxTop && ctx != ctxP2SH) {
+			return nil, ErrInvalidContext
+		}
+		n.sub, err = parseExpr(args[0], subCtx)
+		if err != nil {
+			return nil, err
+		}
+
+	case "multi", "sortedmulti":
+		if ctx == ctxTapscript {
+			return nil, ErrInvalidContext
+		}
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%w: %s() takes a threshold and keys", ErrInvalidExpression, n.name)
+		}
+		n.threshold, err = strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: threshold %q", ErrInvalidExpression, args[0])
+		}
+		for _, arg := range args[1:] {
+			key, err := parseKey(arg, false, compressedOnly)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key)
+		}
+		if n.threshold < 1 || n.threshold > len(n.keys) ||
+			len(n.keys) > script.MaxPubKeysPerMultiSig {
+			return nil, fmt.Errorf("%w: %d of %d keys", ErrInvalidExpression, n.threshold, len(n.keys))
+		}
+
+	case "tr":
+		if ctx != ctxTop {
+			return nil, ErrInvalidContext
+		}
+		if len(args) != 1 && len(args) != 2 {
+			return nil, fmt.Errorf("%w: tr() takes a key and optional tree", ErrInvalidExpression)
+		}
+		key, err := parseKey(args[0], true, true)
+		if err != nil {
+			return nil, err
+		}
+		n.keys = []*keyExpr{key}
+		if len(args) == 2 {
+			n.tree, err = parseTapTree(args[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+	case "addr", "raw":
+		if ctx != ctxTop {
+			return nil, ErrInvalidContext
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: %s() takes one argument", ErrInvalidExpression, n.name)
+		}
+		if n.name == "addr" {
+			n.script, err = addressScript(args[0])
+		} else {
+			n.script, err = hex.DecodeString(args[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidExpression, args[0])
+		}
+
+	default:
+		if ctx == ctxP2WSH || ctx == ctxTapscript {
+			return parseMiniscript(s, ctx)
+		}
+		return nil, fmt.Errorf("%w: unknown function %q", ErrInvalidExpression, n.name)
+	}
+	return n, nil
+}
+
+// parseMiniscript parses the miniscript s appearing in context ctx, which
+// must be a sane top-level expression.
+func parseMiniscript(s string, ctx context) (*node, error) {
+	msCtx := miniscript.P2WSH
+	if ctx == ctxTapscript {
+		msCtx = miniscript.Tapscript
+	}
+	ms, err := miniscript.Parse(s, msCtx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExpression, err)
+	}
+	if err := ms.CheckSane(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExpression, err)
+	}
+
+	n := &node{ms: ms}
+	for _, arg := range ms.Keys() {
+		key, err := parseKey(arg, ctx == ctxTapscript, true)
+		if err != nil {
+			return nil, err
+		}
+		n.keys = append(n.keys, key)
+	}
+	return n, nil
+}
+
+// parseTapTree parses a tr() script tree: a script expression or a pair of
+// trees in braces.
+func parseTapTree(s string) (*tapTree, error) {
+	if !strings.HasPrefix(s, "{") {
+		leaf, err := parseExpr(s, ctxTapscript)
+		if err != nil {
+			return nil, err
+		}
+		return &tapTree{leaf: leaf}, nil
+	}
+
+	if !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidExpression, s)
+	}
+	children, err := splitArgs(s[1 : len(s)-1])
+	if err != nil {
+		return nil, err
+	}
+	if len(children) != 2 {
+		return nil, fmt.Errorf("%w: script tree branch needs two children", ErrInvalidExpression)
+	}
+	left, err := parseTapTree(children[0])
+	if err != nil {
+		return nil, err
+	}
+	right, err := parseTapTree(children[1])
+	if err != nil {
+		return nil, err
+	}
+	return &tapTree{left: left, right: right}, nil
+}
+
+// splitArgs splits the arguments of an expression at the commas which are
+// not nested within parentheses, braces or brackets.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("%w: unbalanced %q", ErrInvalidExpression, s)
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unbalanced %q", ErrInvalidExpression, s)
+	}
+	return append(args, s[start:]), nil
+}
+
+// addressScript returns the output script paid to by addr, a base58 or
+// segwit address of a supported network.
+func addressScript(addr string) ([]byte, error) {
+	for _, hrp := range []string{"bc", "tb"} {
+		if !strings.HasPrefix(strings.ToLower(addr), hrp+"1") {
+			continue
+		}
+		version, program, err := bech32.DecodeSegWitAddress(hrp, addr)
+		if err != nil {
+			return nil, err
+		}
+		return script.NewScriptBuilder().AddInt64(int64(version)).
+			AddData(program).Script()
+	}
+
+	payload, version, err := base58.DecodeCheck(addr)
+	if err != nil {
+		return nil, err
+	}
+	switch base58.VersionPrefix(version) {
+	case base58.Address, base58.TestNetAddress:
+		return script.PayToPubKeyHashScript(payload)
+	case base58.P2SH, base58.TestNetP2SH:
+		return script.PayToScriptHashScript(payload)
+	}
+	return nil, ErrInvalidAddress
+}
+
+// isRange returns whether any key of n or its children is ranged.
+func (n *node) isRange() bool {
+	for _, key := range n.keys {
+		if key.isRange() {
+			return true
+		}
+	}
+	if n.sub != nil && n.sub.isRange() {
+		return true
+	}
+	return n.tree != nil && n.tree.isRange()
+}
+
+// isRange returns whether any leaf of t has a ranged key.
+func (t *tapTree) isRange() bool {
+	if t.leaf != nil {
+		return t.leaf.isRange()
+	}
+	return t.left.isRange() || t.right.isRange()
+}
+
+// expand returns the script of n with its keys derived at index.
+func (n *node) expand(index uint32) ([]byte, error) {
+	keys := make([][]byte, len(n.keys))
+	for i, key := range n.keys {
+		var err error
+		keys[i], err = key.derive(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if n.ms != nil {
+		derived := make(map[string][]byte, len(keys))
+		for i, arg := range n.ms.Keys() {
+			derived[arg] = keys[i]
+		}
+		return n.ms.Script(func(arg string) ([]byte, error) {
+			return derived[arg], nil
+		})
+	}
+
+	switch n.name {
+	case "pk":
+		return script.NewScriptBuilder().AddData(keys[0]).
+			AddOp(byte(script.OpCheckSig)).Script()
+
+	case "pkh":
+		return script.PayToPubKeyHashScript(hashing.Hash160(keys[0]))
+
+	case "wpkh":
+		return script.PayToWitnessPubKeyHashScript(hashing.Hash160(keys[0]))
+
+	case "sh":
+		redeem, err := n.sub.expand(index)
+		if err != nil {
+			return nil, err
+		}
+		if len(redeem) > script.MaxScriptElementSize {
+			return nil, script.ErrElementTooBig
+		}
+		return script.PayToScriptHashScriptFor(redeem)
+
+	case "wsh":
+		witnessScript, err := n.sub.expand(index)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(witnessScript)
+		return script.PayToWitnessScriptHashScript(hash[:])
+
+	case "multi", "sortedmulti":
+		if n.name == "sortedmulti" {
+			sort.Slice(keys, func(i, j int) bool {
+				return bytes.Compare(keys[i], keys[j]) < 0
+			})
+		}
+		return script.MultiSigScript(keys, n.threshold)
+
+	case "tr":
+		internalKey, err := schnorr.ParsePubKey(keys[0])
+		if err != nil {
+			return nil, err
+		}
+		var rootHash []byte
+		if n.tree != nil {
+			rootHash, err = n.tree.hash(index)
+			if err != nil {
+				return nil, err
+			}
+		}
+		outputKey, err := script.ComputeTaprootOutputKey(internalKey, rootHash)
+		if err != nil {
+			return nil, err
+		}
+		return script.PayToTaprootScript(schnorr.SerializePubKey(outputKey))
+	}
+
+	// addr() and raw().
+	return append([]byte(nil), n.script...), nil
+}
+
+// hash returns the hash of the script tree t with its keys derived at index.
+func (t *tapTree) hash(index uint32) ([]byte, error) {
+	if t.leaf != nil {
+		leafScript, err := t.leaf.expand(index)
+		if err != nil {
+			return nil, err
+		}
+		return script.TapLeafHash(script.BaseLeafVersion, leafScript), nil
+	}
+
+	left, err := t.left.hash(index)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.right.hash(index)
+	if err != nil {
+		return nil, err
+	}
+	return script.TapBranchHash(left, right), nil
+}
@@ -0,0 +1,260 @@
+package descriptor
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// Keys shared by the test vectors of BIP380 through BIP386.
+const (
+	keyA  = "03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd"
+	keyAU = "04a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd" +
+		"5b8dec5235a0fa8722476c7709c02559e3aa73aa03918ba2d492eea75abea235"
+	keyAX = "a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd"
+	keyB  = "02e493dbf1c10d80f3581e4904930b1404cc6c13900ee0758474fa94abe8c4cd13"
+	keyCX = "669b8afcec803a0d323e9a17f3ea8e68e8abe5a278020a929adbec52421adbd0"
+
+	xpub1 = "xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH"
+	xpub2 = "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"
+	xprv1 = "xprvA1RpRA33e1JQ7ifknakTFpgNXPmW2YvmhqLQYMmrj4xJXXWYpDPS3xz7iAxn8L39njGVyuoseXzU6rcxFLJ8HFsTjSyQbLYnMpCqE2VbFWc"
+	xprv2 = "xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxXeJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFceUvJFjaPdGZ2y9WACViL4L"
+	xprv3 = "xprv9s21ZrQH143K31xYSDQpPDxsXRTUcvj2iNHm5NUtrGiGG5e2DtALGdso3pGz6ssrdK4PFmM8NSpSBHNqPqm55Qn3LqFtT2emdEXVYsCzC2U"
+	xprv4 = "xprv9vHkqa6EV4sPZHYqZznhT2NPtPCjKuDKGY38FBWLvgaDx45zo9WQRUT3dKYnjwih2yJD9mkrocEZXo1ex8G81dwSM1fwqWpWkeS3v86pgKt"
+	xprv5 = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+	xprv6 = "xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334"
+)
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+	}{
+		{"raw(deadbeef)#89f8spxm", nil},
+		{"raw(deadbeef)", nil},
+		{"raw(deadbeef)#", ErrInvalidChecksum},
+		{"raw(deadbeef)#89f8spxmx", ErrInvalidChecksum},
+		{"raw(deadbeef)#89f8spx", ErrInvalidChecksum},
+		{"raw(deadbeef)#89f8spxn", ErrInvalidChecksum},
+		{"raw(deedbeef)#89f8spxm", ErrInvalidChecksum},
+		{"raw(Ü)#00000000", ErrInvalidCharacter},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.desc)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.desc, err, test.err)
+		}
+	}
+
+	d, err := Parse("raw(deadbeef)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.String(); got != "raw(deadbeef)#89f8spxm" {
+		t.Errorf("got %s, want raw(deadbeef)#89f8spxm", got)
+	}
+}
+
+// TestDescriptors expands the test vectors of BIP380 through BIP386 which
+// use public keys and extended keys. Ranged descriptors are expanded at the
+// indexes 0, 1 and 2.
+func TestDescriptors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		scripts []string
+	}{
+		// BIP380 key expressions.
+		{"pk(" + keyA + ")", []string{"21" + keyA + "ac"}},
+		{"pk(" + keyAU + ")", []string{"41" + keyAU + "ac"}},
+		{"pkh([deadbeef/1/2'/3/4']" + keyA + ")",
+			[]string{"76a9149a1c78a507689f6f54b847ad1cef1e614ee23f1e88ac"}},
+		{"pkh([deadbeef/1/2h/3/4h]" + keyA + ")",
+			[]string{"76a9149a1c78a507689f6f54b847ad1cef1e614ee23f1e88ac"}},
+
+		// BIP381 non-segwit outputs.
+		{"pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)",
+			[]string{"76a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac"}},
+		{"pkh(" + keyAU + ")",
+			[]string{"76a914b5bd079c4d57cc7fc28ecf8213a6b791625b818388ac"}},
+		{"sh(multi(2,[00000000/111'/222]" + xprv1 + "," + xprv2 + "/0))",
+			[]string{"a91445a9a622a8b0a1269944be477640eedc447bbd8487"}},
+
+		// BIP382 segwit outputs.
+		{"wpkh(02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9)",
+			[]string{"00147dd65592d0ab2fe0d0257d571abf032cd9db93dc"}},
+		{"wpkh(" + keyA + ")",
+			[]string{"00149a1c78a507689f6f54b847ad1cef1e614ee23f1e"}},
+		{"sh(wpkh(03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556))",
+			[]string{"a914cc6ffbc0bf31af759451068f90ba7a0272b6b33287"}},
+		{"sh(wpkh(" + keyA + "))",
+			[]string{"a91484ab21b1b2fd065d4504ff693d832434b6108d7b87"}},
+		{"wsh(pkh(" + keyB + "))",
+			[]string{"0020fc5acc302aab97f821f9a61e1cc572e7968a603551e95d4ba12b51df6581482f"}},
+		{"wpkh([ffffffff/13']" + xpub1 + "/1/2/*)", []string{
+			"0014326b2249e3a25d5dc60935f044ee835d090ba859",
+			"0014af0bd98abc2f2cae66e36896a39ffe2d32984fb7",
+			"00141fa798efd1cbf95cebf912c031b8a4a6e9fb9f27",
+		}},
+
+		// BIP383 multisig.
+		{"multi(1," + keyA + "," + keyAU + ")",
+			[]string{"5121" + keyA + "41" + keyAU + "52ae"}},
+		{"sortedmulti(1," + keyAU + "," + keyA + ")",
+			[]string{"5121" + keyA + "41" + keyAU + "52ae"}},
+		{"wsh(multi(2," + xprv3 + "/2147483647'/0," + xprv4 + "/1/2/*," +
+			xprv5 + "/10/20/30/40/*'))", []string{
+			"0020b92623201f3bb7c3771d45b2ad1d0351ea8fbf8cfe0a0e570264e1075fa1948f",
+			"002036a08bbe4923af41cf4316817c93b8d37e2f635dd25cfff06bd50df6ae7ea203",
+			"0020a96e7ab4607ca6b261bfe3245ffda9c746b28d3f59e83d34820ec0e2b36c139c",
+		}},
+
+		// BIP385 raw() and addr().
+		{"raw(deadbeef)", []string{"deadbeef"}},
+		{"addr(1PMycacnJaSqwwJqjawXBErnLsZ7RkXUAs)",
+			[]string{"76a914f54a5851e9372b87810a8e60cdd2e7cfd80b6e3188ac"}},
+		{"addr(3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy)",
+			[]string{"a914b472a266d0bd89c13706a4132ccfb16f7c3b9fcb87"}},
+		{"addr(bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4)",
+			[]string{"0014751e76e8199196d454941c45d1b3a323f1433bd6"}},
+
+		// BIP386 taproot outputs.
+		{"tr(" + keyAX + ")",
+			[]string{"512077aab6e066f8a7419c5ab714c12c67d25007ed55a43cadcacb4d7a970a093f11"}},
+		{"tr(" + keyA + ")",
+			[]string{"512077aab6e066f8a7419c5ab714c12c67d25007ed55a43cadcacb4d7a970a093f11"}},
+		{"tr(" + keyAX + ",pk(" + keyCX + "))",
+			[]string{"512017cf18db381d836d8923b1bdb246cfcd818da1a9f0e6e7907f187f0b2f937754"}},
+		{"tr(" + keyAX + ",{pk(" + xprv6 + "/0),pk(" + keyCX + ")})",
+			[]string{"5120c6076ba65c29d1ef61ad2e9a635659af20c5537fb8096e767b9898bce531c22e"}},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.desc)
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		if ranged := len(test.scripts) > 1; d.IsRange() != ranged {
+			t.Errorf("%s: IsRange is %v, want %v", test.desc, d.IsRange(), ranged)
+		}
+		for i, want := range test.scripts {
+			script, err := d.Script(uint32(i))
+			if err != nil {
+				t.Errorf("%s: index %d: %v", test.desc, i, err)
+				continue
+			}
+			if got := hex.EncodeToString(script); got != want {
+				t.Errorf("%s: index %d: got %s, want %s", test.desc, i, got, want)
+			}
+		}
+
+		// The descriptor with its checksum parses to the same scripts.
+		withSum, err := Parse(d.String())
+		if err != nil {
+			t.Errorf("%s: %v", d.String(), err)
+			continue
+		}
+		script, err := withSum.Script(0)
+		if err != nil || hex.EncodeToString(script) != test.scripts[0] {
+			t.Errorf("%s: got %x %v, want %s", d.String(), script, err, test.scripts[0])
+		}
+	}
+}
+
+// TestInvalidDescriptors checks the invalid descriptors of BIP380 through
+// BIP386 which do not rely on private keys in WIF form.
+func TestInvalidDescriptors(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+	}{
+		// Key expressions.
+		{"pk(" + keyA[:64] + ")", ErrInvalidKey},
+		{"pkh(" + xpub2 + "/1'/2)", ErrHardenedFromPublic},
+		{"pkh(" + xpub2 + "/1/*')", ErrHardenedFromPublic},
+		{"pkh(" + xpub2 + "/2147483648)", ErrInvalidPath},
+		{"pkh(" + xpub2 + "/1/aa)", ErrInvalidPath},
+		{"pkh(" + xpub2 + "//1)", ErrInvalidPath},
+		{"pkh([deadbee/1]" + keyA + ")", ErrInvalidKeyOrigin},
+		{"pkh([deadbeefab/1]" + keyA + ")", ErrInvalidKeyOrigin},
+		{"pkh([deadbeef/1/2'/3/4'" + keyA + ")", ErrInvalidExpression},
+		{"pkh([deadbeef/1/x]" + keyA + ")", ErrInvalidPath},
+		{"pkh(" + keyA + "/1)", ErrInvalidKey},
+
+		// Segwit and taproot outputs take compressed keys only.
+		{"wpkh(" + keyAU + ")", ErrUncompressedKey},
+		{"sh(wpkh(" + keyAU + "))", ErrUncompressedKey},
+		{"wsh(pk(" + keyAU + "))", ErrUncompressedKey},
+		{"wsh(multi(1," + keyA + "," + keyAU + "))", ErrUncompressedKey},
+		{"tr(" + keyAU + ")", ErrUncompressedKey},
+		{"tr(" + keyAX + ",pk(" + keyAU + "))", ErrUncompressedKey},
+
+		// Expressions outside of the contexts allowed for them.
+		{"sh(sh(pk(" + keyA + ")))", ErrInvalidContext},
+		{"wsh(sh(pk(" + keyA + ")))", ErrInvalidContext},
+		{"wsh(wsh(pk(" + keyA + ")))", ErrInvalidContext},
+		{"wsh(wpkh(" + keyA + "))", ErrInvalidContext},
+		{"sh(tr(" + keyAX + "))", ErrInvalidContext},
+		{"wsh(tr(" + keyAX + "))", ErrInvalidContext},
+		{"sh(raw(deadbeef))", ErrInvalidContext},
+		{"sh(addr(1PMycacnJaSqwwJqjawXBErnLsZ7RkXUAs))", ErrInvalidContext},
+		{"tr(" + keyAX + ",multi(1," + keyA + "))", ErrInvalidContext},
+		{"tr(" + keyAX + ",wpkh(" + keyA + "))", ErrInvalidContext},
+
+		// Malformed expressions.
+		{"multi(0," + keyA + ")", ErrInvalidExpression},
+		{"multi(3," + keyA + "," + keyB + ")", ErrInvalidExpression},
+		{"multi(1)", ErrInvalidExpression},
+		{"pk(" + keyA + "," + keyB + ")", ErrInvalidExpression},
+		{"tr(" + keyAX + ",{pk(" + keyCX + ")})", ErrInvalidExpression},
+		{"tr(" + keyAX + ",{pk(" + keyCX + "),pk(" + keyAX + ")}", ErrInvalidExpression},
+		{"raw(deadbee)", ErrInvalidExpression},
+		{"addr(1PMycacnJaSqwwJqjawXBErnLsZ7RkXUAt)", ErrInvalidExpression},
+		{"foo(" + keyA + ")", ErrInvalidExpression},
+	}
+	for _, test := range tests {
+		if _, err := Parse(test.desc); !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.desc, err, test.err)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		desc string
+		net  protocol.BitcoinNet
+		addr string
+		err  error
+	}{
+		{"addr(1PMycacnJaSqwwJqjawXBErnLsZ7RkXUAs)", protocol.MainNet,
+			"1PMycacnJaSqwwJqjawXBErnLsZ7RkXUAs", nil},
+		{"addr(3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy)", protocol.MainNet,
+			"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", nil},
+		{"addr(bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4)", protocol.MainNet,
+			"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", nil},
+		{"raw(deadbeef)", protocol.MainNet, "", ErrNoAddress},
+		{"pk(" + keyA + ")", protocol.MainNet, "", ErrNoAddress},
+		{"multi(1," + keyA + ")", protocol.MainNet, "", ErrNoAddress},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.desc)
+		if err != nil {
+			t.Fatalf("%s: %v", test.desc, err)
+		}
+		addr, err := d.Address(0, test.net)
+		if err != test.err || addr != test.addr {
+			t.Errorf("%s: got %q %v, want %q %v", test.desc, addr, err,
+				test.addr, test.err)
+		}
+	}
+
+	// Expanding a ranged descriptor past the unhardened range fails.
+	d, err := Parse("wpkh(" + xpub1 + "/*)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Script(maxPathIndex + 1); err != ErrInvalidIndex {
+		t.Errorf("index %d: got %v, want %v", maxPathIndex+1, err, ErrInvalidIndex)
+	}
+}
@@ -0,0 +1,203 @@
+package descriptor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hdkeychain"
+)
+
+// maxPathIndex is the largest unhardened derivation index.
+const maxPathIndex = hdkeychain.HardenedKeyStart - 1
+
+var (
+	// ErrInvalidKey indicates a key expression which is neither a hex
+	// public key nor an extended key.
+	ErrInvalidKey = errors.New("invalid key expression")
+
+	// ErrInvalidKeyOrigin indicates a malformed [fingerprint/path] key
+	// origin.
+	ErrInvalidKeyOrigin = errors.New("invalid key origin")
+
+	// ErrInvalidPath indicates a malformed derivation path element.
+	ErrInvalidPath = errors.New("invalid derivation path")
+
+	// ErrUncompressedKey indicates an uncompressed public key where only
+	// compressed keys are allowed, such as within segwit descriptors.
+	ErrUncompressedKey = errors.New("uncompressed key not allowed here")
+
+	// ErrHardenedFromPublic indicates a hardened derivation step below a
+	// public extended key.
+	ErrHardenedFromPublic = errors.New("hardened derivation requires a private extended key")
+)
+
+// wildcard is the kind of final derivation step of a ranged key.
+type wildcard int
+
+const (
+	noWildcard wildcard = iota
+	unhardenedWildcard
+	hardenedWildcard
+)
+
+// A keyExpr is a parsed key expression: a hex public key or an extended key
+// with a derivation path, optionally preceded by the origin of the key.
+type keyExpr struct {
+	// pubKey is the serialized key of a hex key expression. xonly is set
+	// when the key is used as an x-only key within tr().
+	pubKey []byte
+	xonly  bool
+
+	// xkey is the extended key of an extended key expression, from which
+	// path and then any wildcard step derive the key.
+	xkey     *hdkeychain.ExtendedKey
+	path     []uint32
+	wildcard wildcard
+}
+
+// parseKey parses the key expression s. Within tr(), xonly is set and
+// 32-byte hex keys are allowed; compressedOnly rejects uncompressed keys.
+func parseKey(s string, xonly, compressedOnly bool) (*keyExpr, error) {
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return nil, ErrInvalidKeyOrigin
+		}
+		err := checkOrigin(s[1:end])
+		if err != nil {
+			return nil, err
+		}
+		s = s[end+1:]
+	}
+
+	k := &keyExpr{xonly: xonly}
+	parts := strings.Split(s, "/")
+	if b, err := hex.DecodeString(parts[0]); err == nil {
+		if len(parts) != 1 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, s)
+		}
+		switch {
+		case xonly && len(b) == 32:
+			_, err = btcec.ParsePubKey(append([]byte{0x02}, b...))
+		case len(b) == btcec.PubKeyBytesLenUncompressed && (xonly || compressedOnly):
+			return nil, ErrUncompressedKey
+		default:
+			_, err = btcec.ParsePubKeyStrict(b)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, s)
+		}
+		k.pubKey = b
+		return k, nil
+	}
+
+	xkey, err := hdkeychain.NewKeyFromString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKey, s)
+	}
+	k.xkey = xkey
+
+	steps := parts[1:]
+	if n := len(steps); n > 0 {
+		switch steps[n-1] {
+		case "*":
+			k.wildcard = unhardenedWildcard
+			steps = steps[:n-1]
+		case "*'", "*h":
+			k.wildcard = hardenedWildcard
+			steps = steps[:n-1]
+		}
+	}
+	for _, step := range steps {
+		i, err := parsePathStep(step)
+		if err != nil {
+			return nil, err
+		}
+		k.path = append(k.path, i)
+		if i >= hdkeychain.HardenedKeyStart && !xkey.IsPrivate() {
+			return nil, ErrHardenedFromPublic
+		}
+	}
+	if k.wildcard == hardenedWildcard && !xkey.IsPrivate() {
+		return nil, ErrHardenedFromPublic
+	}
+	return k, nil
+}
+
+// checkOrigin checks the key origin s, the contents of [fingerprint/path].
+func checkOrigin(s string) error {
+	parts := strings.Split(s, "/")
+	fp, err := hex.DecodeString(parts[0])
+	if err != nil || len(fp) != 4 {
+		return ErrInvalidKeyOrigin
+	}
+	for _, step := range parts[1:] {
+		_, err := parsePathStep(step)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parsePathStep parses a derivation path element: an index, marked hardened
+// by a trailing ' or h.
+func parsePathStep(s string) (uint32, error) {
+	var hardened uint32
+	if strings.HasSuffix(s, "'") || strings.HasSuffix(s, "h") {
+		hardened = hdkeychain.HardenedKeyStart
+		s = s[:len(s)-1]
+	}
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPath, s)
+	}
+	i, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || i > maxPathIndex {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPath, s)
+	}
+	return uint32(i) + hardened, nil
+}
+
+// isRange returns whether k derives a different key at each index.
+func (k *keyExpr) isRange() bool {
+	return k.wildcard != noWildcard
+}
+
+// derive returns the serialized public key of k at index, which is ignored
+// unless k is ranged. Keys within tr() are serialized as x-only keys.
+func (k *keyExpr) derive(index uint32) ([]byte, error) {
+	if k.xkey == nil {
+		if k.xonly && len(k.pubKey) != 32 {
+			return k.pubKey[1:], nil
+		}
+		return k.pubKey, nil
+	}
+
+	xkey, err := k.xkey.DerivePath(k.path)
+	if err != nil {
+		return nil, err
+	}
+	switch k.wildcard {
+	case unhardenedWildcard:
+		xkey, err = xkey.Derive(index)
+	case hardenedWildcard:
+		xkey, err = xkey.Derive(index + hdkeychain.HardenedKeyStart)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := xkey.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	b := pubKey.SerializeCompressed()
+	if k.xonly {
+		return b[1:], nil
+	}
+	return b, nil
+}
@@ -3,6 +3,7 @@ package mempool
 import (
 	"sync"
 
+	"github.com/jacobkaufmann/gocoin/pkg/blockchain"
 	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
 	"github.com/jacobkaufmann/gocoin/pkg/util"
 )
@@ -10,8 +11,15 @@ import (
 // MemPool represents the transaction mempool, which holds valid but
 // unconfirmed transactions. MemPool implements the TxPool interface.
 type MemPool struct {
-	txns map[hashing.Hash]*Entry
+	txns     map[hashing.Hash]*Entry
+	notifier *blockchain.Notifier
 	*sync.RWMutex
+
+	// procMux serializes changes to the pool together with their
+	// notifications, so that subscribers see events in the order the
+	// changes were made without the pool lock being held while they are
+	// sent.
+	procMux sync.Mutex
 }
 
 // Entry stores data about the corresponding transaction as well as
@@ -34,7 +42,19 @@ func newEntry(tx *util.Tx) *Entry {
 // New returns a new mempool.
 func New() *MemPool {
 	txns := make(map[hashing.Hash]*Entry)
-	return &MemPool{txns}
+	return &MemPool{
+		txns:     txns,
+		notifier: blockchain.NewNotifier(),
+		RWMutex:  &sync.RWMutex{},
+	}
+}
+
+// Subscribe registers a subscriber for mempool notifications whose channel
+// holds up to bufSize undelivered notifications. If types are given, only
+// notifications of those types are delivered.
+func (mp *MemPool) Subscribe(bufSize int,
+	types ...blockchain.NotificationType) *blockchain.Subscription {
+	return mp.notifier.Subscribe(bufSize, types...)
 }
 
 // Get returns a transaction entry specified by id if it exists in the mempool.
@@ -42,44 +62,163 @@ func (mp *MemPool) Get(id *hashing.Hash) *Entry {
 	mp.RLock()
 	defer mp.RUnlock()
 
-	tx, ok := mp.txns[id]
+	tx, ok := mp.txns[*id]
 	if ok == false {
 		return nil
 	}
 	return tx
 }
 
-// Insert adds a transaction entry to the mempool if it does not exist.
-func (mp *MemPool) Insert(tx *util.Tx, pver uint32) {
+// Insert adds a transaction entry to the mempool if it does not exist and
+// notifies subscribers that the transaction was accepted.
+func (mp *MemPool) Insert(tx *util.Tx, pver uint32) error {
+	txID, err := tx.TxID(pver)
+	if err != nil {
+		return err
+	}
+
+	mp.procMux.Lock()
+	defer mp.procMux.Unlock()
+
 	mp.Lock()
-	defer mp.Unlock()
+	_, ok := mp.txns[*txID]
+	if ok {
+		mp.Unlock()
+		return nil
+	}
 
 	entry := newEntry(tx)
 
 	// TODO: update fields of entry for related transactions in mempool.
 
-	mp.txns[tx.TxID(pver)] = entry
+	mp.txns[*txID] = entry
+	mp.Unlock()
+
+	mp.notifier.Notify(&blockchain.Notification{
+		Type: blockchain.NTTxAcceptedToMempool,
+		Data: &blockchain.TxNotification{Tx: tx, TxID: *txID},
+	})
+	return nil
 }
 
 // Remove removes a transaction entry specified by id if it exists in the
-// mempool.
-func (mp *MemPool) Remove(id *hashing.Hash) bool {
-	mp.Lock()
-	defer mp.Unlock()
+// mempool and notifies subscribers of the removal and its reason.
+func (mp *MemPool) Remove(id *hashing.Hash, reason blockchain.RemovalReason) bool {
+	mp.procMux.Lock()
+	defer mp.procMux.Unlock()
 
-	_, ok := mp.txns[id]
+	mp.Lock()
+	entry, ok := mp.txns[*id]
 	if ok == false {
+		mp.Unlock()
 		return false
 	}
 
-	delete(mp.txns, id)
+	delete(mp.txns, *id)
+	mp.Unlock()
+
+	mp.notifier.Notify(&blockchain.Notification{
+		Type: blockchain.NTTxRemovedFromMempool,
+		Data: &blockchain.TxNotification{
+			Tx:     entry.Tx,
+			TxID:   *id,
+			Reason: reason,
+		},
+	})
 	return true
 }
 
-// Clear removes all transactions from the mempool.
-func (mp *MemPool) Clear() {
+// outPoint is a comparable reference to a transaction output.
+type outPoint struct {
+	hash  hashing.Hash
+	index uint32
+}
+
+// ConnectBlock removes from the mempool the transactions of blk, which was
+// connected to the chain, and the transactions which conflict with them by
+// spending an output that a transaction of blk spends. Subscribers are
+// notified of each removal with reason RemovalReasonBlock or
+// RemovalReasonConflict respectively. Transaction ids are computed with
+// protocol version pver.
+func (mp *MemPool) ConnectBlock(blk *util.Block, pver uint32) error {
+	blockTxns := make(map[hashing.Hash]struct{}, len(blk.Txns))
+	spent := make(map[outPoint]struct{})
+	for _, tx := range blk.Txns {
+		txID, err := tx.TxID(pver)
+		if err != nil {
+			return err
+		}
+		blockTxns[*txID] = struct{}{}
+		for _, in := range tx.Inputs {
+			if in.PrevOutput.Hash != nil {
+				spent[outPoint{*in.PrevOutput.Hash, in.PrevOutput.Index}] = struct{}{}
+			}
+		}
+	}
+
+	mp.procMux.Lock()
+	defer mp.procMux.Unlock()
+
+	var removed []*blockchain.TxNotification
 	mp.Lock()
-	defer mp.Unlock()
+	for id, entry := range mp.txns {
+		reason := blockchain.RemovalReasonBlock
+		if _, ok := blockTxns[id]; !ok {
+			if !spendsAny(entry.Tx, spent) {
+				continue
+			}
+			reason = blockchain.RemovalReasonConflict
+		}
+		delete(mp.txns, id)
+		removed = append(removed, &blockchain.TxNotification{
+			Tx:     entry.Tx,
+			TxID:   id,
+			Reason: reason,
+		})
+	}
+	mp.Unlock()
+
+	for _, data := range removed {
+		mp.notifier.Notify(&blockchain.Notification{
+			Type: blockchain.NTTxRemovedFromMempool,
+			Data: data,
+		})
+	}
+	return nil
+}
 
-	mp.txns = make(map[hashing.Hash]*util.Tx)
+// spendsAny returns whether tx spends any of the outputs in spent.
+func spendsAny(tx *util.Tx, spent map[outPoint]struct{}) bool {
+	for _, in := range tx.Inputs {
+		if in.PrevOutput.Hash == nil {
+			continue
+		}
+		if _, ok := spent[outPoint{*in.PrevOutput.Hash, in.PrevOutput.Index}]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Clear removes all transactions from the mempool and notifies subscribers
+// of each removal.
+func (mp *MemPool) Clear() {
+	mp.procMux.Lock()
+	defer mp.procMux.Unlock()
+
+	mp.Lock()
+	txns := mp.txns
+	mp.txns = make(map[hashing.Hash]*Entry)
+	mp.Unlock()
+
+	for id, entry := range txns {
+		mp.notifier.Notify(&blockchain.Notification{
+			Type: blockchain.NTTxRemovedFromMempool,
+			Data: &blockchain.TxNotification{
+				Tx:     entry.Tx,
+				TxID:   id,
+				Reason: blockchain.RemovalReasonManual,
+			},
+		})
+	}
 }
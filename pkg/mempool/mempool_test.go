@@ -0,0 +1,154 @@
+package mempool
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/blockchain"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+	"github.com/jacobkaufmann/gocoin/pkg/util"
+)
+
+// testPver is the protocol version with which test transactions are
+// serialized.
+const testPver = 70015
+
+// testTx returns a transaction which differs from those with other tags.
+func testTx(tag uint32) *util.Tx {
+	in := &protocol.TxIn{
+		PrevOutput: protocol.TxOutPoint{
+			Hash:  &[protocol.HashSize]byte{},
+			Index: tag,
+		},
+		Sequence: 0xffffffff,
+	}
+	out := &protocol.TxOut{Value: 1, ScriptLock: []byte{0x51}}
+	return util.NewTx(1, []*protocol.TxIn{in}, []*protocol.TxOut{out}, 0)
+}
+
+func TestNotificationOrder(t *testing.T) {
+	const workers, rounds = 8, 1000
+
+	mp := New()
+	sub := mp.Subscribe(0)
+	defer sub.Unsubscribe()
+
+	// The workers race to insert and remove the same transaction, so its
+	// notifications must alternate between acceptance and removal,
+	// starting with acceptance. The subscriber is unbuffered so that
+	// notifications are held up while later changes are made.
+	tx := testTx(0)
+	txID, err := tx.TxID(testPver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				if err := mp.Insert(tx, testPver); err != nil {
+					t.Error(err)
+				}
+				mp.Remove(txID, blockchain.RemovalReasonConflict)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		sub.Unsubscribe()
+	}()
+
+	inPool := false
+	for ntfn := range sub.Notifications() {
+		accepted := ntfn.Type == blockchain.NTTxAcceptedToMempool
+		if accepted == inPool {
+			t.Fatalf("%v notification out of order", ntfn.Type)
+		}
+		inPool = accepted
+	}
+	if inPool || mp.Get(txID) != nil {
+		t.Fatal("transaction still in mempool")
+	}
+}
+
+func TestClear(t *testing.T) {
+	mp := New()
+	sub := mp.Subscribe(4, blockchain.NTTxRemovedFromMempool)
+	defer sub.Unsubscribe()
+
+	var ids []*hashing.Hash
+	for i := uint32(0); i < 3; i++ {
+		tx := testTx(i)
+		if err := mp.Insert(tx, testPver); err != nil {
+			t.Fatal(err)
+		}
+		id, err := tx.TxID(testPver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	mp.Clear()
+
+	if n := len(sub.Notifications()); n != len(ids) {
+		t.Fatalf("got %d removal notifications, want %d", n, len(ids))
+	}
+	for _, id := range ids {
+		if mp.Get(id) != nil {
+			t.Fatal("transaction still in mempool after Clear")
+		}
+	}
+}
+
+func TestConnectBlock(t *testing.T) {
+	mp := New()
+	sub := mp.Subscribe(4, blockchain.NTTxRemovedFromMempool)
+	defer sub.Unsubscribe()
+
+	// The block confirms tx 0 and spends the output spent by tx 1 in a
+	// different transaction. tx 2 is unrelated.
+	var ids []*hashing.Hash
+	for i := uint32(0); i < 3; i++ {
+		tx := testTx(i)
+		if err := mp.Insert(tx, testPver); err != nil {
+			t.Fatal(err)
+		}
+		id, err := tx.TxID(testPver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	conflict := testTx(1)
+	conflict.Outputs[0].Value = 2
+	blk := &util.Block{Txns: []*util.Tx{testTx(0), conflict}}
+	if err := mp.ConnectBlock(blk, testPver); err != nil {
+		t.Fatal(err)
+	}
+
+	reasons := make(map[hashing.Hash]blockchain.RemovalReason)
+	for len(sub.Notifications()) > 0 {
+		data := (<-sub.Notifications()).Data.(*blockchain.TxNotification)
+		reasons[data.TxID] = data.Reason
+	}
+	if len(reasons) != 2 {
+		t.Fatalf("got %d removal notifications, want 2", len(reasons))
+	}
+	if r, ok := reasons[*ids[0]]; !ok || r != blockchain.RemovalReasonBlock {
+		t.Errorf("tx 0 removed with reason %v, want %v", r,
+			blockchain.RemovalReasonBlock)
+	}
+	if r, ok := reasons[*ids[1]]; !ok || r != blockchain.RemovalReasonConflict {
+		t.Errorf("tx 1 removed with reason %v, want %v", r,
+			blockchain.RemovalReasonConflict)
+	}
+	if mp.Get(ids[0]) != nil || mp.Get(ids[1]) != nil {
+		t.Error("confirmed or conflicting transaction still in mempool")
+	}
+	if mp.Get(ids[2]) == nil {
+		t.Error("unrelated transaction removed from mempool")
+	}
+}
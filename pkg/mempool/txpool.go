@@ -1,6 +1,7 @@
 package mempool
 
 import (
+	"github.com/jacobkaufmann/gocoin/pkg/blockchain"
 	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
 	"github.com/jacobkaufmann/gocoin/pkg/util"
 )
@@ -8,8 +9,8 @@ import (
 // TxPool represents a pool of transactions. It provides methods for retrieving
 // a transaction as well as for inserting and removing transactions.
 type TxPool interface {
-	Get(*hashing.Hash) *util.Tx
-	Insert(*util.Tx)
-	Remove(*hashing.Hash) bool
+	Get(*hashing.Hash) *Entry
+	Insert(*util.Tx, uint32) error
+	Remove(*hashing.Hash, blockchain.RemovalReason) bool
 	Clear()
 }
@@ -0,0 +1,104 @@
+package miniscript
+
+import (
+	"errors"
+
+	"github.com/jacobkaufmann/gocoin/pkg/script"
+)
+
+var (
+	// ErrNotTopLevel indicates an expression which is not of type B and
+	// so cannot be used as a whole script.
+	ErrNotTopLevel = errors.New("miniscript is not a valid top-level expression")
+
+	// ErrMalleable indicates an expression without a non-malleable
+	// satisfaction.
+	ErrMalleable = errors.New("miniscript has no non-malleable satisfaction")
+
+	// ErrNoSignature indicates an expression which can be satisfied
+	// without a signature.
+	ErrNoSignature = errors.New("miniscript can be satisfied without a signature")
+
+	// ErrTimelockMix indicates an expression whose satisfaction may need
+	// both a time lock and a height lock of the same kind.
+	ErrTimelockMix = errors.New("miniscript mixes time and height locks")
+
+	// ErrTooManyOps indicates a P2WSH expression whose satisfaction may
+	// execute more than script.MaxOpsPerScript opcodes.
+	ErrTooManyOps = errors.New("miniscript exceeds the opcode limit")
+
+	// ErrScriptTooBig indicates a P2WSH expression whose script is larger
+	// than script.MaxScriptSize.
+	ErrScriptTooBig = errors.New("miniscript exceeds the script size limit")
+
+	// ErrDuplicateKey indicates an expression using the same key more than
+	// once, which makes its satisfactions malleable.
+	ErrDuplicateKey = errors.New("miniscript contains duplicate keys")
+)
+
+// IsValidTopLevel returns whether n is of type B and so can be used as a
+// whole script.
+func (n *Node) IsValidTopLevel() bool {
+	return n.typ.has(mst("B"))
+}
+
+// IsNonMalleable returns whether n has a non-malleable satisfaction in every
+// case it can be satisfied.
+func (n *Node) IsNonMalleable() bool {
+	return n.typ.has(mst("m"))
+}
+
+// NeedsSignature returns whether every satisfaction of n needs a signature.
+func (n *Node) NeedsSignature() bool {
+	return n.typ.has(mst("s"))
+}
+
+// HasTimelockMix returns whether some satisfaction of n needs both a time
+// lock and a height lock of the same kind, which no transaction can meet.
+func (n *Node) HasTimelockMix() bool {
+	return !n.typ.has(mst("k"))
+}
+
+// MaxOps returns the largest number of opcodes counted toward the P2WSH
+// limit when satisfying n, or -1 if n cannot be satisfied.
+func (n *Node) MaxOps() int {
+	if !n.ops.sat.valid {
+		return -1
+	}
+	return n.ops.count + n.ops.sat.n
+}
+
+// CheckSane returns an error unless n is a safe top-level script: it is of
+// type B, is non-malleable, needs a signature, does not mix time locks,
+// stays within the resource limits of its context and has no duplicate
+// keys.
+func (n *Node) CheckSane() error {
+	switch {
+	case !n.IsValidTopLevel():
+		return ErrNotTopLevel
+	case !n.IsNonMalleable():
+		return ErrMalleable
+	case !n.NeedsSignature():
+		return ErrNoSignature
+	case n.HasTimelockMix():
+		return ErrTimelockMix
+	}
+
+	if n.ctx == P2WSH {
+		if n.MaxOps() > script.MaxOpsPerScript {
+			return ErrTooManyOps
+		}
+		if n.ScriptSize() > script.MaxScriptSize {
+			return ErrScriptTooBig
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range n.Keys() {
+		if seen[key] {
+			return ErrDuplicateKey
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,497 @@
+// Package miniscript implements miniscript, a structured subset of Bitcoin
+// script which can be analyzed for correctness, malleability and resource
+// usage, and satisfied mechanically. Expressions are parsed for either P2WSH
+// or tapscript, whose rules differ slightly.
+package miniscript
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jacobkaufmann/gocoin/pkg/script"
+)
+
+// Context identifies the script version a miniscript is used with.
+type Context int
+
+const (
+	// P2WSH is the context of version 0 witness scripts.
+	P2WSH Context = iota
+
+	// Tapscript is the context of taproot leaf scripts.
+	Tapscript
+)
+
+// Lock time constants shared with the OP_CHECKLOCKTIMEVERIFY and
+// OP_CHECKSEQUENCEVERIFY rules.
+const (
+	// lockTimeThreshold is the smallest absolute lock time interpreted as
+	// a timestamp rather than a block height.
+	lockTimeThreshold = 500000000
+
+	// sequenceLockTimeTypeFlag marks a relative lock time in units of 512
+	// seconds rather than blocks.
+	sequenceLockTimeTypeFlag = 1 << 22
+
+	// maxLockTime is the largest lock time argument of older() and
+	// after().
+	maxLockTime = 0x7fffffff
+)
+
+// Limits of multi() and multi_a().
+const (
+	maxMultiKeys  = script.MaxPubKeysPerMultiSig
+	maxMultiAKeys = 999
+)
+
+var (
+	// ErrInvalidExpression indicates a malformed miniscript expression.
+	ErrInvalidExpression = errors.New("invalid miniscript expression")
+
+	// ErrInvalidType indicates an expression whose subexpressions do not
+	// have the types it requires.
+	ErrInvalidType = errors.New("miniscript expression is ill-typed")
+
+	// ErrInvalidContext indicates a fragment which is not available in
+	// the context, such as multi() in tapscript.
+	ErrInvalidContext = errors.New("fragment not allowed in this context")
+)
+
+// fragment identifies a miniscript fragment.
+type fragment int
+
+const (
+	fragJust0 fragment = iota
+	fragJust1
+	fragPkK
+	fragPkH
+	fragOlder
+	fragAfter
+	fragSha256
+	fragHash256
+	fragRipemd160
+	fragHash160
+	fragAndV
+	fragAndB
+	fragOrB
+	fragOrC
+	fragOrD
+	fragOrI
+	fragAndOr
+	fragThresh
+	fragMulti
+	fragMultiA
+	fragWrapA
+	fragWrapS
+	fragWrapC
+	fragWrapD
+	fragWrapV
+	fragWrapJ
+	fragWrapN
+)
+
+// fragmentNames maps the names of fragments which take arguments to their
+// fragments.
+var fragmentNames = map[string]fragment{
+	"pk_k":      fragPkK,
+	"pk_h":      fragPkH,
+	"older":     fragOlder,
+	"after":     fragAfter,
+	"sha256":    fragSha256,
+	"hash256":   fragHash256,
+	"ripemd160": fragRipemd160,
+	"hash160":   fragHash160,
+	"and_v":     fragAndV,
+	"and_b":     fragAndB,
+	"or_b":      fragOrB,
+	"or_c":      fragOrC,
+	"or_d":      fragOrD,
+	"or_i":      fragOrI,
+	"andor":     fragAndOr,
+	"thresh":    fragThresh,
+	"multi":     fragMulti,
+	"multi_a":   fragMultiA,
+}
+
+// wrapperFragments maps each wrapper letter to its fragment. The letters t,
+// l and u are shorthands for and_v() and or_i() and have no fragment.
+var wrapperFragments = map[byte]fragment{
+	'a': fragWrapA,
+	's': fragWrapS,
+	'c': fragWrapC,
+	'd': fragWrapD,
+	'v': fragWrapV,
+	'j': fragWrapJ,
+	'n': fragWrapN,
+}
+
+// A Node is a parsed and type-checked miniscript expression. Keys are kept
+// as the strings they were written as and resolved to serialized public keys
+// by a KeyFunc when the script is compiled or satisfied.
+type Node struct {
+	frag fragment
+	ctx  Context
+
+	// k is the threshold of thresh(), multi() and multi_a() and the lock
+	// time of older() and after().
+	k uint32
+
+	// keys are the key arguments, data the hash of a hash fragment and
+	// subs the subexpressions.
+	keys []string
+	data []byte
+	subs []*Node
+
+	typ typ
+	ops opsCount
+}
+
+// Parse parses and type-checks the miniscript expression s for context ctx.
+func Parse(s string, ctx Context) (*Node, error) {
+	return parse(s, ctx)
+}
+
+// parse parses the expression s, applying any wrappers written before its
+// fragment name.
+func parse(s string, ctx Context) (*Node, error) {
+	name, args := s, []string(nil)
+	if open := strings.IndexByte(s, '('); open >= 0 {
+		if !strings.HasSuffix(s, ")") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidExpression, s)
+		}
+		name = s[:open]
+		var err error
+		args, err = splitArgs(s[open+1 : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var wrappers string
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		wrappers, name = name[:i], name[i+1:]
+		if wrappers == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidExpression, s)
+		}
+	}
+
+	n, err := parseFragment(name, args, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The wrapper nearest the fragment applies first.
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		n, err = wrap(wrappers[i], n, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// parseFragment parses the fragment name applied to args.
+func parseFragment(name string, args []string, ctx Context) (*Node, error) {
+	if args == nil {
+		switch name {
+		case "0":
+			return newNode(fragJust0, ctx, 0, nil, nil, nil)
+		case "1":
+			return newNode(fragJust1, ctx, 0, nil, nil, nil)
+		}
+		return nil, fmt.Errorf("%w: %q", ErrInvalidExpression, name)
+	}
+
+	// Aliases for common combinations.
+	switch name {
+	case "pk", "pkh":
+		inner, err := parseFragment(map[string]string{"pk": "pk_k", "pkh": "pk_h"}[name], args, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newNode(fragWrapC, ctx, 0, nil, nil, []*Node{inner})
+	case "and_n":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%w: and_n() takes two arguments", ErrInvalidExpression)
+		}
+		args = append(args, "0")
+		name = "andor"
+	}
+
+	frag, ok := fragmentNames[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown fragment %q", ErrInvalidExpression, name)
+	}
+
+	switch frag {
+	case fragPkK, fragPkH:
+		if len(args) != 1 || !isKey(args[0]) {
+			return nil, fmt.Errorf("%w: %s() takes one key", ErrInvalidExpression, name)
+		}
+		return newNode(frag, ctx, 0, args, nil, nil)
+
+	case fragOlder, fragAfter:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: %s() takes one lock time", ErrInvalidExpression, name)
+		}
+		k, err := parseUint(args[0])
+		if err != nil || k < 1 || k > maxLockTime {
+			return nil, fmt.Errorf("%w: lock time %q", ErrInvalidExpression, args[0])
+		}
+		return newNode(frag, ctx, k, nil, nil, nil)
+
+	case fragSha256, fragHash256, fragRipemd160, fragHash160:
+		size := 32
+		if frag == fragRipemd160 || frag == fragHash160 {
+			size = 20
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: %s() takes one hash", ErrInvalidExpression, name)
+		}
+		hash, err := hex.DecodeString(args[0])
+		if err != nil || len(hash) != size {
+			return nil, fmt.Errorf("%w: hash %q", ErrInvalidExpression, args[0])
+		}
+		return newNode(frag, ctx, 0, nil, hash, nil)
+
+	case fragMulti, fragMultiA:
+		if (frag == fragMulti) != (ctx == P2WSH) {
+			return nil, fmt.Errorf("%w: %s()", ErrInvalidContext, name)
+		}
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%w: %s() takes a threshold and keys", ErrInvalidExpression, name)
+		}
+		k, err := parseUint(args[0])
+		keys := args[1:]
+		max := maxMultiKeys
+		if frag == fragMultiA {
+			max = maxMultiAKeys
+		}
+		if err != nil || k < 1 || int(k) > len(keys) || len(keys) > max {
+			return nil, fmt.Errorf("%w: %s() threshold %q of %d keys", ErrInvalidExpression, name, args[0], len(keys))
+		}
+		for _, key := range keys {
+			if !isKey(key) {
+				return nil, fmt.Errorf("%w: key %q", ErrInvalidExpression, key)
+			}
+		}
+		return newNode(frag, ctx, k, keys, nil, nil)
+
+	case fragThresh:
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%w: thresh() takes a threshold and expressions", ErrInvalidExpression)
+		}
+		k, err := parseUint(args[0])
+		if err != nil || k < 1 || int(k) > len(args)-1 {
+			return nil, fmt.Errorf("%w: thresh() threshold %q", ErrInvalidExpression, args[0])
+		}
+		subs, err := parseSubs(args[1:], ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newNode(frag, ctx, k, nil, nil, subs)
+	}
+
+	want := 2
+	if frag == fragAndOr {
+		want = 3
+	}
+	if len(args) != want {
+		return nil, fmt.Errorf("%w: %s() takes %d arguments", ErrInvalidExpression, name, want)
+	}
+	subs, err := parseSubs(args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newNode(frag, ctx, 0, nil, nil, subs)
+}
+
+// parseSubs parses each of args as a subexpression.
+func parseSubs(args []string, ctx Context) ([]*Node, error) {
+	subs := make([]*Node, len(args))
+	for i, arg := range args {
+		var err error
+		subs[i], err = parse(arg, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return subs, nil
+}
+
+// wrap applies the wrapper with letter w to n.
+func wrap(w byte, n *Node, ctx Context) (*Node, error) {
+	switch w {
+	case 't':
+		one, _ := newNode(fragJust1, ctx, 0, nil, nil, nil)
+		return newNode(fragAndV, ctx, 0, nil, nil, []*Node{n, one})
+	case 'l', 'u':
+		zero, _ := newNode(fragJust0, ctx, 0, nil, nil, nil)
+		subs := []*Node{zero, n}
+		if w == 'u' {
+			subs = []*Node{n, zero}
+		}
+		return newNode(fragOrI, ctx, 0, nil, nil, subs)
+	}
+
+	frag, ok := wrapperFragments[w]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown wrapper %q", ErrInvalidExpression, w)
+	}
+	return newNode(frag, ctx, 0, nil, nil, []*Node{n})
+}
+
+// newNode returns the node of a fragment with the given arguments. An error
+// is returned if the subexpressions do not have the types it requires.
+func newNode(frag fragment, ctx Context, k uint32, keys []string, data []byte,
+	subs []*Node) (*Node, error) {
+	n := &Node{frag: frag, ctx: ctx, k: k, keys: keys, data: data, subs: subs}
+	n.typ = computeType(frag, k, subs, ctx)
+	if !n.typ.isValid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidType, n)
+	}
+	n.ops = computeOps(frag, k, len(keys), subs)
+	return n, nil
+}
+
+// splitArgs splits the arguments of an expression at the commas which are
+// not nested within parentheses, braces or brackets.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("%w: unbalanced %q", ErrInvalidExpression, s)
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unbalanced %q", ErrInvalidExpression, s)
+	}
+	return append(args, s[start:]), nil
+}
+
+// parseUint parses a decimal number without sign or leading zeros.
+func parseUint(s string) (uint32, error) {
+	if len(s) > 1 && s[0] == '0' {
+		return 0, strconv.ErrSyntax
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	return uint32(n), err
+}
+
+// isKey returns whether s can be a key expression: it is non-empty and
+// contains no characters which delimit expressions.
+func isKey(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "(){},")
+}
+
+// String returns the expression n in its shortest form, using the pk(),
+// pkh() and and_n() aliases and the t:, l: and u: wrappers.
+func (n *Node) String() string {
+	switch n.frag {
+	case fragJust0:
+		return "0"
+	case fragJust1:
+		return "1"
+	case fragPkK, fragPkH:
+		return fragName(n.frag) + "(" + n.keys[0] + ")"
+	case fragOlder, fragAfter:
+		return fmt.Sprintf("%s(%d)", fragName(n.frag), n.k)
+	case fragSha256, fragHash256, fragRipemd160, fragHash160:
+		return fragName(n.frag) + "(" + hex.EncodeToString(n.data) + ")"
+	case fragMulti, fragMultiA:
+		return fmt.Sprintf("%s(%d,%s)", fragName(n.frag), n.k, strings.Join(n.keys, ","))
+	case fragWrapC:
+		switch n.subs[0].frag {
+		case fragPkK:
+			return "pk(" + n.subs[0].keys[0] + ")"
+		case fragPkH:
+			return "pkh(" + n.subs[0].keys[0] + ")"
+		}
+	case fragAndV:
+		if n.subs[1].frag == fragJust1 {
+			return wrapString('t', n.subs[0])
+		}
+	case fragOrI:
+		switch {
+		case n.subs[0].frag == fragJust0:
+			return wrapString('l', n.subs[1])
+		case n.subs[1].frag == fragJust0:
+			return wrapString('u', n.subs[0])
+		}
+	case fragAndOr:
+		if n.subs[2].frag == fragJust0 {
+			return "and_n(" + n.subs[0].String() + "," + n.subs[1].String() + ")"
+		}
+	}
+
+	for w, frag := range wrapperFragments {
+		if n.frag == frag {
+			return wrapString(w, n.subs[0])
+		}
+	}
+
+	args := make([]string, 0, len(n.subs)+1)
+	if n.frag == fragThresh {
+		args = append(args, strconv.FormatUint(uint64(n.k), 10))
+	}
+	for _, sub := range n.subs {
+		args = append(args, sub.String())
+	}
+	return fragName(n.frag) + "(" + strings.Join(args, ",") + ")"
+}
+
+// wrapString returns the expression sub wrapped by the wrapper w, merging
+// the wrapper into any wrappers sub already has.
+func wrapString(w byte, sub *Node) string {
+	s := sub.String()
+	open := strings.IndexByte(s, '(')
+	colon := strings.IndexByte(s, ':')
+	if colon >= 0 && (open < 0 || colon < open) {
+		return string(w) + s
+	}
+	return string(w) + ":" + s
+}
+
+// fragName returns the name of a fragment which takes arguments.
+func fragName(frag fragment) string {
+	for name, f := range fragmentNames {
+		if f == frag {
+			return name
+		}
+	}
+	return ""
+}
+
+// Type returns the type properties of n, such as "Bdemsuk".
+func (n *Node) Type() string {
+	return n.typ.String()
+}
+
+// Context returns the context n was parsed for.
+func (n *Node) Context() Context {
+	return n.ctx
+}
+
+// Keys returns the key expressions of n in the order they appear.
+func (n *Node) Keys() []string {
+	keys := append([]string(nil), n.keys...)
+	for _, sub := range n.subs {
+		keys = append(keys, sub.Keys()...)
+	}
+	return keys
+}
@@ -0,0 +1,446 @@
+package miniscript
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+	"github.com/jacobkaufmann/gocoin/pkg/script"
+)
+
+func TestFragmentTypes(t *testing.T) {
+	const key = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	const hash32 = "e38990d0c7fc009880a9c07c23842e886c6bbdc964ce6bdd5817ad357335ee6f"
+	tests := []struct {
+		ms  string
+		typ string
+	}{
+		{"0", "Bzduesmxk"},
+		{"1", "Bzufmxk"},
+		{"pk_k(" + key + ")", "Konduesmxk"},
+		{"pk_h(" + key + ")", "Knduesmxk"},
+		{"pk(" + key + ")", "Bonduesmk"},
+		{"pkh(" + key + ")", "Bnduesmk"},
+		{"older(1)", "Bzfmxhk"},
+		{"older(4194305)", "Bzfmxgk"},
+		{"after(1)", "Bzfmxjk"},
+		{"after(500000000)", "Bzfmxik"},
+		{"sha256(" + hash32 + ")", "Bondumk"},
+		{"hash160(" + hash32[:40] + ")", "Bondumk"},
+		{"multi(1," + key + ")", "Bnduesmk"},
+		{"v:pk(" + key + ")", "Vonfsmxk"},
+		{"s:pk(" + key + ")", "Wduesmk"},
+		{"a:older(1)", "Wfmxhk"},
+		{"n:pk(" + key + ")", "Bonduesmxk"},
+		{"dv:older(1)", "Bondemxhk"},
+		{"j:pk(" + key + ")", "Bondusmxk"},
+	}
+	for _, test := range tests {
+		n, err := Parse(test.ms, P2WSH)
+		if err != nil {
+			t.Errorf("%s: %v", test.ms, err)
+			continue
+		}
+		if got := n.Type(); got != test.typ {
+			t.Errorf("%s: type %s, want %s", test.ms, got, test.typ)
+		}
+	}
+}
+
+// TestScripts checks the compilation and analysis of the miniscripts in
+// Bitcoin Core's miniscript tests. nonMalleable, needsSig and timelockMix
+// give the expected results of IsNonMalleable, NeedsSignature and
+// HasTimelockMix.
+func TestScripts(t *testing.T) {
+	tests := []struct {
+		ms           string
+		script       string
+		nonMalleable bool
+		needsSig     bool
+		timelockMix  bool
+	}{
+		{"lltvln:after(1231488000)",
+			"6300676300676300670400046749b1926869516868",
+			true, false, false},
+		{"uuj:and_v(v:multi(2,03d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a,025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7cc),after(1231488000))",
+			"6363829263522103d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a21025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7cc52af0400046749b168670068670068",
+			true, true, false},
+		{"or_b(un:multi(2,03daed4f2be3a8bf278e70132fb0beb7522f570e144bf615c07e996d443dee8729,024ce119c96e2fa357200b559b2f7dd5a5f02d5290aff74b03f3e471b273211c97),al:older(16))",
+			"63522103daed4f2be3a8bf278e70132fb0beb7522f570e144bf615c07e996d443dee872921024ce119c96e2fa357200b559b2f7dd5a5f02d5290aff74b03f3e471b273211c9752ae926700686b63006760b2686c9b",
+			false, false, false},
+		{"j:and_v(vdv:after(1567547623),older(2016))",
+			"829263766304e7e06e5db169686902e007b268",
+			true, false, false},
+		{"t:and_v(vu:hash256(131772552c01444cd81360818376a040b7c3b2b7b0a53550ee3edde216cec61b),v:sha256(ec4916dd28fc4c10d78e287ca5d9cc51ee1ae73cbfde08c6b37324cbfaac8bc5))",
+			"6382012088aa20131772552c01444cd81360818376a040b7c3b2b7b0a53550ee3edde216cec61b876700686982012088a820ec4916dd28fc4c10d78e287ca5d9cc51ee1ae73cbfde08c6b37324cbfaac8bc58851",
+			true, false, false},
+		{"or_d(sha256(38df1c1f64a24a77b23393bca50dff872e31edc4f3b5aa3b90ad0b82f4f089b6),and_n(un:after(499999999),older(4194305)))",
+			"82012088a82038df1c1f64a24a77b23393bca50dff872e31edc4f3b5aa3b90ad0b82f4f089b68773646304ff64cd1db19267006864006703010040b26868",
+			false, false, false},
+		{"and_b(older(16),s:or_d(sha256(e38990d0c7fc009880a9c07c23842e886c6bbdc964ce6bdd5817ad357335ee6f),n:after(1567547623)))",
+			"60b27c82012088a820e38990d0c7fc009880a9c07c23842e886c6bbdc964ce6bdd5817ad357335ee6f87736404e7e06e5db192689a",
+			false, false, false},
+		{"j:and_v(v:hash160(20195b5a3d650c17f0f29f91c33f8f6335193d07),or_d(sha256(96de8fc8c256fa1e1556d41af431cace7dca68707c78dd88c3acab8b17164c47),older(16)))",
+			"82926382012088a91420195b5a3d650c17f0f29f91c33f8f6335193d078882012088a82096de8fc8c256fa1e1556d41af431cace7dca68707c78dd88c3acab8b17164c4787736460b26868",
+			false, false, false},
+		{"and_b(hash256(32ba476771d01e37807990ead8719f08af494723de1d228f2c2c07cc0aa40bac),a:and_b(hash256(131772552c01444cd81360818376a040b7c3b2b7b0a53550ee3edde216cec61b),a:older(1)))",
+			"82012088aa2032ba476771d01e37807990ead8719f08af494723de1d228f2c2c07cc0aa40bac876b82012088aa20131772552c01444cd81360818376a040b7c3b2b7b0a53550ee3edde216cec61b876b51b26c9a6c9a",
+			true, false, false},
+		{"and_n(sha256(d1ec675902ef1633427ca360b290b0b3045a0d9058ddb5e648b4c3c3224c5c68),t:or_i(v:older(4252898),v:older(144)))",
+			"82012088a820d1ec675902ef1633427ca360b290b0b3045a0d9058ddb5e648b4c3c3224c5c68876400676303e2e440b26967029000b269685168",
+			false, false, false},
+		{"or_d(nd:and_v(v:older(4252898),v:older(4252898)),sha256(38df1c1f64a24a77b23393bca50dff872e31edc4f3b5aa3b90ad0b82f4f089b6))",
+			"766303e2e440b26903e2e440b2696892736482012088a82038df1c1f64a24a77b23393bca50dff872e31edc4f3b5aa3b90ad0b82f4f089b68768",
+			false, false, false},
+		{"c:and_v(or_c(sha256(9267d3dbed802941483f1afa2a6bc68de5f653128aca9bf1461c5d0a3ad36ed2),v:multi(1,02c44d12c7065d812e8acf28d7cbb19f9011ecd9e9fdf281b0e6a3b5e87d22e7db)),pk_k(03acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbe))",
+			"82012088a8209267d3dbed802941483f1afa2a6bc68de5f653128aca9bf1461c5d0a3ad36ed28764512102c44d12c7065d812e8acf28d7cbb19f9011ecd9e9fdf281b0e6a3b5e87d22e7db51af682103acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbeac",
+			false, true, false},
+		{"and_v(andor(hash256(8a35d9ca92a48eaade6f53a64985e9e2afeb74dcf8acb4c3721e0dc7e4294b25),v:hash256(939894f70e6c3a25da75da0cc2071b4076d9b006563cf635986ada2e93c0d735),v:older(50000)),after(499999999))",
+			"82012088aa208a35d9ca92a48eaade6f53a64985e9e2afeb74dcf8acb4c3721e0dc7e4294b2587640350c300b2696782012088aa20939894f70e6c3a25da75da0cc2071b4076d9b006563cf635986ada2e93c0d735886804ff64cd1db1",
+			false, false, false},
+		{"or_i(c:and_v(v:after(500000),pk_k(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)),sha256(d9147961436944f43cd99d28b2bbddbf452ef872b30c8279e255e7daafc7f946))",
+			"630320a107b1692102c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5ac6782012088a820d9147961436944f43cd99d28b2bbddbf452ef872b30c8279e255e7daafc7f9468768",
+			true, false, false},
+		{"and_n(c:pk_k(03daed4f2be3a8bf278e70132fb0beb7522f570e144bf615c07e996d443dee8729),and_b(l:older(4252898),a:older(16)))",
+			"2103daed4f2be3a8bf278e70132fb0beb7522f570e144bf615c07e996d443dee8729ac64006763006703e2e440b2686b60b26c9a68",
+			true, true, true},
+	}
+	for _, test := range tests {
+		n, err := Parse(test.ms, P2WSH)
+		if err != nil {
+			t.Errorf("%s: %v", test.ms, err)
+			continue
+		}
+		if !n.IsValidTopLevel() {
+			t.Errorf("%s: not a valid top-level expression", test.ms)
+		}
+		script, err := n.Script(nil)
+		if err != nil {
+			t.Errorf("%s: %v", test.ms, err)
+			continue
+		}
+		if got := hex.EncodeToString(script); got != test.script {
+			t.Errorf("%s: got script %s, want %s", test.ms, got, test.script)
+		}
+		if n.ScriptSize() != len(script) {
+			t.Errorf("%s: script size %d, want %d", test.ms, n.ScriptSize(), len(script))
+		}
+		if n.IsNonMalleable() != test.nonMalleable {
+			t.Errorf("%s: IsNonMalleable is %v", test.ms, n.IsNonMalleable())
+		}
+		if n.NeedsSignature() != test.needsSig {
+			t.Errorf("%s: NeedsSignature is %v", test.ms, n.NeedsSignature())
+		}
+		if n.HasTimelockMix() != test.timelockMix {
+			t.Errorf("%s: HasTimelockMix is %v", test.ms, n.HasTimelockMix())
+		}
+	}
+}
+
+// TestTapscript checks the fragments whose types or scripts differ in
+// tapscript.
+func TestTapscript(t *testing.T) {
+	const a = "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	const b = "774ae7f858a9411e5ef4246b70c65aac5649980be5c17891bbec17895da008cb"
+	tests := []struct {
+		ms     string
+		typ    string
+		script string
+	}{
+		{"pk(" + a + ")", "Bonduesmk", "20" + a + "ac"},
+		{"multi_a(2," + a + "," + b + ")", "Bduesmk",
+			"20" + a + "ac20" + b + "ba529c"},
+		{"dv:older(1)", "Bonduemxhk", "766351b26968"},
+	}
+	for _, test := range tests {
+		n, err := Parse(test.ms, Tapscript)
+		if err != nil {
+			t.Errorf("%s: %v", test.ms, err)
+			continue
+		}
+		if got := n.Type(); got != test.typ {
+			t.Errorf("%s: type %s, want %s", test.ms, got, test.typ)
+		}
+		script, err := n.Script(nil)
+		if err != nil {
+			t.Errorf("%s: %v", test.ms, err)
+			continue
+		}
+		if got := hex.EncodeToString(script); got != test.script {
+			t.Errorf("%s: got script %s, want %s", test.ms, got, test.script)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	const key = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	tests := []struct {
+		ms  string
+		ctx Context
+		err error
+	}{
+		{"older(0)", P2WSH, ErrInvalidExpression},
+		{"older(2147483648)", P2WSH, ErrInvalidExpression},
+		{"after(0)", P2WSH, ErrInvalidExpression},
+		{"after(01)", P2WSH, ErrInvalidExpression},
+		{"sha256(00)", P2WSH, ErrInvalidExpression},
+		{"thresh(0,pk(" + key + "))", P2WSH, ErrInvalidExpression},
+		{"thresh(2,pk(" + key + "))", P2WSH, ErrInvalidExpression},
+		{"multi(0," + key + ")", P2WSH, ErrInvalidExpression},
+		{"pk(" + key + ")x", P2WSH, ErrInvalidExpression},
+		{":pk(" + key + ")", P2WSH, ErrInvalidExpression},
+		{"foo(" + key + ")", P2WSH, ErrInvalidExpression},
+		{"and_b(pk(" + key + "),pk(" + key + "))", P2WSH, ErrInvalidType},
+		{"or_b(pk(" + key + "),pk(" + key + "))", P2WSH, ErrInvalidType},
+		{"and_v(pk(" + key + "),pk(" + key + "))", P2WSH, ErrInvalidType},
+		{"c:older(1)", P2WSH, ErrInvalidType},
+		{"d:pk(" + key + ")", P2WSH, ErrInvalidType},
+		{"j:older(1)", P2WSH, ErrInvalidType},
+		{"v:v:pk(" + key + ")", P2WSH, ErrInvalidExpression},
+		{"multi_a(1," + key[2:] + ")", P2WSH, ErrInvalidContext},
+		{"multi(1," + key + ")", Tapscript, ErrInvalidContext},
+	}
+	for _, test := range tests {
+		if _, err := Parse(test.ms, test.ctx); !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.ms, err, test.err)
+		}
+	}
+}
+
+func TestCheckSane(t *testing.T) {
+	const a = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	const b = "03774ae7f858a9411e5ef4246b70c65aac5649980be5c17891bbec17895da008cb"
+	tests := []struct {
+		ms  string
+		err error
+	}{
+		{"pk(" + a + ")", nil},
+		{"s:pk(" + a + ")", ErrNotTopLevel},
+		{"or_d(sha256(e38990d0c7fc009880a9c07c23842e886c6bbdc964ce6bdd5817ad357335ee6f),pk(" + a + "))", ErrMalleable},
+		{"or_i(older(1),pk(" + a + "))", ErrNoSignature},
+		{"sha256(e38990d0c7fc009880a9c07c23842e886c6bbdc964ce6bdd5817ad357335ee6f)", ErrNoSignature},
+		{"and_v(v:pk(" + a + "),and_v(v:after(100),after(500000000)))", ErrTimelockMix},
+		{"and_v(v:pk(" + a + "),pk(" + a + "))", ErrDuplicateKey},
+		{"or_d(pk(" + a + "),pkh(" + b + "))", nil},
+	}
+	for _, test := range tests {
+		n, err := Parse(test.ms, P2WSH)
+		if err != nil {
+			t.Errorf("%s: %v", test.ms, err)
+			continue
+		}
+		if err := n.CheckSane(); err != test.err {
+			t.Errorf("%s: got %v, want %v", test.ms, err, test.err)
+		}
+	}
+}
+
+// A testSatisfier signs for the keys it holds in a P2WSH spend of amount by
+// the first input of tx, and meets the lock times its transaction allows.
+type testSatisfier struct {
+	t             *testing.T
+	privKeys      map[string]*btcec.PrivateKey
+	preimages     map[string][]byte
+	witnessScript []byte
+	tx            *protocol.MsgTx
+	amount        int64
+}
+
+func (s *testSatisfier) Sign(pubKey []byte) ([]byte, bool) {
+	privKey, ok := s.privKeys[hex.EncodeToString(pubKey)]
+	if !ok {
+		return nil, false
+	}
+	sigHashes, err := script.NewTxSigHashes(s.tx, nil)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	hash, err := script.CalcWitnessSignatureHash(s.witnessScript, sigHashes,
+		script.SigHashAll, s.tx, 0, s.amount)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	sig, err := privKey.Sign(hash)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return sig.SerializeWithHashType(byte(script.SigHashAll)), true
+}
+
+func (s *testSatisfier) Preimage(hash []byte) ([]byte, bool) {
+	preimage, ok := s.preimages[hex.EncodeToString(hash)]
+	return preimage, ok
+}
+
+func (s *testSatisfier) CheckOlder(n uint32) bool {
+	return n <= s.tx.Inputs[0].Sequence
+}
+
+func (s *testSatisfier) CheckAfter(n uint32) bool {
+	return n <= s.tx.LockTime
+}
+
+// TestSatisfy satisfies the example policies of BIP379 with different sets
+// of keys, preimages and lock times, and executes the witnesses produced.
+// Keys are named A to D, and H is the HASH160 of the preimage.
+func TestSatisfy(t *testing.T) {
+	pubKeys := make(map[string][]byte)
+	privKeys := make(map[string]*btcec.PrivateKey)
+	for i, name := range []string{"A", "B", "C", "D"} {
+		privKey, pubKey := btcec.PrivKeyFromBytes([]byte{byte(i + 1)})
+		pubKeys[name] = pubKey.SerializeCompressed()
+		privKeys[name] = privKey
+	}
+	keys := func(key string) ([]byte, error) {
+		if pubKey, ok := pubKeys[key]; ok {
+			return pubKey, nil
+		}
+		return nil, ErrInvalidKey
+	}
+	preimage := sha256.Sum256([]byte("preimage"))
+	hash := hex.EncodeToString(hashing.Hash160(preimage[:]))
+
+	tests := []struct {
+		ms       string
+		signers  string
+		preimage bool
+		older    uint32
+		ok       bool
+	}{
+		// A single key.
+		{"pk(A)", "A", false, 0, true},
+		{"pk(A)", "B", false, 0, false},
+
+		// One of two keys, equally likely or one likely.
+		{"or_b(pk(A),s:pk(B))", "B", false, 0, true},
+		{"or_b(pk(A),s:pk(B))", "AB", false, 0, true},
+		{"or_d(pk(A),pkh(B))", "A", false, 0, true},
+		{"or_d(pk(A),pkh(B))", "B", false, 0, true},
+
+		// A user and a 2FA service, or the user alone after 90 days.
+		{"and_v(v:pk(A),or_d(pk(B),older(12960)))", "AB", false, 0, true},
+		{"and_v(v:pk(A),or_d(pk(B),older(12960)))", "A", false, 12960, true},
+		{"and_v(v:pk(A),or_d(pk(B),older(12960)))", "A", false, 12959, false},
+		{"and_v(v:pk(A),or_d(pk(B),older(12960)))", "B", false, 12960, false},
+
+		// A 3-of-3 which becomes a 2-of-3 after 90 days.
+		{"thresh(3,pk(A),s:pk(B),s:pk(C),sln:older(12960))", "ABC", false, 0, true},
+		{"thresh(3,pk(A),s:pk(B),s:pk(C),sln:older(12960))", "AC", false, 12960, true},
+		{"thresh(3,pk(A),s:pk(B),s:pk(C),sln:older(12960))", "AC", false, 0, false},
+
+		// The BOLT #3 to_local policy.
+		{"andor(pk(A),older(1008),pk(B))", "A", false, 1008, true},
+		{"andor(pk(A),older(1008),pk(B))", "B", false, 0, true},
+		{"andor(pk(A),older(1008),pk(B))", "A", false, 0, false},
+
+		// The BOLT #3 offered HTLC policy.
+		{"t:or_c(pk(A),and_v(v:pk(B),or_c(pk(C),v:hash160(H))))", "A", false, 0, true},
+		{"t:or_c(pk(A),and_v(v:pk(B),or_c(pk(C),v:hash160(H))))", "BC", false, 0, true},
+		{"t:or_c(pk(A),and_v(v:pk(B),or_c(pk(C),v:hash160(H))))", "B", true, 0, true},
+		{"t:or_c(pk(A),and_v(v:pk(B),or_c(pk(C),v:hash160(H))))", "B", false, 0, false},
+
+		// The BOLT #3 received HTLC policy.
+		{"andor(pk(B),or_i(and_v(v:pkh(C),hash160(H)),older(1008)),pk(A))", "A", false, 0, true},
+		{"andor(pk(B),or_i(and_v(v:pkh(C),hash160(H)),older(1008)),pk(A))", "BC", true, 0, true},
+		{"andor(pk(B),or_i(and_v(v:pkh(C),hash160(H)),older(1008)),pk(A))", "B", false, 1008, true},
+		{"andor(pk(B),or_i(and_v(v:pkh(C),hash160(H)),older(1008)),pk(A))", "BC", false, 0, false},
+
+		// Multisig with any of its subsets of signers.
+		{"multi(2,A,B,C)", "AC", false, 0, true},
+		{"multi(2,A,B,C)", "ABC", false, 0, true},
+		{"multi(2,A,B,C)", "D", false, 0, false},
+	}
+	for _, test := range tests {
+		ms := test.ms
+		if i := indexOf(ms, "hash160(H)"); i >= 0 {
+			ms = ms[:i] + "hash160(" + hash + ")" + ms[i+len("hash160(H)"):]
+		}
+		name := test.ms + " with " + test.signers
+		n, err := Parse(ms, P2WSH)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := n.CheckSane(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		witnessScript, err := n.Script(keys)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		tx := testTx(test.older)
+		sat := &testSatisfier{
+			t:             t,
+			privKeys:      make(map[string]*btcec.PrivateKey),
+			preimages:     make(map[string][]byte),
+			witnessScript: witnessScript,
+			tx:            tx,
+			amount:        5000,
+		}
+		for _, signer := range test.signers {
+			key := hex.EncodeToString(pubKeys[string(signer)])
+			sat.privKeys[key] = privKeys[string(signer)]
+		}
+		if test.preimage {
+			sat.preimages[hash] = preimage[:]
+		}
+
+		items, err := n.Satisfy(sat, keys)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("%s: satisfied", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		scriptHash := sha256.Sum256(witnessScript)
+		pkScript, err := script.PayToWitnessScriptHashScript(scriptHash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		witness := protocol.TxWitness(append(items, witnessScript))
+		vm, err := script.NewEngine(nil, pkScript, witness, tx, 0, sat.amount,
+			script.StandardVerifyFlags, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Errorf("%s: witness fails: %v", name, err)
+		}
+	}
+}
+
+// testTx returns a transaction spending an output with its first input,
+// whose sequence number is older.
+func testTx(older uint32) *protocol.MsgTx {
+	return &protocol.MsgTx{
+		Version: 2,
+		Inputs: []*protocol.TxIn{{
+			PrevOutput: protocol.TxOutPoint{Hash: &[protocol.HashSize]byte{7}},
+			Sequence:   older,
+		}},
+		Outputs: []*protocol.TxOut{{
+			Value:          4000,
+			ScriptLock:     []byte{byte(script.Op1)},
+			ScriptLockSize: 1,
+		}},
+	}
+}
+
+// indexOf returns the index of the first instance of sub in s, or -1.
+func indexOf(s, sub string) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i:i+len(sub)] == sub {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,324 @@
+package miniscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"golang.org/x/crypto/ripemd160"
+)
+
+var (
+	// ErrUnsatisfiable indicates a miniscript which cannot be satisfied
+	// with the signatures, preimages and lock times available.
+	ErrUnsatisfiable = errors.New("miniscript cannot be satisfied")
+
+	// ErrMalleableSatisfaction indicates a miniscript whose only
+	// satisfactions with what is available are malleable.
+	ErrMalleableSatisfaction = errors.New("miniscript satisfaction is malleable")
+)
+
+// A Satisfier provides what is needed to satisfy a miniscript.
+type Satisfier interface {
+	// Sign returns a signature, with its sighash type appended, by the
+	// public key pubKey.
+	Sign(pubKey []byte) ([]byte, bool)
+
+	// Preimage returns the 32-byte preimage of hash.
+	Preimage(hash []byte) ([]byte, bool)
+
+	// CheckOlder returns whether the input being spent meets the
+	// relative lock time n of older().
+	CheckOlder(n uint32) bool
+
+	// CheckAfter returns whether the transaction meets the absolute lock
+	// time n of after().
+	CheckAfter(n uint32) bool
+}
+
+// A witness is a candidate witness stack satisfying or dissatisfying an
+// expression, together with the properties used to choose between
+// candidates.
+type witness struct {
+	// available is set if the witness can be produced at all.
+	available bool
+
+	// hasSig is set if the witness contains a signature, malleable if a
+	// third party could modify it into another valid witness, and
+	// nonCanon if it is not the canonical witness of its expression.
+	hasSig    bool
+	malleable bool
+	nonCanon  bool
+
+	// size is the serialized size of the items, which are in witness
+	// order: the first item is the bottom of the stack.
+	size  int
+	items [][]byte
+}
+
+// Common witnesses.
+var (
+	emptyWitness   = witness{available: true}
+	invalidWitness = witness{}
+	zeroWitness    = push([]byte{})
+	oneWitness     = push([]byte{1})
+)
+
+// push returns the witness consisting of the single item data.
+func push(data []byte) witness {
+	return witness{available: true, size: len(data) + 1, items: [][]byte{data}}
+}
+
+// then returns the witness of the items of w followed by those of v.
+func (w witness) then(v witness) witness {
+	if !w.available || !v.available {
+		return invalidWitness
+	}
+	return witness{
+		available: true,
+		hasSig:    w.hasSig || v.hasSig,
+		malleable: w.malleable || v.malleable,
+		nonCanon:  w.nonCanon || v.nonCanon,
+		size:      w.size + v.size,
+		items:     append(append([][]byte(nil), w.items...), v.items...),
+	}
+}
+
+// or returns the better of the alternative witnesses w and v. A witness
+// without a signature is preferred, since a third party could otherwise use
+// it in place of the other; if neither has a signature the choice is
+// malleable.
+func (w witness) or(v witness) witness {
+	switch {
+	case !w.available:
+		return v
+	case !v.available:
+		return w
+	case !w.hasSig && v.hasSig:
+		return w
+	case w.hasSig && !v.hasSig:
+		return v
+	case !w.hasSig && !v.hasSig:
+		w.malleable, v.malleable = true, true
+	case !w.malleable && v.malleable:
+		return w
+	case w.malleable && !v.malleable:
+		return v
+	}
+	if w.size <= v.size {
+		return w
+	}
+	return v
+}
+
+// withSig returns w marked as containing a signature.
+func (w witness) withSig() witness {
+	w.hasSig = true
+	return w
+}
+
+// withAvailable returns w, or invalidWitness if available is not set.
+func (w witness) withAvailable(available bool) witness {
+	if !available {
+		return invalidWitness
+	}
+	return w
+}
+
+// nonCanonical returns w marked as malleable and non-canonical if cond
+// holds.
+func (w witness) nonCanonical(cond bool) witness {
+	if cond {
+		w.malleable, w.nonCanon = true, true
+	}
+	return w
+}
+
+// Satisfy returns the witness stack satisfying n, resolving its keys with
+// keys as Script does. The satisfaction must be non-malleable and contain a
+// signature.
+func (n *Node) Satisfy(sat Satisfier, keys KeyFunc) ([][]byte, error) {
+	_, s, err := n.satisfy(sat, keys)
+	if err != nil {
+		return nil, err
+	}
+	if !s.available {
+		return nil, ErrUnsatisfiable
+	}
+	if s.malleable || !s.hasSig {
+		return nil, ErrMalleableSatisfaction
+	}
+	return s.items, nil
+}
+
+// satisfy returns the best dissatisfaction and satisfaction of n.
+func (n *Node) satisfy(sat Satisfier, keys KeyFunc) (witness, witness, error) {
+	switch n.frag {
+	case fragJust0:
+		return emptyWitness, invalidWitness, nil
+	case fragJust1:
+		return invalidWitness, emptyWitness, nil
+
+	case fragPkK:
+		key, err := n.resolveKey(n.keys[0], keys)
+		if err != nil {
+			return witness{}, witness{}, err
+		}
+		sig, ok := sat.Sign(key)
+		return zeroWitness, push(sig).withSig().withAvailable(ok), nil
+
+	case fragPkH:
+		key, err := n.resolveKey(n.keys[0], keys)
+		if err != nil {
+			return witness{}, witness{}, err
+		}
+		sig, ok := sat.Sign(key)
+		return zeroWitness.then(push(key)),
+			push(sig).withSig().then(push(key)).withAvailable(ok), nil
+
+	case fragOlder:
+		return invalidWitness, emptyWitness.withAvailable(sat.CheckOlder(n.k)), nil
+	case fragAfter:
+		return invalidWitness, emptyWitness.withAvailable(sat.CheckAfter(n.k)), nil
+
+	case fragSha256, fragHash256, fragRipemd160, fragHash160:
+		preimage, ok := sat.Preimage(n.data)
+		ok = ok && len(preimage) == 32 && bytes.Equal(n.hash(preimage), n.data)
+		return push(make([]byte, 32)), push(preimage).withAvailable(ok), nil
+
+	case fragMulti:
+		// sats[j] is the best witness with j signatures by the keys
+		// considered so far, below the dummy item OP_CHECKMULTISIG pops.
+		sats := []witness{zeroWitness}
+		for _, k := range n.keys {
+			key, err := n.resolveKey(k, keys)
+			if err != nil {
+				return witness{}, witness{}, err
+			}
+			sig, ok := sat.Sign(key)
+			s := push(sig).withSig().withAvailable(ok)
+			next := []witness{sats[0]}
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].or(sats[j-1].then(s)))
+			}
+			sats = append(next, sats[len(sats)-1].then(s))
+		}
+		dsat := zeroWitness
+		for i := uint32(0); i < n.k; i++ {
+			dsat = dsat.then(zeroWitness)
+		}
+		return dsat, sats[n.k], nil
+
+	case fragMultiA:
+		// The signature of the first key is checked first and so must
+		// be the top of the stack: the keys are considered in reverse.
+		sats := []witness{emptyWitness}
+		for i := len(n.keys) - 1; i >= 0; i-- {
+			key, err := n.resolveKey(n.keys[i], keys)
+			if err != nil {
+				return witness{}, witness{}, err
+			}
+			sig, ok := sat.Sign(key)
+			s := push(sig).withSig().withAvailable(ok)
+			next := []witness{sats[0].then(zeroWitness)}
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].then(zeroWitness).or(sats[j-1].then(s)))
+			}
+			sats = append(next, sats[len(sats)-1].then(s))
+		}
+		dsat := emptyWitness
+		for range n.keys {
+			dsat = dsat.then(zeroWitness)
+		}
+		return dsat, sats[n.k], nil
+	}
+
+	dsats := make([]witness, len(n.subs))
+	sats := make([]witness, len(n.subs))
+	for i, sub := range n.subs {
+		var err error
+		dsats[i], sats[i], err = sub.satisfy(sat, keys)
+		if err != nil {
+			return witness{}, witness{}, err
+		}
+	}
+
+	// The witness of a later subexpression is deeper in the stack, as the
+	// script consumes the witness of an earlier one first.
+	switch n.frag {
+	case fragWrapA, fragWrapS, fragWrapC, fragWrapN:
+		return dsats[0], sats[0], nil
+	case fragWrapD:
+		return zeroWitness, sats[0].then(oneWitness), nil
+	case fragWrapV:
+		return invalidWitness, sats[0], nil
+	case fragWrapJ:
+		// A dissatisfaction of the subexpression with a nonzero top
+		// item would be an alternative to the zero which skips it.
+		x := dsats[0]
+		return zeroWitness.nonCanonical(x.available && !x.hasSig), sats[0], nil
+
+	case fragAndV:
+		return dsats[1].then(sats[0]).nonCanonical(true), sats[1].then(sats[0]), nil
+	case fragAndB:
+		return dsats[1].then(dsats[0]).
+				or(sats[1].then(dsats[0]).nonCanonical(true)).
+				or(dsats[1].then(sats[0]).nonCanonical(true)),
+			sats[1].then(sats[0]), nil
+	case fragOrB:
+		return dsats[1].then(dsats[0]),
+			dsats[1].then(sats[0]).
+				or(sats[1].then(dsats[0])).
+				or(sats[1].then(sats[0]).nonCanonical(true)), nil
+	case fragOrC:
+		return invalidWitness, sats[0].or(sats[1].then(dsats[0])), nil
+	case fragOrD:
+		return dsats[1].then(dsats[0]), sats[0].or(sats[1].then(dsats[0])), nil
+	case fragOrI:
+		return dsats[0].then(oneWitness).or(dsats[1].then(zeroWitness)),
+			sats[0].then(oneWitness).or(sats[1].then(zeroWitness)), nil
+	case fragAndOr:
+		return dsats[1].then(sats[0]).nonCanonical(true).or(dsats[2].then(dsats[0])),
+			sats[1].then(sats[0]).or(sats[2].then(dsats[0])), nil
+
+	case fragThresh:
+		// ks[j] is the best witness satisfying j of the subexpressions
+		// considered so far, from the last.
+		ks := []witness{emptyWitness}
+		for i := len(n.subs) - 1; i >= 0; i-- {
+			next := []witness{ks[0].then(dsats[i])}
+			for j := 1; j < len(ks); j++ {
+				next = append(next, ks[j].then(dsats[i]).or(ks[j-1].then(sats[i])))
+			}
+			ks = append(next, ks[len(ks)-1].then(sats[i]))
+		}
+
+		// Satisfying any number of subexpressions other than k
+		// dissatisfies thresh(), but only none is canonical.
+		dsat := invalidWitness
+		for j := range ks {
+			if j != int(n.k) {
+				dsat = dsat.or(ks[j].nonCanonical(j != 0))
+			}
+		}
+		return dsat, ks[n.k], nil
+	}
+	return invalidWitness, invalidWitness, nil
+}
+
+// hash returns the hash of data computed by the hash fragment n.
+func (n *Node) hash(data []byte) []byte {
+	switch n.frag {
+	case fragSha256:
+		h := sha256.Sum256(data)
+		return h[:]
+	case fragHash256:
+		return hashing.DoubleSHA256B(data)
+	case fragRipemd160:
+		h := ripemd160.New()
+		h.Write(data)
+		return h.Sum(nil)
+	}
+	return hashing.Hash160(data)
+}
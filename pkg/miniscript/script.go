@@ -0,0 +1,222 @@
+package miniscript
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/script"
+)
+
+// ErrInvalidKey indicates a key which the KeyFunc of a compilation or
+// satisfaction could not resolve to a public key of the context's size.
+var ErrInvalidKey = errors.New("invalid miniscript key")
+
+// A KeyFunc resolves a key expression of a miniscript to its serialized
+// public key: 33 bytes in P2WSH and 32-byte x-only keys in tapscript.
+type KeyFunc func(key string) ([]byte, error)
+
+// keySize returns the size of the public keys used in context ctx.
+func keySize(ctx Context) int {
+	if ctx == Tapscript {
+		return 32
+	}
+	return 33
+}
+
+// resolveKey returns the public key of the key expression key using keys,
+// or by decoding key as hex if keys is nil.
+func (n *Node) resolveKey(key string, keys KeyFunc) ([]byte, error) {
+	var b []byte
+	var err error
+	if keys == nil {
+		b, err = hex.DecodeString(key)
+	} else {
+		b, err = keys(key)
+	}
+	if err != nil || len(b) != keySize(n.ctx) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return b, nil
+}
+
+// Script compiles n to script, resolving its keys with keys. If keys is nil,
+// every key must be a hex public key.
+func (n *Node) Script(keys KeyFunc) ([]byte, error) {
+	switch n.frag {
+	case fragJust0:
+		return []byte{byte(script.Op0)}, nil
+	case fragJust1:
+		return []byte{byte(script.Op1)}, nil
+
+	case fragPkK:
+		key, err := n.resolveKey(n.keys[0], keys)
+		if err != nil {
+			return nil, err
+		}
+		return script.NewScriptBuilder().AddData(key).Script()
+
+	case fragPkH:
+		key, err := n.resolveKey(n.keys[0], keys)
+		if err != nil {
+			return nil, err
+		}
+		return script.NewScriptBuilder().
+			AddOps(byte(script.OpDup), byte(script.OpHash160)).
+			AddData(hashing.Hash160(key)).
+			AddOp(byte(script.OpEqualVerify)).Script()
+
+	case fragOlder:
+		return script.NewScriptBuilder().AddInt64(int64(n.k)).
+			AddOp(byte(script.OpCheckSequenceVerify)).Script()
+	case fragAfter:
+		return script.NewScriptBuilder().AddInt64(int64(n.k)).
+			AddOp(byte(script.OpCheckLockTimeVerify)).Script()
+
+	case fragSha256, fragHash256, fragRipemd160, fragHash160:
+		op := map[fragment]byte{
+			fragSha256:    byte(script.OpSha256),
+			fragHash256:   byte(script.OpHash256),
+			fragRipemd160: byte(script.OpRipemd160),
+			fragHash160:   byte(script.OpHash160),
+		}[n.frag]
+		return script.NewScriptBuilder().AddOp(byte(script.OpSize)).
+			AddInt64(32).AddOp(byte(script.OpEqualVerify)).
+			AddOp(op).AddData(n.data).AddOp(byte(script.OpEqual)).Script()
+
+	case fragMulti:
+		b := script.NewScriptBuilder().AddInt64(int64(n.k))
+		for _, k := range n.keys {
+			key, err := n.resolveKey(k, keys)
+			if err != nil {
+				return nil, err
+			}
+			b.AddData(key)
+		}
+		return b.AddInt64(int64(len(n.keys))).
+			AddOp(byte(script.OpCheckMultiSig)).Script()
+
+	case fragMultiA:
+		b := script.NewScriptBuilder()
+		for i, k := range n.keys {
+			key, err := n.resolveKey(k, keys)
+			if err != nil {
+				return nil, err
+			}
+			b.AddData(key)
+			if i == 0 {
+				b.AddOp(byte(script.OpCheckSig))
+			} else {
+				b.AddOp(byte(script.OpCheckSigAdd))
+			}
+		}
+		return b.AddInt64(int64(n.k)).AddOp(byte(script.OpNumEqual)).Script()
+	}
+
+	subs := make([][]byte, len(n.subs))
+	for i, sub := range n.subs {
+		var err error
+		subs[i], err = sub.Script(keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var s []byte
+	switch n.frag {
+	case fragWrapA:
+		s = cat([]byte{byte(script.OpToAltStack)}, subs[0],
+			[]byte{byte(script.OpFromAltStack)})
+	case fragWrapS:
+		s = cat([]byte{byte(script.OpSwap)}, subs[0])
+	case fragWrapC:
+		s = cat(subs[0], []byte{byte(script.OpCheckSig)})
+	case fragWrapD:
+		s = cat([]byte{byte(script.OpDup), byte(script.OpIf)}, subs[0],
+			[]byte{byte(script.OpEndIf)})
+	case fragWrapV:
+		s = verify(subs[0], n.subs[0].typ.has(mst("x")))
+	case fragWrapJ:
+		s = cat([]byte{byte(script.OpSize), byte(script.Op0NotEqual), byte(script.OpIf)},
+			subs[0], []byte{byte(script.OpEndIf)})
+	case fragWrapN:
+		s = cat(subs[0], []byte{byte(script.Op0NotEqual)})
+
+	case fragAndV:
+		s = cat(subs[0], subs[1])
+	case fragAndB:
+		s = cat(subs[0], subs[1], []byte{byte(script.OpBoolAnd)})
+	case fragOrB:
+		s = cat(subs[0], subs[1], []byte{byte(script.OpBoolOr)})
+	case fragOrD:
+		s = cat(subs[0], []byte{byte(script.OpIfDup), byte(script.OpNotIf)},
+			subs[1], []byte{byte(script.OpEndIf)})
+	case fragOrC:
+		s = cat(subs[0], []byte{byte(script.OpNotIf)}, subs[1],
+			[]byte{byte(script.OpEndIf)})
+	case fragOrI:
+		s = cat([]byte{byte(script.OpIf)}, subs[0], []byte{byte(script.OpElse)},
+			subs[1], []byte{byte(script.OpEndIf)})
+	case fragAndOr:
+		s = cat(subs[0], []byte{byte(script.OpNotIf)}, subs[2],
+			[]byte{byte(script.OpElse)}, subs[1], []byte{byte(script.OpEndIf)})
+
+	case fragThresh:
+		s = subs[0]
+		for _, sub := range subs[1:] {
+			s = cat(s, sub, []byte{byte(script.OpAdd)})
+		}
+		k, err := script.NewScriptBuilder().AddInt64(int64(n.k)).
+			AddOp(byte(script.OpEqual)).Script()
+		if err != nil {
+			return nil, err
+		}
+		s = cat(s, k)
+	}
+	return s, nil
+}
+
+// verify returns the script s followed by OP_VERIFY. Unless appendVerify is
+// set, the last opcode of s has a VERIFY form which replaces it instead.
+func verify(s []byte, appendVerify bool) []byte {
+	if appendVerify {
+		return cat(s, []byte{byte(script.OpVerify)})
+	}
+
+	s = append([]byte(nil), s...)
+	last := &s[len(s)-1]
+	switch *last {
+	case byte(script.OpEqual):
+		*last = byte(script.OpEqualVerify)
+	case byte(script.OpCheckSig):
+		*last = byte(script.OpCheckSigVerify)
+	case byte(script.OpCheckMultiSig):
+		*last = byte(script.OpCheckMultiSigVerify)
+	case byte(script.OpNumEqual):
+		*last = byte(script.OpNumEqualVerify)
+	}
+	return s
+}
+
+// cat returns the concatenation of the scripts parts.
+func cat(parts ...[]byte) []byte {
+	var s []byte
+	for _, part := range parts {
+		s = append(s, part...)
+	}
+	return s
+}
+
+// ScriptSize returns the size of the script of n, which does not depend on
+// the keys it is compiled with.
+func (n *Node) ScriptSize() int {
+	size := keySize(n.ctx)
+	s, err := n.Script(func(string) ([]byte, error) {
+		return make([]byte, size), nil
+	})
+	if err != nil {
+		return 0
+	}
+	return len(s)
+}
@@ -0,0 +1,372 @@
+package miniscript
+
+import "strings"
+
+// A typ is the set of type properties of a miniscript expression: exactly one
+// of the basic types B, V, K and W, together with the properties which
+// describe how it can be satisfied and dissatisfied.
+type typ uint32
+
+// typeChars lists the type properties in the order of their bits:
+//
+//	B, V, K, W  the basic types
+//	z, o, n     the expression consumes zero or one stack items, or its
+//	            top input is never zero
+//	d, u        it has a dissatisfaction, and leaves exactly one on the
+//	            stack when satisfied
+//	e, f, s     it has no malleable dissatisfaction, no dissatisfaction
+//	            at all, and every satisfaction needs a signature
+//	m           it has a non-malleable satisfaction
+//	x           its last opcode is not EQUAL, CHECKSIG, CHECKMULTISIG or
+//	            NUMEQUAL, so a VERIFY costs an extra opcode
+//	g, h, i, j  it contains a relative time, relative height, absolute
+//	            time or absolute height lock
+//	k           it does not mix time and height locks
+const typeChars = "BVKWzondufesmxghijk"
+
+// mst returns the type with the properties named by chars.
+func mst(chars string) typ {
+	var t typ
+	for i := 0; i < len(chars); i++ {
+		t |= 1 << uint(strings.IndexByte(typeChars, chars[i]))
+	}
+	return t
+}
+
+// has returns whether t has every property of u.
+func (t typ) has(u typ) bool {
+	return t&u == u
+}
+
+// when returns t if cond holds and the empty type otherwise.
+func (t typ) when(cond bool) typ {
+	if cond {
+		return t
+	}
+	return 0
+}
+
+// String returns the properties of t as characters of typeChars.
+func (t typ) String() string {
+	var b strings.Builder
+	for i := 0; i < len(typeChars); i++ {
+		if t&(1<<uint(i)) != 0 {
+			b.WriteByte(typeChars[i])
+		}
+	}
+	return b.String()
+}
+
+// isValid returns whether t has exactly one basic type.
+func (t typ) isValid() bool {
+	n := 0
+	for _, c := range "BVKW" {
+		if t.has(mst(string(c))) {
+			n++
+		}
+	}
+	return n == 1
+}
+
+// noTimelockMix returns the k property of a combination of x and y which must
+// both be satisfied: neither may mix time and height locks, and they may not
+// mix them with each other.
+func noTimelockMix(x, y typ) typ {
+	mixed := (x.has(mst("g")) && y.has(mst("h"))) ||
+		(x.has(mst("h")) && y.has(mst("g"))) ||
+		(x.has(mst("i")) && y.has(mst("j"))) ||
+		(x.has(mst("j")) && y.has(mst("i")))
+	return mst("k").when((x & y).has(mst("k")) && !mixed)
+}
+
+// computeType returns the type of a fragment with the given arguments and
+// the types of its subexpressions, following the typing rules of the
+// miniscript specification. The result has no basic type if the arguments
+// do not have the types the fragment requires.
+func computeType(frag fragment, k uint32, subs []*Node, ctx Context) typ {
+	var x, y, z typ
+	if len(subs) > 0 {
+		x = subs[0].typ
+	}
+	if len(subs) > 1 {
+		y = subs[1].typ
+	}
+	if len(subs) > 2 {
+		z = subs[2].typ
+	}
+	timelocks := mst("ghij")
+
+	switch frag {
+	case fragJust0:
+		return mst("Bzudemsxk")
+	case fragJust1:
+		return mst("Bzufmxk")
+	case fragPkK:
+		return mst("Konudemsxk")
+	case fragPkH:
+		return mst("Knudemsxk")
+	case fragOlder:
+		return mst("g").when(k&sequenceLockTimeTypeFlag != 0) |
+			mst("h").when(k&sequenceLockTimeTypeFlag == 0) |
+			mst("Bzfmxk")
+	case fragAfter:
+		return mst("i").when(k >= lockTimeThreshold) |
+			mst("j").when(k < lockTimeThreshold) |
+			mst("Bzfmxk")
+	case fragSha256, fragHash256, fragRipemd160, fragHash160:
+		return mst("Bonudmk")
+
+	case fragWrapA:
+		return mst("W").when(x.has(mst("B"))) |
+			x&mst("ghijk") | x&mst("udfems") | mst("x")
+	case fragWrapS:
+		return mst("W").when(x.has(mst("Bo"))) |
+			x&mst("ghijk") | x&mst("udfemsx")
+	case fragWrapC:
+		return mst("B").when(x.has(mst("K"))) |
+			x&mst("ghijk") | x&mst("ondfem") | mst("us")
+	case fragWrapD:
+		// OP_IF requires a minimal argument in tapscript, which makes
+		// d: leave exactly one item when satisfied there.
+		return mst("B").when(x.has(mst("Vz"))) |
+			mst("o").when(x.has(mst("z"))) |
+			mst("e").when(x.has(mst("f"))) |
+			x&mst("ghijk") | x&mst("ms") |
+			mst("u").when(ctx == Tapscript) | mst("ndx")
+	case fragWrapV:
+		return mst("V").when(x.has(mst("B"))) |
+			x&mst("ghijk") | x&mst("zonms") | mst("fx")
+	case fragWrapJ:
+		return mst("B").when(x.has(mst("Bn"))) |
+			mst("e").when(x.has(mst("f"))) |
+			x&mst("ghijk") | x&mst("oums") | mst("ndx")
+	case fragWrapN:
+		return x&mst("ghijk") | x&mst("Bzondfems") | mst("ux")
+
+	case fragAndV:
+		return (y & mst("KVB")).when(x.has(mst("V"))) |
+			x&mst("n") | (y & mst("n")).when(x.has(mst("z"))) |
+			((x | y) & mst("o")).when((x | y).has(mst("z"))) |
+			x&y&mst("dmz") | (x|y)&mst("s") |
+			mst("f").when(y.has(mst("f")) || x.has(mst("s"))) |
+			y&mst("ux") | (x|y)&timelocks | noTimelockMix(x, y)
+	case fragAndB:
+		return (x & mst("B")).when(y.has(mst("W"))) |
+			((x | y) & mst("o")).when((x | y).has(mst("z"))) |
+			x&mst("n") | (y & mst("n")).when(x.has(mst("z"))) |
+			(x & y & mst("e")).when((x & y).has(mst("s"))) |
+			x&y&mst("dzm") |
+			mst("f").when((x&y).has(mst("f")) || x.has(mst("sf")) || y.has(mst("sf"))) |
+			(x|y)&mst("s") | mst("ux") | (x|y)&timelocks | noTimelockMix(x, y)
+	case fragOrB:
+		return mst("B").when(x.has(mst("Bd")) && y.has(mst("Wd"))) |
+			((x | y) & mst("o")).when((x | y).has(mst("z"))) |
+			(x & y & mst("m")).when((x|y).has(mst("s")) && (x&y).has(mst("e"))) |
+			x&y&mst("zse") | mst("dux") | (x|y)&timelocks | x&y&mst("k")
+	case fragOrD:
+		return (y & mst("B")).when(x.has(mst("Bdu"))) |
+			(x & mst("o")).when(y.has(mst("z"))) |
+			(x & y & mst("m")).when(x.has(mst("e")) && (x|y).has(mst("s"))) |
+			x&y&mst("zes") | y&mst("ufde") | mst("x") |
+			(x|y)&timelocks | x&y&mst("k")
+	case fragOrC:
+		return (y & mst("V")).when(x.has(mst("Bdu"))) |
+			(x & mst("o")).when(y.has(mst("z"))) |
+			(x & y & mst("m")).when(x.has(mst("e")) && (x|y).has(mst("s"))) |
+			x&y&mst("zs") | mst("fx") | (x|y)&timelocks | x&y&mst("k")
+	case fragOrI:
+		return x&y&mst("VBKufs") |
+			mst("o").when((x & y).has(mst("z"))) |
+			((x | y) & mst("e")).when((x | y).has(mst("f"))) |
+			(x & y & mst("m")).when((x | y).has(mst("s"))) |
+			(x|y)&mst("d") | mst("x") | (x|y)&timelocks | x&y&mst("k")
+	case fragAndOr:
+		return (y & z & mst("BKV")).when(x.has(mst("Bdu"))) |
+			x&y&z&mst("z") |
+			((x | (y & z)) & mst("o")).when((x | (y & z)).has(mst("z"))) |
+			y&z&mst("u") |
+			(z & mst("f")).when(x.has(mst("s")) || y.has(mst("f"))) |
+			z&mst("d") |
+			(z & mst("e")).when(x.has(mst("s")) || y.has(mst("f"))) |
+			(x & y & z & mst("m")).when(x.has(mst("e")) && (x|y|z).has(mst("s"))) |
+			z&(x|y)&mst("s") | mst("x") | (x|y|z)&timelocks |
+			noTimelockMix(x, y)&z&mst("k")
+
+	case fragMulti:
+		return mst("Bnudemsk")
+	case fragMultiA:
+		// The top input of multi_a() is the signature of the first key,
+		// which is empty when that key does not sign.
+		return mst("Budemsk")
+
+	case fragThresh:
+		allE, allM := true, true
+		var args, numS uint32
+		acc := mst("k")
+		for i, sub := range subs {
+			t := sub.typ
+			want := mst("Wdu")
+			if i == 0 {
+				want = mst("Bdu")
+			}
+			if !t.has(want) {
+				return 0
+			}
+			if !t.has(mst("e")) {
+				allE = false
+			}
+			if !t.has(mst("m")) {
+				allM = false
+			}
+			if t.has(mst("s")) {
+				numS++
+			}
+			switch {
+			case t.has(mst("z")):
+			case t.has(mst("o")):
+				args++
+			default:
+				args += 2
+			}
+			// A threshold of one satisfies a single subexpression, so
+			// its timelocks never combine.
+			mix := noTimelockMix(acc, t)
+			if k <= 1 {
+				mix = mst("k").when((acc & t).has(mst("k")))
+			}
+			acc = (acc|t)&timelocks | mix
+		}
+		n := uint32(len(subs))
+		return mst("Bdu") |
+			mst("z").when(args == 0) |
+			mst("o").when(args == 1) |
+			mst("e").when(allE && numS == n) |
+			mst("m").when(allE && allM && numS >= n-k) |
+			mst("s").when(numS >= n-k+1) |
+			acc
+	}
+	return 0
+}
+
+// A maybeInt is a count which may not exist, such as the number of opcodes
+// executed by the dissatisfaction of an expression which cannot be
+// dissatisfied.
+type maybeInt struct {
+	valid bool
+	n     int
+}
+
+// some returns the count n.
+func some(n int) maybeInt {
+	return maybeInt{valid: true, n: n}
+}
+
+// none is the count which does not exist.
+var none = maybeInt{}
+
+// plus returns the sum of a and b, which exists only if both do.
+func (a maybeInt) plus(b maybeInt) maybeInt {
+	if !a.valid || !b.valid {
+		return none
+	}
+	return some(a.n + b.n)
+}
+
+// or returns the larger of a and b, ignoring either if it does not exist.
+func (a maybeInt) or(b maybeInt) maybeInt {
+	switch {
+	case !a.valid:
+		return b
+	case !b.valid:
+		return a
+	case a.n >= b.n:
+		return a
+	}
+	return b
+}
+
+// opsCount counts the non-push opcodes of an expression: count is the
+// number in its script, and sat and dsat the most executed in addition by
+// OP_CHECKMULTISIG when satisfying and dissatisfying it.
+type opsCount struct {
+	count     int
+	sat, dsat maybeInt
+}
+
+// computeOps returns the opcode counts of a fragment.
+func computeOps(frag fragment, k uint32, numKeys int, subs []*Node) opsCount {
+	var x, y, z opsCount
+	if len(subs) > 0 {
+		x = subs[0].ops
+	}
+	if len(subs) > 1 {
+		y = subs[1].ops
+	}
+	if len(subs) > 2 {
+		z = subs[2].ops
+	}
+
+	switch frag {
+	case fragJust0:
+		return opsCount{0, none, some(0)}
+	case fragJust1:
+		return opsCount{0, some(0), none}
+	case fragPkK:
+		return opsCount{0, some(0), some(0)}
+	case fragPkH:
+		return opsCount{3, some(0), some(0)}
+	case fragOlder, fragAfter:
+		return opsCount{1, some(0), none}
+	case fragSha256, fragHash256, fragRipemd160, fragHash160:
+		return opsCount{4, some(0), none}
+	case fragAndV:
+		return opsCount{x.count + y.count, x.sat.plus(y.sat), none}
+	case fragAndB:
+		return opsCount{1 + x.count + y.count, x.sat.plus(y.sat), x.dsat.plus(y.dsat)}
+	case fragOrB:
+		return opsCount{1 + x.count + y.count,
+			x.sat.plus(y.dsat).or(y.sat.plus(x.dsat)), x.dsat.plus(y.dsat)}
+	case fragOrD:
+		return opsCount{3 + x.count + y.count,
+			x.sat.or(y.sat.plus(x.dsat)), x.dsat.plus(y.dsat)}
+	case fragOrC:
+		return opsCount{2 + x.count + y.count, x.sat.or(y.sat.plus(x.dsat)), none}
+	case fragOrI:
+		return opsCount{3 + x.count + y.count, x.sat.or(y.sat), x.dsat.or(y.dsat)}
+	case fragAndOr:
+		return opsCount{3 + x.count + y.count + z.count,
+			y.sat.plus(x.sat).or(x.dsat.plus(z.sat)), x.dsat.plus(z.dsat)}
+	case fragMulti:
+		return opsCount{1, some(numKeys), some(numKeys)}
+	case fragMultiA:
+		return opsCount{numKeys + 1, some(0), some(0)}
+	case fragWrapS, fragWrapC, fragWrapN:
+		return opsCount{1 + x.count, x.sat, x.dsat}
+	case fragWrapA:
+		return opsCount{2 + x.count, x.sat, x.dsat}
+	case fragWrapD:
+		return opsCount{3 + x.count, x.sat, some(0)}
+	case fragWrapJ:
+		return opsCount{4 + x.count, x.sat, some(0)}
+	case fragWrapV:
+		count := x.count
+		if subs[0].typ.has(mst("x")) {
+			count++
+		}
+		return opsCount{count, x.sat, none}
+	case fragThresh:
+		count := 0
+		sats := []maybeInt{some(0)}
+		for _, sub := range subs {
+			count += sub.ops.count + 1
+			next := []maybeInt{sats[0].plus(sub.ops.dsat)}
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].plus(sub.ops.dsat).or(sats[j-1].plus(sub.ops.sat)))
+			}
+			next = append(next, sats[len(sats)-1].plus(sub.ops.sat))
+			sats = next
+		}
+		return opsCount{count, sats[k], sats[0]}
+	}
+	return opsCount{}
+}
@@ -5,7 +5,14 @@
 
 package protocol
 
-import "io"
+import (
+	"errors"
+	"io"
+)
+
+// ErrVarBytesTooLong is returned when a length-prefixed byte array claims to
+// be longer than the maximum allowed for its field.
+var ErrVarBytesTooLong = errors.New("byte array exceeds maximum length")
 
 // emptyPrefix indicates the non-existance of a prefix when a CompactSize
 // may be represented by a single byte.
@@ -41,21 +48,74 @@ func writeCompactSize(w io.Writer, pver uint32, val uint64) error {
 // readCompactSize reads from r and decodes the CompactSize representation
 // into a uint64.
 func readCompactSize(r io.Reader, pver uint32, val *uint64) error {
-	prefix := make([]byte, 1)
-	_, err := r.Read(prefix)
+	prefix, err := binarySerializer.Uint8(r)
 	if err != nil {
 		return err
 	}
 
-	size := compactSizeSize(prefix[0], pver)
-	b := make([]byte, size)
-	_, err = r.Read(b)
+	switch prefix {
+	case 0xFD:
+		v, err := binarySerializer.Uint16(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		*val = uint64(v)
+	case 0xFE:
+		v, err := binarySerializer.Uint32(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		*val = uint64(v)
+	case 0xFF:
+		v, err := binarySerializer.Uint64(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		*val = v
+	default:
+		*val = uint64(prefix)
+	}
+	return nil
+}
+
+// WriteCompactSize encodes val as a CompactSize and writes the representation
+// to w. It is exported for packages which serialize protocol structures for
+// purposes other than messages, such as signature hashing.
+func WriteCompactSize(w io.Writer, pver uint32, val uint64) error {
+	return writeCompactSize(w, pver, val)
+}
+
+// writeVarBytes writes b to w prefixed by its length as a CompactSize.
+func writeVarBytes(w io.Writer, pver uint32, b []byte) error {
+	err := writeCompactSize(w, pver, uint64(len(b)))
 	if err != nil {
 		return err
 	}
 
-	*val = littleEndian.Uint64(b)
-	return nil
+	_, err = w.Write(b)
+	return err
+}
+
+// readVarBytes reads a CompactSize length prefix from r followed by that many
+// bytes. An error is returned if the length exceeds maxAllowed, which guards
+// against allocating memory for lengths claimed by malicious peers.
+func readVarBytes(r io.Reader, pver uint32, maxAllowed uint64) ([]byte,
+	error) {
+	var n uint64
+	err := readCompactSize(r, pver, &n)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxAllowed {
+		return nil, ErrVarBytesTooLong
+	}
+
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
 }
 
 // makeCompactSize returns the CompactSize representation (prefix and value)
@@ -1,6 +1,27 @@
 package protocol
 
-import "io"
+import (
+	"errors"
+	"io"
+)
+
+const (
+	// MaxScriptSize is the maximum size in bytes of a script read from a
+	// transaction.
+	MaxScriptSize = 10000
+
+	// MaxWitnessItemSize is the maximum size in bytes of a witness stack
+	// item read from a transaction.
+	MaxWitnessItemSize = 4000000
+
+	// MaxWitnessItems is the maximum number of items in a witness stack
+	// read from a transaction.
+	MaxWitnessItems = 500000
+)
+
+// ErrInvalidWitnessFlag is returned when the witness marker of a serialized
+// transaction is not followed by the witness flag.
+var ErrInvalidWitnessFlag = errors.New("invalid witness flag")
 
 // A MsgTx transmits a single bitcoin transaction.
 type MsgTx struct {
@@ -21,13 +42,54 @@ func NewMsgTx(version int32, inputs []*TxIn, outputs []*TxOut, lockTime uint32)
 	}
 }
 
-// Serialize serializes msg and writes to w.
+const (
+	// witnessMarker is the byte which replaces the input count in the
+	// serialization of a transaction with witness data. A transaction
+	// without witness data never has zero inputs, so the marker is
+	// unambiguous.
+	witnessMarker = 0x00
+
+	// witnessFlag is the byte which follows the witness marker.
+	witnessFlag = 0x01
+)
+
+// HasWitness returns whether any input of msg has witness data.
+func (msg *MsgTx) HasWitness() bool {
+	for _, input := range msg.Inputs {
+		if len(input.Witness) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Serialize serializes msg and writes to w. The witness data of the inputs is
+// included if any input has witness data, as specified by BIP144.
 func (msg *MsgTx) Serialize(w io.Writer, pver uint32) error {
+	return msg.serialize(w, pver, msg.HasWitness())
+}
+
+// SerializeNoWitness serializes msg without witness data and writes to w. The
+// transaction id is the hash of this serialization.
+func (msg *MsgTx) SerializeNoWitness(w io.Writer, pver uint32) error {
+	return msg.serialize(w, pver, false)
+}
+
+// serialize serializes msg, including the witness data of the inputs if
+// witness is set, and writes to w.
+func (msg *MsgTx) serialize(w io.Writer, pver uint32, witness bool) error {
 	err := writeElement(w, msg.Version)
 	if err != nil {
 		return err
 	}
 
+	if witness {
+		err = writeElements(w, uint8(witnessMarker), uint8(witnessFlag))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Transaction inputs.
 	err = writeCompactSize(w, pver, msg.TxInCount())
 	if err != nil {
@@ -52,10 +114,21 @@ func (msg *MsgTx) Serialize(w io.Writer, pver uint32) error {
 		}
 	}
 
+	// Witness data, one stack per input.
+	if witness {
+		for _, input := range msg.Inputs {
+			err = input.Witness.Serialize(w, pver)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return writeElement(w, msg.LockTime)
 }
 
-// Deserialize deserializes data from r into msg.
+// Deserialize deserializes data from r into msg. Both the legacy and the
+// BIP144 witness serializations are accepted.
 func (msg *MsgTx) Deserialize(r io.Reader, pver uint32) error {
 	err := readElement(r, &msg.Version)
 	if err != nil {
@@ -64,11 +137,28 @@ func (msg *MsgTx) Deserialize(r io.Reader, pver uint32) error {
 
 	var n uint64
 
-	// Transaction inputs.
+	// Transaction inputs. An input count of zero is the witness marker,
+	// which is followed by the witness flag and the real input count.
 	err = readCompactSize(r, pver, &n)
 	if err != nil {
 		return err
 	}
+	witness := false
+	if n == witnessMarker {
+		flag, err := binarySerializer.Uint8(r)
+		if err != nil {
+			return err
+		}
+		if flag != witnessFlag {
+			return ErrInvalidWitnessFlag
+		}
+		witness = true
+
+		err = readCompactSize(r, pver, &n)
+		if err != nil {
+			return err
+		}
+	}
 	for i := 0; i < int(n); i++ {
 		input := &TxIn{}
 		err = input.Deserialize(r, pver)
@@ -92,6 +182,16 @@ func (msg *MsgTx) Deserialize(r io.Reader, pver uint32) error {
 		msg.Outputs = append(msg.Outputs, output)
 	}
 
+	// Witness data, one stack per input.
+	if witness {
+		for _, input := range msg.Inputs {
+			err = input.Witness.Deserialize(r, pver)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return readElement(r, &msg.LockTime)
 }
 
@@ -116,12 +216,14 @@ func (msg *MsgTx) MaxPayloadSize(pver uint32) uint32 {
 	return MaxMsgSize
 }
 
-// A TxIn is an input to a transaction.
+// A TxIn is an input to a transaction. Witness holds the segregated witness
+// data of the input, if any.
 type TxIn struct {
 	PrevOutput       TxOutPoint
 	ScriptUnlockSize uint64
 	ScriptUnlock     []byte
 	Sequence         uint32
+	Witness          TxWitness
 }
 
 // Serialize serializes in and writes to w.
@@ -146,12 +248,13 @@ func (in *TxIn) Deserialize(r io.Reader, pver uint32) error {
 		return err
 	}
 
-	err = readCompactSize(r, pver, &in.ScriptUnlockSize)
+	in.ScriptUnlock, err = readVarBytes(r, pver, MaxScriptSize)
 	if err != nil {
 		return err
 	}
+	in.ScriptUnlockSize = uint64(len(in.ScriptUnlock))
 
-	return readElements(r, in.ScriptUnlock, &in.Sequence)
+	return readElement(r, &in.Sequence)
 }
 
 // A TxOut is an output of a transaction.
@@ -183,12 +286,55 @@ func (out *TxOut) Deserialize(r io.Reader, pver uint32) error {
 		return err
 	}
 
-	err = readCompactSize(r, pver, &out.ScriptLockSize)
+	out.ScriptLock, err = readVarBytes(r, pver, MaxScriptSize)
 	if err != nil {
 		return err
 	}
+	out.ScriptLockSize = uint64(len(out.ScriptLock))
+	return nil
+}
+
+// A TxWitness is the witness stack of a transaction input.
+type TxWitness [][]byte
+
+// Serialize serializes witness and writes to w.
+func (witness TxWitness) Serialize(w io.Writer, pver uint32) error {
+	err := writeCompactSize(w, pver, uint64(len(witness)))
+	if err != nil {
+		return err
+	}
+
+	for _, item := range witness {
+		err = writeVarBytes(w, pver, item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deserialize deserializes data from r into witness.
+func (witness *TxWitness) Deserialize(r io.Reader, pver uint32) error {
+	var n uint64
+	err := readCompactSize(r, pver, &n)
+	if err != nil {
+		return err
+	}
+	if n > MaxWitnessItems {
+		return ErrVarBytesTooLong
+	}
+
+	items := make(TxWitness, 0, n)
+	for i := uint64(0); i < n; i++ {
+		item, err := readVarBytes(r, pver, MaxWitnessItemSize)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
 
-	return readElement(r, &out.ScriptLock)
+	*witness = items
+	return nil
 }
 
 // A TxOutPoint contains information to refer to a specific transaction output.
@@ -207,5 +353,6 @@ func (outPoint *TxOutPoint) Serialize(w io.Writer, pver uint32) error {
 
 // Deserialize deserializes data from r into outPoint.
 func (outPoint *TxOutPoint) Deserialize(r io.Reader, pver uint32) error {
-	return readElements(r, &outPoint.Hash, &outPoint.Index)
+	outPoint.Hash = new([HashSize]byte)
+	return readElements(r, outPoint.Hash, &outPoint.Index)
 }
@@ -0,0 +1,122 @@
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxAsmNumber is the largest magnitude of a number token accepted by
+// Assemble.
+const maxAsmNumber = 0xffffffff
+
+// asmOpCodes maps the names of opcodes accepted by Assemble to their opcodes.
+// Each name is accepted with and without its OP_ prefix.
+var asmOpCodes = func() map[string]opCode {
+	m := make(map[string]opCode, 2*len(opCodeNames))
+	for op, name := range opCodeNames {
+		m[name] = op
+		m[strings.TrimPrefix(name, "OP_")] = op
+	}
+	m["OP_FALSE"] = Op0
+	m["OP_TRUE"] = Op1
+	return m
+}()
+
+// Disassemble returns the ASM form of script as printed by Bitcoin Core:
+// space-separated tokens where pushes of up to four bytes are shown as
+// decimal numbers, longer pushes as hex, and other opcodes by name, with
+// Op1Negate and Op1 through Op16 shown as numbers. A malformed script ends
+// with the token [error].
+func Disassemble(script []byte) string {
+	var tokens []string
+	t := NewTokenizer(script)
+	for t.Next() {
+		op := opCode(t.Opcode())
+		switch {
+		case op.isDataPush() && len(t.Data()) <= maxNumSize:
+			n, _ := makeScriptNum(t.Data(), false, maxNumSize)
+			tokens = append(tokens, strconv.FormatInt(int64(n), 10))
+		case op.isDataPush():
+			tokens = append(tokens, hex.EncodeToString(t.Data()))
+		case op == Op1Negate:
+			tokens = append(tokens, "-1")
+		case op >= Op1 && op <= Op16:
+			tokens = append(tokens, strconv.Itoa(int(op-Op1)+1))
+		default:
+			tokens = append(tokens, op.String())
+		}
+	}
+	if t.Err() != nil {
+		tokens = append(tokens, "[error]")
+	}
+	return strings.Join(tokens, " ")
+}
+
+// Assemble returns the script written in ASM form by asm. It accepts the
+// output of Disassemble as well as the notation of Bitcoin Core's script
+// tests. Tokens are separated by whitespace and may be:
+//
+//   - an opcode name, with or without its OP_ prefix;
+//   - a decimal number of magnitude at most 0xffffffff, pushed as a script
+//     number using the smallest push;
+//   - an even number of hex digits, pushed as data;
+//   - 0x followed by hex digits, inserted into the script as raw bytes;
+//   - a string in single quotes, pushed as data.
+//
+// Hex data consisting only of decimal digits and short enough to be read as a
+// number must be written as a quoted push or with raw 0x bytes.
+func Assemble(asm string) ([]byte, error) {
+	b := NewScriptBuilder()
+	for _, tok := range strings.Fields(asm) {
+		if op, ok := asmOpCodes[tok]; ok {
+			b.AddOp(byte(op))
+			continue
+		}
+
+		if n, ok := parseAsmNumber(tok); ok {
+			b.AddInt64(n)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(tok, "0x"):
+			raw, err := hex.DecodeString(tok[2:])
+			if err != nil || len(raw) == 0 {
+				return nil, invalidAsmToken(tok)
+			}
+			b.AddOps(raw...)
+
+		case len(tok) >= 2 && tok[0] == '\'' && tok[len(tok)-1] == '\'':
+			b.AddData([]byte(tok[1 : len(tok)-1]))
+
+		default:
+			data, err := hex.DecodeString(tok)
+			if err != nil {
+				return nil, invalidAsmToken(tok)
+			}
+			b.AddData(data)
+		}
+	}
+	return b.Script()
+}
+
+// parseAsmNumber returns the number written by the decimal token tok and
+// whether tok is one.
+func parseAsmNumber(tok string) (int64, bool) {
+	digits := strings.TrimPrefix(tok, "-")
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(tok, 10, 64)
+	if err != nil || n > maxAsmNumber || n < -maxAsmNumber {
+		return 0, false
+	}
+	return n, true
+}
+
+// invalidAsmToken returns the error for an unrecognized ASM token.
+func invalidAsmToken(tok string) error {
+	return scriptError(ErrCodeInvalidAsm, fmt.Sprintf("invalid ASM token %q", tok))
+}
@@ -0,0 +1,135 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		asm    string
+	}{
+		{"empty", "", ""},
+		{"p2pkh", "76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac",
+			"OP_DUP OP_HASH160 62e907b15cbf27d5425399ebf6f0fb50ebb88f18 OP_EQUALVERIFY OP_CHECKSIG"},
+		{"small numbers", "004f5160", "0 -1 1 16"},
+		{"short pushes as numbers", "010102800002808002ff7f",
+			"1 128 -128 32767"},
+		{"non-minimal push", "4c0105", "5"},
+		{"five byte push as hex", "050102030405", "0102030405"},
+		{"unknown opcode", "ba", "OP_CHECKSIGADD"},
+		{"undefined opcode", "bb", "OP_UNKNOWN"},
+		{"malformed push", "5102ab", "1 [error]"},
+		{"malformed pushdata1", "4c", "[error]"},
+	}
+	for _, test := range tests {
+		script, err := hex.DecodeString(test.script)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := Disassemble(script); got != test.asm {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.asm)
+		}
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		name   string
+		asm    string
+		script string
+	}{
+		{"empty", "", ""},
+		{"names", "OP_DUP HASH160 OP_TRUE OP_FALSE", "76a95100"},
+		{"numbers", "0 -1 16 17 -2 1000", "004f6001110182 02e803"},
+		{"hex push", "62e907b15cbf27d5425399ebf6f0fb50ebb88f18",
+			"1462e907b15cbf27d5425399ebf6f0fb50ebb88f18"},
+		{"raw bytes", "0x4c01 0x07", "4c0107"},
+		{"quoted string", "'Az' ''", "02417a00"},
+		{"largest number", "4294967295", "05ffffffff00"},
+		{"whitespace", "  1\t2\n", "5152"},
+	}
+	for _, test := range tests {
+		got, err := Assemble(test.asm)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		want, _ := hex.DecodeString(removeSpaces(test.script))
+		if hex.EncodeToString(got) != hex.EncodeToString(want) {
+			t.Errorf("%s: got %x, want %x", test.name, got, want)
+		}
+	}
+
+	invalid := []string{"OP_FOO", "0x", "0xabc", "abc", "'unterminated"}
+	for _, asm := range invalid {
+		if _, err := Assemble(asm); !IsErrorCode(err, ErrCodeInvalidAsm) {
+			t.Errorf("%q: got %v, want ErrCodeInvalidAsm", asm, err)
+		}
+	}
+}
+
+// removeSpaces returns s without spaces, which separate the bytes of
+// expected scripts for legibility.
+func removeSpaces(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != ' ' {
+			b = append(b, s[i])
+		}
+	}
+	return string(b)
+}
+
+// TestAsmRoundTrip checks that assembling the disassembly of a script gives
+// back the script, for scripts whose pushes are minimal. Hex data made up of
+// decimal digits alone reads back as a number, so the data avoids it.
+func TestAsmRoundTrip(t *testing.T) {
+	key := testKey(1).PubKey().SerializeCompressed()
+	hash := bytes.Repeat([]byte{0xab}, 20)
+	var scripts [][]byte
+	for _, build := range []func() ([]byte, error){
+		func() ([]byte, error) { return PayToPubKeyHashScript(hash) },
+		func() ([]byte, error) { return PayToScriptHashScript(hash) },
+		func() ([]byte, error) { return PayToWitnessPubKeyHashScript(hash) },
+		func() ([]byte, error) { return MultiSigScript([][]byte{key, key}, 2) },
+		func() ([]byte, error) { return NullDataScript([]byte("gocoin")) },
+		func() ([]byte, error) {
+			return NewScriptBuilder().AddInt64(-1000).AddInt64(0).
+				AddInt64(127).AddInt64(1<<32 - 1).AddOp(byte(OpAdd)).
+				AddData(bytes.Repeat([]byte{0xcd}, 300)).Script()
+		},
+	} {
+		script, err := build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		scripts = append(scripts, script)
+	}
+
+	// Every named opcode which is not a push, on its own. Undefined
+	// opcodes are all shown as OP_UNKNOWN, which cannot be assembled.
+	for op := Op1Negate; op <= 0xff; op++ {
+		if !op.isDataPush() && op.String() != "OP_UNKNOWN" {
+			scripts = append(scripts, []byte{byte(op)})
+		}
+		if op == 0xff {
+			break
+		}
+	}
+
+	for _, script := range scripts {
+		asm := Disassemble(script)
+		got, err := Assemble(asm)
+		if err != nil {
+			t.Errorf("%q: %v", asm, err)
+			continue
+		}
+		if hex.EncodeToString(got) != hex.EncodeToString(script) {
+			t.Errorf("%q: assembled to %x, want %x", asm, got, script)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package script
+
+import "github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+
+// MaxDataCarrierSize is the maximum size of the data pushed by a standard null
+// data script.
+const MaxDataCarrierSize = 80
+
+var (
+	// ErrInvalidHashSize is returned when building a script template with a
+	// hash or program of the wrong size.
+	ErrInvalidHashSize = scriptError(ErrCodeInvalidHashSize, "hash has wrong size for script template")
+
+	// ErrTooMuchNullData is returned when building a null data script with
+	// more than MaxDataCarrierSize bytes of data.
+	ErrTooMuchNullData = scriptError(ErrCodeTooMuchNullData, "null data exceeds maximum size")
+)
+
+// A ScriptBuilder builds a script one operation at a time. Data and numbers
+// are pushed with the smallest push operation, so the result satisfies the
+// minimal data rules. The first error encountered is recorded, turns later
+// additions into no-ops and is returned by Script.
+type ScriptBuilder struct {
+	script []byte
+	err    error
+}
+
+// NewScriptBuilder returns a builder of an empty script.
+func NewScriptBuilder() *ScriptBuilder {
+	return &ScriptBuilder{}
+}
+
+// AddOp appends the opcode op to the script.
+func (b *ScriptBuilder) AddOp(op byte) *ScriptBuilder {
+	if b.err != nil {
+		return b
+	}
+	b.script = append(b.script, op)
+	return b
+}
+
+// AddOps appends the opcodes ops to the script.
+func (b *ScriptBuilder) AddOps(ops ...byte) *ScriptBuilder {
+	if b.err != nil {
+		return b
+	}
+	b.script = append(b.script, ops...)
+	return b
+}
+
+// AddInt64 appends a push of the script number n to the script, using an
+// opcode for the numbers -1 and 0 through 16.
+func (b *ScriptBuilder) AddInt64(n int64) *ScriptBuilder {
+	return b.AddData(scriptNum(n).Bytes())
+}
+
+// AddData appends a push of data to the script. An error is recorded if data
+// is larger than MaxScriptElementSize.
+func (b *ScriptBuilder) AddData(data []byte) *ScriptBuilder {
+	if b.err != nil {
+		return b
+	}
+	if len(data) > MaxScriptElementSize {
+		b.err = ErrElementTooBig
+		return b
+	}
+
+	op := canonicalPushOp(data)
+	if op == Op0 || op.isSmallInt() {
+		b.script = append(b.script, byte(op))
+		return b
+	}
+	instr := instruction{op: op, data: data}
+	b.script = append(b.script, instr.bytes()...)
+	return b
+}
+
+// Reset empties the script and clears any recorded error.
+func (b *ScriptBuilder) Reset() *ScriptBuilder {
+	b.script = b.script[:0]
+	b.err = nil
+	return b
+}
+
+// Script returns the built script. An error is returned if an addition
+// failed or the script is larger than MaxScriptSize.
+func (b *ScriptBuilder) Script() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.script) > MaxScriptSize {
+		return nil, ErrScriptTooBig
+	}
+	return append([]byte(nil), b.script...), nil
+}
+
+// PayToPubKeyHashScript returns the pay-to-pubkey-hash locking script for the
+// 20-byte pubKeyHash.
+func PayToPubKeyHashScript(pubKeyHash []byte) ([]byte, error) {
+	if len(pubKeyHash) != 20 {
+		return nil, ErrInvalidHashSize
+	}
+	return NewScriptBuilder().AddOps(byte(OpDup), byte(OpHash160)).
+		AddData(pubKeyHash).AddOps(byte(OpEqualVerify), byte(OpCheckSig)).
+		Script()
+}
+
+// PayToScriptHashScript returns the pay-to-script-hash locking script for the
+// 20-byte scriptHash.
+func PayToScriptHashScript(scriptHash []byte) ([]byte, error) {
+	if len(scriptHash) != 20 {
+		return nil, ErrInvalidHashSize
+	}
+	return NewScriptBuilder().AddOp(byte(OpHash160)).AddData(scriptHash).
+		AddOp(byte(OpEqual)).Script()
+}
+
+// PayToScriptHashScriptFor returns the pay-to-script-hash locking script
+// committing to redeemScript.
+func PayToScriptHashScriptFor(redeemScript []byte) ([]byte, error) {
+	return PayToScriptHashScript(hashing.Hash160(redeemScript))
+}
+
+// PayToWitnessPubKeyHashScript returns the version 0 witness program for the
+// 20-byte pubKeyHash.
+func PayToWitnessPubKeyHashScript(pubKeyHash []byte) ([]byte, error) {
+	if len(pubKeyHash) != payToWitnessPubKeyHashSize {
+		return nil, ErrInvalidHashSize
+	}
+	return NewScriptBuilder().AddOp(byte(Op0)).AddData(pubKeyHash).Script()
+}
+
+// PayToWitnessScriptHashScript returns the version 0 witness program for the
+// 32-byte SHA-256 scriptHash of a witness script.
+func PayToWitnessScriptHashScript(scriptHash []byte) ([]byte, error) {
+	if len(scriptHash) != payToWitnessScriptHashSize {
+		return nil, ErrInvalidHashSize
+	}
+	return NewScriptBuilder().AddOp(byte(Op0)).AddData(scriptHash).Script()
+}
+
+// PayToTaprootScript returns the version 1 witness program for the 32-byte
+// x-only taproot outputKey, as computed by ComputeTaprootOutputKey.
+func PayToTaprootScript(outputKey []byte) ([]byte, error) {
+	if len(outputKey) != payToTaprootSize {
+		return nil, ErrInvalidHashSize
+	}
+	return NewScriptBuilder().AddOp(byte(Op1)).AddData(outputKey).Script()
+}
+
+// MultiSigScript returns the bare multisig script requiring required
+// signatures from pubKeys. An error is returned if the number of keys or
+// required signatures is out of range or a key is not a serialized public key.
+func MultiSigScript(pubKeys [][]byte, required int) ([]byte, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > MaxPubKeysPerMultiSig {
+		return nil, ErrInvalidPubKeyCount
+	}
+	if required < 1 || required > len(pubKeys) {
+		return nil, ErrInvalidSignatureCount
+	}
+
+	b := NewScriptBuilder().AddInt64(int64(required))
+	for _, pubKey := range pubKeys {
+		if !isPubKeySize(pubKey) {
+			return nil, ErrPubKeyType
+		}
+		b.AddData(pubKey)
+	}
+	return b.AddInt64(int64(len(pubKeys))).AddOp(byte(OpCheckMultiSig)).Script()
+}
+
+// NullDataScript returns a provably unspendable script carrying data. An
+// error is returned if data is larger than MaxDataCarrierSize.
+func NullDataScript(data []byte) ([]byte, error) {
+	if len(data) > MaxDataCarrierSize {
+		return nil, ErrTooMuchNullData
+	}
+	return NewScriptBuilder().AddOp(byte(OpReturn)).AddData(data).Script()
+}
@@ -0,0 +1,214 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestScriptBuilderAddInt64(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "00"},
+		{-1, "4f"},
+		{1, "51"},
+		{16, "60"},
+		{17, "0111"},
+		{-2, "0182"},
+		{127, "017f"},
+		{128, "028000"},
+		{-128, "028080"},
+		{255, "02ff00"},
+		{256, "020001"},
+		{32767, "02ff7f"},
+		{32768, "03008000"},
+		{2147483647, "04ffffff7f"},
+		{-2147483647, "04ffffffff"},
+		{549755813887, "05ffffffff7f"},
+	}
+	for _, test := range tests {
+		script, err := NewScriptBuilder().AddInt64(test.n).Script()
+		if err != nil {
+			t.Fatalf("%d: %v", test.n, err)
+		}
+		if got := hex.EncodeToString(script); got != test.want {
+			t.Errorf("%d: got %s, want %s", test.n, got, test.want)
+		}
+	}
+}
+
+func TestScriptBuilderAddData(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"empty", nil, []byte{byte(Op0)}},
+		{"small int", []byte{5}, []byte{byte(Op5)}},
+		{"16", []byte{16}, []byte{byte(Op16)}},
+		{"17", []byte{17}, []byte{1, 17}},
+		{"negative one", []byte{0x81}, []byte{byte(Op1Negate)}},
+		{"zero byte", []byte{0}, []byte{1, 0}},
+	}
+	for _, test := range tests {
+		script, err := NewScriptBuilder().AddData(test.data).Script()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !bytes.Equal(script, test.want) {
+			t.Errorf("%s: got %x, want %x", test.name, script, test.want)
+		}
+	}
+
+	// Longer pushes use the smallest push operation for their size.
+	sizes := []struct {
+		size   int
+		prefix []byte
+	}{
+		{2, []byte{2}},
+		{75, []byte{75}},
+		{76, []byte{byte(OpPushData1), 76}},
+		{255, []byte{byte(OpPushData1), 255}},
+		{256, []byte{byte(OpPushData2), 0, 1}},
+		{MaxScriptElementSize, []byte{byte(OpPushData2), 0x08, 0x02}},
+	}
+	for _, test := range sizes {
+		data := bytes.Repeat([]byte{0xab}, test.size)
+		script, err := NewScriptBuilder().AddData(data).Script()
+		if err != nil {
+			t.Fatalf("size %d: %v", test.size, err)
+		}
+		if !bytes.Equal(script, append(test.prefix, data...)) {
+			t.Errorf("size %d: got prefix %x, want %x", test.size,
+				script[:len(test.prefix)], test.prefix)
+		}
+	}
+}
+
+// TestScriptBuilderMinimalData checks that the pushes of the builder satisfy
+// ScriptVerifyMinimalData and leave what was pushed on the stack.
+func TestScriptBuilderMinimalData(t *testing.T) {
+	var items [][]byte
+	for _, n := range []int64{-1, 0, 1, 16, 17, -17, 1000, -1000} {
+		items = append(items, scriptNum(n).Bytes())
+	}
+	for _, size := range []int{1, 75, 76, 255, 256, MaxScriptElementSize} {
+		items = append(items, bytes.Repeat([]byte{0xcd}, size))
+	}
+
+	b := NewScriptBuilder()
+	for _, item := range items {
+		b.AddData(item)
+	}
+	script, err := b.Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := NewEngine(script, nil, nil, testTx(), 0, 0,
+		ScriptVerifyMinimalData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		done, err := vm.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done {
+			break
+		}
+	}
+	stack := vm.Stack()
+	if stack.Depth() != len(items) {
+		t.Fatalf("stack depth %d, want %d", stack.Depth(), len(items))
+	}
+	for i, item := range items {
+		got, err := stack.Peek(len(items) - 1 - i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, item) {
+			t.Errorf("item %d is %x, want %x", i, got, item)
+		}
+	}
+}
+
+func TestScriptBuilderErrors(t *testing.T) {
+	tooBig := make([]byte, MaxScriptElementSize+1)
+	_, err := NewScriptBuilder().AddData(tooBig).AddOp(byte(Op1)).Script()
+	if err != ErrElementTooBig {
+		t.Fatalf("oversized push: got %v, want %v", err, ErrElementTooBig)
+	}
+
+	// Errors are kept until the builder is reset.
+	b := NewScriptBuilder().AddData(tooBig)
+	if _, err := b.AddOp(byte(Op1)).Script(); err == nil {
+		t.Fatal("error lost")
+	}
+	script, err := b.Reset().AddOp(byte(Op1)).Script()
+	if err != nil || !bytes.Equal(script, []byte{byte(Op1)}) {
+		t.Fatalf("after reset: %x %v", script, err)
+	}
+
+	b = NewScriptBuilder()
+	for i := 0; i <= MaxScriptSize/MaxScriptElementSize; i++ {
+		b.AddData(make([]byte, MaxScriptElementSize))
+	}
+	if _, err := b.Script(); err != ErrScriptTooBig {
+		t.Fatalf("oversized script: got %v, want %v", err, ErrScriptTooBig)
+	}
+}
+
+func TestScriptTemplates(t *testing.T) {
+	hash20 := bytes.Repeat([]byte{0x11}, 20)
+	hash32 := bytes.Repeat([]byte{0x22}, 32)
+	pubKey := testKey(1).PubKey().SerializeCompressed()
+
+	build := func(script []byte, err error) []byte {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return script
+	}
+	tests := []struct {
+		name   string
+		script []byte
+		want   string
+		class  ScriptClass
+	}{
+		{"p2pkh", build(PayToPubKeyHashScript(hash20)),
+			"76a914" + hex.EncodeToString(hash20) + "88ac", PubKeyHashTy},
+		{"p2sh", build(PayToScriptHashScript(hash20)),
+			"a914" + hex.EncodeToString(hash20) + "87", ScriptHashTy},
+		{"p2wpkh", build(PayToWitnessPubKeyHashScript(hash20)),
+			"0014" + hex.EncodeToString(hash20), WitnessV0PubKeyHashTy},
+		{"p2wsh", build(PayToWitnessScriptHashScript(hash32)),
+			"0020" + hex.EncodeToString(hash32), WitnessV0ScriptHashTy},
+		{"p2tr", build(PayToTaprootScript(hash32)),
+			"5120" + hex.EncodeToString(hash32), WitnessV1TaprootTy},
+		{"multisig", build(MultiSigScript([][]byte{pubKey}, 1)),
+			"5121" + hex.EncodeToString(pubKey) + "51ae", MultiSigTy},
+		{"null data", build(NullDataScript([]byte("hi"))), "6a026869",
+			NullDataTy},
+	}
+	for _, test := range tests {
+		if got := hex.EncodeToString(test.script); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+		if class := GetScriptClass(test.script); class != test.class {
+			t.Errorf("%s: class %v, want %v", test.name, class, test.class)
+		}
+	}
+
+	if _, err := PayToPubKeyHashScript(hash32); err != ErrInvalidHashSize {
+		t.Errorf("p2pkh with 32-byte hash: %v", err)
+	}
+	if _, err := MultiSigScript([][]byte{pubKey}, 2); err != ErrInvalidSignatureCount {
+		t.Errorf("1-of-2 multisig: %v", err)
+	}
+	if _, err := NullDataScript(make([]byte, MaxDataCarrierSize+1)); err != ErrTooMuchNullData {
+		t.Errorf("oversized null data: %v", err)
+	}
+}
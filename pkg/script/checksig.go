@@ -0,0 +1,334 @@
+package script
+
+import "github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+
+// MaxPubKeysPerMultiSig is the maximum number of public keys allowed in a
+// single OpCheckMultiSig.
+const MaxPubKeysPerMultiSig = 20
+
+var (
+	// ErrInvalidPubKeyCount is the result of an OpCheckMultiSig with a
+	// negative number of public keys or more than MaxPubKeysPerMultiSig.
+	ErrInvalidPubKeyCount = scriptError(ErrCodeInvalidPubKeyCount, "invalid public key count")
+
+	// ErrInvalidSignatureCount is the result of an OpCheckMultiSig with a
+	// negative number of signatures or more signatures than public keys.
+	ErrInvalidSignatureCount = scriptError(ErrCodeInvalidSignatureCount, "invalid signature count")
+
+	// ErrSigHashType is the result of a signature with an undefined hash
+	// type with ScriptVerifyStrictEncoding.
+	ErrSigHashType = scriptError(ErrCodeSigHashType, "undefined signature hash type")
+
+	// ErrSigDER is the result of a signature which is not strictly DER
+	// encoded with ScriptVerifyDERSignatures, ScriptVerifyLowS or
+	// ScriptVerifyStrictEncoding.
+	ErrSigDER = scriptError(ErrCodeSigDER, "signature is not strictly DER encoded")
+
+	// ErrSigHighS is the result of a signature whose S value is greater
+	// than half the curve order with ScriptVerifyLowS.
+	ErrSigHighS = scriptError(ErrCodeSigHighS, "signature S value is too high")
+
+	// ErrSigNullDummy is the result of an OpCheckMultiSig whose extra item
+	// is not empty with ScriptVerifyNullDummy.
+	ErrSigNullDummy = scriptError(ErrCodeSigNullDummy, "OP_CHECKMULTISIG dummy is not empty")
+
+	// ErrPubKeyType is the result of a public key which is neither
+	// compressed nor uncompressed with ScriptVerifyStrictEncoding.
+	ErrPubKeyType = scriptError(ErrCodePubKeyType, "unsupported public key type")
+
+	// ErrNoTransaction is the result of executing a signature check without
+	// a spending transaction.
+	ErrNoTransaction = scriptError(ErrCodeNoTransaction, "signature check requires a spending transaction")
+)
+
+// subScript returns the part of the executing script which follows the last
+// executed OpCodeSeparator. Signatures commit to this part of the script.
+func (vm *Engine) subScript() []byte {
+	script := vm.scripts[vm.scriptIdx]
+
+	var b []byte
+	for i := vm.lastCodeSep; i < len(script.instructions); i++ {
+		b = append(b, script.instructions[i].bytes()...)
+	}
+	return b
+}
+
+// checkSig returns whether sig, which is suffixed with its hash type, is a
+// valid signature by pubKey of the spending transaction, where script is the
+// script the signature commits to. Signatures and public keys which violate
+// the encoding rules selected by the engine flags fail the script, while
+// other encoding errors make the signature invalid.
+func (vm *Engine) checkSig(sig, pubKey, script []byte) (bool, error) {
+	err := vm.checkSignatureEncoding(sig)
+	if err != nil {
+		return false, err
+	}
+	err = vm.checkPubKeyEncoding(pubKey)
+	if err != nil {
+		return false, err
+	}
+
+	if len(sig) == 0 {
+		return false, nil
+	}
+	hashType := SigHashType(sig[len(sig)-1])
+	sig = sig[:len(sig)-1]
+
+	var hash []byte
+	if vm.witnessExec {
+		if vm.sigHashes == nil {
+			vm.sigHashes, err = NewTxSigHashes(vm.tx, nil)
+			if err != nil {
+				return false, err
+			}
+		}
+		hash, err = CalcWitnessSignatureHash(script, vm.sigHashes, hashType,
+			vm.tx, vm.txIdx, vm.amount)
+	} else {
+		hash, err = CalcSignatureHash(script, hashType, vm.tx, vm.txIdx)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	pk, err := btcec.ParsePubKey(pubKey)
+	if err != nil {
+		return false, nil
+	}
+	s, err := btcec.ParseSignature(sig)
+	if err != nil {
+		return false, nil
+	}
+	return s.Verify(hash, pk), nil
+}
+
+// opCheckSig executes OpCheckSig and OpCheckSigVerify.
+func opCheckSig(op opCode, vm *Engine) error {
+	if vm.tx == nil {
+		return ErrNoTransaction
+	}
+
+	s := &vm.dstack
+	pubKey, err := s.Pop()
+	if err != nil {
+		return err
+	}
+	sig, err := s.Pop()
+	if err != nil {
+		return err
+	}
+
+	if vm.isTapscript() {
+		ok, err := vm.checkTapscriptSig(sig, pubKey)
+		if err != nil {
+			return err
+		}
+		s.PushBool(ok)
+		if op == OpCheckSigVerify {
+			return verify(op, s)
+		}
+		return nil
+	}
+
+	// A signature cannot sign itself, so legacy signatures are removed from
+	// the script they commit to. Witness signatures do not commit to the
+	// witness, so the script is left as is.
+	script := vm.subScript()
+	if !vm.witnessExec {
+		script = findAndDelete(script, sig)
+	}
+
+	ok, err := vm.checkSig(sig, pubKey, script)
+	if err != nil {
+		return err
+	}
+	s.PushBool(ok)
+	if op == OpCheckSigVerify {
+		return verify(op, s)
+	}
+	return nil
+}
+
+// opCheckMultiSig executes OpCheckMultiSig and OpCheckMultiSigVerify. The
+// stack holds, from the top, the number of public keys n, n public keys, the
+// number of signatures m, m signatures, and one extra unused item which the
+// original implementation pops by mistake.
+func opCheckMultiSig(op opCode, vm *Engine) error {
+	if vm.tx == nil {
+		return ErrNoTransaction
+	}
+	if vm.isTapscript() {
+		return ErrTapscriptCheckMultiSig
+	}
+
+	s := &vm.dstack
+	numPubKeys, err := s.PopInt()
+	if err != nil {
+		return err
+	}
+	if numPubKeys < 0 || numPubKeys > MaxPubKeysPerMultiSig {
+		return ErrInvalidPubKeyCount
+	}
+	err = vm.countOps(int(numPubKeys))
+	if err != nil {
+		return err
+	}
+	pubKeys := make([][]byte, numPubKeys)
+	for i := range pubKeys {
+		pubKeys[i], err = s.Pop()
+		if err != nil {
+			return err
+		}
+	}
+
+	numSigs, err := s.PopInt()
+	if err != nil {
+		return err
+	}
+	if numSigs < 0 || numSigs > numPubKeys {
+		return ErrInvalidSignatureCount
+	}
+	sigs := make([][]byte, numSigs)
+	for i := range sigs {
+		sigs[i], err = s.Pop()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Pop the extra item required by the original implementation, which
+	// must be empty with ScriptVerifyNullDummy so that it cannot be
+	// malleated.
+	dummy, err := s.Pop()
+	if err != nil {
+		return err
+	}
+	if vm.flags.HasFlag(ScriptVerifyNullDummy) && len(dummy) != 0 {
+		return ErrSigNullDummy
+	}
+
+	script := vm.subScript()
+	if !vm.witnessExec {
+		for _, sig := range sigs {
+			script = findAndDelete(script, sig)
+		}
+	}
+
+	// Signatures must appear in the same order as their public keys, so each
+	// public key is tried at most once. Both lists were popped from the
+	// stack, so they are matched from the end of the script, as in the
+	// original implementation.
+	success := true
+	for sigIdx, keyIdx := 0, 0; sigIdx < len(sigs); {
+		// Fail early once the remaining keys cannot cover the remaining
+		// signatures.
+		if len(sigs)-sigIdx > len(pubKeys)-keyIdx {
+			success = false
+			break
+		}
+
+		ok, err := vm.checkSig(sigs[sigIdx], pubKeys[keyIdx], script)
+		if err != nil {
+			return err
+		}
+		if ok {
+			sigIdx++
+		}
+		keyIdx++
+	}
+
+	s.PushBool(success)
+	if op == OpCheckMultiSigVerify {
+		return verify(op, s)
+	}
+	return nil
+}
+
+// opCheckSigAdd executes OpCheckSigAdd, which is only valid in tapscript. The
+// stack holds, from the top, a public key, a number n and a signature, which
+// are replaced by n+1 if the signature is valid and by n if it is empty.
+func opCheckSigAdd(vm *Engine) error {
+	if !vm.isTapscript() {
+		return ErrInvalidOpcode
+	}
+	if vm.tx == nil {
+		return ErrNoTransaction
+	}
+
+	s := &vm.dstack
+	pubKey, err := s.Pop()
+	if err != nil {
+		return err
+	}
+	n, err := s.PopInt()
+	if err != nil {
+		return err
+	}
+	sig, err := s.Pop()
+	if err != nil {
+		return err
+	}
+
+	ok, err := vm.checkTapscriptSig(sig, pubKey)
+	if err != nil {
+		return err
+	}
+	if ok {
+		n++
+	}
+	s.PushInt(n)
+	return nil
+}
+
+// checkSignatureEncoding returns an error if sig, which is suffixed with its
+// hash type, violates the signature encoding rules selected by the engine
+// flags. An empty signature is always allowed, as it is how a signature
+// check is made to fail deliberately.
+func (vm *Engine) checkSignatureEncoding(sig []byte) error {
+	if len(sig) == 0 {
+		return nil
+	}
+
+	strictDER := ScriptVerifyDERSignatures | ScriptVerifyLowS |
+		ScriptVerifyStrictEncoding
+	if vm.flags&strictDER != 0 {
+		s, err := btcec.ParseDERSignature(sig[:len(sig)-1])
+		if err != nil {
+			return ErrSigDER
+		}
+
+		// As in the original implementation, a signature with R or S
+		// out of range is left to fail verification rather than being
+		// reported as high.
+		n := btcec.S256().N
+		if vm.flags.HasFlag(ScriptVerifyLowS) && !s.IsLowS() &&
+			s.R.Cmp(n) < 0 && s.S.Cmp(n) < 0 {
+			return ErrSigHighS
+		}
+	}
+	if vm.flags.HasFlag(ScriptVerifyStrictEncoding) {
+		hashType := SigHashType(sig[len(sig)-1]) &^ SigHashAnyOneCanPay
+		if hashType < SigHashAll || hashType > SigHashSingle {
+			return ErrSigHashType
+		}
+	}
+	return nil
+}
+
+// checkPubKeyEncoding returns an error if pubKey violates the public key
+// encoding rules selected by the engine flags. Unlike the original
+// implementation, which only checks the length and prefix, strict encoding
+// also requires the key to be on the curve; this only affects policy, as
+// such a key can never verify a signature.
+func (vm *Engine) checkPubKeyEncoding(pubKey []byte) error {
+	if vm.flags.HasFlag(ScriptVerifyStrictEncoding) {
+		if _, err := btcec.ParsePubKeyStrict(pubKey); err != nil {
+			return ErrPubKeyType
+		}
+	}
+	if vm.witnessExec && vm.flags.HasFlag(ScriptVerifyWitnessPubKeyType) &&
+		!btcec.IsCompressedPubKey(pubKey) {
+		return ErrWitnessPubKeyType
+	}
+	return nil
+}
@@ -0,0 +1,487 @@
+package script
+
+import (
+	"bytes"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// Resource limits of script execution. Tapscripts are exempt from the
+// script size and operation count limits.
+const (
+	// MaxScriptSize is the maximum size in bytes of an executed script.
+	MaxScriptSize = 10000
+
+	// MaxOpsPerScript is the maximum number of non-push operations in a
+	// script, counting each public key of an executed OpCheckMultiSig.
+	MaxOpsPerScript = 201
+
+	// MaxStackSize is the maximum combined number of items on the data and
+	// alt stacks.
+	MaxStackSize = 1000
+
+	// MaxScriptElementSize is the maximum size in bytes of a pushed item.
+	MaxScriptElementSize = 520
+)
+
+var (
+	// ErrScriptTooBig is the result of executing a script larger than
+	// MaxScriptSize.
+	ErrScriptTooBig = scriptError(ErrCodeScriptSize, "script exceeds maximum size")
+
+	// ErrTooManyOperations is the result of a script with more than
+	// MaxOpsPerScript operations.
+	ErrTooManyOperations = scriptError(ErrCodeOpCount, "script exceeds maximum operation count")
+
+	// ErrStackOverflow is the result of a script which grows the stacks to
+	// more than MaxStackSize items.
+	ErrStackOverflow = scriptError(ErrCodeStackSize, "stack exceeds maximum size")
+
+	// ErrElementTooBig is the result of pushing an item larger than
+	// MaxScriptElementSize.
+	ErrElementTooBig = scriptError(ErrCodeElementSize, "element exceeds maximum size")
+
+	// ErrEvalFalse is the result of a script which completes with an empty
+	// stack or a false value on top of the stack.
+	ErrEvalFalse = scriptError(ErrCodeEvalFalse, "script evaluated to false")
+
+	// ErrUnbalancedConditional is the result of an OpIf or OpNotIf without
+	// an argument, an OpElse or OpEndIf without a matching OpIf or OpNotIf,
+	// or a script which ends inside a conditional block.
+	ErrUnbalancedConditional = scriptError(ErrCodeUnbalancedConditional, "unbalanced conditional")
+
+	// ErrInvalidInputIndex is the result of creating an engine for an input
+	// index which is out of range for the spending transaction.
+	ErrInvalidInputIndex = scriptError(ErrCodeInvalidInputIndex, "input index out of range")
+
+	// ErrMinimalData is the result of executing a push which does not use
+	// the smallest push operation for its data with
+	// ScriptVerifyMinimalData.
+	ErrMinimalData = scriptError(ErrCodeMinimalData, "push is not minimally encoded")
+
+	// ErrScriptDone is the result of stepping an engine which has finished
+	// executing all of its scripts.
+	ErrScriptDone = scriptError(ErrCodeScriptDone, "all scripts have been executed")
+)
+
+// Values of the condition stack, which tracks whether each nested
+// conditional branch is executing.
+const (
+	// condFalse marks a branch which is not executing.
+	condFalse = iota
+
+	// condTrue marks a branch which is executing.
+	condTrue
+
+	// condSkip marks a branch nested within a branch which is not
+	// executing. Its OpElse must not switch it to executing.
+	condSkip
+)
+
+// An Engine executes a sequence of scripts, such as an unlocking script
+// followed by the locking script it spends, on a shared data stack.
+type Engine struct {
+	scripts     []*Script
+	scriptIdx   int
+	opIdx       int
+	lastCodeSep int
+	numOps      int
+	dstack      Stack
+	astack      Stack
+	condStack   []int
+	flags       ScriptFlags
+
+	// tx is the spending transaction and txIdx is the index of the input
+	// being verified. Signature checks hash the transaction.
+	tx    *protocol.MsgTx
+	txIdx int
+
+	// amount is the value of the output being spent, which witness
+	// signatures commit to. sigHashes caches the parts of the witness
+	// signature hash shared by all inputs of tx.
+	amount    int64
+	sigHashes *TxSigHashes
+
+	// bip16 is set when the locking script is pay-to-script-hash, in which
+	// case savedStack holds the stack after the unlocking script, whose top
+	// item is the redeem script.
+	bip16      bool
+	savedStack [][]byte
+
+	// witness is the witness of the input. When the locking or redeem
+	// script is a witness program, witnessVersion and witnessProgram hold
+	// its parts. witnessExec is set while executing a witness script.
+	witness        protocol.TxWitness
+	witnessVersion int
+	witnessProgram []byte
+	witnessExec    bool
+
+	// taproot holds the state of a taproot spend.
+	taproot *taprootContext
+
+	// tracer, if set, observes each executed instruction.
+	tracer Tracer
+
+	// batch, if set, receives the Schnorr signatures to verify instead of
+	// them being verified as they are checked.
+	batch *schnorr.BatchVerifier
+}
+
+// NewEngine returns an engine which executes scriptUnlock followed by
+// scriptLock to verify input txIdx of the spending transaction tx, where
+// amount is the value of the output being spent and flags selects the
+// optional rules to apply. With ScriptVerifyP2SH, pay-to-script-hash locking
+// scripts go on to execute the redeem script, and with ScriptVerifyWitness,
+// witness programs, native or wrapped in pay-to-script-hash, go on to execute
+// the witness. sigHashes may be nil, in which case it is computed when first
+// needed; taproot spends require it to have been computed with the outputs
+// spent by tx. An error is returned if a script cannot be parsed or the
+// scripts and witness do not fit together.
+func NewEngine(scriptUnlock, scriptLock []byte, witness protocol.TxWitness,
+	tx *protocol.MsgTx, txIdx int, amount int64, flags ScriptFlags,
+	sigHashes *TxSigHashes) (*Engine, error) {
+	if txIdx < 0 || txIdx >= len(tx.Inputs) {
+		return nil, ErrInvalidInputIndex
+	}
+	err := flags.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(scriptUnlock) > MaxScriptSize || len(scriptLock) > MaxScriptSize {
+		return nil, ErrScriptTooBig
+	}
+
+	unlock, err := Parse(scriptUnlock)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := Parse(scriptLock)
+	if err != nil {
+		return nil, err
+	}
+
+	vm := &Engine{
+		scripts:   []*Script{unlock, lock},
+		tx:        tx,
+		txIdx:     txIdx,
+		amount:    amount,
+		sigHashes: sigHashes,
+		witness:   witness,
+		flags:     flags,
+	}
+	vm.dstack.verifyMinimalData = flags.HasFlag(ScriptVerifyMinimalData)
+
+	if flags.HasFlag(ScriptVerifySigPushOnly) && !unlock.isPushOnly() {
+		return nil, ErrSigPushOnly
+	}
+	if flags.HasFlag(ScriptVerifyP2SH) && isScriptHash(scriptLock) {
+		if !unlock.isPushOnly() {
+			return nil, ErrSigPushOnly
+		}
+		vm.bip16 = true
+	}
+
+	// Without ScriptVerifyWitness, witness programs are ordinary scripts
+	// and the witness is ignored.
+	if !flags.HasFlag(ScriptVerifyWitness) {
+		return vm, nil
+	}
+
+	var program []byte
+	switch {
+	case IsWitnessProgram(scriptLock):
+		// A native witness program is spent by its witness alone.
+		if len(scriptUnlock) != 0 {
+			return nil, ErrWitnessMalleated
+		}
+		program = scriptLock
+
+	case vm.bip16 && len(unlock.instructions) > 0:
+		// A wrapped witness program is spent by a single push of it.
+		redeem := unlock.instructions[len(unlock.instructions)-1].data
+		if IsWitnessProgram(redeem) {
+			if len(unlock.instructions) != 1 ||
+				!bytes.Equal(scriptUnlock, pushDataBytes(redeem)) {
+				return nil, ErrWitnessMalleatedP2SH
+			}
+			program = redeem
+		}
+	}
+
+	if program != nil {
+		vm.witnessVersion, vm.witnessProgram, _ = extractWitnessProgram(program)
+	} else if len(witness) != 0 {
+		return nil, ErrWitnessUnexpected
+	}
+
+	return vm, nil
+}
+
+// Stack returns the data stack of the engine.
+func (vm *Engine) Stack() *Stack {
+	return &vm.dstack
+}
+
+// AltStack returns the alt stack of the engine.
+func (vm *Engine) AltStack() *Stack {
+	return &vm.astack
+}
+
+// isBranchExecuting returns whether the current conditional branch is
+// executing.
+func (vm *Engine) isBranchExecuting() bool {
+	return len(vm.condStack) == 0 || vm.condStack[len(vm.condStack)-1] == condTrue
+}
+
+// done returns whether every script has been executed.
+func (vm *Engine) done() bool {
+	return vm.scriptIdx >= len(vm.scripts)
+}
+
+// skipEmptyScripts advances the engine past any scripts without
+// instructions.
+func (vm *Engine) skipEmptyScripts() {
+	for !vm.done() && len(vm.scripts[vm.scriptIdx].instructions) == 0 {
+		vm.scriptIdx++
+	}
+}
+
+// Step executes the next instruction. It returns true once every script has
+// been executed.
+func (vm *Engine) Step() (done bool, err error) {
+	vm.skipEmptyScripts()
+	if vm.done() {
+		return true, ErrScriptDone
+	}
+
+	script := vm.scripts[vm.scriptIdx]
+	instr := &script.instructions[vm.opIdx]
+	if vm.tracer != nil {
+		vm.tracer.BeforeStep(vm.stepState(instr))
+	}
+	err = vm.executeInstruction(instr)
+	if err == nil && vm.dstack.Depth()+vm.astack.Depth() > MaxStackSize {
+		err = ErrStackOverflow
+	}
+	if vm.tracer != nil {
+		vm.tracer.AfterStep(vm.stepState(instr), err)
+	}
+	if err != nil {
+		return true, err
+	}
+
+	vm.opIdx++
+	if vm.opIdx < len(script.instructions) {
+		return false, nil
+	}
+
+	// Conditionals may not span scripts, and the alt stack does not carry
+	// over from one script to the next.
+	if len(vm.condStack) != 0 {
+		return true, ErrUnbalancedConditional
+	}
+	vm.astack.items = nil
+	vm.scriptIdx++
+	vm.opIdx = 0
+	vm.lastCodeSep = 0
+	vm.numOps = 0
+
+	err = vm.finishScript()
+	if err != nil {
+		return true, err
+	}
+
+	vm.skipEmptyScripts()
+	return vm.done(), nil
+}
+
+// finishScript sets up the scripts which follow the one which just finished:
+// the redeem script of a pay-to-script-hash output after the locking script,
+// and the witness script of a witness program after the locking or redeem
+// script which holds it.
+func (vm *Engine) finishScript() error {
+	switch {
+	case vm.scriptIdx == 1 && vm.bip16:
+		// Save the stack left by the unlocking script, whose top item
+		// is the redeem script.
+		vm.savedStack = append([][]byte(nil), vm.dstack.items...)
+
+	case vm.scriptIdx == 2 && vm.bip16:
+		// The locking script checked the hash of the redeem script,
+		// which now executes on the rest of the unlocking stack.
+		err := vm.checkFinalStack()
+		if err != nil {
+			return err
+		}
+		n := len(vm.savedStack)
+		if len(vm.savedStack[n-1]) > MaxScriptSize {
+			return ErrScriptTooBig
+		}
+		redeem, err := Parse(vm.savedStack[n-1])
+		if err != nil {
+			return err
+		}
+		vm.scripts = append(vm.scripts, redeem)
+		vm.dstack.items = vm.savedStack[:n-1]
+		vm.savedStack = nil
+	}
+
+	// The witness takes over once the script holding the witness program
+	// has executed.
+	if vm.witnessProgram != nil && !vm.witnessExec && vm.done() {
+		err := vm.checkFinalStack()
+		if err != nil {
+			return err
+		}
+		return vm.verifyWitnessProgram()
+	}
+	return nil
+}
+
+// Execute executes every script in the engine. An error is returned if a
+// script fails or the final stack does not have a true value on top.
+func (vm *Engine) Execute() error {
+	for vm.skipEmptyScripts(); !vm.done(); {
+		_, err := vm.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := vm.checkFinalStack()
+	if err != nil {
+		return err
+	}
+
+	// Witness scripts must leave exactly one item on the stack. Other
+	// scripts must as well with ScriptVerifyCleanStack, except when the
+	// stack is that of a witness program whose witness has been verified.
+	switch {
+	case vm.witnessExec && vm.dstack.Depth() != 1:
+		return ErrCleanStack
+	case vm.witnessProgram == nil && vm.flags.HasFlag(ScriptVerifyCleanStack) &&
+		vm.dstack.Depth() != 1:
+		return ErrCleanStack
+	}
+	return nil
+}
+
+// checkFinalStack returns an error unless the data stack has a true value on
+// top.
+func (vm *Engine) checkFinalStack() error {
+	top, err := vm.dstack.Peek(0)
+	if err != nil {
+		return ErrEvalFalse
+	}
+	if !castToBool(top) {
+		return ErrEvalFalse
+	}
+	return nil
+}
+
+// executeInstruction executes a single instruction, honoring the current
+// conditional branch.
+func (vm *Engine) executeInstruction(instr *instruction) error {
+	// Oversized pushes and the operation count limit fail the script even
+	// in branches which are not executing, as do disabled and
+	// always-illegal opcodes.
+	if len(instr.data) > MaxScriptElementSize {
+		return ErrElementTooBig
+	}
+	if instr.op > Op16 && !vm.isTapscript() {
+		err := vm.countOps(1)
+		if err != nil {
+			return err
+		}
+	}
+	if instr.op.isDisabled() {
+		return ErrDisabledOpcode
+	}
+	if instr.op.isAlwaysIllegal() {
+		return ErrReservedOpcode
+	}
+
+	if !vm.isBranchExecuting() && !instr.op.isConditional() {
+		return nil
+	}
+
+	if vm.flags.HasFlag(ScriptVerifyMinimalData) && instr.op.isDataPush() {
+		err := checkMinimalDataPush(instr)
+		if err != nil {
+			return err
+		}
+	}
+
+	return instr.op.execute(instr.data, vm)
+}
+
+// checkMinimalDataPush returns an error unless the data push instr uses the
+// smallest push operation for its data.
+func checkMinimalDataPush(instr *instruction) error {
+	if instr.op != canonicalPushOp(instr.data) {
+		return ErrMinimalData
+	}
+	return nil
+}
+
+// canonicalPushOp returns the opcode of the smallest push of data: an opcode
+// pushing the number for empty data and the numbers -1 and 1 through 16, or
+// else the smallest direct or OpPushData push which fits it.
+func canonicalPushOp(data []byte) opCode {
+	n := len(data)
+	switch {
+	case n == 0:
+		return Op0
+	case n == 1 && data[0] >= 1 && data[0] <= 16:
+		return Op1 + opCode(data[0]-1)
+	case n == 1 && data[0] == 0x81:
+		return Op1Negate
+	case n <= int(OpData75):
+		return opCode(n)
+	case n <= 0xff:
+		return OpPushData1
+	case n <= 0xffff:
+		return OpPushData2
+	}
+	return OpPushData4
+}
+
+// VerifyScript verifies that scriptSig and witness satisfy scriptPubKey for
+// input idx of the spending transaction tx, where amount is the value of the
+// output being spent, under the rules selected by flags. It returns nil if
+// the spend is valid and otherwise an error, which is a ScriptError whose code
+// identifies the rule which failed unless the failure is internal. Taproot
+// spends commit to every output spent by tx, so verifying them requires an
+// engine created by NewEngine with TxSigHashes computed from those outputs.
+func VerifyScript(scriptSig, scriptPubKey []byte, witness protocol.TxWitness,
+	tx *protocol.MsgTx, idx int, amount int64, flags ScriptFlags) error {
+	vm, err := NewEngine(scriptSig, scriptPubKey, witness, tx, idx, amount,
+		flags, nil)
+	if err != nil {
+		return err
+	}
+	return vm.Execute()
+}
+
+// countOps adds n operations to the operation count of the executing script
+// and returns an error if it exceeds MaxOpsPerScript.
+func (vm *Engine) countOps(n int) error {
+	vm.numOps += n
+	if vm.numOps > MaxOpsPerScript {
+		return ErrTooManyOperations
+	}
+	return nil
+}
+
+// checkStackItemSizes returns an error if an item of stack is larger than
+// MaxScriptElementSize.
+func checkStackItemSizes(stack [][]byte) error {
+	for _, item := range stack {
+		if len(item) > MaxScriptElementSize {
+			return ErrElementTooBig
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An ErrorCode identifies the rule violated by a script which fails
+// validation.
+type ErrorCode int
+
+// Error codes of script validation.
+const (
+	// ErrCodeInternal indicates an error which is not a script rule
+	// violation, such as a failure to serialize the transaction.
+	ErrCodeInternal ErrorCode = iota
+
+	// Engine setup and execution.
+	ErrCodeInvalidFlags
+	ErrCodeInvalidInputIndex
+	ErrCodeNoTransaction
+	ErrCodeScriptDone
+	ErrCodeEvalFalse
+	ErrCodeEarlyReturn
+	ErrCodeUnbalancedConditional
+
+	// Resource limits.
+	ErrCodeScriptSize
+	ErrCodeOpCount
+	ErrCodeStackSize
+	ErrCodeElementSize
+
+	// Parsing and opcodes.
+	ErrCodeMalformedPush
+	ErrCodeMinimalData
+	ErrCodeDisabledOpcode
+	ErrCodeReservedOpcode
+	ErrCodeInvalidOpcode
+	ErrCodeDiscourageUpgradableNops
+	ErrCodeInvalidAsm
+
+	// Stack and number operands.
+	ErrCodePopFromEmptyStack
+	ErrCodeInvalidStackOperation
+	ErrCodeInvalidAltStackOperation
+	ErrCodeNumberTooBig
+	ErrCodeMinimalIf
+
+	// Failed VERIFY operations.
+	ErrCodeVerify
+	ErrCodeEqualVerify
+	ErrCodeNumEqualVerify
+	ErrCodeCheckSigVerify
+	ErrCodeCheckMultiSigVerify
+
+	// Signature and public key checks.
+	ErrCodeInvalidPubKeyCount
+	ErrCodeInvalidSignatureCount
+	ErrCodeSigHashType
+	ErrCodeSigDER
+	ErrCodeSigHighS
+	ErrCodeSigNullDummy
+	ErrCodePubKeyType
+
+	// Lock times.
+	ErrCodeNegativeLockTime
+	ErrCodeUnsatisfiedLockTime
+
+	// Pay-to-script-hash and stack cleanliness.
+	ErrCodeSigPushOnly
+	ErrCodeCleanStack
+
+	// Segregated witness.
+	ErrCodeNotWitnessProgram
+	ErrCodeWitnessMalleated
+	ErrCodeWitnessMalleatedP2SH
+	ErrCodeWitnessUnexpected
+	ErrCodeWitnessProgramEmpty
+	ErrCodeWitnessProgramMismatch
+	ErrCodeWitnessProgramWrongLength
+	ErrCodeWitnessPubKeyType
+
+	// Taproot.
+	ErrCodeInvalidTaprootSigHashType
+	ErrCodeTaprootSigHashSingle
+	ErrCodeMissingPrevOuts
+	ErrCodeTaprootSigInvalid
+	ErrCodeTaprootControlBlockSize
+	ErrCodeTaprootInternalKey
+	ErrCodeTaprootTweak
+	ErrCodeTaprootPubKeyEmpty
+	ErrCodeTaprootMaxSigOps
+	ErrCodeTapscriptCheckMultiSig
+
+	// Standard scripts.
+	ErrCodeUnsupportedNet
+	ErrCodeInvalidHashSize
+	ErrCodeTooMuchNullData
+)
+
+// errorCodeNames maps each error code to its name.
+var errorCodeNames = map[ErrorCode]string{
+	ErrCodeInternal:                  "ErrCodeInternal",
+	ErrCodeInvalidFlags:              "ErrCodeInvalidFlags",
+	ErrCodeInvalidInputIndex:         "ErrCodeInvalidInputIndex",
+	ErrCodeNoTransaction:             "ErrCodeNoTransaction",
+	ErrCodeScriptDone:                "ErrCodeScriptDone",
+	ErrCodeEvalFalse:                 "ErrCodeEvalFalse",
+	ErrCodeEarlyReturn:               "ErrCodeEarlyReturn",
+	ErrCodeUnbalancedConditional:     "ErrCodeUnbalancedConditional",
+	ErrCodeScriptSize:                "ErrCodeScriptSize",
+	ErrCodeOpCount:                   "ErrCodeOpCount",
+	ErrCodeStackSize:                 "ErrCodeStackSize",
+	ErrCodeElementSize:               "ErrCodeElementSize",
+	ErrCodeMalformedPush:             "ErrCodeMalformedPush",
+	ErrCodeMinimalData:               "ErrCodeMinimalData",
+	ErrCodeDisabledOpcode:            "ErrCodeDisabledOpcode",
+	ErrCodeReservedOpcode:            "ErrCodeReservedOpcode",
+	ErrCodeInvalidOpcode:             "ErrCodeInvalidOpcode",
+	ErrCodeDiscourageUpgradableNops:  "ErrCodeDiscourageUpgradableNops",
+	ErrCodeInvalidAsm:                "ErrCodeInvalidAsm",
+	ErrCodePopFromEmptyStack:         "ErrCodePopFromEmptyStack",
+	ErrCodeInvalidStackOperation:     "ErrCodeInvalidStackOperation",
+	ErrCodeInvalidAltStackOperation:  "ErrCodeInvalidAltStackOperation",
+	ErrCodeNumberTooBig:              "ErrCodeNumberTooBig",
+	ErrCodeMinimalIf:                 "ErrCodeMinimalIf",
+	ErrCodeVerify:                    "ErrCodeVerify",
+	ErrCodeEqualVerify:               "ErrCodeEqualVerify",
+	ErrCodeNumEqualVerify:            "ErrCodeNumEqualVerify",
+	ErrCodeCheckSigVerify:            "ErrCodeCheckSigVerify",
+	ErrCodeCheckMultiSigVerify:       "ErrCodeCheckMultiSigVerify",
+	ErrCodeInvalidPubKeyCount:        "ErrCodeInvalidPubKeyCount",
+	ErrCodeInvalidSignatureCount:     "ErrCodeInvalidSignatureCount",
+	ErrCodeSigHashType:               "ErrCodeSigHashType",
+	ErrCodeSigDER:                    "ErrCodeSigDER",
+	ErrCodeSigHighS:                  "ErrCodeSigHighS",
+	ErrCodeSigNullDummy:              "ErrCodeSigNullDummy",
+	ErrCodePubKeyType:                "ErrCodePubKeyType",
+	ErrCodeNegativeLockTime:          "ErrCodeNegativeLockTime",
+	ErrCodeUnsatisfiedLockTime:       "ErrCodeUnsatisfiedLockTime",
+	ErrCodeSigPushOnly:               "ErrCodeSigPushOnly",
+	ErrCodeCleanStack:                "ErrCodeCleanStack",
+	ErrCodeNotWitnessProgram:         "ErrCodeNotWitnessProgram",
+	ErrCodeWitnessMalleated:          "ErrCodeWitnessMalleated",
+	ErrCodeWitnessMalleatedP2SH:      "ErrCodeWitnessMalleatedP2SH",
+	ErrCodeWitnessUnexpected:         "ErrCodeWitnessUnexpected",
+	ErrCodeWitnessProgramEmpty:       "ErrCodeWitnessProgramEmpty",
+	ErrCodeWitnessProgramMismatch:    "ErrCodeWitnessProgramMismatch",
+	ErrCodeWitnessProgramWrongLength: "ErrCodeWitnessProgramWrongLength",
+	ErrCodeWitnessPubKeyType:         "ErrCodeWitnessPubKeyType",
+	ErrCodeInvalidTaprootSigHashType: "ErrCodeInvalidTaprootSigHashType",
+	ErrCodeTaprootSigHashSingle:      "ErrCodeTaprootSigHashSingle",
+	ErrCodeMissingPrevOuts:           "ErrCodeMissingPrevOuts",
+	ErrCodeTaprootSigInvalid:         "ErrCodeTaprootSigInvalid",
+	ErrCodeTaprootControlBlockSize:   "ErrCodeTaprootControlBlockSize",
+	ErrCodeTaprootInternalKey:        "ErrCodeTaprootInternalKey",
+	ErrCodeTaprootTweak:              "ErrCodeTaprootTweak",
+	ErrCodeTaprootPubKeyEmpty:        "ErrCodeTaprootPubKeyEmpty",
+	ErrCodeTaprootMaxSigOps:          "ErrCodeTaprootMaxSigOps",
+	ErrCodeTapscriptCheckMultiSig:    "ErrCodeTapscriptCheckMultiSig",
+	ErrCodeUnsupportedNet:            "ErrCodeUnsupportedNet",
+	ErrCodeInvalidHashSize:           "ErrCodeInvalidHashSize",
+	ErrCodeTooMuchNullData:           "ErrCodeTooMuchNullData",
+}
+
+// String returns the name of the error code.
+func (c ErrorCode) String() string {
+	if name, ok := errorCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(c))
+}
+
+// A ScriptError is the result of a script which fails validation. Code
+// identifies the rule which failed, and Description explains it.
+type ScriptError struct {
+	Code        ErrorCode
+	Description string
+}
+
+// Error returns the description of the error.
+func (e ScriptError) Error() string {
+	return e.Description
+}
+
+// scriptError returns a ScriptError with the given code and description.
+func scriptError(code ErrorCode, desc string) ScriptError {
+	return ScriptError{Code: code, Description: desc}
+}
+
+// IsErrorCode returns whether err is a ScriptError with the given code.
+func IsErrorCode(err error, code ErrorCode) bool {
+	var serr ScriptError
+	return errors.As(err, &serr) && serr.Code == code
+}
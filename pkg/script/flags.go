@@ -0,0 +1,115 @@
+package script
+
+// ScriptFlags is a set of optional rules applied when verifying a script.
+// Consensus rules introduced by soft forks are enabled by flags so that
+// historical transactions still verify, and policy rules which are stricter
+// than consensus are enabled by flags when accepting transactions to the
+// mempool.
+type ScriptFlags uint32
+
+// Script verification flags.
+const (
+	// ScriptVerifyP2SH evaluates pay-to-script-hash outputs as specified by
+	// BIP16.
+	ScriptVerifyP2SH ScriptFlags = 1 << iota
+
+	// ScriptVerifyStrictEncoding requires signatures to be strictly DER
+	// encoded with a defined hash type, and public keys to be compressed
+	// or uncompressed.
+	ScriptVerifyStrictEncoding
+
+	// ScriptVerifyDERSignatures requires signatures to be strictly DER
+	// encoded, as specified by BIP66.
+	ScriptVerifyDERSignatures
+
+	// ScriptVerifyLowS requires the S value of signatures to be at most
+	// half the curve order, as specified by BIP62.
+	ScriptVerifyLowS
+
+	// ScriptVerifyNullDummy requires the extra item popped by
+	// OpCheckMultiSig to be empty, as specified by BIP147.
+	ScriptVerifyNullDummy
+
+	// ScriptVerifySigPushOnly requires unlocking scripts to contain only
+	// push operations.
+	ScriptVerifySigPushOnly
+
+	// ScriptVerifyMinimalData requires pushes to use the smallest push
+	// operation for their data.
+	ScriptVerifyMinimalData
+
+	// ScriptVerifyCleanStack requires scripts to leave exactly one item on
+	// the stack. It requires ScriptVerifyP2SH and ScriptVerifyWitness.
+	ScriptVerifyCleanStack
+
+	// ScriptVerifyCheckLockTimeVerify enables OpCheckLockTimeVerify, as
+	// specified by BIP65.
+	ScriptVerifyCheckLockTimeVerify
+
+	// ScriptVerifyCheckSequenceVerify enables OpCheckSequenceVerify, as
+	// specified by BIP112.
+	ScriptVerifyCheckSequenceVerify
+
+	// ScriptVerifyWitness evaluates witness programs, as specified by
+	// BIP141. It requires ScriptVerifyP2SH.
+	ScriptVerifyWitness
+
+	// ScriptVerifyTaproot evaluates version 1 witness programs as taproot
+	// outputs, as specified by BIP341 and BIP342.
+	ScriptVerifyTaproot
+
+	// ScriptDiscourageUpgradableNops fails scripts which execute an OpNop
+	// reserved for future soft forks.
+	ScriptDiscourageUpgradableNops
+
+	// ScriptVerifyMinimalIf requires the argument of OpIf and OpNotIf in a
+	// version 0 witness script to be empty or 0x01. Tapscripts always
+	// require it.
+	ScriptVerifyMinimalIf
+
+	// ScriptVerifyWitnessPubKeyType requires the public keys checked in a
+	// version 0 witness script to be compressed.
+	ScriptVerifyWitnessPubKeyType
+)
+
+const (
+	// ScriptVerifyNone applies no optional rules.
+	ScriptVerifyNone ScriptFlags = 0
+
+	// ConsensusVerifyFlags are the flags enforced by consensus for new
+	// blocks.
+	ConsensusVerifyFlags = ScriptVerifyP2SH | ScriptVerifyDERSignatures |
+		ScriptVerifyNullDummy | ScriptVerifyCheckLockTimeVerify |
+		ScriptVerifyCheckSequenceVerify | ScriptVerifyWitness |
+		ScriptVerifyTaproot
+
+	// StandardVerifyFlags are the flags enforced by policy for transactions
+	// accepted to the mempool. They include ConsensusVerifyFlags.
+	StandardVerifyFlags = ConsensusVerifyFlags |
+		ScriptVerifyStrictEncoding | ScriptVerifyLowS |
+		ScriptVerifySigPushOnly | ScriptVerifyMinimalData |
+		ScriptVerifyCleanStack | ScriptDiscourageUpgradableNops |
+		ScriptVerifyMinimalIf | ScriptVerifyWitnessPubKeyType
+)
+
+// ErrInvalidFlags is the result of creating an engine with a flag set in
+// which a flag is missing a flag it requires.
+var ErrInvalidFlags = scriptError(ErrCodeInvalidFlags, "invalid flag combination")
+
+// HasFlag returns whether every flag in flag is set in flags.
+func (flags ScriptFlags) HasFlag(flag ScriptFlags) bool {
+	return flags&flag == flag
+}
+
+// validate returns an error if a flag in flags is missing a flag it
+// requires.
+func (flags ScriptFlags) validate() error {
+	if flags.HasFlag(ScriptVerifyWitness) && !flags.HasFlag(ScriptVerifyP2SH) {
+		return ErrInvalidFlags
+	}
+	if flags.HasFlag(ScriptVerifyCleanStack) &&
+		!flags.HasFlag(ScriptVerifyP2SH|ScriptVerifyWitness) {
+		return ErrInvalidFlags
+	}
+	return nil
+}
@@ -2,31 +2,28 @@ package script
 
 // An instruction is an executable instruction specified by the
 // Bitcoin scripting language.
-// Instructions may be data instructions or opcodes.
-type instruction interface {
-	// Execute the Instruction
-	Execute() error
+// Instructions may be data pushes or opcodes. For data pushes, the opcode
+// records which push operation was used so that the instruction serializes
+// back to the same bytes.
+type instruction struct {
+	op   opCode
+	data []byte
 }
 
-// A dataInstruction is a Bitcoin scripting language data instruction.
-type dataInstruction struct {
-	data  []byte
-	stack *Stack
-}
-
-// Execute pushes the dataInstruction onto its associated Stack.
-func (i *dataInstruction) Execute() error {
-	i.stack.Push(i.data)
-	return nil
-}
-
-// An opCodeInstruction is a Bitcoin scripting language opcode instruction.
-type opCodeInstruction struct {
-	op    opCode
-	stack *Stack
-}
+// bytes returns the serialized form of the instruction: the opcode followed
+// by any length prefix and pushed data.
+func (i *instruction) bytes() []byte {
+	b := make([]byte, 0, 5+len(i.data))
+	b = append(b, byte(i.op))
 
-// Execute executes the operation in the opCodeInstruction.
-func (i *opCodeInstruction) Execute() error {
-	return i.op.executeOp(i.stack)
+	n := len(i.data)
+	switch i.op {
+	case OpPushData1:
+		b = append(b, byte(n))
+	case OpPushData2:
+		b = append(b, byte(n), byte(n>>8))
+	case OpPushData4:
+		b = append(b, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(b, i.data...)
 }
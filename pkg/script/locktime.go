@@ -0,0 +1,128 @@
+package script
+
+// lockTimeNumSize is the maximum size in bytes of the lock time operand of
+// OpCheckLockTimeVerify and OpCheckSequenceVerify. It is larger than that of
+// arithmetic operands so that lock times up to 2^39-1 are representable.
+const lockTimeNumSize = 5
+
+// Lock time constants, matching those used for transaction finality.
+const (
+	// lockTimeThreshold is the lock time below which lock times are block
+	// heights and at or above which they are Unix timestamps.
+	lockTimeThreshold = 500000000
+
+	// maxTxInSequenceNum is the sequence number which disables the lock
+	// time of a transaction.
+	maxTxInSequenceNum = 0xffffffff
+
+	// sequenceLockTimeDisabled is set in a sequence number which does not
+	// encode a relative lock time.
+	sequenceLockTimeDisabled = 1 << 31
+
+	// sequenceLockTimeIsSeconds is set in a sequence number whose relative
+	// lock time is in units of 512 seconds rather than blocks.
+	sequenceLockTimeIsSeconds = 1 << 22
+
+	// sequenceLockTimeMask selects the relative lock time of a sequence
+	// number.
+	sequenceLockTimeMask = 0x0000ffff
+)
+
+var (
+	// ErrNegativeLockTime is the result of a negative lock time operand.
+	ErrNegativeLockTime = scriptError(ErrCodeNegativeLockTime, "negative lock time")
+
+	// ErrUnsatisfiedLockTime is the result of a lock time which the
+	// spending transaction does not satisfy.
+	ErrUnsatisfiedLockTime = scriptError(ErrCodeUnsatisfiedLockTime, "lock time requirement not satisfied")
+)
+
+// peekLockTime returns the lock time operand on top of the stack without
+// removing it.
+func peekLockTime(s *Stack) (int64, error) {
+	n, err := s.PeekInt(0, lockTimeNumSize)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, ErrNegativeLockTime
+	}
+	return int64(n), nil
+}
+
+// opCheckLockTimeVerify executes OpCheckLockTimeVerify as specified by BIP65.
+// The script fails unless the lock time of the spending transaction is at
+// least the operand and of the same kind, block height or timestamp.
+func opCheckLockTimeVerify(vm *Engine) error {
+	if !vm.flags.HasFlag(ScriptVerifyCheckLockTimeVerify) {
+		return vm.executeUpgradableNop()
+	}
+	if vm.tx == nil {
+		return ErrNoTransaction
+	}
+
+	lockTime, err := peekLockTime(&vm.dstack)
+	if err != nil {
+		return err
+	}
+
+	txLockTime := int64(vm.tx.LockTime)
+	if (txLockTime < lockTimeThreshold) != (lockTime < lockTimeThreshold) {
+		return ErrUnsatisfiedLockTime
+	}
+	if lockTime > txLockTime {
+		return ErrUnsatisfiedLockTime
+	}
+
+	// A final input disables the lock time of the transaction, which would
+	// bypass the check.
+	if vm.tx.Inputs[vm.txIdx].Sequence == maxTxInSequenceNum {
+		return ErrUnsatisfiedLockTime
+	}
+	return nil
+}
+
+// opCheckSequenceVerify executes OpCheckSequenceVerify as specified by
+// BIP112. The script fails unless the relative lock time of the spending
+// input is at least the operand and of the same kind, blocks or time.
+func opCheckSequenceVerify(vm *Engine) error {
+	if !vm.flags.HasFlag(ScriptVerifyCheckSequenceVerify) {
+		return vm.executeUpgradableNop()
+	}
+	if vm.tx == nil {
+		return ErrNoTransaction
+	}
+
+	sequence, err := peekLockTime(&vm.dstack)
+	if err != nil {
+		return err
+	}
+
+	// An operand with the disable flag set is reserved for future soft
+	// forks and acts as a NOP.
+	if sequence&sequenceLockTimeDisabled != 0 {
+		return nil
+	}
+
+	// Relative lock times apply only to version 2 transactions. The version
+	// is compared as unsigned, as by BIP68, so negative versions count as
+	// large ones.
+	if uint32(vm.tx.Version) < 2 {
+		return ErrUnsatisfiedLockTime
+	}
+	txSequence := int64(vm.tx.Inputs[vm.txIdx].Sequence)
+	if txSequence&sequenceLockTimeDisabled != 0 {
+		return ErrUnsatisfiedLockTime
+	}
+
+	mask := int64(sequenceLockTimeIsSeconds | sequenceLockTimeMask)
+	lockTime, txLockTime := sequence&mask, txSequence&mask
+	if (lockTime < sequenceLockTimeIsSeconds) !=
+		(txLockTime < sequenceLockTimeIsSeconds) {
+		return ErrUnsatisfiedLockTime
+	}
+	if lockTime > txLockTime {
+		return ErrUnsatisfiedLockTime
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package script
+
+import "testing"
+
+func TestCheckLockTimeVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		operand  int64
+		lockTime uint32
+		sequence uint32
+		err      error
+	}{
+		{"height satisfied", 100, 100, 0, nil},
+		{"height unsatisfied", 101, 100, 0, ErrUnsatisfiedLockTime},
+		{"time satisfied", 500000000, 500000001, 0, nil},
+		{"kind mismatch", 100, 500000000, 0, ErrUnsatisfiedLockTime},
+		{"final input", 100, 100, maxTxInSequenceNum, ErrUnsatisfiedLockTime},
+		{"negative", -1, 100, 0, ErrNegativeLockTime},
+	}
+	for _, test := range tests {
+		lock, err := NewScriptBuilder().
+			AddInt64(test.operand).
+			AddOp(byte(OpCheckLockTimeVerify)).
+			Script()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := testTx()
+		tx.LockTime = test.lockTime
+		tx.Inputs[0].Sequence = test.sequence
+		err = VerifyScript(nil, lock, nil, tx, 0, 0, StandardVerifyFlags&^
+			ScriptVerifyCleanStack)
+		if err != test.err {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestCheckSequenceVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		operand  int64
+		version  int32
+		sequence uint32
+		err      error
+	}{
+		{"blocks satisfied", 10, 2, 10, nil},
+		{"blocks unsatisfied", 11, 2, 10, ErrUnsatisfiedLockTime},
+		{"seconds satisfied", sequenceLockTimeIsSeconds | 10, 2,
+			sequenceLockTimeIsSeconds | 10, nil},
+		{"kind mismatch", sequenceLockTimeIsSeconds | 10, 2, 10,
+			ErrUnsatisfiedLockTime},
+		{"disabled operand", sequenceLockTimeDisabled, 1, 0, nil},
+		{"disabled sequence", 10, 2, sequenceLockTimeDisabled | 10,
+			ErrUnsatisfiedLockTime},
+		{"version 1", 10, 1, 10, ErrUnsatisfiedLockTime},
+
+		// The version is unsigned, so a negative version is at least 2.
+		{"negative version", 10, -1, 10, nil},
+	}
+	for _, test := range tests {
+		lock, err := NewScriptBuilder().
+			AddInt64(test.operand).
+			AddOp(byte(OpCheckSequenceVerify)).
+			Script()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := testTx()
+		tx.Version = test.version
+		tx.Inputs[0].Sequence = test.sequence
+		err = VerifyScript(nil, lock, nil, tx, 0, 0, StandardVerifyFlags&^
+			ScriptVerifyCleanStack)
+		if err != test.err {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package script
+
+// maxNumSize is the maximum size in bytes of a number operand of an
+// arithmetic opcode.
+const maxNumSize = 4
+
+var (
+	// ErrNumberTooBig is the result of interpreting a stack item longer
+	// than the allowed size as a number.
+	ErrNumberTooBig = scriptError(ErrCodeNumberTooBig, "number exceeds maximum size")
+
+	// ErrMinimalNumber is the result of interpreting a stack item which is
+	// not minimally encoded as a number with ScriptVerifyMinimalData.
+	ErrMinimalNumber = scriptError(ErrCodeMinimalData, "number is not minimally encoded")
+)
+
+// A scriptNum is a number operand of the scripting language. Script numbers
+// are encoded as little-endian sign-magnitude integers with the sign in the
+// most significant bit of the last byte, and zero is encoded as an empty byte
+// array.
+//
+// Operands are limited to 4 bytes, or 5 for lock times, but the results of
+// arithmetic may overflow that range, so a scriptNum holds an int64. Such
+// results may be pushed to the stack but fail when used as operands.
+type scriptNum int64
+
+// makeScriptNum interprets d as a script number. An error is returned if d is
+// longer than maxSize bytes, or if requireMinimal is set and d is not the
+// minimal encoding of its value.
+func makeScriptNum(d []byte, requireMinimal bool,
+	maxSize int) (scriptNum, error) {
+	if len(d) > maxSize {
+		return 0, ErrNumberTooBig
+	}
+	if requireMinimal {
+		err := checkMinimalNumEncoding(d)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(d) == 0 {
+		return 0, nil
+	}
+
+	var n int64
+	for i, b := range d {
+		n |= int64(b) << uint(8*i)
+	}
+
+	// Clear the sign bit and negate the result if it was set.
+	last := len(d) - 1
+	if d[last]&0x80 != 0 {
+		n &^= int64(0x80) << uint(8*last)
+		return scriptNum(-n), nil
+	}
+	return scriptNum(n), nil
+}
+
+// checkMinimalNumEncoding returns an error unless d is the minimal encoding
+// of a script number: its last byte may only be 0x00 or 0x80 when the
+// previous byte needs its sign bit for the magnitude.
+func checkMinimalNumEncoding(d []byte) error {
+	if len(d) == 0 {
+		return nil
+	}
+
+	last := d[len(d)-1]
+	if last&0x7f == 0 {
+		if len(d) == 1 || d[len(d)-2]&0x80 == 0 {
+			return ErrMinimalNumber
+		}
+	}
+	return nil
+}
+
+// Bytes returns the minimal encoding of n.
+func (n scriptNum) Bytes() []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	abs := uint64(n)
+	if negative {
+		abs = uint64(-n)
+	}
+
+	var b []byte
+	for abs > 0 {
+		b = append(b, byte(abs))
+		abs >>= 8
+	}
+
+	// When the most significant byte already uses the sign bit, an extra
+	// byte holds the sign. Otherwise the sign is stored in that byte.
+	if b[len(b)-1]&0x80 != 0 {
+		if negative {
+			b = append(b, 0x80)
+		} else {
+			b = append(b, 0x00)
+		}
+	} else if negative {
+		b[len(b)-1] |= 0x80
+	}
+	return b
+}
+
+// castToBool interprets d as a boolean. Any item other than a representation
+// of zero, positive or negative, is true.
+func castToBool(d []byte) bool {
+	for i, b := range d {
+		if b != 0 {
+			// Negative zero is false.
+			if i == len(d)-1 && b == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
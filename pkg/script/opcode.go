@@ -1,97 +1,554 @@
 package script
 
 import (
-	"bytes"
+	"fmt"
+)
+
+// An opCode represents an opcode in the Bitcoin scripting language. The value
+// of an opCode is its byte value in a serialized script.
+type opCode byte
+
+// Push opcodes.
+const (
+	// Op0 pushes an empty byte array onto the stack.
+	Op0 opCode = 0x00
+
+	// OpFalse is an alias for Op0.
+	OpFalse = Op0
+
+	// OpData1 through OpData75 push the next 1 through 75 bytes of the
+	// script onto the stack. Only the bounds of the range are named.
+	OpData1  opCode = 0x01
+	OpData75 opCode = 0x4b
+
+	// OpPushData1 pushes the number of bytes given by the next byte of the
+	// script onto the stack.
+	OpPushData1 opCode = 0x4c
+
+	// OpPushData2 pushes the number of bytes given by the next two bytes
+	// (little-endian) of the script onto the stack.
+	OpPushData2 opCode = 0x4d
+
+	// OpPushData4 pushes the number of bytes given by the next four bytes
+	// (little-endian) of the script onto the stack.
+	OpPushData4 opCode = 0x4e
 
-	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	// Op1Negate pushes the number -1 onto the stack.
+	Op1Negate opCode = 0x4f
+
+	// OpReserved fails the script if executed.
+	OpReserved opCode = 0x50
+
+	// Op1 through Op16 push the numbers 1 through 16 onto the stack.
+	Op1  opCode = 0x51
+	Op2  opCode = 0x52
+	Op3  opCode = 0x53
+	Op4  opCode = 0x54
+	Op5  opCode = 0x55
+	Op6  opCode = 0x56
+	Op7  opCode = 0x57
+	Op8  opCode = 0x58
+	Op9  opCode = 0x59
+	Op10 opCode = 0x5a
+	Op11 opCode = 0x5b
+	Op12 opCode = 0x5c
+	Op13 opCode = 0x5d
+	Op14 opCode = 0x5e
+	Op15 opCode = 0x5f
+	Op16 opCode = 0x60
+
+	// OpTrue is an alias for Op1.
+	OpTrue = Op1
 )
 
-// An opCode represents an opcode in the Bitcoin scripting language
-type opCode int
+// Flow control opcodes.
+const (
+	// OpNop does nothing.
+	OpNop opCode = 0x61
+
+	// OpVer fails the script if executed.
+	OpVer opCode = 0x62
+
+	// OpIf executes the following statements if the top stack item is true.
+	OpIf opCode = 0x63
+
+	// OpNotIf executes the following statements if the top stack item is
+	// false.
+	OpNotIf opCode = 0x64
+
+	// OpVerIf fails the script even when it is not executed.
+	OpVerIf opCode = 0x65
 
+	// OpVerNotIf fails the script even when it is not executed.
+	OpVerNotIf opCode = 0x66
+
+	// OpElse executes the following statements if the preceding OpIf,
+	// OpNotIf or OpElse statements were not executed.
+	OpElse opCode = 0x67
+
+	// OpEndIf ends an if/else block.
+	OpEndIf opCode = 0x68
+
+	// OpVerify consumes the topmost item on the stack, and if that value is zero,
+	// it terminates in failure.
+	OpVerify opCode = 0x69
+
+	// OpReturn fails the script. It is used to mark outputs as provably
+	// unspendable.
+	OpReturn opCode = 0x6a
+)
+
+// Stack opcodes.
 const (
+	// OpToAltStack moves the top item of the stack to the alt stack.
+	OpToAltStack opCode = 0x6b
+
+	// OpFromAltStack moves the top item of the alt stack to the stack.
+	OpFromAltStack opCode = 0x6c
+
+	// Op2Drop removes the top two stack items.
+	Op2Drop opCode = 0x6d
+
+	// Op2Dup duplicates the top two stack items.
+	Op2Dup opCode = 0x6e
+
+	// Op3Dup duplicates the top three stack items.
+	Op3Dup opCode = 0x6f
+
+	// Op2Over copies the pair of items two spaces back to the top.
+	Op2Over opCode = 0x70
+
+	// Op2Rot moves the fifth and sixth items to the top.
+	Op2Rot opCode = 0x71
+
+	// Op2Swap swaps the top two pairs of items.
+	Op2Swap opCode = 0x72
+
+	// OpIfDup duplicates the top stack item if it is true.
+	OpIfDup opCode = 0x73
+
+	// OpDepth pushes the number of stack items.
+	OpDepth opCode = 0x74
+
+	// OpDrop removes the top stack item.
+	OpDrop opCode = 0x75
+
 	// OpDup duplicates the top item on the stack.
-	OpDup opCode = iota
+	OpDup opCode = 0x76
 
-	// OpHash160 performs two hashes using SHA256 followed by RIPEMD-160.
-	OpHash160
+	// OpNip removes the second-to-top stack item.
+	OpNip opCode = 0x77
+
+	// OpOver copies the second-to-top stack item to the top.
+	OpOver opCode = 0x78
+
+	// OpPick copies the item n back in the stack to the top.
+	OpPick opCode = 0x79
+
+	// OpRoll moves the item n back in the stack to the top.
+	OpRoll opCode = 0x7a
+
+	// OpRot moves the third item to the top.
+	OpRot opCode = 0x7b
+
+	// OpSwap swaps the top two stack items.
+	OpSwap opCode = 0x7c
+
+	// OpTuck copies the top stack item before the second-to-top item.
+	OpTuck opCode = 0x7d
+)
+
+// Splice opcodes.
+const (
+	// OpCat is disabled.
+	OpCat opCode = 0x7e
+
+	// OpSubStr is disabled.
+	OpSubStr opCode = 0x7f
+
+	// OpLeft is disabled.
+	OpLeft opCode = 0x80
+
+	// OpRight is disabled.
+	OpRight opCode = 0x81
+
+	// OpSize pushes the length of the top stack item without removing it.
+	OpSize opCode = 0x82
+)
+
+// Bitwise logic opcodes.
+const (
+	// OpInvert is disabled.
+	OpInvert opCode = 0x83
+
+	// OpAnd is disabled.
+	OpAnd opCode = 0x84
+
+	// OpOr is disabled.
+	OpOr opCode = 0x85
+
+	// OpXor is disabled.
+	OpXor opCode = 0x86
 
 	// OpEqual consumes the top two items from the stack and determines if they
 	// are equal or not.
-	OpEqual
-
-	// OpVerify consumes the topmost item on the stack, and if that value is zero,
-	// it terminates in failure.
-	OpVerify
+	OpEqual opCode = 0x87
 
 	// OpEqualVerify runs OpEqual and then OpVerify in sequence.
-	OpEqualVerify
+	OpEqualVerify opCode = 0x88
+
+	// OpReserved1 fails the script if executed.
+	OpReserved1 opCode = 0x89
+
+	// OpReserved2 fails the script if executed.
+	OpReserved2 opCode = 0x8a
 )
 
-// Execute the operations specified by the opcode.
-func (op opCode) executeOp(s *Stack) error {
+// Arithmetic opcodes. Arithmetic operands are script numbers.
+const (
+	// Op1Add adds 1 to the top stack item.
+	Op1Add opCode = 0x8b
+
+	// Op1Sub subtracts 1 from the top stack item.
+	Op1Sub opCode = 0x8c
+
+	// Op2Mul is disabled.
+	Op2Mul opCode = 0x8d
+
+	// Op2Div is disabled.
+	Op2Div opCode = 0x8e
+
+	// OpNegate negates the top stack item.
+	OpNegate opCode = 0x8f
+
+	// OpAbs replaces the top stack item with its absolute value.
+	OpAbs opCode = 0x90
+
+	// OpNot replaces 0 with 1 and any other number with 0.
+	OpNot opCode = 0x91
+
+	// Op0NotEqual replaces 0 with 0 and any other number with 1.
+	Op0NotEqual opCode = 0x92
+
+	// OpAdd replaces the top two stack items with their sum.
+	OpAdd opCode = 0x93
+
+	// OpSub replaces the top two stack items with the second minus the
+	// first.
+	OpSub opCode = 0x94
+
+	// OpMul is disabled.
+	OpMul opCode = 0x95
+
+	// OpDiv is disabled.
+	OpDiv opCode = 0x96
+
+	// OpMod is disabled.
+	OpMod opCode = 0x97
+
+	// OpLShift is disabled.
+	OpLShift opCode = 0x98
+
+	// OpRShift is disabled.
+	OpRShift opCode = 0x99
+
+	// OpBoolAnd pushes 1 if both of the top two items are not 0.
+	OpBoolAnd opCode = 0x9a
+
+	// OpBoolOr pushes 1 if either of the top two items is not 0.
+	OpBoolOr opCode = 0x9b
+
+	// OpNumEqual pushes 1 if the top two numbers are equal.
+	OpNumEqual opCode = 0x9c
+
+	// OpNumEqualVerify runs OpNumEqual and then OpVerify in sequence.
+	OpNumEqualVerify opCode = 0x9d
+
+	// OpNumNotEqual pushes 1 if the top two numbers are not equal.
+	OpNumNotEqual opCode = 0x9e
+
+	// OpLessThan pushes 1 if the second item is less than the top item.
+	OpLessThan opCode = 0x9f
+
+	// OpGreaterThan pushes 1 if the second item is greater than the top
+	// item.
+	OpGreaterThan opCode = 0xa0
+
+	// OpLessThanOrEqual pushes 1 if the second item is less than or equal to
+	// the top item.
+	OpLessThanOrEqual opCode = 0xa1
+
+	// OpGreaterThanOrEqual pushes 1 if the second item is greater than or
+	// equal to the top item.
+	OpGreaterThanOrEqual opCode = 0xa2
+
+	// OpMin replaces the top two items with the smaller of the two.
+	OpMin opCode = 0xa3
+
+	// OpMax replaces the top two items with the larger of the two.
+	OpMax opCode = 0xa4
+
+	// OpWithin pushes 1 if the third item is within the range given by the
+	// second (inclusive) and the top (exclusive) items.
+	OpWithin opCode = 0xa5
+)
+
+// Crypto opcodes.
+const (
+	// OpRipemd160 hashes the top stack item with RIPEMD-160.
+	OpRipemd160 opCode = 0xa6
+
+	// OpSha1 hashes the top stack item with SHA-1.
+	OpSha1 opCode = 0xa7
+
+	// OpSha256 hashes the top stack item with SHA-256.
+	OpSha256 opCode = 0xa8
+
+	// OpHash160 performs two hashes using SHA256 followed by RIPEMD-160.
+	OpHash160 opCode = 0xa9
+
+	// OpHash256 hashes the top stack item with SHA-256 twice.
+	OpHash256 opCode = 0xaa
+
+	// OpCodeSeparator marks the start of the script covered by signatures.
+	OpCodeSeparator opCode = 0xab
+
+	// OpCheckSig checks a signature against a public key.
+	OpCheckSig opCode = 0xac
+
+	// OpCheckSigVerify runs OpCheckSig and then OpVerify in sequence.
+	OpCheckSigVerify opCode = 0xad
+
+	// OpCheckMultiSig checks m signatures against n public keys.
+	OpCheckMultiSig opCode = 0xae
+
+	// OpCheckMultiSigVerify runs OpCheckMultiSig and then OpVerify in
+	// sequence.
+	OpCheckMultiSigVerify opCode = 0xaf
+)
+
+// Expansion opcodes.
+const (
+	// OpNop1 does nothing.
+	OpNop1 opCode = 0xb0
+
+	// OpCheckLockTimeVerify (formerly OpNop2) checks the lock time of the
+	// spending transaction.
+	OpCheckLockTimeVerify opCode = 0xb1
+
+	// OpNop2 is an alias for OpCheckLockTimeVerify.
+	OpNop2 = OpCheckLockTimeVerify
+
+	// OpCheckSequenceVerify (formerly OpNop3) checks the relative lock time
+	// of the spending input.
+	OpCheckSequenceVerify opCode = 0xb2
+
+	// OpNop3 is an alias for OpCheckSequenceVerify.
+	OpNop3 = OpCheckSequenceVerify
+
+	// OpNop4 through OpNop10 do nothing.
+	OpNop4  opCode = 0xb3
+	OpNop5  opCode = 0xb4
+	OpNop6  opCode = 0xb5
+	OpNop7  opCode = 0xb6
+	OpNop8  opCode = 0xb7
+	OpNop9  opCode = 0xb8
+	OpNop10 opCode = 0xb9
+
+	// OpCheckSigAdd is valid only in tapscript. It checks a signature like
+	// OpCheckSig and adds the result to the number below the signature.
+	OpCheckSigAdd opCode = 0xba
+
+	// OpInvalidOpCode is not a valid opcode. Bytes 0xbb through 0xfe are
+	// unassigned as well.
+	OpInvalidOpCode opCode = 0xff
+)
+
+// opCodeNames maps each assigned opcode to its name as used by Bitcoin Core.
+var opCodeNames = map[opCode]string{
+	Op0:                   "OP_0",
+	OpPushData1:           "OP_PUSHDATA1",
+	OpPushData2:           "OP_PUSHDATA2",
+	OpPushData4:           "OP_PUSHDATA4",
+	Op1Negate:             "OP_1NEGATE",
+	OpReserved:            "OP_RESERVED",
+	Op1:                   "OP_1",
+	Op2:                   "OP_2",
+	Op3:                   "OP_3",
+	Op4:                   "OP_4",
+	Op5:                   "OP_5",
+	Op6:                   "OP_6",
+	Op7:                   "OP_7",
+	Op8:                   "OP_8",
+	Op9:                   "OP_9",
+	Op10:                  "OP_10",
+	Op11:                  "OP_11",
+	Op12:                  "OP_12",
+	Op13:                  "OP_13",
+	Op14:                  "OP_14",
+	Op15:                  "OP_15",
+	Op16:                  "OP_16",
+	OpNop:                 "OP_NOP",
+	OpVer:                 "OP_VER",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpVerIf:               "OP_VERIF",
+	OpVerNotIf:            "OP_VERNOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpToAltStack:          "OP_TOALTSTACK",
+	OpFromAltStack:        "OP_FROMALTSTACK",
+	Op2Drop:               "OP_2DROP",
+	Op2Dup:                "OP_2DUP",
+	Op3Dup:                "OP_3DUP",
+	Op2Over:               "OP_2OVER",
+	Op2Rot:                "OP_2ROT",
+	Op2Swap:               "OP_2SWAP",
+	OpIfDup:               "OP_IFDUP",
+	OpDepth:               "OP_DEPTH",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpNip:                 "OP_NIP",
+	OpOver:                "OP_OVER",
+	OpPick:                "OP_PICK",
+	OpRoll:                "OP_ROLL",
+	OpRot:                 "OP_ROT",
+	OpSwap:                "OP_SWAP",
+	OpTuck:                "OP_TUCK",
+	OpCat:                 "OP_CAT",
+	OpSubStr:              "OP_SUBSTR",
+	OpLeft:                "OP_LEFT",
+	OpRight:               "OP_RIGHT",
+	OpSize:                "OP_SIZE",
+	OpInvert:              "OP_INVERT",
+	OpAnd:                 "OP_AND",
+	OpOr:                  "OP_OR",
+	OpXor:                 "OP_XOR",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpReserved1:           "OP_RESERVED1",
+	OpReserved2:           "OP_RESERVED2",
+	Op1Add:                "OP_1ADD",
+	Op1Sub:                "OP_1SUB",
+	Op2Mul:                "OP_2MUL",
+	Op2Div:                "OP_2DIV",
+	OpNegate:              "OP_NEGATE",
+	OpAbs:                 "OP_ABS",
+	OpNot:                 "OP_NOT",
+	Op0NotEqual:           "OP_0NOTEQUAL",
+	OpAdd:                 "OP_ADD",
+	OpSub:                 "OP_SUB",
+	OpMul:                 "OP_MUL",
+	OpDiv:                 "OP_DIV",
+	OpMod:                 "OP_MOD",
+	OpLShift:              "OP_LSHIFT",
+	OpRShift:              "OP_RSHIFT",
+	OpBoolAnd:             "OP_BOOLAND",
+	OpBoolOr:              "OP_BOOLOR",
+	OpNumEqual:            "OP_NUMEQUAL",
+	OpNumEqualVerify:      "OP_NUMEQUALVERIFY",
+	OpNumNotEqual:         "OP_NUMNOTEQUAL",
+	OpLessThan:            "OP_LESSTHAN",
+	OpGreaterThan:         "OP_GREATERTHAN",
+	OpLessThanOrEqual:     "OP_LESSTHANOREQUAL",
+	OpGreaterThanOrEqual:  "OP_GREATERTHANOREQUAL",
+	OpMin:                 "OP_MIN",
+	OpMax:                 "OP_MAX",
+	OpWithin:              "OP_WITHIN",
+	OpRipemd160:           "OP_RIPEMD160",
+	OpSha1:                "OP_SHA1",
+	OpSha256:              "OP_SHA256",
+	OpHash160:             "OP_HASH160",
+	OpHash256:             "OP_HASH256",
+	OpCodeSeparator:       "OP_CODESEPARATOR",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpNop1:                "OP_NOP1",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+	OpCheckSequenceVerify: "OP_CHECKSEQUENCEVERIFY",
+	OpNop4:                "OP_NOP4",
+	OpNop5:                "OP_NOP5",
+	OpNop6:                "OP_NOP6",
+	OpNop7:                "OP_NOP7",
+	OpNop8:                "OP_NOP8",
+	OpNop9:                "OP_NOP9",
+	OpNop10:               "OP_NOP10",
+	OpCheckSigAdd:         "OP_CHECKSIGADD",
+	OpInvalidOpCode:       "OP_INVALIDOPCODE",
+}
+
+// String returns the name of the opcode. Direct pushes are named by the
+// number of bytes they push, and unassigned opcodes are named OP_UNKNOWN.
+func (op opCode) String() string {
+	if name, ok := opCodeNames[op]; ok {
+		return name
+	}
+	if op >= OpData1 && op <= OpData75 {
+		return fmt.Sprintf("OP_DATA_%d", int(op))
+	}
+	return "OP_UNKNOWN"
+}
+
+// isDataPush returns whether op pushes data embedded in the script, which
+// includes Op0 pushing an empty byte array.
+func (op opCode) isDataPush() bool {
+	return op <= OpPushData4
+}
+
+// isSmallInt returns whether op pushes one of the numbers -1 or 1 through 16.
+func (op opCode) isSmallInt() bool {
+	return op == Op1Negate || (op >= Op1 && op <= Op16)
+}
+
+// isConditional returns whether op is a flow control opcode which is
+// processed even in branches that are not executed.
+func (op opCode) isConditional() bool {
 	switch op {
-	case OpDup:
-		// Attempt to pop top item from the stack
-		data, err := s.Pop()
-		if err != nil {
-			return err
-		}
-
-		// Duplicate the popped item and push both onto the stack
-		dup := make([]byte, len(data))
-		copy(dup, data)
-		s.Push(data)
-		s.Push(dup)
-	case OpHash160:
-		// Attempt to pop top item from stack
-		data, err := s.Pop()
-		if err != nil {
-			return err
-		}
-
-		// Perform SHA256 hash followed by RIPEMD-160 and push result
-		// onto the stack
-		hash := hashing.Hash160(data)
-		s.Push(hash)
-	case OpEqual:
-		// Attempt to pop top two items from the stack
-		d0, err := s.Pop()
-		if err != nil {
-			return err
-		}
-		d1, err := s.Pop()
-		if err != nil {
-			return err
-		}
-
-		// If the items are not equal, push a 0 (false) onto the stack.
-		// Otherwise, push a 1 (true) onto the stack
-		if bytes.Equal(d0, d1) == false {
-			s.Push([]byte{0})
-		} else {
-			s.Push([]byte{1})
-		}
-	case OpVerify:
-		// Attempt to remove the top item from the stack
-		data, err := s.Pop()
-		if err != nil {
-			return err
-		}
-		// If the item equals false, terminate the script in error
-		if bytes.Equal(data, []byte{0}) {
-			return err
-		}
-	case OpEqualVerify:
-		err := OpEqual.executeOp(s)
-		if err != nil {
-			return err
-		}
-		err = OpVerify.executeOp(s)
-		if err != nil {
-			return err
-		}
+	case OpIf, OpNotIf, OpElse, OpEndIf:
+		return true
 	}
+	return false
+}
+
+// isDisabled returns whether op is one of the opcodes disabled in response
+// to CVE-2010-5137. Disabled opcodes fail the script even when they are not
+// executed.
+func (op opCode) isDisabled() bool {
+	switch op {
+	case OpCat, OpSubStr, OpLeft, OpRight, OpInvert, OpAnd, OpOr, OpXor,
+		Op2Mul, Op2Div, OpMul, OpDiv, OpMod, OpLShift, OpRShift:
+		return true
+	}
+	return false
+}
+
+// isAlwaysIllegal returns whether op fails the script even when it is not
+// executed.
+func (op opCode) isAlwaysIllegal() bool {
+	return op == OpVerIf || op == OpVerNotIf
+}
 
-	return nil
+// isSuccess returns whether op is an OP_SUCCESSx opcode in tapscript. A
+// tapscript containing one succeeds without being executed, which reserves
+// these opcodes for future soft forks.
+func (op opCode) isSuccess() bool {
+	switch {
+	case op == OpReserved, op == OpVer:
+		return true
+	case op >= OpCat && op <= OpRight:
+		return true
+	case op >= OpInvert && op <= OpXor:
+		return true
+	case op == OpReserved1, op == OpReserved2:
+		return true
+	case op == Op2Mul, op == Op2Div:
+		return true
+	case op >= OpMul && op <= OpRShift:
+		return true
+	}
+	return op >= 0xbb && op <= 0xfe
 }
@@ -0,0 +1,453 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"golang.org/x/crypto/ripemd160"
+)
+
+var (
+	// ErrVerify is the result of an OpVerify which finds a false value on
+	// top of the stack.
+	ErrVerify = scriptError(ErrCodeVerify, "verify failed")
+
+	// ErrEqualVerify is the result of an OpEqualVerify whose operands are
+	// not equal.
+	ErrEqualVerify = scriptError(ErrCodeEqualVerify, "OP_EQUALVERIFY failed")
+
+	// ErrNumEqualVerify is the result of an OpNumEqualVerify whose operands
+	// are not equal.
+	ErrNumEqualVerify = scriptError(ErrCodeNumEqualVerify, "OP_NUMEQUALVERIFY failed")
+
+	// ErrCheckSigVerify is the result of an OpCheckSigVerify with an
+	// invalid signature.
+	ErrCheckSigVerify = scriptError(ErrCodeCheckSigVerify, "OP_CHECKSIGVERIFY failed")
+
+	// ErrCheckMultiSigVerify is the result of an OpCheckMultiSigVerify with
+	// invalid signatures.
+	ErrCheckMultiSigVerify = scriptError(ErrCodeCheckMultiSigVerify, "OP_CHECKMULTISIGVERIFY failed")
+
+	// ErrDiscourageUpgradableNops is the result of executing an OpNop
+	// reserved for future soft forks with ScriptDiscourageUpgradableNops.
+	ErrDiscourageUpgradableNops = scriptError(ErrCodeDiscourageUpgradableNops, "upgradable NOP executed")
+
+	// ErrEarlyReturn is the result of executing OpReturn.
+	ErrEarlyReturn = scriptError(ErrCodeEarlyReturn, "script returned early")
+
+	// ErrDisabledOpcode is the result of a script containing a disabled
+	// opcode.
+	ErrDisabledOpcode = scriptError(ErrCodeDisabledOpcode, "disabled opcode")
+
+	// ErrReservedOpcode is the result of executing a reserved opcode, or of a
+	// script containing OpVerIf or OpVerNotIf.
+	ErrReservedOpcode = scriptError(ErrCodeReservedOpcode, "reserved opcode")
+
+	// ErrInvalidOpcode is the result of executing an unassigned opcode.
+	ErrInvalidOpcode = scriptError(ErrCodeInvalidOpcode, "invalid opcode")
+
+	// ErrInvalidAltStackOperation is the result of an OpFromAltStack with an
+	// empty alt stack.
+	ErrInvalidAltStackOperation = scriptError(ErrCodeInvalidAltStackOperation, "not enough items on the alt stack")
+)
+
+// execute executes the operation specified by the opcode. Data pushes push
+// data, which must be the data embedded in the script for op.
+func (op opCode) execute(data []byte, vm *Engine) error {
+	s := &vm.dstack
+
+	switch {
+	case op.isDataPush():
+		s.Push(data)
+		return nil
+
+	case op == Op1Negate:
+		s.PushInt(-1)
+		return nil
+
+	case op >= Op1 && op <= Op16:
+		s.PushInt(scriptNum(op-Op1) + 1)
+		return nil
+	}
+
+	switch op {
+	// Flow control.
+	case OpNop:
+		return nil
+
+	case OpNop1, OpNop4, OpNop5, OpNop6, OpNop7, OpNop8, OpNop9, OpNop10:
+		return vm.executeUpgradableNop()
+
+	case OpCheckLockTimeVerify:
+		return opCheckLockTimeVerify(vm)
+
+	case OpCheckSequenceVerify:
+		return opCheckSequenceVerify(vm)
+
+	case OpIf, OpNotIf:
+		cond := condSkip
+		if vm.isBranchExecuting() {
+			// A conditional without an argument can never be
+			// balanced.
+			if s.Depth() == 0 {
+				return ErrUnbalancedConditional
+			}
+
+			// Tapscripts, and witness scripts under policy, require
+			// the argument to be exactly true or false so that it
+			// cannot be malleated.
+			if vm.isTapscript() || (vm.witnessExec &&
+				vm.flags.HasFlag(ScriptVerifyMinimalIf)) {
+				d, err := s.Peek(0)
+				if err != nil {
+					return err
+				}
+				if len(d) > 1 || (len(d) == 1 && d[0] != 1) {
+					return ErrMinimalIf
+				}
+			}
+
+			ok, err := s.PopBool()
+			if err != nil {
+				return err
+			}
+			if op == OpNotIf {
+				ok = !ok
+			}
+			cond = condFalse
+			if ok {
+				cond = condTrue
+			}
+		}
+		vm.condStack = append(vm.condStack, cond)
+		return nil
+
+	case OpElse:
+		n := len(vm.condStack)
+		if n == 0 {
+			return ErrUnbalancedConditional
+		}
+		switch vm.condStack[n-1] {
+		case condTrue:
+			vm.condStack[n-1] = condFalse
+		case condFalse:
+			vm.condStack[n-1] = condTrue
+		}
+		return nil
+
+	case OpEndIf:
+		n := len(vm.condStack)
+		if n == 0 {
+			return ErrUnbalancedConditional
+		}
+		vm.condStack = vm.condStack[:n-1]
+		return nil
+
+	case OpVerify:
+		return verify(op, s)
+
+	case OpReturn:
+		return ErrEarlyReturn
+
+	// Stack operations.
+	case OpToAltStack:
+		d, err := s.Pop()
+		if err != nil {
+			return err
+		}
+		vm.astack.Push(d)
+		return nil
+
+	case OpFromAltStack:
+		d, err := vm.astack.Pop()
+		if err != nil {
+			return ErrInvalidAltStackOperation
+		}
+		s.Push(d)
+		return nil
+
+	case Op2Drop:
+		return s.dropN(2)
+
+	case Op2Dup:
+		return s.dupN(2)
+
+	case Op3Dup:
+		return s.dupN(3)
+
+	case Op2Over:
+		return s.overN(2)
+
+	case Op2Rot:
+		return s.rotN(2)
+
+	case Op2Swap:
+		return s.swapN(2)
+
+	case OpIfDup:
+		d, err := s.Peek(0)
+		if err != nil {
+			return err
+		}
+		if castToBool(d) {
+			s.Push(d)
+		}
+		return nil
+
+	case OpDepth:
+		s.PushInt(scriptNum(s.Depth()))
+		return nil
+
+	case OpDrop:
+		return s.dropN(1)
+
+	case OpDup:
+		return s.dupN(1)
+
+	case OpNip:
+		_, err := s.nipN(1)
+		return err
+
+	case OpOver:
+		return s.overN(1)
+
+	case OpPick, OpRoll:
+		n, err := s.PopInt()
+		if err != nil {
+			return err
+		}
+		if n < 0 || n >= scriptNum(s.Depth()) {
+			return ErrInvalidStackOperation
+		}
+		if op == OpPick {
+			return s.pickN(int(n))
+		}
+		return s.rollN(int(n))
+
+	case OpRot:
+		return s.rotN(1)
+
+	case OpSwap:
+		return s.swapN(1)
+
+	case OpTuck:
+		return s.tuck()
+
+	// Splice operations.
+	case OpSize:
+		d, err := s.Peek(0)
+		if err != nil {
+			return err
+		}
+		s.PushInt(scriptNum(len(d)))
+		return nil
+
+	// Bitwise logic.
+	case OpEqual, OpEqualVerify:
+		d0, err := s.Pop()
+		if err != nil {
+			return err
+		}
+		d1, err := s.Pop()
+		if err != nil {
+			return err
+		}
+		s.PushBool(bytes.Equal(d0, d1))
+		if op == OpEqualVerify {
+			return verify(op, s)
+		}
+		return nil
+
+	// Arithmetic.
+	case Op1Add, Op1Sub, OpNegate, OpAbs, OpNot, Op0NotEqual:
+		return executeUnaryArithmetic(op, s)
+
+	case OpAdd, OpSub, OpBoolAnd, OpBoolOr, OpNumEqual, OpNumEqualVerify,
+		OpNumNotEqual, OpLessThan, OpGreaterThan, OpLessThanOrEqual,
+		OpGreaterThanOrEqual, OpMin, OpMax:
+		return executeBinaryArithmetic(op, s)
+
+	case OpWithin:
+		max, err := s.PopInt()
+		if err != nil {
+			return err
+		}
+		min, err := s.PopInt()
+		if err != nil {
+			return err
+		}
+		x, err := s.PopInt()
+		if err != nil {
+			return err
+		}
+		s.PushBool(min <= x && x < max)
+		return nil
+
+	// Crypto.
+	case OpRipemd160, OpSha1, OpSha256, OpHash160, OpHash256:
+		d, err := s.Pop()
+		if err != nil {
+			return err
+		}
+		s.Push(hashData(op, d))
+		return nil
+
+	case OpCodeSeparator:
+		vm.lastCodeSep = vm.opIdx + 1
+		if vm.isTapscript() {
+			vm.taproot.codeSepPos = uint32(vm.opIdx)
+		}
+		return nil
+
+	case OpCheckSig, OpCheckSigVerify:
+		return opCheckSig(op, vm)
+
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		return opCheckMultiSig(op, vm)
+
+	case OpCheckSigAdd:
+		return opCheckSigAdd(vm)
+
+	// Reserved opcodes fail the script only when executed.
+	case OpReserved, OpVer, OpReserved1, OpReserved2:
+		return ErrReservedOpcode
+	}
+
+	return ErrInvalidOpcode
+}
+
+// verify removes the top item from s and returns an error if it is false.
+// The error identifies op, which is OpVerify or an opcode ending in VERIFY.
+func verify(op opCode, s *Stack) error {
+	ok, err := s.PopBool()
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	switch op {
+	case OpEqualVerify:
+		return ErrEqualVerify
+	case OpNumEqualVerify:
+		return ErrNumEqualVerify
+	case OpCheckSigVerify:
+		return ErrCheckSigVerify
+	case OpCheckMultiSigVerify:
+		return ErrCheckMultiSigVerify
+	}
+	return ErrVerify
+}
+
+// executeUpgradableNop executes an OpNop reserved for future soft forks,
+// which fails the script with ScriptDiscourageUpgradableNops.
+func (vm *Engine) executeUpgradableNop() error {
+	if vm.flags.HasFlag(ScriptDiscourageUpgradableNops) {
+		return ErrDiscourageUpgradableNops
+	}
+	return nil
+}
+
+// executeUnaryArithmetic executes an arithmetic opcode which takes a single
+// number operand.
+func executeUnaryArithmetic(op opCode, s *Stack) error {
+	n, err := s.PopInt()
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case Op1Add:
+		n++
+	case Op1Sub:
+		n--
+	case OpNegate:
+		n = -n
+	case OpAbs:
+		if n < 0 {
+			n = -n
+		}
+	case OpNot:
+		s.PushBool(n == 0)
+		return nil
+	case Op0NotEqual:
+		s.PushBool(n != 0)
+		return nil
+	}
+
+	s.PushInt(n)
+	return nil
+}
+
+// executeBinaryArithmetic executes an arithmetic opcode which takes two
+// number operands. The top stack item is the second operand.
+func executeBinaryArithmetic(op opCode, s *Stack) error {
+	b, err := s.PopInt()
+	if err != nil {
+		return err
+	}
+	a, err := s.PopInt()
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case OpAdd:
+		s.PushInt(a + b)
+	case OpSub:
+		s.PushInt(a - b)
+	case OpBoolAnd:
+		s.PushBool(a != 0 && b != 0)
+	case OpBoolOr:
+		s.PushBool(a != 0 || b != 0)
+	case OpNumEqual:
+		s.PushBool(a == b)
+	case OpNumEqualVerify:
+		s.PushBool(a == b)
+		return verify(op, s)
+	case OpNumNotEqual:
+		s.PushBool(a != b)
+	case OpLessThan:
+		s.PushBool(a < b)
+	case OpGreaterThan:
+		s.PushBool(a > b)
+	case OpLessThanOrEqual:
+		s.PushBool(a <= b)
+	case OpGreaterThanOrEqual:
+		s.PushBool(a >= b)
+	case OpMin:
+		if b < a {
+			a = b
+		}
+		s.PushInt(a)
+	case OpMax:
+		if b > a {
+			a = b
+		}
+		s.PushInt(a)
+	}
+	return nil
+}
+
+// hashData hashes d with the hash function of the crypto opcode op.
+func hashData(op opCode, d []byte) []byte {
+	switch op {
+	case OpRipemd160:
+		h := ripemd160.New()
+		h.Write(d)
+		return h.Sum(nil)
+	case OpSha1:
+		h := sha1.Sum(d)
+		return h[:]
+	case OpSha256:
+		h := sha256.Sum256(d)
+		return h[:]
+	case OpHash160:
+		return hashing.Hash160(d)
+	default:
+		return hashing.DoubleSHA256B(d)
+	}
+}
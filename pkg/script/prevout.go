@@ -0,0 +1,51 @@
+package script
+
+import "github.com/jacobkaufmann/gocoin/pkg/protocol"
+
+// A PrevOutputFetcher returns the output spent by an outpoint, or nil if the
+// output is unknown. Taproot signatures commit to the amounts and locking
+// scripts of every output spent by a transaction.
+type PrevOutputFetcher interface {
+	FetchPrevOutput(outPoint protocol.TxOutPoint) *protocol.TxOut
+}
+
+// outPointKey is the comparable form of a protocol.TxOutPoint, whose hash is
+// a pointer.
+type outPointKey struct {
+	hash  [protocol.HashSize]byte
+	index uint32
+}
+
+// newOutPointKey returns the key of outPoint.
+func newOutPointKey(outPoint protocol.TxOutPoint) outPointKey {
+	key := outPointKey{index: outPoint.Index}
+	if outPoint.Hash != nil {
+		key.hash = *outPoint.Hash
+	}
+	return key
+}
+
+// A MultiPrevOutFetcher is a PrevOutputFetcher backed by a map of outputs.
+type MultiPrevOutFetcher struct {
+	prevOuts map[outPointKey]*protocol.TxOut
+}
+
+// NewMultiPrevOutFetcher returns an empty MultiPrevOutFetcher.
+func NewMultiPrevOutFetcher() *MultiPrevOutFetcher {
+	return &MultiPrevOutFetcher{
+		prevOuts: make(map[outPointKey]*protocol.TxOut),
+	}
+}
+
+// AddPrevOut adds the output txOut spent by outPoint.
+func (f *MultiPrevOutFetcher) AddPrevOut(outPoint protocol.TxOutPoint,
+	txOut *protocol.TxOut) {
+	f.prevOuts[newOutPointKey(outPoint)] = txOut
+}
+
+// FetchPrevOutput returns the output spent by outPoint, or nil if it was not
+// added.
+func (f *MultiPrevOutFetcher) FetchPrevOutput(
+	outPoint protocol.TxOutPoint) *protocol.TxOut {
+	return f.prevOuts[newOutPointKey(outPoint)]
+}
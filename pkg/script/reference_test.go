@@ -0,0 +1,276 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// The reference tests in testdata come from Bitcoin Core, whose names for
+// verification flags and script errors differ from those of this package.
+
+// coreFlags maps the names of Bitcoin Core's verification flags to flags.
+var coreFlags = map[string]ScriptFlags{
+	"NONE":                       ScriptVerifyNone,
+	"P2SH":                       ScriptVerifyP2SH,
+	"STRICTENC":                  ScriptVerifyStrictEncoding,
+	"DERSIG":                     ScriptVerifyDERSignatures,
+	"LOW_S":                      ScriptVerifyLowS,
+	"NULLDUMMY":                  ScriptVerifyNullDummy,
+	"SIGPUSHONLY":                ScriptVerifySigPushOnly,
+	"MINIMALDATA":                ScriptVerifyMinimalData,
+	"CLEANSTACK":                 ScriptVerifyCleanStack,
+	"CHECKLOCKTIMEVERIFY":        ScriptVerifyCheckLockTimeVerify,
+	"CHECKSEQUENCEVERIFY":        ScriptVerifyCheckSequenceVerify,
+	"WITNESS":                    ScriptVerifyWitness,
+	"TAPROOT":                    ScriptVerifyTaproot,
+	"DISCOURAGE_UPGRADABLE_NOPS": ScriptDiscourageUpgradableNops,
+	"MINIMALIF":                  ScriptVerifyMinimalIf,
+	"WITNESS_PUBKEYTYPE":         ScriptVerifyWitnessPubKeyType,
+}
+
+// coreErrors maps the names of Bitcoin Core's script errors to the error
+// codes which correspond to them. Errors missing from the map, such as
+// UNKNOWN_ERROR, are only checked to be failures.
+var coreErrors = map[string][]ErrorCode{
+	"EVAL_FALSE":                    {ErrCodeEvalFalse},
+	"OP_RETURN":                     {ErrCodeEarlyReturn},
+	"UNBALANCED_CONDITIONAL":        {ErrCodeUnbalancedConditional},
+	"SCRIPT_SIZE":                   {ErrCodeScriptSize},
+	"OP_COUNT":                      {ErrCodeOpCount},
+	"STACK_SIZE":                    {ErrCodeStackSize},
+	"PUSH_SIZE":                     {ErrCodeElementSize},
+	"BAD_OPCODE":                    {ErrCodeMalformedPush, ErrCodeReservedOpcode, ErrCodeInvalidOpcode},
+	"DISABLED_OPCODE":               {ErrCodeDisabledOpcode},
+	"MINIMALDATA":                   {ErrCodeMinimalData},
+	"DISCOURAGE_UPGRADABLE_NOPS":    {ErrCodeDiscourageUpgradableNops},
+	"INVALID_STACK_OPERATION":       {ErrCodePopFromEmptyStack, ErrCodeInvalidStackOperation},
+	"INVALID_ALTSTACK_OPERATION":    {ErrCodeInvalidAltStackOperation},
+	"MINIMALIF":                     {ErrCodeMinimalIf},
+	"VERIFY":                        {ErrCodeVerify},
+	"EQUALVERIFY":                   {ErrCodeEqualVerify},
+	"NUMEQUALVERIFY":                {ErrCodeNumEqualVerify},
+	"CHECKSIGVERIFY":                {ErrCodeCheckSigVerify},
+	"CHECKMULTISIGVERIFY":           {ErrCodeCheckMultiSigVerify},
+	"PUBKEY_COUNT":                  {ErrCodeInvalidPubKeyCount},
+	"SIG_COUNT":                     {ErrCodeInvalidSignatureCount},
+	"SIG_HASHTYPE":                  {ErrCodeSigHashType},
+	"SIG_DER":                       {ErrCodeSigDER},
+	"SIG_HIGH_S":                    {ErrCodeSigHighS},
+	"SIG_NULLDUMMY":                 {ErrCodeSigNullDummy},
+	"PUBKEYTYPE":                    {ErrCodePubKeyType},
+	"NEGATIVE_LOCKTIME":             {ErrCodeNegativeLockTime},
+	"UNSATISFIED_LOCKTIME":          {ErrCodeUnsatisfiedLockTime},
+	"SIG_PUSHONLY":                  {ErrCodeSigPushOnly},
+	"CLEANSTACK":                    {ErrCodeCleanStack},
+	"WITNESS_PROGRAM_WRONG_LENGTH":  {ErrCodeWitnessProgramWrongLength},
+	"WITNESS_PROGRAM_WITNESS_EMPTY": {ErrCodeWitnessProgramEmpty},
+	"WITNESS_PROGRAM_MISMATCH":      {ErrCodeWitnessProgramMismatch},
+	"WITNESS_MALLEATED":             {ErrCodeWitnessMalleated},
+	"WITNESS_MALLEATED_P2SH":        {ErrCodeWitnessMalleatedP2SH},
+	"WITNESS_UNEXPECTED":            {ErrCodeWitnessUnexpected},
+	"WITNESS_PUBKEYTYPE":            {ErrCodeWitnessPubKeyType},
+}
+
+// parseCoreFlags returns the flags named by the comma-separated list s and
+// whether every flag in it is supported.
+func parseCoreFlags(s string) (ScriptFlags, bool) {
+	var flags ScriptFlags
+	supported := true
+	for _, name := range strings.Split(s, ",") {
+		if name == "" {
+			continue
+		}
+		flag, ok := coreFlags[name]
+		if !ok {
+			supported = false
+			continue
+		}
+		flags |= flag
+	}
+	return flags, supported
+}
+
+// readTestData decodes the JSON array in the file name of testdata into a
+// slice of test entries, dropping those which are comments: entries with a
+// single string.
+func readTestData(t *testing.T, name string) [][]interface{} {
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries [][]interface{}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		t.Fatal(err)
+	}
+	tests := entries[:0]
+	for _, entry := range entries {
+		if len(entry) > 1 {
+			tests = append(tests, entry)
+		}
+	}
+	return tests
+}
+
+// creditingTx returns the transaction whose only output, paying amount to
+// scriptPubKey, is spent by the script tests.
+func creditingTx(scriptPubKey []byte, amount int64) *protocol.MsgTx {
+	return &protocol.MsgTx{
+		Version: 1,
+		Inputs: []*protocol.TxIn{{
+			PrevOutput: protocol.TxOutPoint{
+				Hash:  &[protocol.HashSize]byte{},
+				Index: math.MaxUint32,
+			},
+			ScriptUnlock:     []byte{byte(Op0), byte(Op0)},
+			ScriptUnlockSize: 2,
+			Sequence:         maxTxInSequenceNum,
+		}},
+		Outputs: []*protocol.TxOut{{
+			Value:          amount,
+			ScriptLock:     scriptPubKey,
+			ScriptLockSize: uint64(len(scriptPubKey)),
+		}},
+	}
+}
+
+// spendingTx returns the transaction which spends the output of crediting
+// with scriptSig and witness.
+func spendingTx(t *testing.T, crediting *protocol.MsgTx, scriptSig []byte,
+	witness protocol.TxWitness) *protocol.MsgTx {
+	var buf bytes.Buffer
+	if err := crediting.SerializeNoWitness(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	hash := [protocol.HashSize]byte(hashing.DoubleSHA256H(buf.Bytes()))
+	return &protocol.MsgTx{
+		Version: 1,
+		Inputs: []*protocol.TxIn{{
+			PrevOutput:       protocol.TxOutPoint{Hash: &hash, Index: 0},
+			ScriptUnlock:     scriptSig,
+			ScriptUnlockSize: uint64(len(scriptSig)),
+			Witness:          witness,
+			Sequence:         maxTxInSequenceNum,
+		}},
+		Outputs: []*protocol.TxOut{{Value: crediting.Outputs[0].Value}},
+	}
+}
+
+// TestScripts runs the script tests of Bitcoin Core. Each test spends an
+// output with the given scripts, and possibly a witness, under the given
+// flags. Tests which expect a failure under a flag this package does not
+// implement are skipped, and such flags are dropped from tests which expect
+// success, since no flag makes a failing script succeed.
+func TestScripts(t *testing.T) {
+	var run int
+	for i, test := range readTestData(t, "script_tests.json") {
+		name := fmt.Sprintf("test #%d %v", i, test)
+
+		var witness protocol.TxWitness
+		var amount int64
+		if items, ok := test[0].([]interface{}); ok {
+			for _, item := range items[:len(items)-1] {
+				b, err := hex.DecodeString(item.(string))
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				witness = append(witness, b)
+			}
+			amount = int64(math.Round(items[len(items)-1].(float64) * 1e8))
+			test = test[1:]
+		}
+
+		// As in Bitcoin Core's test runner, ScriptVerifyCleanStack
+		// implies the flags it requires.
+		flags, supported := parseCoreFlags(test[2].(string))
+		if flags.HasFlag(ScriptVerifyCleanStack) {
+			flags |= ScriptVerifyP2SH | ScriptVerifyWitness
+		}
+		want := test[3].(string)
+		if !supported && want != "OK" {
+			continue
+		}
+		run++
+
+		// The builder refuses pushes and scripts over the size limits,
+		// so such scripts fail to assemble rather than to execute.
+		scriptSig, err := Assemble(test[0].(string))
+		var scriptPubKey []byte
+		if err == nil {
+			scriptPubKey, err = Assemble(test[1].(string))
+		}
+		if err == nil {
+			crediting := creditingTx(scriptPubKey, amount)
+			spending := spendingTx(t, crediting, scriptSig, witness)
+			err = VerifyScript(scriptSig, scriptPubKey, witness, spending,
+				0, amount, flags)
+		}
+		switch codes, ok := coreErrors[want]; {
+		case want == "OK":
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", name, err)
+			}
+		case err == nil:
+			t.Errorf("%s: succeeded, want %s", name, want)
+		case ok && !hasErrorCode(err, codes):
+			t.Errorf("%s: got %v, want %s", name, err, want)
+		}
+	}
+	if run == 0 {
+		t.Fatal("no script tests run")
+	}
+}
+
+// hasErrorCode returns whether err has one of codes.
+func hasErrorCode(err error, codes []ErrorCode) bool {
+	for _, code := range codes {
+		if IsErrorCode(err, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestSigHash checks the legacy signature hashes of the transactions in
+// Bitcoin Core's signature hash tests, which are given with their bytes
+// reversed.
+func TestSigHash(t *testing.T) {
+	for i, test := range readTestData(t, "sighash.json") {
+		name := fmt.Sprintf("test #%d", i)
+		rawTx, err := hex.DecodeString(test[0].(string))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		tx := new(protocol.MsgTx)
+		if err := tx.Deserialize(bytes.NewReader(rawTx), 0); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		script, err := hex.DecodeString(test[1].(string))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		idx := int(test[2].(float64))
+		hashType := SigHashType(uint32(int32(test[3].(float64))))
+		want, err := hashing.NewHashFromStr(test[4].(string))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		hash, err := CalcSignatureHash(script, hashType, tx, idx)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(hash, want[:]) {
+			t.Errorf("%s: got %x, want %x", name, hash, want[:])
+		}
+	}
+}
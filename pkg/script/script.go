@@ -2,17 +2,43 @@ package script
 
 // A Script is a sequence of Bitcoin scripting language instructions.
 type Script struct {
-	dataStack    Stack
 	instructions []instruction
 }
 
-// Execute executes the instructions in a script.
-func (s Script) Execute() error {
-	for _, i := range s.instructions {
-		err := i.Execute()
-		if err != nil {
-			return err
-		}
+// Parse tokenizes a raw script, such as a TxOut.ScriptLock or a
+// TxIn.ScriptUnlock, into a Script. An error is returned if a push operation
+// claims more data than remains in the script.
+func Parse(b []byte) (*Script, error) {
+	s := &Script{}
+
+	t := NewTokenizer(b)
+	for t.Next() {
+		s.instructions = append(s.instructions, instruction{
+			op:   opCode(t.Opcode()),
+			data: t.Data(),
+		})
+	}
+	if err := t.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Bytes returns the serialized form of the script. A script returned by Parse
+// serializes to the bytes it was parsed from.
+func (s *Script) Bytes() []byte {
+	var b []byte
+	for i := range s.instructions {
+		b = append(b, s.instructions[i].bytes()...)
 	}
-	return nil
+	return b
+}
+
+// Execute executes the instructions in a script on an empty stack. An error
+// is returned if the script fails or leaves a false value on top of the
+// stack.
+func (s *Script) Execute() error {
+	vm := &Engine{scripts: []*Script{s}}
+	return vm.Execute()
 }
@@ -0,0 +1,383 @@
+package script
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// SigHashType represents the hash type suffixed to a signature, which
+// selects the parts of the spending transaction covered by the signature.
+type SigHashType uint32
+
+// Signature hash types.
+const (
+	// SigHashOld is the hash type of some historical signatures. It is
+	// treated as SigHashAll.
+	SigHashOld SigHashType = 0x0
+
+	// SigHashDefault is the hash type of a 64-byte taproot signature, which
+	// omits the hash type byte. It signs the same parts as SigHashAll.
+	SigHashDefault SigHashType = 0x0
+
+	// SigHashAll signs all inputs and outputs.
+	SigHashAll SigHashType = 0x1
+
+	// SigHashNone signs all inputs and no outputs.
+	SigHashNone SigHashType = 0x2
+
+	// SigHashSingle signs all inputs and the output with the same index as
+	// the input being signed.
+	SigHashSingle SigHashType = 0x3
+
+	// SigHashAnyOneCanPay is a modifier which signs only the input being
+	// signed rather than all inputs.
+	SigHashAnyOneCanPay SigHashType = 0x80
+
+	// sigHashMask selects the base hash type, without modifiers.
+	sigHashMask = 0x1f
+)
+
+// legacySigHashProtocolVersion is the protocol version used to serialize
+// transactions for the legacy signature hash.
+const legacySigHashProtocolVersion = 0
+
+// sigHashSingleBug is the hash signed by a SigHashSingle signature for an
+// input without a corresponding output. The original software returned the
+// number one instead of an error, so the value is part of consensus.
+var sigHashSingleBug = []byte{
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// CalcSignatureHash returns the legacy (pre-segwit) signature hash of input
+// idx of tx for hashType, where script is the script being executed from its
+// last executed OpCodeSeparator onwards. Any OpCodeSeparator in script is
+// removed before hashing; the caller is responsible for removing the
+// signature itself from script.
+func CalcSignatureHash(script []byte, hashType SigHashType,
+	tx *protocol.MsgTx, idx int) ([]byte, error) {
+	if idx < 0 || idx >= len(tx.Inputs) {
+		return nil, ErrInvalidInputIndex
+	}
+
+	if hashType&sigHashMask == SigHashSingle && idx >= len(tx.Outputs) {
+		return sigHashSingleBug, nil
+	}
+
+	script, err := removeOpCode(script, OpCodeSeparator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build a modified copy of the transaction with the unlocking scripts of
+	// all inputs cleared except the one being signed, which is replaced by
+	// the script being executed.
+	txCopy := *tx
+	txCopy.Inputs = make([]*protocol.TxIn, len(tx.Inputs))
+	for i, in := range tx.Inputs {
+		inCopy := *in
+		inCopy.ScriptUnlock = nil
+		if i == idx {
+			inCopy.ScriptUnlock = script
+		}
+		inCopy.ScriptUnlockSize = uint64(len(inCopy.ScriptUnlock))
+		txCopy.Inputs[i] = &inCopy
+	}
+	txCopy.Outputs = make([]*protocol.TxOut, len(tx.Outputs))
+	copy(txCopy.Outputs, tx.Outputs)
+
+	switch hashType & sigHashMask {
+	case SigHashNone:
+		txCopy.Outputs = txCopy.Outputs[:0]
+		zeroOtherSequences(&txCopy, idx)
+
+	case SigHashSingle:
+		// Outputs before the signed one are blanked, and those after it
+		// are dropped.
+		txCopy.Outputs = txCopy.Outputs[:idx+1]
+		for i := 0; i < idx; i++ {
+			txCopy.Outputs[i] = &protocol.TxOut{Value: -1}
+		}
+		zeroOtherSequences(&txCopy, idx)
+	}
+
+	if hashType&SigHashAnyOneCanPay != 0 {
+		txCopy.Inputs = txCopy.Inputs[idx : idx+1]
+	}
+
+	hasher := hashing.NewDoubleSHA256Hasher()
+	err = txCopy.SerializeNoWitness(hasher, legacySigHashProtocolVersion)
+	if err != nil {
+		return nil, err
+	}
+	var ht [4]byte
+	binary.LittleEndian.PutUint32(ht[:], uint32(hashType))
+	hasher.Write(ht[:])
+
+	hash := hasher.Sum()
+	return hash[:], nil
+}
+
+// zeroOtherSequences sets the sequence of every input of tx except idx to
+// zero so that the other inputs may be updated without invalidating the
+// signature.
+func zeroOtherSequences(tx *protocol.MsgTx, idx int) {
+	for i, in := range tx.Inputs {
+		if i != idx {
+			in.Sequence = 0
+		}
+	}
+}
+
+// removeOpCode returns script with every occurrence of op removed.
+func removeOpCode(script []byte, op opCode) ([]byte, error) {
+	result := make([]byte, 0, len(script))
+
+	t := NewTokenizer(script)
+	prev := 0
+	for t.Next() {
+		if opCode(t.Opcode()) != op {
+			result = append(result, script[prev:t.ByteIndex()]...)
+		}
+		prev = t.ByteIndex()
+	}
+	if err := t.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// findAndDelete returns script with every push of data removed, where the
+// push is encoded as it would be by the original software. Only matches which
+// start on an opcode boundary are removed. Legacy signature hashing removes a
+// signature from the script it signs this way.
+func findAndDelete(script, data []byte) []byte {
+	target := pushDataBytes(data)
+	result := make([]byte, 0, len(script))
+
+	pc := 0
+	for pc < len(script) {
+		// Skip any number of consecutive matches at this boundary.
+		for bytes.HasPrefix(script[pc:], target) {
+			pc += len(target)
+		}
+		if pc >= len(script) {
+			break
+		}
+
+		t := NewTokenizer(script[pc:])
+		if !t.Next() {
+			// Keep a malformed remainder as is.
+			result = append(result, script[pc:]...)
+			break
+		}
+		result = append(result, script[pc:pc+t.ByteIndex()]...)
+		pc += t.ByteIndex()
+	}
+	return result
+}
+
+// pushDataBytes returns the serialization of a push of data using the
+// smallest direct or OpPushData push which fits it. Unlike a canonical push,
+// small numbers are not replaced by their opcode.
+func pushDataBytes(data []byte) []byte {
+	instr := instruction{data: data}
+
+	n := len(data)
+	switch {
+	case n <= int(OpData75):
+		instr.op = opCode(n)
+	case n <= 0xff:
+		instr.op = OpPushData1
+	case n <= 0xffff:
+		instr.op = OpPushData2
+	default:
+		instr.op = OpPushData4
+	}
+	return instr.bytes()
+}
+
+// TxSigHashes holds the intermediate hashes of a transaction which are shared
+// by the witness signature hashes of all of its inputs, as specified by
+// BIP143 and BIP341. Caching them makes verifying a transaction linear rather
+// than quadratic in its number of inputs.
+type TxSigHashes struct {
+	HashPrevOuts hashing.Hash
+	HashSequence hashing.Hash
+	HashOutputs  hashing.Hash
+
+	// The taproot hashes are single rather than double SHA-256 hashes, and
+	// also commit to the amounts and locking scripts of the spent outputs.
+	// They are only set when the spent outputs are known.
+	HashPrevOutsV1     hashing.Hash
+	HashSequenceV1     hashing.Hash
+	HashOutputsV1      hashing.Hash
+	HashInputAmountsV1 hashing.Hash
+	HashInputScriptsV1 hashing.Hash
+
+	hasTaproot bool
+}
+
+// NewTxSigHashes returns the intermediate witness signature hashes of tx.
+// prevOuts supplies the outputs spent by tx, which are needed for taproot
+// signature hashes; it may be nil if tx spends no taproot outputs.
+func NewTxSigHashes(tx *protocol.MsgTx,
+	prevOuts PrevOutputFetcher) (*TxSigHashes, error) {
+	var prevOutsBuf, sequences, outputs bytes.Buffer
+	for _, in := range tx.Inputs {
+		err := in.PrevOutput.Serialize(&prevOutsBuf,
+			legacySigHashProtocolVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		var seq [4]byte
+		binary.LittleEndian.PutUint32(seq[:], in.Sequence)
+		sequences.Write(seq[:])
+	}
+	for _, out := range tx.Outputs {
+		err := writeTxOut(&outputs, out)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The BIP143 hashes are the SHA-256 hashes of the taproot ones.
+	sigHashes := &TxSigHashes{
+		HashPrevOutsV1: hashing.SHA256H(prevOutsBuf.Bytes()),
+		HashSequenceV1: hashing.SHA256H(sequences.Bytes()),
+		HashOutputsV1:  hashing.SHA256H(outputs.Bytes()),
+	}
+	sigHashes.HashPrevOuts = hashing.SHA256H(sigHashes.HashPrevOutsV1[:])
+	sigHashes.HashSequence = hashing.SHA256H(sigHashes.HashSequenceV1[:])
+	sigHashes.HashOutputs = hashing.SHA256H(sigHashes.HashOutputsV1[:])
+
+	if prevOuts == nil {
+		return sigHashes, nil
+	}
+
+	var amounts, scripts bytes.Buffer
+	for _, in := range tx.Inputs {
+		prevOut := prevOuts.FetchPrevOutput(in.PrevOutput)
+		if prevOut == nil {
+			// Without every spent output, taproot signatures cannot
+			// be checked, but the BIP143 hashes remain usable.
+			return sigHashes, nil
+		}
+
+		var amount [8]byte
+		binary.LittleEndian.PutUint64(amount[:], uint64(prevOut.Value))
+		amounts.Write(amount[:])
+
+		err := protocol.WriteCompactSize(&scripts,
+			legacySigHashProtocolVersion, uint64(len(prevOut.ScriptLock)))
+		if err != nil {
+			return nil, err
+		}
+		scripts.Write(prevOut.ScriptLock)
+	}
+	sigHashes.HashInputAmountsV1 = hashing.SHA256H(amounts.Bytes())
+	sigHashes.HashInputScriptsV1 = hashing.SHA256H(scripts.Bytes())
+	sigHashes.hasTaproot = true
+
+	return sigHashes, nil
+}
+
+// CalcWitnessSignatureHash returns the BIP143 signature hash of input idx of
+// tx for hashType, where scriptCode is the script being executed from its
+// last executed OpCodeSeparator onwards and amount is the value of the output
+// being spent. Unlike the legacy signature hash, scriptCode is hashed as is.
+func CalcWitnessSignatureHash(scriptCode []byte, sigHashes *TxSigHashes,
+	hashType SigHashType, tx *protocol.MsgTx, idx int,
+	amount int64) ([]byte, error) {
+	if idx < 0 || idx >= len(tx.Inputs) {
+		return nil, ErrInvalidInputIndex
+	}
+
+	var zeroHash hashing.Hash
+	baseType := hashType & sigHashMask
+	anyoneCanPay := hashType&SigHashAnyOneCanPay != 0
+
+	hasher := hashing.NewDoubleSHA256Hasher()
+	var b4 [4]byte
+	var b8 [8]byte
+
+	binary.LittleEndian.PutUint32(b4[:], uint32(tx.Version))
+	hasher.Write(b4[:])
+
+	if anyoneCanPay {
+		hasher.Write(zeroHash[:])
+	} else {
+		hasher.Write(sigHashes.HashPrevOuts[:])
+	}
+
+	if anyoneCanPay || baseType == SigHashSingle || baseType == SigHashNone {
+		hasher.Write(zeroHash[:])
+	} else {
+		hasher.Write(sigHashes.HashSequence[:])
+	}
+
+	in := tx.Inputs[idx]
+	err := in.PrevOutput.Serialize(hasher, legacySigHashProtocolVersion)
+	if err != nil {
+		return nil, err
+	}
+	err = protocol.WriteCompactSize(hasher, legacySigHashProtocolVersion,
+		uint64(len(scriptCode)))
+	if err != nil {
+		return nil, err
+	}
+	hasher.Write(scriptCode)
+
+	binary.LittleEndian.PutUint64(b8[:], uint64(amount))
+	hasher.Write(b8[:])
+	binary.LittleEndian.PutUint32(b4[:], in.Sequence)
+	hasher.Write(b4[:])
+
+	switch {
+	case baseType != SigHashSingle && baseType != SigHashNone:
+		hasher.Write(sigHashes.HashOutputs[:])
+
+	case baseType == SigHashSingle && idx < len(tx.Outputs):
+		// Only the output with the same index as the input is signed.
+		var out bytes.Buffer
+		err = writeTxOut(&out, tx.Outputs[idx])
+		if err != nil {
+			return nil, err
+		}
+		hash := hashing.DoubleSHA256H(out.Bytes())
+		hasher.Write(hash[:])
+
+	default:
+		hasher.Write(zeroHash[:])
+	}
+
+	binary.LittleEndian.PutUint32(b4[:], tx.LockTime)
+	hasher.Write(b4[:])
+	binary.LittleEndian.PutUint32(b4[:], uint32(hashType))
+	hasher.Write(b4[:])
+
+	hash := hasher.Sum()
+	return hash[:], nil
+}
+
+// writeTxOut writes the serialization of out to buf, with the length prefix
+// of its script computed from the script itself.
+func writeTxOut(buf *bytes.Buffer, out *protocol.TxOut) error {
+	var value [8]byte
+	binary.LittleEndian.PutUint64(value[:], uint64(out.Value))
+	buf.Write(value[:])
+
+	err := protocol.WriteCompactSize(buf, legacySigHashProtocolVersion,
+		uint64(len(out.ScriptLock)))
+	if err != nil {
+		return err
+	}
+	buf.Write(out.ScriptLock)
+	return nil
+}
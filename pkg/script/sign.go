@@ -0,0 +1,95 @@
+package script
+
+import (
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// RawTxInSignature returns the signature by key of input idx of tx for
+// hashType, using the legacy signature hash of subScript. The signature is
+// strict DER with a low S value and is suffixed with hashType, ready to be
+// pushed by an unlocking script.
+func RawTxInSignature(tx *protocol.MsgTx, idx int, subScript []byte,
+	hashType SigHashType, key *btcec.PrivateKey) ([]byte, error) {
+	hash, err := CalcSignatureHash(subScript, hashType, tx, idx)
+	if err != nil {
+		return nil, err
+	}
+	return signHash(hash, hashType, key)
+}
+
+// RawTxInWitnessSignature returns the signature by key of input idx of tx,
+// spending an output of the given amount, for hashType using the BIP143
+// signature hash of scriptCode. The signature is strict DER with a low S
+// value and is suffixed with hashType, ready to be placed in a witness.
+func RawTxInWitnessSignature(tx *protocol.MsgTx, sigHashes *TxSigHashes,
+	idx int, amount int64, scriptCode []byte, hashType SigHashType,
+	key *btcec.PrivateKey) ([]byte, error) {
+	hash, err := CalcWitnessSignatureHash(scriptCode, sigHashes, hashType,
+		tx, idx, amount)
+	if err != nil {
+		return nil, err
+	}
+	return signHash(hash, hashType, key)
+}
+
+// signHash returns the signature of hash by key suffixed with hashType.
+func signHash(hash []byte, hashType SigHashType,
+	key *btcec.PrivateKey) ([]byte, error) {
+	sig, err := key.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	return sig.SerializeWithHashType(byte(hashType)), nil
+}
+
+// RawTxInTaprootSignature returns the signature of a key path spend of input
+// idx of tx, which spends prevOut, for hashType. key is the internal key of
+// the output, which is tweaked with merkleRoot, the root of its script tree
+// or nil if it has none. sigHashes must have been computed with the outputs
+// spent by tx. The hash type is appended unless it is SigHashDefault.
+func RawTxInTaprootSignature(tx *protocol.MsgTx, sigHashes *TxSigHashes,
+	idx int, prevOut *protocol.TxOut, merkleRoot []byte,
+	hashType SigHashType, key *btcec.PrivateKey) ([]byte, error) {
+	hash, err := CalcTaprootSignatureHash(sigHashes, hashType, tx, idx,
+		prevOut, nil)
+	if err != nil {
+		return nil, err
+	}
+	outputKey, err := schnorr.TweakPrivKey(key, merkleRoot)
+	if err != nil {
+		return nil, ErrTaprootTweak
+	}
+	return signSchnorrHash(hash, hashType, outputKey)
+}
+
+// RawTxInTapscriptSignature returns the signature by key of input idx of tx,
+// which spends prevOut through the tapscript with leaf hash tapLeafHash,
+// for hashType. The signature commits to no OpCodeSeparator position. The
+// hash type is appended unless it is SigHashDefault.
+func RawTxInTapscriptSignature(tx *protocol.MsgTx, sigHashes *TxSigHashes,
+	idx int, prevOut *protocol.TxOut, tapLeafHash []byte,
+	hashType SigHashType, key *btcec.PrivateKey) ([]byte, error) {
+	hash, err := CalcTapscriptSignatureHash(sigHashes, hashType, tx, idx,
+		prevOut, nil, tapLeafHash, blankCodeSepPos)
+	if err != nil {
+		return nil, err
+	}
+	return signSchnorrHash(hash, hashType, key)
+}
+
+// signSchnorrHash returns the BIP340 signature of hash by key, followed by
+// hashType unless it is SigHashDefault. Signatures are deterministic.
+func signSchnorrHash(hash []byte, hashType SigHashType,
+	key *btcec.PrivateKey) ([]byte, error) {
+	sig, err := schnorr.Sign(key, hash, nil)
+	if err != nil {
+		return nil, err
+	}
+	b := sig.Serialize()
+	if hashType != SigHashDefault {
+		b = append(b, byte(hashType))
+	}
+	return b, nil
+}
@@ -1,10 +1,12 @@
 package script
 
-import "errors"
-
 // A Stack holds a collection of data from DataInstruction.
 type Stack struct {
 	items [][]byte
+
+	// verifyMinimalData requires numbers popped from the Stack to be
+	// minimally encoded.
+	verifyMinimalData bool
 }
 
 // NewStack returns an empty stack.
@@ -12,15 +14,20 @@ func NewStack() *Stack {
 	return &Stack{}
 }
 
+// Depth returns the number of items on the Stack.
+func (s *Stack) Depth() int {
+	return len(s.items)
+}
+
 // Push pushes data onto the top of the Stack.
-func (s Stack) Push(d []byte) {
+func (s *Stack) Push(d []byte) {
 	s.items = append(s.items, d)
 }
 
 // Pop attempts to remove the top item from the Stack and
 // returns it if Stack is non-empty.
 // Otherwise, an error is returned.
-func (s Stack) Pop() ([]byte, error) {
+func (s *Stack) Pop() ([]byte, error) {
 	size := len(s.items)
 	if size == 0 {
 		return nil, ErrPopFromEmptyStack
@@ -30,5 +37,168 @@ func (s Stack) Pop() ([]byte, error) {
 	return i, nil
 }
 
-// ErrPopFromEmptyStack is the result of an attempt to pop from an empty stack.
-var ErrPopFromEmptyStack = errors.New("cannot pop from empty stack")
+// Peek returns the item idx positions from the top of the Stack without
+// removing it. An index of zero refers to the top item.
+func (s *Stack) Peek(idx int) ([]byte, error) {
+	size := len(s.items)
+	if idx < 0 || idx >= size {
+		return nil, ErrInvalidStackOperation
+	}
+	return s.items[size-1-idx], nil
+}
+
+// PushInt pushes n onto the Stack as a script number.
+func (s *Stack) PushInt(n scriptNum) {
+	s.Push(n.Bytes())
+}
+
+// PopInt removes the top item from the Stack and interprets it as a script
+// number of at most 4 bytes.
+func (s *Stack) PopInt() (scriptNum, error) {
+	d, err := s.Pop()
+	if err != nil {
+		return 0, err
+	}
+	return makeScriptNum(d, s.verifyMinimalData, maxNumSize)
+}
+
+// PeekInt interprets the item idx positions from the top of the Stack as a
+// script number of at most maxSize bytes without removing it.
+func (s *Stack) PeekInt(idx, maxSize int) (scriptNum, error) {
+	d, err := s.Peek(idx)
+	if err != nil {
+		return 0, err
+	}
+	return makeScriptNum(d, s.verifyMinimalData, maxSize)
+}
+
+// PushBool pushes b onto the Stack as 1 for true and an empty item for false.
+func (s *Stack) PushBool(b bool) {
+	if b {
+		s.Push([]byte{1})
+		return
+	}
+	s.Push(nil)
+}
+
+// PopBool removes the top item from the Stack and interprets it as a
+// boolean.
+func (s *Stack) PopBool() (bool, error) {
+	d, err := s.Pop()
+	if err != nil {
+		return false, err
+	}
+	return castToBool(d), nil
+}
+
+// nipN removes the item idx positions from the top of the Stack and returns
+// it.
+func (s *Stack) nipN(idx int) ([]byte, error) {
+	size := len(s.items)
+	if idx < 0 || idx >= size {
+		return nil, ErrInvalidStackOperation
+	}
+	pos := size - 1 - idx
+	d := s.items[pos]
+	s.items = append(s.items[:pos], s.items[pos+1:]...)
+	return d, nil
+}
+
+// dupN duplicates the top n items of the Stack.
+func (s *Stack) dupN(n int) error {
+	if n < 1 || n > len(s.items) {
+		return ErrInvalidStackOperation
+	}
+	for i := 0; i < n; i++ {
+		d, _ := s.Peek(n - 1)
+		s.Push(d)
+	}
+	return nil
+}
+
+// dropN removes the top n items of the Stack.
+func (s *Stack) dropN(n int) error {
+	if n < 1 || n > len(s.items) {
+		return ErrInvalidStackOperation
+	}
+	s.items = s.items[:len(s.items)-n]
+	return nil
+}
+
+// overN copies the n items which follow the top n items to the top of the
+// Stack.
+func (s *Stack) overN(n int) error {
+	if n < 1 || 2*n > len(s.items) {
+		return ErrInvalidStackOperation
+	}
+	for i := 0; i < n; i++ {
+		d, _ := s.Peek(2*n - 1)
+		s.Push(d)
+	}
+	return nil
+}
+
+// rotN moves the third group of n items from the top of the Stack to the
+// top.
+func (s *Stack) rotN(n int) error {
+	if n < 1 || 3*n > len(s.items) {
+		return ErrInvalidStackOperation
+	}
+	for i := 0; i < n; i++ {
+		d, _ := s.nipN(3*n - 1)
+		s.Push(d)
+	}
+	return nil
+}
+
+// swapN swaps the top n items of the Stack with the n items below them.
+func (s *Stack) swapN(n int) error {
+	if n < 1 || 2*n > len(s.items) {
+		return ErrInvalidStackOperation
+	}
+	for i := 0; i < n; i++ {
+		d, _ := s.nipN(2*n - 1)
+		s.Push(d)
+	}
+	return nil
+}
+
+// pickN copies the item idx positions from the top of the Stack to the top.
+func (s *Stack) pickN(idx int) error {
+	d, err := s.Peek(idx)
+	if err != nil {
+		return err
+	}
+	s.Push(d)
+	return nil
+}
+
+// rollN moves the item idx positions from the top of the Stack to the top.
+func (s *Stack) rollN(idx int) error {
+	d, err := s.nipN(idx)
+	if err != nil {
+		return err
+	}
+	s.Push(d)
+	return nil
+}
+
+// tuck copies the top item of the Stack below the second-to-top item.
+func (s *Stack) tuck() error {
+	if len(s.items) < 2 {
+		return ErrInvalidStackOperation
+	}
+	top := s.items[len(s.items)-1]
+	pos := len(s.items) - 2
+	s.items = append(s.items[:pos], append([][]byte{top}, s.items[pos:]...)...)
+	return nil
+}
+
+var (
+	// ErrPopFromEmptyStack is the result of an attempt to pop from an empty stack.
+	ErrPopFromEmptyStack = scriptError(ErrCodePopFromEmptyStack, "cannot pop from empty stack")
+
+	// ErrInvalidStackOperation is the result of an operation which refers to
+	// more items than are on the stack.
+	ErrInvalidStackOperation = scriptError(ErrCodeInvalidStackOperation, "not enough items on the stack")
+)
@@ -0,0 +1,278 @@
+package script
+
+import (
+	"bytes"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+	"github.com/jacobkaufmann/gocoin/pkg/util/encoding/base58"
+	"github.com/jacobkaufmann/gocoin/pkg/util/encoding/bech32"
+)
+
+// ScriptClass identifies the standard template a locking script follows.
+type ScriptClass byte
+
+// Classes of locking scripts.
+const (
+	// NonStandardTy is a script which follows no standard template.
+	NonStandardTy ScriptClass = iota
+
+	// PubKeyTy is a pay-to-pubkey script: <pubkey> OpCheckSig.
+	PubKeyTy
+
+	// PubKeyHashTy is a pay-to-pubkey-hash script: OpDup OpHash160
+	// <20 bytes> OpEqualVerify OpCheckSig.
+	PubKeyHashTy
+
+	// ScriptHashTy is a pay-to-script-hash script: OpHash160 <20 bytes>
+	// OpEqual.
+	ScriptHashTy
+
+	// MultiSigTy is a bare multisig script: <m> <pubkey>... <n>
+	// OpCheckMultiSig.
+	MultiSigTy
+
+	// NullDataTy is a provably unspendable data carrier script: OpReturn
+	// followed only by pushes.
+	NullDataTy
+
+	// WitnessV0PubKeyHashTy is a version 0 witness program of 20 bytes.
+	WitnessV0PubKeyHashTy
+
+	// WitnessV0ScriptHashTy is a version 0 witness program of 32 bytes.
+	WitnessV0ScriptHashTy
+
+	// WitnessV1TaprootTy is a version 1 witness program of 32 bytes.
+	WitnessV1TaprootTy
+
+	// AnchorTy is the pay-to-anchor script, the version 1 witness program
+	// 0x4e73, which anyone can spend.
+	AnchorTy
+
+	// WitnessUnknownTy is a witness program of a version or length with no
+	// rules yet.
+	WitnessUnknownTy
+)
+
+// scriptClassNames maps each script class to its name.
+var scriptClassNames = map[ScriptClass]string{
+	NonStandardTy:         "nonstandard",
+	PubKeyTy:              "pubkey",
+	PubKeyHashTy:          "pubkeyhash",
+	ScriptHashTy:          "scripthash",
+	MultiSigTy:            "multisig",
+	NullDataTy:            "nulldata",
+	WitnessV0PubKeyHashTy: "witness_v0_keyhash",
+	WitnessV0ScriptHashTy: "witness_v0_scripthash",
+	WitnessV1TaprootTy:    "witness_v1_taproot",
+	AnchorTy:              "anchor",
+	WitnessUnknownTy:      "witness_unknown",
+}
+
+// String returns the name of the script class.
+func (c ScriptClass) String() string {
+	if name, ok := scriptClassNames[c]; ok {
+		return name
+	}
+	return "invalid"
+}
+
+// anchorProgram is the witness program of the pay-to-anchor script.
+var anchorProgram = []byte{0x4e, 0x73}
+
+// ErrUnsupportedNet is returned when rendering addresses for a network with no
+// known address prefixes.
+var ErrUnsupportedNet = scriptError(ErrCodeUnsupportedNet, "no address format for network")
+
+// addressParams holds the address prefixes of a network.
+type addressParams struct {
+	pubKeyHash base58.VersionPrefix
+	scriptHash base58.VersionPrefix
+	hrp        string
+}
+
+// netAddressParams maps each supported network to its address prefixes.
+var netAddressParams = map[protocol.BitcoinNet]addressParams{
+	protocol.MainNet: {base58.Address, base58.P2SH, "bc"},
+	protocol.TestNet: {base58.TestNetAddress, base58.TestNetP2SH, "tb"},
+}
+
+// GetScriptClass returns the class of the locking script.
+func GetScriptClass(script []byte) ScriptClass {
+	class, _ := ExtractScriptData(script)
+	return class
+}
+
+// ExtractScriptData returns the class of the locking script together with
+// the data its template commits to: the public key of a pay-to-pubkey script,
+// the hash of a pay-to-pubkey-hash or pay-to-script-hash script, the public
+// keys of a multisig script, the pushes of a null data script, and the
+// program of a witness program. No data is returned for non-standard scripts.
+func ExtractScriptData(script []byte) (ScriptClass, [][]byte) {
+	switch {
+	case isPubKeyHash(script):
+		return PubKeyHashTy, [][]byte{script[3:23]}
+	case isScriptHash(script):
+		return ScriptHashTy, [][]byte{script[2:22]}
+	}
+
+	if version, program, ok := extractWitnessProgram(script); ok {
+		class := witnessClass(version, program)
+		if class == NonStandardTy {
+			return class, nil
+		}
+		return class, [][]byte{program}
+	}
+
+	if pubKey, ok := pubKeyScriptKey(script); ok {
+		return PubKeyTy, [][]byte{pubKey}
+	}
+
+	parsed, err := Parse(script)
+	if err != nil {
+		return NonStandardTy, nil
+	}
+	instrs := parsed.instructions
+	if _, pubKeys, ok := multiSigInfo(instrs); ok {
+		return MultiSigTy, pubKeys
+	}
+	if len(instrs) > 0 && instrs[0].op == OpReturn {
+		rest := &Script{instructions: instrs[1:]}
+		if rest.isPushOnly() {
+			var pushes [][]byte
+			for _, instr := range rest.instructions {
+				pushes = append(pushes, instr.data)
+			}
+			return NullDataTy, pushes
+		}
+	}
+	return NonStandardTy, nil
+}
+
+// ExtractScriptAddrs returns the class of the locking script, the addresses
+// it pays to on network net and the number of signatures required to spend
+// it. Public keys are rendered as the pay-to-pubkey-hash addresses of their
+// hashes. Null data and non-standard scripts have no addresses.
+func ExtractScriptAddrs(script []byte, net protocol.BitcoinNet) (ScriptClass, []string, int, error) {
+	params, ok := netAddressParams[net]
+	if !ok {
+		return NonStandardTy, nil, 0, ErrUnsupportedNet
+	}
+
+	class, data := ExtractScriptData(script)
+	switch class {
+	case PubKeyTy:
+		addr := base58.EncodeCheck(hashing.Hash160(data[0]), byte(params.pubKeyHash))
+		return class, []string{addr}, 1, nil
+
+	case PubKeyHashTy:
+		addr := base58.EncodeCheck(data[0], byte(params.pubKeyHash))
+		return class, []string{addr}, 1, nil
+
+	case ScriptHashTy:
+		addr := base58.EncodeCheck(data[0], byte(params.scriptHash))
+		return class, []string{addr}, 1, nil
+
+	case MultiSigTy:
+		parsed, _ := Parse(script)
+		m, _, _ := multiSigInfo(parsed.instructions)
+		addrs := make([]string, len(data))
+		for i, pubKey := range data {
+			addrs[i] = base58.EncodeCheck(hashing.Hash160(pubKey), byte(params.pubKeyHash))
+		}
+		return class, addrs, m, nil
+
+	case WitnessV0PubKeyHashTy, WitnessV0ScriptHashTy, WitnessV1TaprootTy,
+		AnchorTy, WitnessUnknownTy:
+		version, _, _ := extractWitnessProgram(script)
+		addr, err := bech32.EncodeSegWitAddress(params.hrp, byte(version), data[0])
+		if err != nil {
+			return class, nil, 0, err
+		}
+		reqSigs := 1
+		if class == AnchorTy || class == WitnessUnknownTy {
+			reqSigs = 0
+		}
+		return class, []string{addr}, reqSigs, nil
+	}
+	return class, nil, 0, nil
+}
+
+// witnessClass returns the class of a witness program.
+func witnessClass(version int, program []byte) ScriptClass {
+	switch {
+	case version == 0 && len(program) == payToWitnessPubKeyHashSize:
+		return WitnessV0PubKeyHashTy
+	case version == 0 && len(program) == payToWitnessScriptHashSize:
+		return WitnessV0ScriptHashTy
+	case version == 0:
+		// Version 0 programs of other lengths are unspendable.
+		return NonStandardTy
+	case version == 1 && len(program) == payToTaprootSize:
+		return WitnessV1TaprootTy
+	case version == 1 && bytes.Equal(program, anchorProgram):
+		return AnchorTy
+	}
+	return WitnessUnknownTy
+}
+
+// isPubKeyHash returns whether script is a pay-to-pubkey-hash locking script.
+func isPubKeyHash(script []byte) bool {
+	return len(script) == 25 &&
+		opCode(script[0]) == OpDup &&
+		opCode(script[1]) == OpHash160 &&
+		script[2] == 20 &&
+		opCode(script[23]) == OpEqualVerify &&
+		opCode(script[24]) == OpCheckSig
+}
+
+// pubKeyScriptKey returns the public key of a pay-to-pubkey locking script
+// and whether script is one.
+func pubKeyScriptKey(script []byte) ([]byte, bool) {
+	n := len(script)
+	if n < 2 || opCode(script[n-1]) != OpCheckSig || int(script[0]) != n-2 {
+		return nil, false
+	}
+	pubKey := script[1 : n-1]
+	return pubKey, isPubKeySize(pubKey)
+}
+
+// multiSigInfo returns the number of required signatures and the public keys
+// of a bare multisig script and whether instrs form one.
+func multiSigInfo(instrs []instruction) (int, [][]byte, bool) {
+	n := len(instrs)
+	if n < 4 || instrs[n-1].op != OpCheckMultiSig {
+		return 0, nil, false
+	}
+	first, last := instrs[0].op, instrs[n-2].op
+	if first < Op1 || first > Op16 || last < Op1 || last > Op16 {
+		return 0, nil, false
+	}
+	required := int(first-Op1) + 1
+	numPubKeys := int(last-Op1) + 1
+	if numPubKeys != n-3 || required > numPubKeys {
+		return 0, nil, false
+	}
+
+	pubKeys := make([][]byte, 0, numPubKeys)
+	for _, instr := range instrs[1 : n-2] {
+		if !instr.op.isDataPush() || !isPubKeySize(instr.data) {
+			return 0, nil, false
+		}
+		pubKeys = append(pubKeys, instr.data)
+	}
+	return required, pubKeys, true
+}
+
+// isPubKeySize returns whether pubKey has the size and prefix of a
+// serialized public key. The key itself is not checked to be on the curve.
+func isPubKeySize(pubKey []byte) bool {
+	switch len(pubKey) {
+	case btcec.PubKeyBytesLenCompressed:
+		return pubKey[0] == 0x02 || pubKey[0] == 0x03
+	case btcec.PubKeyBytesLenUncompressed:
+		return pubKey[0] == 0x04 || pubKey[0] == 0x06 || pubKey[0] == 0x07
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package script
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// The compressed and uncompressed public keys of the generator, and the
+// compressed public key of twice the generator.
+const (
+	pubKeyG = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	pubKeyU = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798" +
+		"483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+	pubKey2G = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+
+	// hashG is the hash160 of pubKeyG.
+	hashG = "751e76e8199196d454941c45d1b3a323f1433bd6"
+)
+
+func TestExtractScriptAddrs(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		net     protocol.BitcoinNet
+		class   ScriptClass
+		addrs   []string
+		reqSigs int
+	}{
+		{"p2pk compressed", "21" + pubKeyG + "ac", protocol.MainNet, PubKeyTy,
+			[]string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"}, 1},
+		{"p2pk uncompressed", "41" + pubKeyU + "ac", protocol.MainNet, PubKeyTy,
+			[]string{"1EHNa6Q4Jz2uvNExL497mE43ikXhwF6kZm"}, 1},
+		{"p2pkh", "76a914" + hashG + "88ac", protocol.MainNet, PubKeyHashTy,
+			[]string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"}, 1},
+		{"p2pkh testnet", "76a914" + hashG + "88ac", protocol.TestNet,
+			PubKeyHashTy, []string{"mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r"}, 1},
+		{"p2sh", "a914" + hashG + "87", protocol.MainNet, ScriptHashTy,
+			[]string{"3CNHUhP3uyB9EUtRLsmvFUmvGdjGdkTxJw"}, 1},
+		{"p2sh testnet", "a914" + hashG + "87", protocol.TestNet, ScriptHashTy,
+			[]string{"2N3vVYSK5XRgVSGWy21PnsRmBUywSQNdCsf"}, 1},
+		{"multisig", "5121" + pubKeyG + "21" + pubKey2G + "52ae", protocol.MainNet,
+			MultiSigTy, []string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+				"1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP"}, 1},
+		{"p2wpkh", "0014" + hashG, protocol.MainNet, WitnessV0PubKeyHashTy,
+			[]string{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"}, 1},
+		{"p2wsh testnet",
+			"00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262",
+			protocol.TestNet, WitnessV0ScriptHashTy,
+			[]string{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"}, 1},
+		{"p2tr", "5120" + pubKeyG[2:], protocol.MainNet, WitnessV1TaprootTy,
+			[]string{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"}, 1},
+		{"anchor", "51024e73", protocol.MainNet, AnchorTy,
+			[]string{"bc1pfeessrawgf"}, 0},
+		{"witness version 16", "6002751e", protocol.MainNet, WitnessUnknownTy,
+			[]string{"bc1sw50qgdz25j"}, 0},
+		{"null data", "6a04deadbeef", protocol.MainNet, NullDataTy, nil, 0},
+		{"witness version 0 of 21 bytes", "0015" + hashG + "00", protocol.MainNet,
+			NonStandardTy, nil, 0},
+		{"nonstandard", "51", protocol.MainNet, NonStandardTy, nil, 0},
+	}
+	for _, test := range tests {
+		script, err := hex.DecodeString(test.script)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if class := GetScriptClass(script); class != test.class {
+			t.Errorf("%s: class %v, want %v", test.name, class, test.class)
+		}
+		class, addrs, reqSigs, err := ExtractScriptAddrs(script, test.net)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if class != test.class || !reflect.DeepEqual(addrs, test.addrs) ||
+			reqSigs != test.reqSigs {
+			t.Errorf("%s: got %v %v requiring %d, want %v %v requiring %d",
+				test.name, class, addrs, reqSigs, test.class, test.addrs,
+				test.reqSigs)
+		}
+	}
+}
+
+func TestExtractScriptAddrsUnsupportedNet(t *testing.T) {
+	script, _ := hex.DecodeString("0014" + hashG)
+	_, addrs, _, err := ExtractScriptAddrs(script, protocol.BitcoinNet(0))
+	if err != ErrUnsupportedNet || addrs != nil {
+		t.Fatalf("got %v and %v, want %v", addrs, err, ErrUnsupportedNet)
+	}
+}
@@ -0,0 +1,374 @@
+package script
+
+import (
+	"bytes"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// Tags of the hashes which commit to the scripts of a taproot output.
+const (
+	tapLeafTag   = "TapLeaf"
+	tapBranchTag = "TapBranch"
+)
+
+const (
+	// BaseLeafVersion is the leaf version of tapscript, the script version
+	// specified by BIP342.
+	BaseLeafVersion = 0xc0
+
+	// TaprootLeafMask selects the leaf version from the first byte of a
+	// control block. The remaining bit is the parity of the output key.
+	TaprootLeafMask = 0xfe
+
+	// TaprootAnnexTag is the first byte of an annex, an optional last item
+	// of a taproot witness which is reserved for future extensions.
+	TaprootAnnexTag = 0x50
+
+	// payToTaprootSize is the size of a taproot witness program, which is
+	// an x-only output key.
+	payToTaprootSize = 32
+
+	// controlBlockBaseSize is the size of a control block without an
+	// inclusion proof: the leaf version and parity byte, and the x-only
+	// internal key.
+	controlBlockBaseSize = 33
+
+	// controlBlockNodeSize is the size of each node of the inclusion proof.
+	controlBlockNodeSize = 32
+
+	// controlBlockMaxNodes is the maximum depth of a script tree.
+	controlBlockMaxNodes = 128
+
+	// sigOpsBudgetBase is the signature check budget of a tapscript in
+	// addition to the size of its witness.
+	sigOpsBudgetBase = 50
+
+	// sigOpsBudgetCost is the budget spent by each signature check with a
+	// non-empty signature.
+	sigOpsBudgetCost = 50
+)
+
+var (
+	// ErrTaprootSigInvalid is the result of a taproot signature which is
+	// malformed or does not verify.
+	ErrTaprootSigInvalid = scriptError(ErrCodeTaprootSigInvalid, "invalid taproot signature")
+
+	// ErrTaprootControlBlockSize is the result of a control block whose
+	// size is not 33 plus a multiple of 32 bytes, up to 128 nodes.
+	ErrTaprootControlBlockSize = scriptError(ErrCodeTaprootControlBlockSize, "invalid control block size")
+
+	// ErrTaprootInternalKey is the result of a control block whose internal
+	// key is not a valid x-only public key.
+	ErrTaprootInternalKey = scriptError(ErrCodeTaprootInternalKey, "invalid taproot internal key")
+
+	// ErrTaprootTweak is the result of a taproot tweak which is not less
+	// than the curve order.
+	ErrTaprootTweak = scriptError(ErrCodeTaprootTweak, "invalid taproot tweak")
+
+	// ErrTaprootPubKeyEmpty is the result of a tapscript signature check
+	// with an empty public key.
+	ErrTaprootPubKeyEmpty = scriptError(ErrCodeTaprootPubKeyEmpty, "empty tapscript public key")
+
+	// ErrTaprootMaxSigOps is the result of a tapscript which checks more
+	// signatures than its budget allows.
+	ErrTaprootMaxSigOps = scriptError(ErrCodeTaprootMaxSigOps, "tapscript signature check budget exceeded")
+
+	// ErrTapscriptCheckMultiSig is the result of executing OpCheckMultiSig
+	// or OpCheckMultiSigVerify in a tapscript.
+	ErrTapscriptCheckMultiSig = scriptError(ErrCodeTapscriptCheckMultiSig, "OP_CHECKMULTISIG is disabled in tapscript")
+)
+
+// taprootContext holds the state of a taproot spend which signatures commit
+// to.
+type taprootContext struct {
+	// annex is the annex of the witness, or nil if it has none.
+	annex []byte
+
+	// tapLeafHash is the hash of the executing tapscript, or nil for a key
+	// path spend. codeSepPos is the position of the last executed
+	// OpCodeSeparator in the tapscript.
+	tapLeafHash []byte
+	codeSepPos  uint32
+
+	// sigOpsBudget is the remaining signature check budget of the
+	// tapscript.
+	sigOpsBudget int
+}
+
+// TapLeafHash returns the hash of a leaf of a taproot script tree holding
+// script with the given leaf version.
+func TapLeafHash(leafVersion byte, script []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(leafVersion)
+	protocol.WriteCompactSize(&buf, 0, uint64(len(script)))
+	buf.Write(script)
+
+	hash := hashing.TaggedHash(tapLeafTag, buf.Bytes())
+	return hash[:]
+}
+
+// TapBranchHash returns the hash of a branch of a taproot script tree with
+// children a and b. The children are sorted so that a proof does not need
+// to record which side each node is on.
+func TapBranchHash(a, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	hash := hashing.TaggedHash(tapBranchTag, a, b)
+	return hash[:]
+}
+
+// ComputeTaprootOutputKey returns the output key committing to internalKey
+// and the script tree with the given root hash, which is nil for an output
+// without scripts: Q = P + int(hash_TapTweak(P.x || root))⋅G.
+func ComputeTaprootOutputKey(internalKey *btcec.PublicKey,
+	rootHash []byte) (*btcec.PublicKey, error) {
+	outputKey, err := schnorr.TweakPubKey(internalKey, rootHash)
+	if err != nil {
+		return nil, ErrTaprootTweak
+	}
+	return outputKey, nil
+}
+
+// A ControlBlock proves that a tapscript is committed to by a taproot output
+// key.
+type ControlBlock struct {
+	// LeafVersion is the leaf version of the tapscript.
+	LeafVersion byte
+
+	// OutputKeyYIsOdd is the parity of the y coordinate of the output key.
+	OutputKeyYIsOdd bool
+
+	// InternalKey is the key which the output key tweaks.
+	InternalKey *btcec.PublicKey
+
+	// InclusionProof is the concatenation of the hashes of the nodes on the
+	// path from the leaf to the root of the script tree.
+	InclusionProof []byte
+}
+
+// ParseControlBlock parses a control block from the last item of a taproot
+// script path witness.
+func ParseControlBlock(b []byte) (*ControlBlock, error) {
+	if len(b) < controlBlockBaseSize ||
+		len(b) > controlBlockBaseSize+controlBlockMaxNodes*controlBlockNodeSize ||
+		(len(b)-controlBlockBaseSize)%controlBlockNodeSize != 0 {
+		return nil, ErrTaprootControlBlockSize
+	}
+
+	internalKey, err := schnorr.ParsePubKey(b[1:controlBlockBaseSize])
+	if err != nil {
+		return nil, ErrTaprootInternalKey
+	}
+
+	return &ControlBlock{
+		LeafVersion:     b[0] & TaprootLeafMask,
+		OutputKeyYIsOdd: b[0]&^TaprootLeafMask == 1,
+		InternalKey:     internalKey,
+		InclusionProof:  b[controlBlockBaseSize:],
+	}, nil
+}
+
+// RootHash returns the root hash of the script tree in which the leaf with
+// hash leafHash is proven by the inclusion proof of c.
+func (c *ControlBlock) RootHash(leafHash []byte) []byte {
+	hash := leafHash
+	for i := 0; i < len(c.InclusionProof); i += controlBlockNodeSize {
+		hash = TapBranchHash(hash, c.InclusionProof[i:i+controlBlockNodeSize])
+	}
+	return hash
+}
+
+// verifyCommitment returns an error unless the tapscript with hash leafHash
+// is committed to by the x-only output key outputKey.
+func (c *ControlBlock) verifyCommitment(outputKey, leafHash []byte) error {
+	key, err := ComputeTaprootOutputKey(c.InternalKey, c.RootHash(leafHash))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(schnorr.SerializePubKey(key), outputKey) ||
+		(key.Y.Bit(0) == 1) != c.OutputKeyYIsOdd {
+		return ErrWitnessProgramMismatch
+	}
+	return nil
+}
+
+// verifyTaproot executes the witness of a taproot output, whose program is
+// the x-only output key. A witness with a single item, not counting any
+// annex, is a key path spend: a signature by the output key. Otherwise the
+// last two items are a tapscript and the control block proving that the
+// output key commits to it, and the remaining items are its initial stack.
+func (vm *Engine) verifyTaproot() error {
+	witness := vm.witness
+	if len(witness) == 0 {
+		return ErrWitnessProgramEmpty
+	}
+
+	ctx := &taprootContext{codeSepPos: blankCodeSepPos}
+	if last := witness[len(witness)-1]; len(witness) >= 2 &&
+		len(last) > 0 && last[0] == TaprootAnnexTag {
+		ctx.annex = last
+		witness = witness[:len(witness)-1]
+	}
+	vm.taproot = ctx
+
+	if len(witness) == 1 {
+		pubKey, err := schnorr.ParsePubKey(vm.witnessProgram)
+		if err != nil {
+			return ErrTaprootSigInvalid
+		}
+		return vm.verifySchnorrSig(witness[0], pubKey)
+	}
+
+	script := witness[len(witness)-2]
+	controlBlock, err := ParseControlBlock(witness[len(witness)-1])
+	if err != nil {
+		return err
+	}
+	ctx.tapLeafHash = TapLeafHash(controlBlock.LeafVersion, script)
+	err = controlBlock.verifyCommitment(vm.witnessProgram, ctx.tapLeafHash)
+	if err != nil {
+		return err
+	}
+
+	// Unknown leaf versions are left for future soft forks.
+	if controlBlock.LeafVersion != BaseLeafVersion {
+		return nil
+	}
+
+	// A tapscript containing an OP_SUCCESSx opcode succeeds without being
+	// executed, as long as it parses up to that opcode.
+	t := NewTokenizer(script)
+	for t.Next() {
+		if opCode(t.Opcode()).isSuccess() {
+			return nil
+		}
+	}
+	if err := t.Err(); err != nil {
+		return err
+	}
+
+	parsed, err := Parse(script)
+	if err != nil {
+		return err
+	}
+
+	stack := witness[:len(witness)-2]
+	if len(stack) > MaxStackSize {
+		return ErrStackOverflow
+	}
+	err = checkStackItemSizes(stack)
+	if err != nil {
+		return err
+	}
+
+	var witnessBuf bytes.Buffer
+	err = vm.witness.Serialize(&witnessBuf, 0)
+	if err != nil {
+		return err
+	}
+	ctx.sigOpsBudget = sigOpsBudgetBase + witnessBuf.Len()
+
+	vm.scripts = append(vm.scripts, parsed)
+	vm.dstack.items = append([][]byte(nil), stack...)
+	vm.witnessExec = true
+	return nil
+}
+
+// isTapscript returns whether the engine is executing a tapscript.
+func (vm *Engine) isTapscript() bool {
+	return vm.witnessExec && vm.taproot != nil
+}
+
+// SetBatchVerifier makes the engine add the Schnorr signatures of taproot
+// spends to bv rather than verifying them as they are checked. Since every
+// non-empty signature checked in a taproot spend must be valid for the spend
+// to succeed, deferring them does not change which spends are valid: the
+// input is valid if Execute succeeds and bv finds no invalid signatures.
+// Engines validating the inputs of a block in parallel can share one
+// verifier. A nil verifier restores immediate verification.
+func (vm *Engine) SetBatchVerifier(bv *schnorr.BatchVerifier) {
+	vm.batch = bv
+}
+
+// verifySchnorrSig returns an error unless sig, which is suffixed with its
+// hash type unless the hash type is SigHashDefault, is a valid taproot
+// signature by pubKey of the spending transaction.
+func (vm *Engine) verifySchnorrSig(sig []byte, pubKey *btcec.PublicKey) error {
+	hashType := SigHashDefault
+	switch len(sig) {
+	case schnorr.SignatureSize:
+	case schnorr.SignatureSize + 1:
+		// An explicit hash type may not repeat the default.
+		hashType = SigHashType(sig[schnorr.SignatureSize])
+		if hashType == SigHashDefault {
+			return ErrInvalidTaprootSigHashType
+		}
+		sig = sig[:schnorr.SignatureSize]
+	default:
+		return ErrTaprootSigInvalid
+	}
+
+	prevOut := &protocol.TxOut{
+		Value:      vm.amount,
+		ScriptLock: vm.scripts[1].Bytes(),
+	}
+	hash, err := calcTaprootSignatureHash(vm.sigHashes, hashType, vm.tx,
+		vm.txIdx, prevOut, vm.taproot.annex, vm.taproot.tapLeafHash,
+		vm.taproot.codeSepPos)
+	if err != nil {
+		return err
+	}
+
+	s, err := schnorr.ParseSignature(sig)
+	if err != nil {
+		return ErrTaprootSigInvalid
+	}
+	if vm.batch != nil {
+		vm.batch.Add(pubKey, hash, s)
+		return nil
+	}
+	if !s.Verify(hash, pubKey) {
+		return ErrTaprootSigInvalid
+	}
+	return nil
+}
+
+// checkTapscriptSig returns whether sig is a valid signature by pubKey in a
+// tapscript. An empty signature is a failed check, while any other invalid
+// signature fails the script. Public keys which are neither empty nor 32
+// bytes are reserved for future soft forks, and any non-empty signature for
+// them succeeds.
+func (vm *Engine) checkTapscriptSig(sig, pubKey []byte) (bool, error) {
+	if len(sig) != 0 {
+		vm.taproot.sigOpsBudget -= sigOpsBudgetCost
+		if vm.taproot.sigOpsBudget < 0 {
+			return false, ErrTaprootMaxSigOps
+		}
+	}
+
+	switch len(pubKey) {
+	case 0:
+		return false, ErrTaprootPubKeyEmpty
+
+	case schnorr.PubKeyBytesLen:
+		if len(sig) == 0 {
+			return false, nil
+		}
+		pk, err := schnorr.ParsePubKey(pubKey)
+		if err != nil {
+			return false, ErrTaprootSigInvalid
+		}
+		err = vm.verifySchnorrSig(sig, pk)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return len(sig) != 0, nil
+}
@@ -0,0 +1,521 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// bip341Vectors is the layout of the wallet test vectors of BIP341.
+type bip341Vectors struct {
+	ScriptPubKey []struct {
+		Given struct {
+			InternalPubkey string          `json:"internalPubkey"`
+			ScriptTree     json.RawMessage `json:"scriptTree"`
+		} `json:"given"`
+		Intermediary struct {
+			LeafHashes    []string `json:"leafHashes"`
+			MerkleRoot    string   `json:"merkleRoot"`
+			Tweak         string   `json:"tweak"`
+			TweakedPubkey string   `json:"tweakedPubkey"`
+		} `json:"intermediary"`
+		Expected struct {
+			ScriptPubKey            string   `json:"scriptPubKey"`
+			Bip350Address           string   `json:"bip350Address"`
+			ScriptPathControlBlocks []string `json:"scriptPathControlBlocks"`
+		} `json:"expected"`
+	} `json:"scriptPubKey"`
+
+	KeyPathSpending []struct {
+		Given struct {
+			RawUnsignedTx string `json:"rawUnsignedTx"`
+			UtxosSpent    []struct {
+				ScriptPubKey string `json:"scriptPubKey"`
+				AmountSats   int64  `json:"amountSats"`
+			} `json:"utxosSpent"`
+		} `json:"given"`
+		Intermediary struct {
+			HashAmounts       string `json:"hashAmounts"`
+			HashOutputs       string `json:"hashOutputs"`
+			HashPrevouts      string `json:"hashPrevouts"`
+			HashScriptPubkeys string `json:"hashScriptPubkeys"`
+			HashSequences     string `json:"hashSequences"`
+		} `json:"intermediary"`
+		InputSpending []struct {
+			Given struct {
+				TxinIndex       int         `json:"txinIndex"`
+				InternalPrivkey string      `json:"internalPrivkey"`
+				MerkleRoot      string      `json:"merkleRoot"`
+				HashType        SigHashType `json:"hashType"`
+			} `json:"given"`
+			Intermediary struct {
+				InternalPubkey string `json:"internalPubkey"`
+				TweakedPrivkey string `json:"tweakedPrivkey"`
+				SigHash        string `json:"sigHash"`
+			} `json:"intermediary"`
+			Expected struct {
+				Witness []string `json:"witness"`
+			} `json:"expected"`
+		} `json:"inputSpending"`
+		Auxiliary struct {
+			FullySignedTx string `json:"fullySignedTx"`
+		} `json:"auxiliary"`
+	} `json:"keyPathSpending"`
+}
+
+// readBIP341Vectors decodes the wallet test vectors of BIP341.
+func readBIP341Vectors(t *testing.T) *bip341Vectors {
+	b, err := os.ReadFile("testdata/bip341_wallet_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors bip341Vectors
+	if err := json.Unmarshal(b, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	return &vectors
+}
+
+// decodeHex decodes the hex string s, failing the test if it is invalid.
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// tapTreeRoot returns the root hash of the script tree of the BIP341 test
+// vectors encoded in tree, which is a leaf or a pair of trees, and records
+// the hash and version of each leaf by its id.
+func tapTreeRoot(t *testing.T, tree json.RawMessage, leafHashes map[int][]byte,
+	leafVersions map[int]byte) []byte {
+	var branch []json.RawMessage
+	if err := json.Unmarshal(tree, &branch); err == nil {
+		if len(branch) != 2 {
+			t.Fatalf("branch with %d children", len(branch))
+		}
+		return TapBranchHash(tapTreeRoot(t, branch[0], leafHashes, leafVersions),
+			tapTreeRoot(t, branch[1], leafHashes, leafVersions))
+	}
+
+	var leaf struct {
+		ID          int    `json:"id"`
+		Script      string `json:"script"`
+		LeafVersion byte   `json:"leafVersion"`
+	}
+	if err := json.Unmarshal(tree, &leaf); err != nil {
+		t.Fatal(err)
+	}
+	hash := TapLeafHash(leaf.LeafVersion, decodeHex(t, leaf.Script))
+	leafHashes[leaf.ID] = hash
+	leafVersions[leaf.ID] = leaf.LeafVersion
+	return hash
+}
+
+// TestTaprootOutputVectors checks the leaf hashes, merkle roots, output keys,
+// scripts and addresses of the outputs in the BIP341 test vectors, and that
+// their control blocks parse and prove each leaf.
+func TestTaprootOutputVectors(t *testing.T) {
+	for i, test := range readBIP341Vectors(t).ScriptPubKey {
+		internalKey, err := schnorr.ParsePubKey(decodeHex(t, test.Given.InternalPubkey))
+		if err != nil {
+			t.Fatalf("output %d: %v", i, err)
+		}
+
+		leafHashes := make(map[int][]byte)
+		leafVersions := make(map[int]byte)
+		var root []byte
+		if string(test.Given.ScriptTree) != "null" {
+			root = tapTreeRoot(t, test.Given.ScriptTree, leafHashes, leafVersions)
+		}
+		for id, want := range test.Intermediary.LeafHashes {
+			if got := hex.EncodeToString(leafHashes[id]); got != want {
+				t.Errorf("output %d: leaf %d hash %s, want %s", i, id, got, want)
+			}
+		}
+		if got := hex.EncodeToString(root); got != test.Intermediary.MerkleRoot {
+			t.Errorf("output %d: merkle root %s, want %s", i, got,
+				test.Intermediary.MerkleRoot)
+		}
+
+		tweak := hashing.TaggedHash("TapTweak",
+			schnorr.SerializePubKey(internalKey), root)
+		if got := hex.EncodeToString(tweak[:]); got != test.Intermediary.Tweak {
+			t.Errorf("output %d: tweak %s, want %s", i, got, test.Intermediary.Tweak)
+		}
+		outputKey, err := ComputeTaprootOutputKey(internalKey, root)
+		if err != nil {
+			t.Fatalf("output %d: %v", i, err)
+		}
+		xOnly := schnorr.SerializePubKey(outputKey)
+		if got := hex.EncodeToString(xOnly); got != test.Intermediary.TweakedPubkey {
+			t.Errorf("output %d: output key %s, want %s", i, got,
+				test.Intermediary.TweakedPubkey)
+		}
+
+		lock, err := PayToTaprootScript(xOnly)
+		if err != nil {
+			t.Fatalf("output %d: %v", i, err)
+		}
+		if got := hex.EncodeToString(lock); got != test.Expected.ScriptPubKey {
+			t.Errorf("output %d: script %s, want %s", i, got, test.Expected.ScriptPubKey)
+		}
+		class, addrs, _, err := ExtractScriptAddrs(lock, protocol.MainNet)
+		if err != nil || class != WitnessV1TaprootTy || len(addrs) != 1 ||
+			addrs[0] != test.Expected.Bip350Address {
+			t.Errorf("output %d: %v address %v (%v), want %s", i, class, addrs,
+				err, test.Expected.Bip350Address)
+		}
+
+		for id, cb := range test.Expected.ScriptPathControlBlocks {
+			controlBlock, err := ParseControlBlock(decodeHex(t, cb))
+			if err != nil {
+				t.Errorf("output %d: control block %d: %v", i, id, err)
+				continue
+			}
+			if controlBlock.LeafVersion != leafVersions[id] {
+				t.Errorf("output %d: control block %d has leaf version %#x, "+
+					"want %#x", i, id, controlBlock.LeafVersion, leafVersions[id])
+			}
+			if controlBlock.OutputKeyYIsOdd != (outputKey.Y.Bit(0) == 1) {
+				t.Errorf("output %d: control block %d has the wrong parity", i, id)
+			}
+			if !controlBlock.InternalKey.IsEqual(internalKey) {
+				t.Errorf("output %d: control block %d has the wrong internal "+
+					"key", i, id)
+			}
+			if got := controlBlock.RootHash(leafHashes[id]); !bytes.Equal(got, root) {
+				t.Errorf("output %d: control block %d proves root %x, want %x",
+					i, id, got, root)
+			}
+			if err := controlBlock.verifyCommitment(xOnly, leafHashes[id]); err != nil {
+				t.Errorf("output %d: control block %d: %v", i, id, err)
+			}
+		}
+	}
+}
+
+// TestTaprootKeyPathVectors checks the signature hashes and signatures of the
+// key path spends in the BIP341 test vectors, and validates every input of
+// the signed transaction, which also spends legacy and version 0 witness
+// outputs.
+func TestTaprootKeyPathVectors(t *testing.T) {
+	for i, test := range readBIP341Vectors(t).KeyPathSpending {
+		tx := new(protocol.MsgTx)
+		err := tx.Deserialize(bytes.NewReader(decodeHex(t, test.Given.RawUnsignedTx)), 0)
+		if err != nil {
+			t.Fatalf("spend %d: %v", i, err)
+		}
+		prevOuts := NewMultiPrevOutFetcher()
+		for j, utxo := range test.Given.UtxosSpent {
+			prevOuts.AddPrevOut(tx.Inputs[j].PrevOutput, &protocol.TxOut{
+				Value:      utxo.AmountSats,
+				ScriptLock: decodeHex(t, utxo.ScriptPubKey),
+			})
+		}
+
+		sigHashes, err := NewTxSigHashes(tx, prevOuts)
+		if err != nil {
+			t.Fatalf("spend %d: %v", i, err)
+		}
+		for _, hash := range []struct {
+			name string
+			got  hashing.Hash
+			want string
+		}{
+			{"amounts", sigHashes.HashInputAmountsV1, test.Intermediary.HashAmounts},
+			{"outputs", sigHashes.HashOutputsV1, test.Intermediary.HashOutputs},
+			{"prevouts", sigHashes.HashPrevOutsV1, test.Intermediary.HashPrevouts},
+			{"scripts", sigHashes.HashInputScriptsV1, test.Intermediary.HashScriptPubkeys},
+			{"sequences", sigHashes.HashSequenceV1, test.Intermediary.HashSequences},
+		} {
+			if got := hex.EncodeToString(hash.got[:]); got != hash.want {
+				t.Errorf("spend %d: hash of %s %s, want %s", i, hash.name, got,
+					hash.want)
+			}
+		}
+
+		for _, in := range test.InputSpending {
+			idx := in.Given.TxinIndex
+			prevOut := prevOuts.FetchPrevOutput(tx.Inputs[idx].PrevOutput)
+			var merkleRoot []byte
+			if in.Given.MerkleRoot != "" {
+				merkleRoot = decodeHex(t, in.Given.MerkleRoot)
+			}
+			key, pub := btcec.PrivKeyFromBytes(decodeHex(t, in.Given.InternalPrivkey))
+			if got := hex.EncodeToString(schnorr.SerializePubKey(pub)); got != in.Intermediary.InternalPubkey {
+				t.Errorf("spend %d: input %d internal key %s, want %s", i, idx,
+					got, in.Intermediary.InternalPubkey)
+			}
+			tweaked, err := schnorr.TweakPrivKey(key, merkleRoot)
+			if err != nil {
+				t.Fatalf("spend %d: input %d: %v", i, idx, err)
+			}
+			if got := hex.EncodeToString(tweaked.Serialize()); got != in.Intermediary.TweakedPrivkey {
+				t.Errorf("spend %d: input %d tweaked key %s, want %s", i, idx,
+					got, in.Intermediary.TweakedPrivkey)
+			}
+
+			hash, err := CalcTaprootSignatureHash(sigHashes, in.Given.HashType,
+				tx, idx, prevOut, nil)
+			if err != nil {
+				t.Fatalf("spend %d: input %d: %v", i, idx, err)
+			}
+			if got := hex.EncodeToString(hash); got != in.Intermediary.SigHash {
+				t.Errorf("spend %d: input %d signature hash %s, want %s", i,
+					idx, got, in.Intermediary.SigHash)
+			}
+
+			// The signatures of the vectors use no auxiliary randomness.
+			sig, err := RawTxInTaprootSignature(tx, sigHashes, idx, prevOut,
+				merkleRoot, in.Given.HashType, key)
+			if err != nil {
+				t.Fatalf("spend %d: input %d: %v", i, idx, err)
+			}
+			if got := hex.EncodeToString(sig); got != in.Expected.Witness[0] {
+				t.Errorf("spend %d: input %d signature %s, want %s", i, idx,
+					got, in.Expected.Witness[0])
+			}
+		}
+
+		signed := new(protocol.MsgTx)
+		err = signed.Deserialize(bytes.NewReader(decodeHex(t, test.Auxiliary.FullySignedTx)), 0)
+		if err != nil {
+			t.Fatalf("spend %d: %v", i, err)
+		}
+		signedHashes, err := NewTxSigHashes(signed, prevOuts)
+		if err != nil {
+			t.Fatalf("spend %d: %v", i, err)
+		}
+		for idx, in := range signed.Inputs {
+			prevOut := prevOuts.FetchPrevOutput(in.PrevOutput)
+			vm, err := NewEngine(in.ScriptUnlock, prevOut.ScriptLock, in.Witness,
+				signed, idx, prevOut.Value, StandardVerifyFlags, signedHashes)
+			if err == nil {
+				err = vm.Execute()
+			}
+			if err != nil {
+				t.Errorf("spend %d: input %d: %v", i, idx, err)
+			}
+		}
+	}
+}
+
+// payToTapTree returns the output script of a taproot output whose script
+// tree is leaf, with the given leaf version, or a branch of leaf and the
+// tapscript sibling if sibling is not nil, and the control block which
+// spends leaf.
+func payToTapTree(t *testing.T, leafVersion byte, leaf,
+	sibling []byte) ([]byte, []byte) {
+	internalKey := testKey(1).PubKey()
+	root := TapLeafHash(leafVersion, leaf)
+	var proof []byte
+	if sibling != nil {
+		proof = TapLeafHash(BaseLeafVersion, sibling)
+		root = TapBranchHash(root, proof)
+	}
+	outputKey, err := ComputeTaprootOutputKey(internalKey, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := PayToTaprootScript(schnorr.SerializePubKey(outputKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	controlBlock := append([]byte{leafVersion | byte(outputKey.Y.Bit(0))},
+		schnorr.SerializePubKey(internalKey)...)
+	return lock, append(controlBlock, proof...)
+}
+
+// checkSigRounds returns a tapscript which checks the signature on top of the
+// stack against pubKey n+1 times.
+func checkSigRounds(t *testing.T, pubKey []byte, n int) []byte {
+	b := NewScriptBuilder()
+	for i := 0; i < n; i++ {
+		b.AddOp(byte(OpDup)).AddData(pubKey).AddOp(byte(OpCheckSigVerify))
+	}
+	leaf, err := b.AddData(pubKey).AddOp(byte(OpCheckSig)).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func TestTapscriptSpend(t *testing.T) {
+	const amount = 5000
+	keys := []*btcec.PrivateKey{testKey(2), testKey(3), testKey(4)}
+	pubKeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		pubKeys[i] = schnorr.SerializePubKey(key.PubKey())
+	}
+
+	checkSig, err := NewScriptBuilder().AddData(pubKeys[0]).
+		AddOp(byte(OpCheckSig)).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiSig, err := NewScriptBuilder().
+		AddData(pubKeys[0]).AddOp(byte(OpCheckSig)).
+		AddData(pubKeys[1]).AddOp(byte(OpCheckSigAdd)).
+		AddData(pubKeys[2]).AddOp(byte(OpCheckSigAdd)).
+		AddOp(byte(Op2)).AddOp(byte(OpNumEqual)).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	annex := []byte{TaprootAnnexTag, 1, 2, 3}
+
+	// The signature check budget is 50 plus the size of the witness, and
+	// each check costs 50. With one signature of 64 bytes, checkSigRounds
+	// with 9 rounds leaves 2 of its budget, and with 10 rounds exceeds it.
+	//
+	// The stack of each test is given as the keys which sign it, from the
+	// top of the stack down, where nil is an empty signature.
+	tests := []struct {
+		name    string
+		leaf    []byte
+		sibling []byte
+		signers []*btcec.PrivateKey
+
+		// leafVersion is the version of leaf, or BaseLeafVersion if it
+		// is zero.
+		leafVersion byte
+
+		// annex is appended to the witness and signedAnnex is committed to
+		// by the signatures.
+		annex       []byte
+		signedAnnex []byte
+
+		// controlBlock modifies the control block if it is not nil.
+		controlBlock func([]byte) []byte
+		err          error
+	}{
+		{name: "checksig", leaf: checkSig,
+			signers: []*btcec.PrivateKey{keys[0]}},
+		{name: "checksig wrong key", leaf: checkSig,
+			signers: []*btcec.PrivateKey{keys[1]}, err: ErrTaprootSigInvalid},
+		{name: "checksig empty signature", leaf: checkSig,
+			signers: []*btcec.PrivateKey{nil}, err: ErrEvalFalse},
+		{name: "checksig in branch", leaf: checkSig,
+			sibling: []byte{byte(OpReturn)},
+			signers: []*btcec.PrivateKey{keys[0]}},
+		{name: "checksigadd 2 of 3", leaf: multiSig,
+			signers: []*btcec.PrivateKey{keys[0], nil, keys[2]}},
+		{name: "checksigadd 3 of 3", leaf: multiSig,
+			signers: []*btcec.PrivateKey{keys[0], keys[1], keys[2]},
+			err:     ErrEvalFalse},
+		{name: "checksigadd 1 of 3", leaf: multiSig,
+			signers: []*btcec.PrivateKey{nil, keys[1], nil},
+			err:     ErrEvalFalse},
+		{name: "checksigadd wrong key", leaf: multiSig,
+			signers: []*btcec.PrivateKey{keys[0], keys[0], nil},
+			err:     ErrTaprootSigInvalid},
+		{name: "annex", leaf: checkSig, signers: []*btcec.PrivateKey{keys[0]},
+			annex: annex, signedAnnex: annex},
+		{name: "annex not signed", leaf: checkSig,
+			signers: []*btcec.PrivateKey{keys[0]}, annex: annex,
+			err: ErrTaprootSigInvalid},
+		{name: "annex signed but missing", leaf: checkSig,
+			signers:     []*btcec.PrivateKey{keys[0]},
+			signedAnnex: annex, err: ErrTaprootSigInvalid},
+		{name: "op_success", leaf: []byte{byte(OpReturn), 0x50}},
+		{name: "op_success before malformed push",
+			leaf: []byte{0xbb, byte(OpPushData2), 1}},
+		{name: "malformed push before op_success",
+			leaf: []byte{byte(OpPushData2), 1}, err: ErrMalformedPush},
+		{name: "unknown leaf version", leaf: []byte{byte(OpReturn)},
+			leafVersion: 0xc2},
+		{name: "wrong parity", leaf: checkSig,
+			signers: []*btcec.PrivateKey{keys[0]},
+			controlBlock: func(cb []byte) []byte {
+				cb[0] ^= 1
+				return cb
+			},
+			err: ErrWitnessProgramMismatch},
+		{name: "wrong internal key", leaf: checkSig,
+			signers: []*btcec.PrivateKey{keys[0]},
+			controlBlock: func(cb []byte) []byte {
+				return append([]byte{cb[0]}, pubKeys[1]...)
+			},
+			err: ErrWitnessProgramMismatch},
+		{name: "control block too short", leaf: checkSig,
+			signers: []*btcec.PrivateKey{keys[0]},
+			controlBlock: func(cb []byte) []byte {
+				return cb[:controlBlockBaseSize-1]
+			},
+			err: ErrTaprootControlBlockSize},
+		{name: "control block partial node", leaf: checkSig,
+			signers: []*btcec.PrivateKey{keys[0]},
+			controlBlock: func(cb []byte) []byte {
+				return append(cb, 1)
+			},
+			err: ErrTaprootControlBlockSize},
+		{name: "sigops budget", leaf: checkSigRounds(t, pubKeys[0], 9),
+			signers: []*btcec.PrivateKey{keys[0]}},
+		{name: "sigops budget exceeded", leaf: checkSigRounds(t, pubKeys[0], 10),
+			signers: []*btcec.PrivateKey{keys[0]}, err: ErrTaprootMaxSigOps},
+	}
+	for _, test := range tests {
+		leafVersion := byte(BaseLeafVersion)
+		if test.leafVersion != 0 {
+			leafVersion = test.leafVersion
+		}
+		lock, controlBlock := payToTapTree(t, leafVersion, test.leaf,
+			test.sibling)
+		if test.controlBlock != nil {
+			controlBlock = test.controlBlock(controlBlock)
+		}
+
+		tx := testTx()
+		prevOut := &protocol.TxOut{Value: amount, ScriptLock: lock}
+		prevOuts := NewMultiPrevOutFetcher()
+		for _, in := range tx.Inputs {
+			prevOuts.AddPrevOut(in.PrevOutput, prevOut)
+		}
+		sigHashes, err := NewTxSigHashes(tx, prevOuts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := CalcTapscriptSignatureHash(sigHashes, SigHashDefault, tx,
+			0, prevOut, test.signedAnnex,
+			TapLeafHash(leafVersion, test.leaf), blankCodeSepPos)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The witness holds the stack from the bottom up.
+		var witness protocol.TxWitness
+		for i := len(test.signers) - 1; i >= 0; i-- {
+			if test.signers[i] == nil {
+				witness = append(witness, nil)
+				continue
+			}
+			sig, err := schnorr.Sign(test.signers[i], hash, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			witness = append(witness, sig.Serialize())
+		}
+		witness = append(witness, test.leaf, controlBlock)
+		if test.annex != nil {
+			witness = append(witness, test.annex)
+		}
+
+		vm, err := NewEngine(nil, lock, witness, tx, 0, amount,
+			ConsensusVerifyFlags, sigHashes)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if err := vm.Execute(); err != test.err {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
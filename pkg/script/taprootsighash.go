@@ -0,0 +1,170 @@
+package script
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// tapSighashTag is the tag of the taproot signature hash.
+const tapSighashTag = "TapSighash"
+
+// blankCodeSepPos is the code separator position committed to by tapscript
+// signatures when no OpCodeSeparator has been executed.
+const blankCodeSepPos uint32 = 0xffffffff
+
+var (
+	// ErrInvalidTaprootSigHashType is the result of a taproot signature with
+	// a hash type other than those defined by BIP341.
+	ErrInvalidTaprootSigHashType = scriptError(ErrCodeInvalidTaprootSigHashType, "invalid taproot signature hash type")
+
+	// ErrTaprootSigHashSingle is the result of a taproot SigHashSingle
+	// signature for an input without a corresponding output.
+	ErrTaprootSigHashSingle = scriptError(ErrCodeTaprootSigHashSingle, "taproot SIGHASH_SINGLE without corresponding output")
+
+	// ErrMissingPrevOuts is the result of computing a taproot signature hash
+	// without the outputs spent by the transaction.
+	ErrMissingPrevOuts = scriptError(ErrCodeMissingPrevOuts, "taproot signature hash requires all spent outputs")
+)
+
+// isValidTaprootSigHashType returns whether hashType is allowed in a taproot
+// signature.
+func isValidTaprootSigHashType(hashType SigHashType) bool {
+	switch hashType {
+	case SigHashDefault, SigHashAll, SigHashNone, SigHashSingle,
+		SigHashAll | SigHashAnyOneCanPay, SigHashNone | SigHashAnyOneCanPay,
+		SigHashSingle | SigHashAnyOneCanPay:
+		return true
+	}
+	return false
+}
+
+// CalcTaprootSignatureHash returns the BIP341 signature hash of a key path
+// spend of input idx of tx for hashType, where prevOut is the output being
+// spent and annex is the annex of the witness, if any. sigHashes must have
+// been computed with the outputs spent by tx.
+func CalcTaprootSignatureHash(sigHashes *TxSigHashes, hashType SigHashType,
+	tx *protocol.MsgTx, idx int, prevOut *protocol.TxOut,
+	annex []byte) ([]byte, error) {
+	return calcTaprootSignatureHash(sigHashes, hashType, tx, idx, prevOut,
+		annex, nil, blankCodeSepPos)
+}
+
+// CalcTapscriptSignatureHash returns the BIP342 signature hash of a script
+// path spend of input idx of tx for hashType. In addition to the key path
+// signature hash, it commits to the tapleaf hash of the executing script and
+// the position of its last executed OpCodeSeparator.
+func CalcTapscriptSignatureHash(sigHashes *TxSigHashes, hashType SigHashType,
+	tx *protocol.MsgTx, idx int, prevOut *protocol.TxOut, annex []byte,
+	tapLeafHash []byte, codeSepPos uint32) ([]byte, error) {
+	return calcTaprootSignatureHash(sigHashes, hashType, tx, idx, prevOut,
+		annex, tapLeafHash, codeSepPos)
+}
+
+// calcTaprootSignatureHash returns the taproot signature hash. A nil
+// tapLeafHash selects the key path message, and a non-nil one the tapscript
+// message extension.
+func calcTaprootSignatureHash(sigHashes *TxSigHashes, hashType SigHashType,
+	tx *protocol.MsgTx, idx int, prevOut *protocol.TxOut, annex []byte,
+	tapLeafHash []byte, codeSepPos uint32) ([]byte, error) {
+	if idx < 0 || idx >= len(tx.Inputs) {
+		return nil, ErrInvalidInputIndex
+	}
+	if !isValidTaprootSigHashType(hashType) {
+		return nil, ErrInvalidTaprootSigHashType
+	}
+	if sigHashes == nil || !sigHashes.hasTaproot {
+		return nil, ErrMissingPrevOuts
+	}
+
+	baseType := hashType & 0x03
+	anyoneCanPay := hashType&SigHashAnyOneCanPay != 0
+	if baseType == SigHashSingle && idx >= len(tx.Outputs) {
+		return nil, ErrTaprootSigHashSingle
+	}
+
+	var buf bytes.Buffer
+	var b4 [4]byte
+
+	// The signature hash epoch, followed by the hash type.
+	buf.WriteByte(0x00)
+	buf.WriteByte(byte(hashType))
+
+	binary.LittleEndian.PutUint32(b4[:], uint32(tx.Version))
+	buf.Write(b4[:])
+	binary.LittleEndian.PutUint32(b4[:], tx.LockTime)
+	buf.Write(b4[:])
+
+	if !anyoneCanPay {
+		buf.Write(sigHashes.HashPrevOutsV1[:])
+		buf.Write(sigHashes.HashInputAmountsV1[:])
+		buf.Write(sigHashes.HashInputScriptsV1[:])
+		buf.Write(sigHashes.HashSequenceV1[:])
+	}
+	if baseType != SigHashNone && baseType != SigHashSingle {
+		buf.Write(sigHashes.HashOutputsV1[:])
+	}
+
+	// The spend type records whether this is a script path spend and
+	// whether an annex is present.
+	var spendType byte
+	if tapLeafHash != nil {
+		spendType |= 0x02
+	}
+	if annex != nil {
+		spendType |= 0x01
+	}
+	buf.WriteByte(spendType)
+
+	if anyoneCanPay {
+		in := tx.Inputs[idx]
+		err := in.PrevOutput.Serialize(&buf, legacySigHashProtocolVersion)
+		if err != nil {
+			return nil, err
+		}
+		err = writeTxOut(&buf, prevOut)
+		if err != nil {
+			return nil, err
+		}
+		binary.LittleEndian.PutUint32(b4[:], in.Sequence)
+		buf.Write(b4[:])
+	} else {
+		binary.LittleEndian.PutUint32(b4[:], uint32(idx))
+		buf.Write(b4[:])
+	}
+
+	if annex != nil {
+		var annexBuf bytes.Buffer
+		err := protocol.WriteCompactSize(&annexBuf,
+			legacySigHashProtocolVersion, uint64(len(annex)))
+		if err != nil {
+			return nil, err
+		}
+		annexBuf.Write(annex)
+		hash := hashing.SHA256H(annexBuf.Bytes())
+		buf.Write(hash[:])
+	}
+
+	if baseType == SigHashSingle {
+		var out bytes.Buffer
+		err := writeTxOut(&out, tx.Outputs[idx])
+		if err != nil {
+			return nil, err
+		}
+		hash := hashing.SHA256H(out.Bytes())
+		buf.Write(hash[:])
+	}
+
+	if tapLeafHash != nil {
+		buf.Write(tapLeafHash)
+		// The key version, which is zero for BIP342 public keys.
+		buf.WriteByte(0x00)
+		binary.LittleEndian.PutUint32(b4[:], codeSepPos)
+		buf.Write(b4[:])
+	}
+
+	hash := hashing.TaggedHash(tapSighashTag, buf.Bytes())
+	return hash[:], nil
+}
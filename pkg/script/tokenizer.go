@@ -0,0 +1,123 @@
+package script
+
+import "encoding/binary"
+
+// ErrMalformedPush is the result of a push operation which claims more data
+// than remains in the script.
+var ErrMalformedPush = scriptError(ErrCodeMalformedPush, "malformed push: script ends before pushed data")
+
+// A Tokenizer splits a raw script into its opcodes along with any data they
+// push. The zero value is not usable; use NewTokenizer.
+//
+// Typical usage:
+//
+//	t := NewTokenizer(script)
+//	for t.Next() {
+//		// Use t.Opcode() and t.Data().
+//	}
+//	if err := t.Err(); err != nil {
+//		// Handle the malformed script.
+//	}
+type Tokenizer struct {
+	script []byte
+	offset int
+	op     opCode
+	data   []byte
+	err    error
+}
+
+// NewTokenizer returns a tokenizer positioned before the first opcode of
+// script.
+func NewTokenizer(script []byte) *Tokenizer {
+	return &Tokenizer{script: script}
+}
+
+// Done returns whether the tokenizer has consumed the whole script or has
+// stopped due to an error.
+func (t *Tokenizer) Done() bool {
+	return t.err != nil || t.offset >= len(t.script)
+}
+
+// Next advances the tokenizer to the next opcode in the script. It returns
+// false when the end of the script is reached or the script is malformed, in
+// which case Err reports the reason.
+func (t *Tokenizer) Next() bool {
+	if t.Done() {
+		return false
+	}
+
+	op := opCode(t.script[t.offset])
+	rest := t.script[t.offset+1:]
+
+	// Determine how much data the opcode pushes and where that data begins
+	// within the rest of the script.
+	var dataLen, prefixLen int
+	switch {
+	case op >= OpData1 && op <= OpData75:
+		dataLen = int(op)
+	case op == OpPushData1:
+		prefixLen = 1
+		if len(rest) >= prefixLen {
+			dataLen = int(rest[0])
+		}
+	case op == OpPushData2:
+		prefixLen = 2
+		if len(rest) >= prefixLen {
+			dataLen = int(binary.LittleEndian.Uint16(rest))
+		}
+	case op == OpPushData4:
+		prefixLen = 4
+		if len(rest) >= prefixLen {
+			// Guard against lengths which overflow an int on 32-bit
+			// platforms; no script is anywhere near this large.
+			n := binary.LittleEndian.Uint32(rest)
+			if uint64(n) > uint64(len(rest)) {
+				t.fail()
+				return false
+			}
+			dataLen = int(n)
+		}
+	}
+
+	if len(rest) < prefixLen+dataLen {
+		t.fail()
+		return false
+	}
+
+	t.op = op
+	t.data = nil
+	if op.isDataPush() {
+		t.data = rest[prefixLen : prefixLen+dataLen]
+	}
+	t.offset += 1 + prefixLen + dataLen
+	return true
+}
+
+// fail stops the tokenizer due to a malformed push.
+func (t *Tokenizer) fail() {
+	t.err = ErrMalformedPush
+	t.op = 0
+	t.data = nil
+}
+
+// Opcode returns the current opcode.
+func (t *Tokenizer) Opcode() byte {
+	return byte(t.op)
+}
+
+// Data returns the data pushed by the current opcode, if any. The returned
+// slice aliases the underlying script.
+func (t *Tokenizer) Data() []byte {
+	return t.data
+}
+
+// ByteIndex returns the offset into the script of the byte following the
+// current opcode and its data.
+func (t *Tokenizer) ByteIndex() int {
+	return t.offset
+}
+
+// Err returns the error which stopped the tokenizer, if any.
+func (t *Tokenizer) Err() error {
+	return t.err
+}
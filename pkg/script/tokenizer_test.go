@@ -0,0 +1,129 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// A token is an opcode with the data it pushes.
+type token struct {
+	op   opCode
+	data []byte
+}
+
+// tokenize returns the tokens of script and the error which stopped the
+// tokenizer.
+func tokenize(script []byte) ([]token, error) {
+	var tokens []token
+	t := NewTokenizer(script)
+	for t.Next() {
+		tokens = append(tokens, token{opCode(t.Opcode()), t.Data()})
+	}
+	return tokens, t.Err()
+}
+
+func TestTokenizer(t *testing.T) {
+	data := func(n int) []byte { return bytes.Repeat([]byte{0xab}, n) }
+	tests := []struct {
+		name   string
+		script string
+		tokens []token
+		err    error
+	}{
+		{"empty", "", nil, nil},
+		{"opcodes", "0051ae", []token{{Op0, nil}, {Op1, nil}, {OpCheckMultiSig, nil}}, nil},
+		{"data1", "01ab", []token{{OpData1, data(1)}}, nil},
+		{"data75", "4b" + hex.EncodeToString(data(75)), []token{{OpData75, data(75)}}, nil},
+		{"pushdata1", "4c02abab", []token{{OpPushData1, data(2)}}, nil},
+		{"pushdata1 empty", "4c00", []token{{OpPushData1, []byte{}}}, nil},
+		{"pushdata2", "4d0200abab", []token{{OpPushData2, data(2)}}, nil},
+		{"pushdata4", "4e02000000abab", []token{{OpPushData4, data(2)}}, nil},
+		{"push then opcode", "02abab87", []token{{OpData1 + 1, data(2)}, {OpEqual, nil}}, nil},
+		{"data1 short", "01", nil, ErrMalformedPush},
+		{"data2 short", "5102ab", []token{{Op1, nil}}, ErrMalformedPush},
+		{"pushdata1 no length", "4c", nil, ErrMalformedPush},
+		{"pushdata1 short", "4c02ab", nil, ErrMalformedPush},
+		{"pushdata2 no length", "4d01", nil, ErrMalformedPush},
+		{"pushdata2 short", "4d0100", nil, ErrMalformedPush},
+		{"pushdata4 no length", "4e010000", nil, ErrMalformedPush},
+		{"pushdata4 huge", "4effffffffab", nil, ErrMalformedPush},
+	}
+	for _, test := range tests {
+		script, err := hex.DecodeString(test.script)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		tokens, err := tokenize(script)
+		if err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.err)
+		}
+		if len(tokens) != len(test.tokens) {
+			t.Errorf("%s: got %d tokens, want %d", test.name, len(tokens),
+				len(test.tokens))
+			continue
+		}
+		for i, tok := range tokens {
+			want := test.tokens[i]
+			if tok.op != want.op || !bytes.Equal(tok.data, want.data) {
+				t.Errorf("%s: token %d is %v %x, want %v %x", test.name, i,
+					tok.op, tok.data, want.op, want.data)
+			}
+		}
+	}
+}
+
+func TestTokenizerByteIndex(t *testing.T) {
+	script, _ := hex.DecodeString("004c02abab4d0100ab51")
+	want := []int{1, 5, 9, 10}
+	tok := NewTokenizer(script)
+	for i := 0; tok.Next(); i++ {
+		if tok.ByteIndex() != want[i] {
+			t.Errorf("opcode %d: byte index %d, want %d", i, tok.ByteIndex(),
+				want[i])
+		}
+	}
+	if !tok.Done() || tok.Err() != nil {
+		t.Fatalf("tokenizer not done: %v", tok.Err())
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	// Every opcode, with non-minimal pushes kept as they are.
+	var scripts [][]byte
+	for op := 0; op <= 0xff; op++ {
+		switch opCode(op) {
+		case OpPushData1:
+			scripts = append(scripts, []byte{byte(op), 1, 0x51})
+		case OpPushData2:
+			scripts = append(scripts, []byte{byte(op), 1, 0, 0x51})
+		case OpPushData4:
+			scripts = append(scripts, []byte{byte(op), 1, 0, 0, 0, 0x51})
+		default:
+			script := append([]byte{byte(op)}, make([]byte, op)...)
+			if opCode(op) > OpData75 {
+				script = []byte{byte(op)}
+			}
+			scripts = append(scripts, script)
+		}
+	}
+	big := make([]byte, 0x10000)
+	scripts = append(scripts,
+		append([]byte{byte(OpPushData2), 0xff, 0xff}, big[:0xffff]...),
+		append([]byte{byte(OpPushData4), 0, 0, 1, 0}, big...),
+	)
+
+	for _, script := range scripts {
+		s, err := Parse(script)
+		if err != nil {
+			t.Fatalf("opcode %x: %v", script[0], err)
+		}
+		if got := s.Bytes(); !bytes.Equal(got, script) {
+			t.Errorf("opcode %x: round trip gave a different script", script[0])
+		}
+	}
+
+	if _, err := Parse([]byte{2, 0}); err != ErrMalformedPush {
+		t.Fatalf("malformed script parsed: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package script
+
+// A StepState is a snapshot of an engine taken around the execution of a
+// single instruction.
+type StepState struct {
+	// ScriptIdx is the index of the executing script: 0 for the unlocking
+	// script and 1 for the locking script, followed by any redeem or
+	// witness script.
+	ScriptIdx int
+
+	// OpIdx is the program counter, the index of the instruction within
+	// its script.
+	OpIdx int
+
+	// Opcode is the opcode of the instruction and Data is any data it
+	// pushes.
+	Opcode byte
+	Data   []byte
+
+	// Stack and AltStack are copies of the data and alt stacks, ordered
+	// from bottom to top.
+	Stack    [][]byte
+	AltStack [][]byte
+
+	// Executing reports whether the current conditional branch is
+	// executing, and CondDepth is the number of enclosing conditionals.
+	Executing bool
+	CondDepth int
+}
+
+// A Tracer observes the execution of an engine one instruction at a time.
+type Tracer interface {
+	// BeforeStep is called before the instruction described by state
+	// executes.
+	BeforeStep(state *StepState)
+
+	// AfterStep is called after the instruction described by state has
+	// executed, with the error it failed with, if any. The stacks and
+	// conditional state reflect its execution.
+	AfterStep(state *StepState, err error)
+}
+
+// SetTracer sets the tracer which observes the instructions executed by the
+// engine. A nil tracer disables tracing.
+func (vm *Engine) SetTracer(tracer Tracer) {
+	vm.tracer = tracer
+}
+
+// stepState returns a snapshot of the engine at instr, the current
+// instruction.
+func (vm *Engine) stepState(instr *instruction) *StepState {
+	return &StepState{
+		ScriptIdx: vm.scriptIdx,
+		OpIdx:     vm.opIdx,
+		Opcode:    byte(instr.op),
+		Data:      instr.data,
+		Stack:     copyStack(vm.dstack.items),
+		AltStack:  copyStack(vm.astack.items),
+		Executing: vm.isBranchExecuting(),
+		CondDepth: len(vm.condStack),
+	}
+}
+
+// copyStack returns a copy of the items of a stack.
+func copyStack(items [][]byte) [][]byte {
+	c := make([][]byte, len(items))
+	for i, item := range items {
+		c[i] = append([]byte(nil), item...)
+	}
+	return c
+}
+
+// Instruction returns the instruction described by s in ASM form.
+func (s *StepState) Instruction() string {
+	instr := instruction{op: opCode(s.Opcode), data: s.Data}
+	return Disassemble(instr.bytes())
+}
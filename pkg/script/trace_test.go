@@ -0,0 +1,105 @@
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A recordTracer records each step of an engine as a line giving the
+// position and instruction, whether it executes, the stack before and after
+// it, the alt stack and conditional depth after it, and its error.
+type recordTracer struct {
+	t      *testing.T
+	before *StepState
+	steps  []string
+}
+
+func (r *recordTracer) BeforeStep(state *StepState) {
+	if r.before != nil {
+		r.t.Errorf("step %d:%d began before %d:%d ended", state.ScriptIdx,
+			state.OpIdx, r.before.ScriptIdx, r.before.OpIdx)
+	}
+	r.before = state
+}
+
+func (r *recordTracer) AfterStep(state *StepState, err error) {
+	before := r.before
+	r.before = nil
+	if before == nil || state.ScriptIdx != before.ScriptIdx ||
+		state.OpIdx != before.OpIdx {
+		r.t.Errorf("step %d:%d ended without beginning", state.ScriptIdx,
+			state.OpIdx)
+		return
+	}
+	r.steps = append(r.steps, fmt.Sprintf("%d:%d %s executing=%v %s -> %s alt %s depth %d: %v",
+		state.ScriptIdx, state.OpIdx, state.Instruction(), before.Executing,
+		stackString(before.Stack), stackString(state.Stack),
+		stackString(state.AltStack), state.CondDepth, err))
+}
+
+// stackString returns the items of stack from bottom to top in hex, with
+// empty items as <>.
+func stackString(stack [][]byte) string {
+	items := make([]string, len(stack))
+	for i, item := range stack {
+		items[i] = hex.EncodeToString(item)
+		if len(item) == 0 {
+			items[i] = "<>"
+		}
+	}
+	return "[" + strings.Join(items, " ") + "]"
+}
+
+func TestTracer(t *testing.T) {
+	tests := []struct {
+		name   string
+		unlock string
+		lock   string
+		steps  []string
+		err    error
+	}{
+		{"success", "5 0", "OP_IF OP_RETURN OP_ENDIF OP_TOALTSTACK 1", []string{
+			"0:0 5 executing=true [] -> [05] alt [] depth 0: <nil>",
+			"0:1 0 executing=true [05] -> [05 <>] alt [] depth 0: <nil>",
+			"1:0 OP_IF executing=true [05 <>] -> [05] alt [] depth 1: <nil>",
+			"1:1 OP_RETURN executing=false [05] -> [05] alt [] depth 1: <nil>",
+			"1:2 OP_ENDIF executing=false [05] -> [05] alt [] depth 0: <nil>",
+			"1:3 OP_TOALTSTACK executing=true [05] -> [] alt [05] depth 0: <nil>",
+			"1:4 1 executing=true [] -> [01] alt [05] depth 0: <nil>",
+		}, nil},
+		{"failure", "0", "OP_VERIFY 1", []string{
+			"0:0 0 executing=true [] -> [<>] alt [] depth 0: <nil>",
+			"1:0 OP_VERIFY executing=true [<>] -> [] alt [] depth 0: " +
+				ErrVerify.Error(),
+		}, ErrVerify},
+	}
+	for _, test := range tests {
+		unlock, err := Assemble(test.unlock)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		lock, err := Assemble(test.lock)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		vm, err := NewEngine(unlock, lock, nil, testTx(), 0, 0,
+			ScriptVerifyNone, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		tracer := &recordTracer{t: t}
+		vm.SetTracer(tracer)
+		if err := vm.Execute(); err != test.err {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+		if !reflect.DeepEqual(tracer.steps, test.steps) {
+			t.Errorf("%s: got steps", test.name)
+			for _, step := range tracer.steps {
+				t.Log(step)
+			}
+		}
+	}
+}
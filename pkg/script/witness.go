@@ -0,0 +1,194 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Sizes of version 0 witness programs.
+const (
+	// payToWitnessPubKeyHashSize is the size of a P2WPKH program, which is
+	// the Hash160 of a public key.
+	payToWitnessPubKeyHashSize = 20
+
+	// payToWitnessScriptHashSize is the size of a P2WSH program, which is
+	// the SHA-256 hash of a witness script.
+	payToWitnessScriptHashSize = 32
+)
+
+var (
+	// ErrNotWitnessProgram is returned when extracting the witness program
+	// of a script which is not one.
+	ErrNotWitnessProgram = scriptError(ErrCodeNotWitnessProgram, "script is not a witness program")
+
+	// ErrWitnessMalleated is the result of spending a native witness
+	// program with a non-empty unlocking script.
+	ErrWitnessMalleated = scriptError(ErrCodeWitnessMalleated, "witness program spent with non-empty unlocking script")
+
+	// ErrWitnessMalleatedP2SH is the result of spending a P2SH-wrapped
+	// witness program with an unlocking script which is not a single push
+	// of the redeem script.
+	ErrWitnessMalleatedP2SH = scriptError(ErrCodeWitnessMalleatedP2SH, "P2SH witness program spent with invalid unlocking script")
+
+	// ErrWitnessUnexpected is the result of providing witness data for an
+	// input which does not spend a witness program.
+	ErrWitnessUnexpected = scriptError(ErrCodeWitnessUnexpected, "unexpected witness data")
+
+	// ErrWitnessProgramEmpty is the result of spending a witness program
+	// with an empty witness.
+	ErrWitnessProgramEmpty = scriptError(ErrCodeWitnessProgramEmpty, "witness program spent with empty witness")
+
+	// ErrWitnessProgramMismatch is the result of a witness which does not
+	// match the witness program it spends.
+	ErrWitnessProgramMismatch = scriptError(ErrCodeWitnessProgramMismatch, "witness does not match witness program")
+
+	// ErrWitnessProgramWrongLength is the result of spending a version 0
+	// witness program which is neither 20 nor 32 bytes long.
+	ErrWitnessProgramWrongLength = scriptError(ErrCodeWitnessProgramWrongLength, "version 0 witness program has wrong length")
+
+	// ErrWitnessPubKeyType is the result of a signature check with an
+	// uncompressed public key in a witness script when
+	// ScriptVerifyWitnessPubKeyType is set.
+	ErrWitnessPubKeyType = scriptError(ErrCodeWitnessPubKeyType, "witness public keys must be compressed")
+
+	// ErrSigPushOnly is the result of spending a P2SH output with an
+	// unlocking script which contains operations other than pushes.
+	ErrSigPushOnly = scriptError(ErrCodeSigPushOnly, "unlocking script is not push only")
+
+	// ErrCleanStack is the result of a witness script which does not leave
+	// exactly one item on the stack.
+	ErrCleanStack = scriptError(ErrCodeCleanStack, "stack must contain exactly one item")
+
+	// ErrMinimalIf is the result of an OpIf or OpNotIf in a tapscript, or
+	// in a witness script when ScriptVerifyMinimalIf is set, whose argument
+	// is neither empty nor exactly 0x01.
+	ErrMinimalIf = scriptError(ErrCodeMinimalIf, "conditional argument must be empty or 0x01")
+)
+
+// IsWitnessProgram returns whether script is a witness program: a version
+// opcode (Op0 or Op1 through Op16) followed by a single direct push of 2 to 40
+// bytes.
+func IsWitnessProgram(script []byte) bool {
+	_, _, ok := extractWitnessProgram(script)
+	return ok
+}
+
+// ExtractWitnessProgram returns the version and program of the witness
+// program script. An error is returned if script is not a witness program.
+func ExtractWitnessProgram(script []byte) (int, []byte, error) {
+	version, program, ok := extractWitnessProgram(script)
+	if !ok {
+		return 0, nil, ErrNotWitnessProgram
+	}
+	return version, program, nil
+}
+
+// extractWitnessProgram returns the version and program of script and
+// whether script is a witness program.
+func extractWitnessProgram(script []byte) (int, []byte, bool) {
+	if len(script) < 4 || len(script) > 42 {
+		return 0, nil, false
+	}
+
+	version := opCode(script[0])
+	if version != Op0 && (version < Op1 || version > Op16) {
+		return 0, nil, false
+	}
+	if int(script[1])+2 != len(script) {
+		return 0, nil, false
+	}
+
+	v := 0
+	if version != Op0 {
+		v = int(version-Op1) + 1
+	}
+	return v, script[2:], true
+}
+
+// isScriptHash returns whether script is a pay-to-script-hash (BIP16) locking
+// script: OpHash160 <20 bytes> OpEqual.
+func isScriptHash(script []byte) bool {
+	return len(script) == 23 &&
+		opCode(script[0]) == OpHash160 &&
+		script[1] == 20 &&
+		opCode(script[22]) == OpEqual
+}
+
+// isPushOnly returns whether s contains only push operations. As in the
+// original implementation, OpReserved counts as a push.
+func (s *Script) isPushOnly() bool {
+	for _, instr := range s.instructions {
+		if instr.op > Op16 {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyWitnessProgram executes the witness of the input against its witness
+// program. Witness programs of unknown versions are left for future soft forks
+// and succeed without executing anything.
+func (vm *Engine) verifyWitnessProgram() error {
+	switch {
+	case vm.witnessVersion == 1 && len(vm.witnessProgram) == payToTaprootSize &&
+		!vm.bip16 && vm.flags.HasFlag(ScriptVerifyTaproot):
+		// Taproot applies only to native witness programs.
+		return vm.verifyTaproot()
+	case vm.witnessVersion != 0:
+		return nil
+	}
+
+	var script []byte
+	var stack [][]byte
+	switch len(vm.witnessProgram) {
+	case payToWitnessPubKeyHashSize:
+		// The witness is a signature and public key, which are checked
+		// by the equivalent pay-to-pubkey-hash script.
+		if len(vm.witness) != 2 {
+			return ErrWitnessProgramMismatch
+		}
+		script = payToPubKeyHashScript(vm.witnessProgram)
+		stack = vm.witness
+
+	case payToWitnessScriptHashSize:
+		// The last witness item is the witness script, which must hash
+		// to the program, and the others are its initial stack.
+		if len(vm.witness) == 0 {
+			return ErrWitnessProgramEmpty
+		}
+		script = vm.witness[len(vm.witness)-1]
+		hash := sha256.Sum256(script)
+		if !bytes.Equal(hash[:], vm.witnessProgram) {
+			return ErrWitnessProgramMismatch
+		}
+		stack = vm.witness[:len(vm.witness)-1]
+		if len(script) > MaxScriptSize {
+			return ErrScriptTooBig
+		}
+
+	default:
+		return ErrWitnessProgramWrongLength
+	}
+
+	err := checkStackItemSizes(stack)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := Parse(script)
+	if err != nil {
+		return err
+	}
+	vm.scripts = append(vm.scripts, parsed)
+	vm.dstack.items = append([][]byte(nil), stack...)
+	vm.witnessExec = true
+	return nil
+}
+
+// payToPubKeyHashScript returns the pay-to-pubkey-hash locking script for
+// pubKeyHash: OpDup OpHash160 <pubKeyHash> OpEqualVerify OpCheckSig.
+func payToPubKeyHashScript(pubKeyHash []byte) []byte {
+	b := []byte{byte(OpDup), byte(OpHash160)}
+	b = append(b, pushDataBytes(pubKeyHash)...)
+	return append(b, byte(OpEqualVerify), byte(OpCheckSig))
+}
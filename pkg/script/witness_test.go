@@ -0,0 +1,146 @@
+package script
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec/schnorr"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+)
+
+// testTx returns a transaction with two inputs and one output to sign and
+// verify spends with.
+func testTx() *protocol.MsgTx {
+	prevHash := &[protocol.HashSize]byte{7}
+	return &protocol.MsgTx{
+		Version: 1,
+		Inputs: []*protocol.TxIn{
+			{
+				PrevOutput: protocol.TxOutPoint{Hash: prevHash, Index: 0},
+				Sequence:   0xffffffff,
+			},
+			{
+				PrevOutput: protocol.TxOutPoint{Hash: prevHash, Index: 1},
+				Sequence:   0xffffffff,
+			},
+		},
+		Outputs: []*protocol.TxOut{
+			{Value: 1000, ScriptLock: []byte{byte(Op1)}, ScriptLockSize: 1},
+		},
+	}
+}
+
+// testKey returns the private key with the given scalar.
+func testKey(d byte) *btcec.PrivateKey {
+	key, _ := btcec.PrivKeyFromBytes([]byte{d})
+	return key
+}
+
+// payToWitnessScript returns the P2WSH output script of witnessScript.
+func payToWitnessScript(t *testing.T, witnessScript []byte) []byte {
+	hash := sha256.Sum256(witnessScript)
+	lock, err := PayToWitnessScriptHashScript(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lock
+}
+
+// payToTapscript returns the output script of a taproot output whose only
+// script is leaf, and the control block which spends it.
+func payToTapscript(t *testing.T, leaf []byte) ([]byte, []byte) {
+	internalKey := testKey(1).PubKey()
+	outputKey, err := ComputeTaprootOutputKey(internalKey,
+		TapLeafHash(BaseLeafVersion, leaf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := PayToTaprootScript(schnorr.SerializePubKey(outputKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	controlBlock := append([]byte{BaseLeafVersion | byte(outputKey.Y.Bit(0))},
+		schnorr.SerializePubKey(internalKey)...)
+	return lock, controlBlock
+}
+
+func TestWitnessPubKeyType(t *testing.T) {
+	const amount = 5000
+	tx := testTx()
+	key := testKey(9)
+	witnessScript, err := NewScriptBuilder().
+		AddData(key.PubKey().SerializeUncompressed()).
+		AddOp(byte(OpCheckSig)).
+		Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigHashes, err := NewTxSigHashes(tx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := RawTxInWitnessSignature(tx, sigHashes, 0, amount,
+		witnessScript, SigHashAll, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := payToWitnessScript(t, witnessScript)
+	witness := protocol.TxWitness{sig, witnessScript}
+
+	// Uncompressed keys are valid by consensus and only rejected by
+	// policy.
+	tests := []struct {
+		flags ScriptFlags
+		err   error
+	}{
+		{ConsensusVerifyFlags, nil},
+		{ConsensusVerifyFlags | ScriptVerifyWitnessPubKeyType, ErrWitnessPubKeyType},
+		{StandardVerifyFlags, ErrWitnessPubKeyType},
+	}
+	for _, test := range tests {
+		err := VerifyScript(nil, lock, witness, tx, 0, amount, test.flags)
+		if err != test.err {
+			t.Errorf("flags %#x: got %v, want %v", test.flags, err, test.err)
+		}
+	}
+}
+
+func TestMinimalIf(t *testing.T) {
+	tx := testTx()
+	leaf := []byte{byte(OpIf), byte(Op1), byte(OpElse), byte(Op1), byte(OpEndIf)}
+	witnessLock := payToWitnessScript(t, leaf)
+	tapLock, controlBlock := payToTapscript(t, leaf)
+
+	// A non-minimal argument is valid by consensus in a version 0 witness
+	// script, but never in a tapscript.
+	tests := []struct {
+		name      string
+		tapscript bool
+		arg       []byte
+		flags     ScriptFlags
+		err       error
+	}{
+		{"witness v0 minimal", false, []byte{1}, StandardVerifyFlags, nil},
+		{"witness v0 consensus", false, []byte{2}, ConsensusVerifyFlags, nil},
+		{"witness v0 policy", false, []byte{2}, ConsensusVerifyFlags | ScriptVerifyMinimalIf, ErrMinimalIf},
+		{"witness v0 false", false, []byte{0}, StandardVerifyFlags, ErrMinimalIf},
+		{"tapscript minimal", true, []byte{1}, ConsensusVerifyFlags, nil},
+		{"tapscript consensus", true, []byte{2}, ConsensusVerifyFlags, ErrMinimalIf},
+	}
+	for _, test := range tests {
+		lock := witnessLock
+		witness := protocol.TxWitness{test.arg, leaf}
+		if test.tapscript {
+			lock = tapLock
+			witness = append(witness, controlBlock)
+		}
+		vm, err := NewEngine(nil, lock, witness, tx, 0, 0, test.flags, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if err := vm.Execute(); err != test.err {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package util
 
 import (
+	"bytes"
 	"math"
 	"time"
 
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
 	"github.com/jacobkaufmann/gocoin/pkg/protocol"
 )
 
@@ -37,6 +39,21 @@ func NewBlockFromMsg(msg *protocol.MsgBlock) *Block {
 	return blk
 }
 
+// BlockHash returns the block hash (double-SHA256 hash of the block header) of
+// blk.
+func (blk *Block) BlockHash(pver uint32) (*hashing.Hash, error) {
+	var buf bytes.Buffer
+	err := blk.BlockHeader.Serialize(&buf, pver)
+	if err != nil {
+		return nil, err
+	}
+
+	// The serialized header is followed by the transaction count, which is
+	// not part of the hashed header.
+	blkHash := hashing.DoubleSHA256H(buf.Bytes()[:protocol.BlockHeaderSize])
+	return &blkHash, nil
+}
+
 // maxNonce is a convenience variable representing the maximum possible nonce
 // value.
 const maxNonce = math.MaxUint32
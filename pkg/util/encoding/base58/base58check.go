@@ -18,6 +18,13 @@ const (
 
 	// PrivateKeyWIF refers to a Private key WIF (Wallet Import Format).
 	PrivateKeyWIF VersionPrefix = 128
+
+	// TestNetAddress refers to a Bitcoin address on the test network.
+	TestNetAddress VersionPrefix = 111
+
+	// TestNetP2SH refers to a Pay-to-Script-Hash address on the test
+	// network.
+	TestNetP2SH VersionPrefix = 196
 )
 
 var (
@@ -62,12 +69,12 @@ func DecodeCheck(src string) (dst []byte, version byte, err error) {
 	// Extract checksum and verify.
 	var check [4]byte
 	copy(check[:], decoded[l-4:])
-	if checksum(decoded[l-4:]) != check {
+	if checksum(decoded[:l-4]) != check {
 		return nil, 0, ErrCheckSum
 	}
 
 	// Extract version and payload.
 	version = decoded[0]
-	copy(dst, decoded[1:l-4])
+	dst = decoded[1 : l-4]
 	return
 }
@@ -0,0 +1,258 @@
+package bech32
+
+import (
+	"errors"
+	"strings"
+)
+
+// Encoding represents a bech32 checksum variant.
+type Encoding int
+
+const (
+	// Bech32 is the original checksum of BIP173, used by version 0 witness
+	// addresses.
+	Bech32 Encoding = iota + 1
+
+	// Bech32m is the checksum of BIP350, used by witness addresses of
+	// version 1 and above.
+	Bech32m
+)
+
+// charset is the bech32 alphabet.
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Checksum constants of each encoding.
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// maxLength is the maximum length of an encoded string.
+const maxLength = 90
+
+var (
+	// ErrInvalidCharacter indicates that a bech32 string has a character
+	// outside of the bech32 alphabet.
+	ErrInvalidCharacter = errors.New("invalid bech32 character")
+
+	// ErrInvalidLength indicates that a bech32 string is too long or too
+	// short to hold a checksum.
+	ErrInvalidLength = errors.New("invalid bech32 string length")
+
+	// ErrMixedCase indicates that a bech32 string mixes upper and lower
+	// case characters.
+	ErrMixedCase = errors.New("bech32 string has mixed case")
+
+	// ErrInvalidChecksum indicates that a bech32 string does not verify
+	// against its checksum.
+	ErrInvalidChecksum = errors.New("invalid bech32 checksum")
+
+	// ErrInvalidPadding indicates that 5-bit data converted to bytes has
+	// non-zero or excess padding.
+	ErrInvalidPadding = errors.New("invalid padding")
+
+	// ErrInvalidFormat indicates that a segwit address has the wrong
+	// human-readable part or no witness version.
+	ErrInvalidFormat = errors.New("invalid segwit address format")
+
+	// ErrInvalidWitnessVersion indicates a witness version above 16 or
+	// encoded with the wrong checksum.
+	ErrInvalidWitnessVersion = errors.New("invalid witness version")
+
+	// ErrInvalidProgramLength indicates a witness program of invalid length
+	// for its version.
+	ErrInvalidProgramLength = errors.New("invalid witness program length")
+)
+
+// polymod computes the bech32 checksum polynomial of values.
+func polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+// hrpExpand returns the human-readable part expanded for checksumming.
+func hrpExpand(hrp string) []byte {
+	b := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		b = append(b, hrp[i]>>5)
+	}
+	b = append(b, 0)
+	for i := 0; i < len(hrp); i++ {
+		b = append(b, hrp[i]&31)
+	}
+	return b
+}
+
+// checksumConst returns the constant the checksum of enc must produce.
+func checksumConst(enc Encoding) uint32 {
+	if enc == Bech32m {
+		return bech32mConst
+	}
+	return bech32Const
+}
+
+// Encode encodes the 5-bit values of data with the human-readable part hrp
+// and the checksum of enc.
+func Encode(hrp string, data []byte, enc Encoding) (string, error) {
+	hrp = strings.ToLower(hrp)
+	if len(hrp)+1+len(data)+6 > maxLength || len(hrp) == 0 {
+		return "", ErrInvalidLength
+	}
+
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := polymod(values) ^ checksumConst(enc)
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range data {
+		if v >= 32 {
+			return "", ErrInvalidCharacter
+		}
+		sb.WriteByte(charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(charset[(mod>>uint(5*(5-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// Decode decodes a bech32 string into its human-readable part and 5-bit
+// values, and returns which encoding its checksum verifies against.
+func Decode(s string) (string, []byte, Encoding, error) {
+	if len(s) > maxLength {
+		return "", nil, 0, ErrInvalidLength
+	}
+	lower := strings.ToLower(s)
+	if lower != s && strings.ToUpper(s) != s {
+		return "", nil, 0, ErrMixedCase
+	}
+	s = lower
+
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, 0, ErrInvalidLength
+	}
+	hrp := s[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, ErrInvalidCharacter
+		}
+	}
+
+	data := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(charset, s[i])
+		if v < 0 {
+			return "", nil, 0, ErrInvalidCharacter
+		}
+		data = append(data, byte(v))
+	}
+
+	var enc Encoding
+	switch polymod(append(hrpExpand(hrp), data...)) {
+	case bech32Const:
+		enc = Bech32
+	case bech32mConst:
+		enc = Bech32m
+	default:
+		return "", nil, 0, ErrInvalidChecksum
+	}
+	return hrp, data[:len(data)-6], enc, nil
+}
+
+// ConvertBits regroups data from groups of fromBits bits into groups of
+// toBits bits. When pad is set, a final incomplete group is padded with
+// zeros; otherwise it must consist of fewer than fromBits zero bits.
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, ErrInvalidCharacter
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, ErrInvalidPadding
+	}
+	return out, nil
+}
+
+// EncodeSegWitAddress encodes a witness program of the given version as a
+// segwit address with the human-readable part hrp. Version 0 programs use the
+// Bech32 checksum and later versions the Bech32m checksum.
+func EncodeSegWitAddress(hrp string, version byte, program []byte) (string, error) {
+	if version > 16 {
+		return "", ErrInvalidWitnessVersion
+	}
+	if !validProgramLength(version, len(program)) {
+		return "", ErrInvalidProgramLength
+	}
+
+	enc := Bech32m
+	if version == 0 {
+		enc = Bech32
+	}
+	data, err := ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return Encode(hrp, append([]byte{version}, data...), enc)
+}
+
+// DecodeSegWitAddress decodes a segwit address with the human-readable part
+// hrp into its witness version and program.
+func DecodeSegWitAddress(hrp, addr string) (byte, []byte, error) {
+	gotHRP, data, enc, err := Decode(addr)
+	if err != nil {
+		return 0, nil, err
+	}
+	if gotHRP != strings.ToLower(hrp) || len(data) == 0 {
+		return 0, nil, ErrInvalidFormat
+	}
+
+	version := data[0]
+	if version > 16 || (version == 0) != (enc == Bech32) {
+		return 0, nil, ErrInvalidWitnessVersion
+	}
+	program, err := ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !validProgramLength(version, len(program)) {
+		return 0, nil, ErrInvalidProgramLength
+	}
+	return version, program, nil
+}
+
+// validProgramLength returns whether n is a valid length for a witness
+// program of the given version.
+func validProgramLength(version byte, n int) bool {
+	if n < 2 || n > 40 {
+		return false
+	}
+	return version != 0 || n == 20 || n == 32
+}
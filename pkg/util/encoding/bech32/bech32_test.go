@@ -0,0 +1,193 @@
+package bech32
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// TestDecode checks the strings of BIP173 and BIP350. Each valid string is
+// valid under exactly one of the checksums, and encodes back to itself in
+// lower case.
+func TestDecode(t *testing.T) {
+	valid := []struct {
+		s   string
+		enc Encoding
+	}{
+		{"A12UEL5L", Bech32},
+		{"a12uel5l", Bech32},
+		{"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs", Bech32},
+		{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", Bech32},
+		{"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j", Bech32},
+		{"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", Bech32},
+		{"?1ezyfcl", Bech32},
+		{"A1LQFN3A", Bech32m},
+		{"a1lqfn3a", Bech32m},
+		{"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6", Bech32m},
+		{"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", Bech32m},
+		{"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8", Bech32m},
+		{"split1checkupstagehandshakeupstreamerranterredcaperredlc445v", Bech32m},
+		{"?1v759aa", Bech32m},
+	}
+	for _, test := range valid {
+		hrp, data, enc, err := Decode(test.s)
+		if err != nil {
+			t.Errorf("%s: %v", test.s, err)
+			continue
+		}
+		if enc != test.enc {
+			t.Errorf("%s: got encoding %d, want %d", test.s, enc, test.enc)
+		}
+		s, err := Encode(hrp, data, enc)
+		if err != nil {
+			t.Errorf("%s: %v", test.s, err)
+			continue
+		}
+		if s != strings.ToLower(test.s) {
+			t.Errorf("%s: encoded as %s", test.s, s)
+		}
+	}
+
+	invalid := []string{
+		// HRP characters out of range.
+		"\x201nwldj5",
+		"\x7f1axkwrx",
+		"\x801eym55h",
+		"\x201xj0phk",
+		"\x7f1g6xzxy",
+		"\x801vctc34",
+		// Overall maximum length exceeded.
+		"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx",
+		"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4",
+		// No separator.
+		"pzry9x0s0muk",
+		"qyrz8wqd2c9m",
+		// Empty HRP.
+		"1pzry9x0s0muk",
+		"1qyrz8wqd2c9m",
+		"10a06t8",
+		"1qzzfhee",
+		"16plkw9",
+		"1p2gdwpf",
+		// Invalid data character.
+		"x1b4n0q5v",
+		"y1b0jsk6g",
+		"lt1igcx5c0",
+		// Checksum too short.
+		"li1dgmt3",
+		"in1muywd",
+		// Invalid character in checksum.
+		"de1lg7wt\xff",
+		"mm1crxm3i",
+		"au1s5cgom",
+		// Checksum computed with the upper case HRP.
+		"A1G7SGD8",
+		"M1VUXWEZ",
+	}
+	for _, s := range invalid {
+		if _, _, _, err := Decode(s); err == nil {
+			t.Errorf("%q: decoded", s)
+		}
+	}
+}
+
+// TestSegWitAddress checks the segwit addresses of BIP350, which supersedes
+// the version 1 and above addresses of BIP173.
+func TestSegWitAddress(t *testing.T) {
+	valid := []struct {
+		addr         string
+		scriptPubKey string
+	}{
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4",
+			"0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7",
+			"00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y",
+			"5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"BC1SW50QGDZ25J", "6002751e"},
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs",
+			"5210751e76e8199196d454941c45d1b3a323"},
+		{"tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy",
+			"0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c",
+			"5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+			"512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+	for _, test := range valid {
+		hrp := strings.ToLower(test.addr[:2])
+		version, program, err := DecodeSegWitAddress(hrp, test.addr)
+		if err != nil {
+			t.Errorf("%s: %v", test.addr, err)
+			continue
+		}
+
+		// The script pushes the version and then the program.
+		op := byte(0)
+		if version > 0 {
+			op = 0x50 + version
+		}
+		script := append([]byte{op, byte(len(program))}, program...)
+		if got := hex.EncodeToString(script); got != test.scriptPubKey {
+			t.Errorf("%s: script %s, want %s", test.addr, got, test.scriptPubKey)
+		}
+
+		addr, err := EncodeSegWitAddress(hrp, version, program)
+		if err != nil {
+			t.Errorf("%s: %v", test.addr, err)
+			continue
+		}
+		if addr != strings.ToLower(test.addr) {
+			t.Errorf("%s: encoded as %s", test.addr, addr)
+		}
+	}
+
+	invalid := []struct {
+		addr string
+		err  error
+	}{
+		{"tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut", ErrInvalidFormat},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", ErrInvalidWitnessVersion},
+		{"tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf", ErrInvalidWitnessVersion},
+		{"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL", ErrInvalidWitnessVersion},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", ErrInvalidWitnessVersion},
+		{"tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47", ErrInvalidWitnessVersion},
+		{"bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4", ErrInvalidCharacter},
+		{"BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R", ErrInvalidWitnessVersion},
+		{"bc1pw5dgrnzv", ErrInvalidProgramLength},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav", ErrInvalidProgramLength},
+		{"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", ErrInvalidProgramLength},
+		{"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq", ErrMixedCase},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf", ErrInvalidPadding},
+		{"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j", ErrInvalidPadding},
+		{"bc1gmk9yu", ErrInvalidFormat},
+	}
+	for _, test := range invalid {
+		hrp := "bc"
+		if strings.HasPrefix(strings.ToLower(test.addr), "tb") {
+			hrp = "tb"
+		}
+		if _, _, err := DecodeSegWitAddress(hrp, test.addr); err != test.err {
+			t.Errorf("%s: got %v, want %v", test.addr, err, test.err)
+		}
+	}
+}
+
+func TestEncodeSegWitAddressInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		version byte
+		program []byte
+		err     error
+	}{
+		{"version 17", 17, make([]byte, 20), ErrInvalidWitnessVersion},
+		{"short program", 1, make([]byte, 1), ErrInvalidProgramLength},
+		{"long program", 1, make([]byte, 41), ErrInvalidProgramLength},
+		{"version 0 program", 0, make([]byte, 21), ErrInvalidProgramLength},
+	}
+	for _, test := range tests {
+		if _, err := EncodeSegWitAddress("bc", test.version, test.program); err != test.err {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
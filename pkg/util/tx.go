@@ -1,8 +1,6 @@
 package util
 
 import (
-	"bytes"
-
 	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
 	"github.com/jacobkaufmann/gocoin/pkg/protocol"
 )
@@ -34,18 +32,33 @@ func (tx *Tx) Message() *protocol.MsgTx {
 	return tx.MsgTx
 }
 
-// TxID returns the transaction id (double-SHA256 hash) of tx.
+// TxID returns the transaction id (double-SHA256 hash) of tx. Witness data
+// is not covered by the transaction id.
 func (tx *Tx) TxID(pver uint32) (*hashing.Hash, error) {
-	var buf bytes.Buffer
-	err := tx.MsgTx.Serialize(&buf, pver)
+	hasher := hashing.NewDoubleSHA256Hasher()
+	err := tx.MsgTx.SerializeNoWitness(hasher, pver)
 	if err != nil {
 		return nil, err
 	}
 
-	txID := hashing.DoubleSHA256H(buf.Bytes())
+	txID := hasher.Sum()
 	return &txID, nil
 }
 
+// WTxID returns the witness transaction id (double-SHA256 hash including
+// witness data) of tx. For a transaction without witness data, it is equal
+// to the transaction id.
+func (tx *Tx) WTxID(pver uint32) (*hashing.Hash, error) {
+	hasher := hashing.NewDoubleSHA256Hasher()
+	err := tx.MsgTx.Serialize(hasher, pver)
+	if err != nil {
+		return nil, err
+	}
+
+	wtxID := hasher.Sum()
+	return &wtxID, nil
+}
+
 // AddInput adds a transaction input to the transaction.
 func (tx *Tx) AddInput(in *protocol.TxIn) {
 	tx.Inputs = append(tx.Inputs, in)
@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/hashing"
+	"github.com/jacobkaufmann/gocoin/pkg/protocol"
+	"github.com/jacobkaufmann/gocoin/pkg/util/encoding/base58"
+)
+
+// messageMagic is prefixed to signed messages so that a message signature
+// can never be a valid transaction signature.
+const messageMagic = "Bitcoin Signed Message:\n"
+
+var (
+	// ErrNotPubKeyHashAddress indicates a message signature checked
+	// against an address which is not a P2PKH address, the only kind the
+	// legacy message signing scheme supports.
+	ErrNotPubKeyHashAddress = errors.New("address is not a pay-to-pubkey-hash address")
+
+	// ErrMalformedMessageSignature indicates a message signature which is
+	// not base64 or from which no public key can be recovered.
+	ErrMalformedMessageSignature = errors.New("malformed message signature")
+)
+
+// MessageHash returns the hash signed by a message signature: the double
+// SHA-256 of the magic prefix and the message, each serialized with its
+// length.
+func MessageHash(message string) []byte {
+	var buf bytes.Buffer
+	protocol.WriteCompactSize(&buf, 0, uint64(len(messageMagic)))
+	buf.WriteString(messageMagic)
+	protocol.WriteCompactSize(&buf, 0, uint64(len(message)))
+	buf.WriteString(message)
+	return hashing.DoubleSHA256B(buf.Bytes())
+}
+
+// SignMessage signs message with key in the format of Bitcoin Core's
+// signmessage: a base64 compact signature from which the public key and so
+// its P2PKH address can be recovered. compressed selects the address of the
+// compressed or the uncompressed serialization of the key.
+func SignMessage(key *btcec.PrivateKey, compressed bool, message string) (string,
+	error) {
+	sig, err := btcec.SignCompact(key, MessageHash(message), compressed)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// VerifyMessage returns whether signature, in the format produced by
+// SignMessage, is a signature of message by the key of the mainnet or
+// testnet P2PKH address.
+func VerifyMessage(address, signature, message string) (bool, error) {
+	pubKeyHash, version, err := base58.DecodeCheck(address)
+	if err != nil {
+		return false, err
+	}
+	switch base58.VersionPrefix(version) {
+	case base58.Address, base58.TestNetAddress:
+	default:
+		return false, ErrNotPubKeyHashAddress
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, ErrMalformedMessageSignature
+	}
+	pubKey, compressed, err := btcec.RecoverCompact(sig, MessageHash(message))
+	if err != nil {
+		return false, ErrMalformedMessageSignature
+	}
+
+	serialized := pubKey.SerializeUncompressed()
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	}
+	return bytes.Equal(hashing.Hash160(serialized), pubKeyHash), nil
+}
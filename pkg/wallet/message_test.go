@@ -0,0 +1,123 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/jacobkaufmann/gocoin/pkg/crypto/btcec"
+	"github.com/jacobkaufmann/gocoin/pkg/util/encoding/base58"
+)
+
+// The signmessage vector of Bitcoin Core's functional tests: a testnet key
+// and its P2PKH address.
+const (
+	coreMessageKey       = "cUeKHd5orzT3mz8P9pxyREHfsWtVfgsfDjiZZBcjUBAaGk1BTj7N"
+	coreMessageAddress   = "mpLQjfK79b7CCV4VMJWEWAj5Mpx8Up5zxB"
+	coreMessage          = "This is just a test message"
+	coreMessageSignature = "INbVnW4e6PeRmsv2Qgu8NuopvrVjkcxob+sX8OcZG0SALhWybUjzMLPdAsXI46YZGb0KQTRii+wWIQzRpG/U+S0="
+)
+
+// The P2PKH addresses of the compressed and uncompressed public keys of the
+// private key 1.
+const (
+	addrOneCompressed   = "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"
+	addrOneUncompressed = "1EHNa6Q4Jz2uvNExL497mE43ikXhwF6kZm"
+)
+
+func TestSignMessageCore(t *testing.T) {
+	wif, _, err := base58.DecodeCheck(coreMessageKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := btcec.PrivKeyFromBytes(wif[:btcec.PrivKeyBytesLen])
+
+	sig, err := SignMessage(key, true, coreMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig != coreMessageSignature {
+		t.Errorf("got %s, want %s", sig, coreMessageSignature)
+	}
+	ok, err := VerifyMessage(coreMessageAddress, coreMessageSignature, coreMessage)
+	if err != nil || !ok {
+		t.Errorf("got %v, %v, want true", ok, err)
+	}
+}
+
+func TestSignMessage(t *testing.T) {
+	key, _ := btcec.PrivKeyFromBytes([]byte{0x01})
+	tests := []struct {
+		compressed bool
+		addr       string
+	}{
+		{true, addrOneCompressed},
+		{false, addrOneUncompressed},
+	}
+	for _, test := range tests {
+		for _, message := range []string{"", "message", string(make([]byte, 300))} {
+			sig, err := SignMessage(key, test.compressed, message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok, err := VerifyMessage(test.addr, sig, message)
+			if err != nil || !ok {
+				t.Errorf("%s: message of %d bytes: got %v, %v, want true",
+					test.addr, len(message), ok, err)
+			}
+		}
+	}
+}
+
+func TestVerifyMessageInvalid(t *testing.T) {
+	// flipCompressed returns the signature with the other serialization of
+	// the same key, which has a different address.
+	flipCompressed := func(sig string) string {
+		b, _ := base64.StdEncoding.DecodeString(sig)
+		if b[0] >= 31 {
+			b[0] -= 4
+		} else {
+			b[0] += 4
+		}
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	header := func(sig string, h byte) string {
+		b, _ := base64.StdEncoding.DecodeString(sig)
+		b[0] = h
+		return base64.StdEncoding.EncodeToString(b)
+	}
+
+	tests := []struct {
+		name      string
+		addr      string
+		signature string
+		message   string
+		err       error
+	}{
+		{"wrong address", addrOneCompressed, coreMessageSignature, coreMessage, nil},
+		{"other serialization", coreMessageAddress,
+			flipCompressed(coreMessageSignature), coreMessage, nil},
+		{"tampered message", coreMessageAddress, coreMessageSignature,
+			coreMessage + ".", nil},
+		{"script hash address", "3CNHUhP3uyB9EUtRLsmvFUmvGdjGdkTxJw",
+			coreMessageSignature, coreMessage, ErrNotPubKeyHashAddress},
+		{"bad address checksum", "mpLQjfK79b7CCV4VMJWEWAj5Mpx8Up5zxC",
+			coreMessageSignature, coreMessage, base58.ErrCheckSum},
+		{"not base64", coreMessageAddress, "!" + coreMessageSignature[1:],
+			coreMessage, ErrMalformedMessageSignature},
+		{"truncated signature", coreMessageAddress, coreMessageSignature[:84],
+			coreMessage, ErrMalformedMessageSignature},
+		{"header below range", coreMessageAddress,
+			header(coreMessageSignature, 26), coreMessage,
+			ErrMalformedMessageSignature},
+		{"header above range", coreMessageAddress,
+			header(coreMessageSignature, 35), coreMessage,
+			ErrMalformedMessageSignature},
+	}
+	for _, test := range tests {
+		ok, err := VerifyMessage(test.addr, test.signature, test.message)
+		if ok || err != test.err {
+			t.Errorf("%s: got %v, %v, want false, %v", test.name, ok, err,
+				test.err)
+		}
+	}
+}